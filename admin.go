@@ -0,0 +1,92 @@
+package main
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+var (
+	// adminListenAddr is the address of the admin server, which is kept
+	// apart from the HTTP API so it does not have to be exposed with it.
+	adminListenAddr string
+	adminDebug      bool
+)
+
+// processStart is the start of the process, for the uptime of the runtime
+// stats.
+var processStart = time.Now()
+
+// registerAdminFlags defines the flags of the admin server.
+func registerAdminFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&adminListenAddr, "admin-listen", "", "Address the admin server listens on, e.g. 127.0.0.1:8081 to check the health with GET /healthz and to change the log level with PUT /debug/loglevel, disabled if empty")
+	flags.BoolVar(&adminDebug, "admin-debug", false, "Serve the pprof profiles at /debug/pprof/ and the runtime stats at /debug/vars on the --admin-listen address, e.g. to diagnose the memory or goroutines of a long-running scraper, keep the address local as the profiles expose the internals of the process")
+	envFlags["admin-listen"] = "ADMIN_LISTEN_ADDR"
+	envFlags["admin-debug"] = "ADMIN_DEBUG"
+}
+
+// adminService serves the admin endpoints, nil if the admin server is
+// disabled.
+func adminService(logger *zap.Logger) service {
+	if adminListenAddr == "" {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", healthz)
+	// GET returns the current level, PUT with level=debug or {"level":"debug"}
+	// changes it
+	mux.Handle("/debug/loglevel", logLevel)
+	if adminDebug {
+		if !isLoopback(adminListenAddr) {
+			logger.Warn("serving the debug endpoints on a non-local address", zap.String("addr", adminListenAddr))
+		}
+		registerDebugHandlers(mux)
+	}
+	return httpService(adminListenAddr, mux)
+}
+
+// registerDebugHandlers serves the pprof profiles and the expvar variables,
+// which include the memstats, on the mux instead of the default mux their
+// packages register with.
+func registerDebugHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+}
+
+func init() {
+	expvar.Publish("runtime", expvar.Func(func() any {
+		return map[string]any{
+			"goroutines":     runtime.NumGoroutine(),
+			"gomaxprocs":     runtime.GOMAXPROCS(0),
+			"cpus":           runtime.NumCPU(),
+			"cgo_calls":      runtime.NumCgoCall(),
+			"go_version":     runtime.Version(),
+			"version":        version,
+			"uptime_seconds": time.Since(processStart).Seconds(),
+		}
+	}))
+}
+
+// isLoopback returns whether the address only listens on the loopback
+// interface, an address without a host listens on all interfaces.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/archive"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+var (
+	archiveDir         string
+	archiveMaxSize     int64
+	archiveMaxFileSize int64
+
+	// responseArchive is the archive shared by the clients of all accounts
+	responseArchive *archive.Archive
+)
+
+// registerArchiveFlags defines the flags of the archive of the raw responses
+// of the egain API.
+func registerArchiveFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&archiveDir, "archive-dir", "", "Keep the raw responses of the readings of the egain API in gzip compressed files in the directory, e.g. to inspect them with the archive command when a reading looks wrong, for debugging only")
+	flags.Int64Var(&archiveMaxSize, "archive-max-size", archive.DefaultMaxSize, "Maximum size of the files of the --archive-dir in bytes, the oldest files are deleted beyond it")
+	flags.Int64Var(&archiveMaxFileSize, "archive-max-file-size", archive.DefaultMaxFileSize, "Size in bytes at which a file of the --archive-dir is rotated")
+	envFlags["archive-dir"] = "EGAIN_ARCHIVE_DIR"
+	envFlags["archive-max-size"] = "EGAIN_ARCHIVE_MAX_SIZE"
+	envFlags["archive-max-file-size"] = "EGAIN_ARCHIVE_MAX_FILE_SIZE"
+}
+
+// archiveOption returns the option of the egain client archiving its
+// responses, nil if they are not archived.
+func archiveOption(logger *zap.Logger) egain.Option {
+	if archiveDir == "" {
+		return nil
+	}
+	if responseArchive == nil {
+		a, err := archive.New(archiveDir, archive.WithMaxSize(archiveMaxSize), archive.WithMaxFileSize(archiveMaxFileSize))
+		if err != nil {
+			return func(c *egain.Client) error { return err }
+		}
+		logger.Warn("archiving the responses of the egain API", zap.String("dir", archiveDir), zap.Int64("maxSize", archiveMaxSize))
+		responseArchive = a
+	}
+	return egain.WithArchive(responseArchive)
+}
+
+// closeArchive closes the current file of the archive, if any. The records
+// are flushed as they are written, so only the end of the file is missing on
+// a crash.
+func closeArchive(logger *zap.Logger) {
+	if responseArchive == nil {
+		return
+	}
+	if err := responseArchive.Close(); err != nil {
+		logger.Warn("cannot close archive", zap.Error(err))
+	}
+}
+
+var (
+	archiveSensor string
+	archiveFrom   string
+	archiveTo     string
+)
+
+func newArchiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive <dir>",
+		Short: "Print the raw responses of the egain API kept in an --archive-dir",
+		Long: `Print the raw responses of the egain API kept in a directory of --archive-dir as
+JSON lines, the oldest first, e.g. to inspect exactly what the API returned for
+a reading which looks wrong. The responses can be limited to a sensor and to a
+time range, e.g. archive /var/lib/egain/archive --sensor ID12312 --from 48h.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runArchive(args[0])
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&archiveSensor, "sensor", "", "ID of the sensor whose responses are printed, all sensors by default")
+	flags.StringVar(&archiveFrom, "from", "", "Start of the printed responses as RFC 3339 time or as duration before now (e.g. 2024-01-15T00:00:00Z, 24h)")
+	flags.StringVar(&archiveTo, "to", "", "End of the printed responses as RFC 3339 time or as duration before now")
+	return cmd
+}
+
+func runArchive(dir string) error {
+	var from, to time.Time
+	now := time.Now()
+	var err error
+	if archiveFrom != "" {
+		if from, err = parseTime(archiveFrom, now); err != nil {
+			return fmt.Errorf("invalid --from: %w", err)
+		}
+	}
+	if archiveTo != "" {
+		if to, err = parseTime(archiveTo, now); err != nil {
+			return fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	var errWrite error
+	err = archive.Read(dir, func(r *archive.Record) bool {
+		switch {
+		case archiveSensor != "" && !slices.Contains(r.Sensors, archiveSensor),
+			!from.IsZero() && r.Time.Before(from),
+			!to.IsZero() && r.Time.After(to):
+			return true
+		}
+		errWrite = enc.Encode(r)
+		return errWrite == nil
+	})
+	if err != nil {
+		return err
+	}
+	return errWrite
+}
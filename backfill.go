@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/providers"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	backfillFrom  string
+	backfillTo    string
+	backfillChunk time.Duration
+)
+
+func newBackfillCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Fetch historical readings and export them with their original timestamps",
+		Long: `Fetch the historical readings of the sensors from the egain history endpoint
+and replay them into the exporters which keep the original timestamps, i.e.
+InfluxDB, the store, the CSV and Parquet files, PostgreSQL, the webhook,
+Graphite over the plaintext protocol, Zabbix, CloudWatch, Timestream and Log
+Analytics. CloudWatch and Log Analytics reject readings older than two weeks
+and two days, which are skipped. The readings are not published to MQTT,
+recorded as OpenTelemetry metrics, whose gauges are stamped at the time of
+the collection, or evaluated against the alert rules.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBackfill(cmd)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&backfillFrom, "from", "", "Start of the backfill as RFC 3339 time or as duration before now (e.g. 2024-01-15T00:00:00Z, 24h)")
+	flags.StringVar(&backfillTo, "to", "", "End of the backfill as RFC 3339 time or as duration before now, now by default")
+	flags.DurationVar(&backfillChunk, "chunk", 24*time.Hour, "Maximum time range fetched in a single request")
+	cmd.MarkFlagRequired("from")
+	registerExporterFlags(flags)
+	return cmd
+}
+
+func runBackfill(cmd *cobra.Command) error {
+	now := time.Now()
+	from, err := parseTime(backfillFrom, now)
+	if err != nil {
+		return fmt.Errorf("invalid --from: %w", err)
+	}
+	to := now
+	if backfillTo != "" {
+		if to, err = parseTime(backfillTo, now); err != nil {
+			return fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("--from %s is not before --to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if backfillChunk <= 0 {
+		return fmt.Errorf("invalid --chunk %s", backfillChunk)
+	}
+
+	cfg, sensors, err := loadSensors()
+	if err != nil {
+		return err
+	}
+	if len(sensors) == 0 {
+		return errNoSensors
+	}
+
+	logger, err := newLogger(zapcore.AddSync(os.Stderr), zapcore.InfoLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	enabled, err := newHistoryExporters(logger, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create exporters: %w", err)
+	}
+	defer enabled.Close()
+	// the readings are only exported to the exporters keeping their
+	// timestamps, e.g. not to StatsD
+	exporters := enabled.Timestamped(logger)
+	if skipped := len(enabled) - len(exporters); skipped > 0 {
+		logger.Warn("skipping the exporters which do not keep the timestamps of the readings", zap.Int("skipped", skipped))
+	}
+	if len(exporters) == 0 {
+		return errors.New("please enable an exporter keeping the timestamps with --influx-url, --store-path, --csv-path, --parquet-dir, --postgres-url, --webhook-url, --graphite-addr, --zabbix-server, --cloudwatch-namespace, --timestream-table or --azure-logs-endpoint")
+	}
+
+	// only the egain API serves the history of the sensors
+	if egainSensors := providers.Select(sensors, providers.Default); len(egainSensors) < len(sensors) {
+		logger.Warn("skipping the sensors of other providers, only egain sensors have a history", zap.Int("skipped", len(sensors)-len(egainSensors)))
+		sensors = egainSensors
+	}
+	client, err := newFetcher(logger, cfg, sensors)
+	if err != nil {
+		return fmt.Errorf("cannot create fetcher: %w", err)
+	}
+
+	ctx := cmd.Context()
+	if resolveLocations(ctx, logger, client) {
+		sensors = client.Sensors()
+	}
+	processors, err := newProcessors(cfg, logger, noop.Meter{})
+	if err != nil {
+		return fmt.Errorf("cannot create processors: %w", err)
+	}
+	var errs []error
+	for _, sensor := range sensors {
+		total := 0
+		for start := from; start.Before(to); start = start.Add(backfillChunk) {
+			end := start.Add(backfillChunk)
+			if end.After(to) {
+				end = to
+			}
+			readings, err := client.FetchHistory(ctx, sensor, start, end)
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			if readings, err = processors.Process(ctx, readings); err != nil {
+				errs = append(errs, err)
+				break
+			}
+			if err := exporters.Export(ctx, readings); err != nil {
+				errs = append(errs, fmt.Errorf("cannot export history of sensor %s: %w", sensor.SensorID, err))
+				break
+			}
+			total += len(readings)
+		}
+		logger.Info("backfilled sensor", zap.String("sensorId", sensor.SensorID), zap.Int("readings", total))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseTime parses an RFC 3339 time or a duration before now.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,806 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/alert"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/processor"
+	"github.com/nimdanitro/again-scraper-go/pkg/providers"
+	"github.com/nimdanitro/again-scraper-go/pkg/secrets"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the structure of the configuration file.
+type config struct {
+	// Accounts are the egain accounts besides the default account of the
+	// flags, sensors select one by its name.
+	Accounts []accountConfig `yaml:"accounts"`
+	// HostRateLimits limits the requests to the hosts of the egain API
+	// across all accounts and mirrors by the host of their URL, e.g.
+	// api.egain.io, the limit of "*" applies to the other hosts.
+	HostRateLimits map[string]hostRateLimitConfig `yaml:"host_rate_limits"`
+	// Providers are the backends of sensors besides egain, sensors select
+	// one by its name.
+	Providers []providerConfig `yaml:"providers"`
+	Sensors   []sensorConfig   `yaml:"sensors"`
+	Groups    []groupConfig    `yaml:"groups"`
+	Alerts    alertsConfig     `yaml:"alerts"`
+	// Validation overrides the bounds of plausible readings.
+	Validation validationConfig `yaml:"validation"`
+	// Limits overrides the limits of the attributes of the metrics.
+	Limits limitsConfig `yaml:"limits"`
+	// Views change the aggregation, name or attributes of the instruments
+	// of the metrics, e.g. the buckets of a histogram, in order. They are
+	// applied at startup, a reload keeps the previous views.
+	Views []viewConfig `yaml:"views"`
+	// Comfort derives the dew point, absolute humidity and heat index of
+	// the readings.
+	Comfort bool `yaml:"comfort"`
+	// Window aggregates the readings of each sensor over windows of the
+	// duration, e.g. 15m, 0 disables the windows.
+	Window time.Duration `yaml:"window"`
+	// MoldRisk derives the mold risk of each sensor over the rolling window
+	// of the duration, e.g. 168h, 0 disables the mold risk.
+	MoldRisk time.Duration `yaml:"mold_risk"`
+	// Trend derives the rates of change per hour of the temperature and
+	// humidity of each sensor over the window of the duration, e.g. 30m, 0
+	// disables the trend.
+	Trend time.Duration `yaml:"trend"`
+	// Summary summarizes the readings of each sensor over a day.
+	Summary *summaryConfig `yaml:"summary"`
+	// Expressions filter the readings or derive labels and values of them
+	// with CEL expressions, in order.
+	Expressions []expressionConfig `yaml:"expressions"`
+	// Flags sets the flags by their names, e.g. interval: 5m, which are not
+	// given on the command line or in the environment.
+	Flags map[string]any `yaml:"flags"`
+}
+
+type summaryConfig struct {
+	// TimeZone is the time zone of the days, e.g. Europe/Zurich, the local
+	// time zone by default.
+	TimeZone string `yaml:"time_zone"`
+	// ReportDir is the directory the daily reports are written to, e.g.
+	// summary-2024-01-15.json, no reports are written if empty.
+	ReportDir string `yaml:"report_dir"`
+	// ReportFormat is the format of the reports, json or csv.
+	ReportFormat string `yaml:"report_format"`
+	// Webhook is the URL the daily reports are posted to as JSON.
+	Webhook string `yaml:"webhook"`
+}
+
+type sensorConfig struct {
+	ID       string        `yaml:"id"`
+	Location string        `yaml:"location"`
+	Kind     string        `yaml:"kind"`
+	Account  string        `yaml:"account"`
+	Interval time.Duration `yaml:"interval"`
+	// Schedule is a cron expression replacing the interval, e.g.
+	// */15 8-18 * * MON-FRI to poll the sensor only during business hours.
+	Schedule string `yaml:"schedule"`
+	// Timeout overrides the --timeout for the requests of the sensor.
+	Timeout time.Duration `yaml:"timeout"`
+	// Provider is the name of the provider of the sensor, egain if empty.
+	Provider string `yaml:"provider"`
+	// Labels are attached to the metrics of the sensor, e.g. building: main.
+	Labels map[string]string `yaml:"labels"`
+	// TimeZone is the IANA time zone of the location of the sensor, e.g.
+	// Europe/Zurich, the timestamps of its readings are localized to it.
+	TimeZone string `yaml:"time_zone"`
+	// Calibration corrects the measurements of the sensor.
+	Calibration *calibrationConfig `yaml:"calibration"`
+}
+
+// providerConfig is a backend of sensors of a kind registered in the
+// providers package, the settings depend on the kind.
+type providerConfig struct {
+	Name     string         `yaml:"name"`
+	Kind     string         `yaml:"kind"`
+	Settings map[string]any `yaml:"settings"`
+}
+
+// accountConfig is an egain account with its own credentials and rate limit,
+// unset settings are taken from the flags.
+type accountConfig struct {
+	Name     string `yaml:"name"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// PasswordEnv is the environment variable holding the password, so it
+	// does not have to be stored in the file.
+	PasswordEnv string  `yaml:"password_env"`
+	BaseURL     string  `yaml:"base_url"`
+	Proxy       string  `yaml:"proxy"`
+	RateLimit   float64 `yaml:"rate_limit"`
+	RateBurst   int     `yaml:"rate_burst"`
+	// FailoverURLs are the mirrors of the BaseURL of the account.
+	FailoverURLs []string `yaml:"failover_urls"`
+}
+
+type hostRateLimitConfig struct {
+	RateLimit float64 `yaml:"rate_limit"`
+	// RateBurst defaults to the --rate-burst.
+	RateBurst int `yaml:"rate_burst"`
+}
+
+// rateLimits returns the registry of the rate limiters of the hosts.
+func (c *config) rateLimits() (*egain.RateLimits, error) {
+	var opts []egain.RateLimitsOption
+	for host, l := range c.HostRateLimits {
+		burst := l.RateBurst
+		if burst == 0 {
+			burst = rateBurst
+		}
+		if host == "*" {
+			host = ""
+		}
+		opts = append(opts, egain.WithHostRateLimit(host, egain.RateLimit{Rate: rate.Limit(l.RateLimit), Burst: burst}))
+	}
+	return egain.NewRateLimits(opts...)
+}
+
+// password returns the password of the account.
+func (a accountConfig) password() string {
+	if a.PasswordEnv != "" {
+		return os.Getenv(a.PasswordEnv)
+	}
+	return a.Password
+}
+
+type calibrationConfig struct {
+	Temperature correctionConfig `yaml:"temperature"`
+	Humidity    correctionConfig `yaml:"humidity"`
+}
+
+// correctionConfig corrects a measurement to value * scale + offset, the
+// scale is 1 if unset.
+type correctionConfig struct {
+	Offset float64  `yaml:"offset"`
+	Scale  *float64 `yaml:"scale"`
+}
+
+func (c correctionConfig) correction() processor.Correction {
+	corr := processor.NoCorrection
+	corr.Offset = c.Offset
+	if c.Scale != nil {
+		corr.Scale = *c.Scale
+	}
+	return corr
+}
+
+// groupConfig is a group of sensors with aggregate metrics, the sensors are
+// listed by id or selected by their labels.
+type groupConfig struct {
+	Name    string            `yaml:"name"`
+	Sensors []string          `yaml:"sensors"`
+	Labels  map[string]string `yaml:"labels"`
+	// Schedule is the cron expression of the sensors of the group without
+	// an own interval or schedule.
+	Schedule string `yaml:"schedule"`
+	// TimeZone is the time zone of the sensors of the group without an own
+	// time zone, e.g. of a building.
+	TimeZone string `yaml:"time_zone"`
+}
+
+// expressionConfig is a filter, a label or a value expression, e.g.
+// filter: reading.humidity > 0.0.
+type expressionConfig struct {
+	Filter string `yaml:"filter"`
+	Label  string `yaml:"label"`
+	Expr   string `yaml:"expr"`
+	Value  string `yaml:"value"`
+	Unit   string `yaml:"unit"`
+}
+
+// limitsConfig caps the length of the locations and label values and the
+// number of unique series, unset limits keep their defaults.
+type limitsConfig struct {
+	MaxLength int `yaml:"max_length"`
+	MaxSeries int `yaml:"max_series"`
+}
+
+// viewConfig is an OTel view of the instruments matching the name, which
+// may contain * and ? wildcards.
+type viewConfig struct {
+	Instrument string `yaml:"instrument"`
+	// Rename renames the instrument, only for the name of a single
+	// instrument.
+	Rename string `yaml:"rename"`
+	// Buckets are the explicit bucket boundaries of a histogram.
+	Buckets []float64 `yaml:"buckets"`
+	// Attributes keeps only the attributes with these keys.
+	Attributes []string `yaml:"attributes"`
+	// Drop drops the instrument altogether.
+	Drop bool `yaml:"drop"`
+}
+
+type validationConfig struct {
+	// Temperature is the range of plausible temperatures in °C.
+	Temperature rangeConfig `yaml:"temperature"`
+	Humidity    rangeConfig `yaml:"humidity"`
+	// Future is the clock skew tolerated for timestamps in the future.
+	Future *time.Duration `yaml:"future"`
+}
+
+// rangeConfig is a range of values, unset limits keep their default.
+type rangeConfig struct {
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+type alertsConfig struct {
+	// Webhook is the URL the alerts are posted to.
+	Webhook string `yaml:"webhook"`
+	// Template is the template of the messages of the Slack, Telegram and
+	// email notifiers, alert.DefaultTemplate if empty.
+	Template string            `yaml:"template"`
+	Slack    *slackConfig      `yaml:"slack"`
+	Telegram *telegramConfig   `yaml:"telegram"`
+	Email    *emailConfig      `yaml:"email"`
+	Rules    []alertRuleConfig `yaml:"rules"`
+}
+
+type slackConfig struct {
+	// Webhook is the URL of the incoming webhook.
+	Webhook string `yaml:"webhook"`
+}
+
+type telegramConfig struct {
+	Token string `yaml:"token"`
+	// TokenEnv is the environment variable holding the token of the bot.
+	TokenEnv string `yaml:"token_env"`
+	ChatID   string `yaml:"chat_id"`
+}
+
+type emailConfig struct {
+	// Address is the host and port of the SMTP server.
+	Address     string   `yaml:"address"`
+	Username    string   `yaml:"username"`
+	Password    string   `yaml:"password"`
+	PasswordEnv string   `yaml:"password_env"`
+	From        string   `yaml:"from"`
+	To          []string `yaml:"to"`
+	// Subject is the template of the subjects, alert.DefaultSubject if
+	// empty.
+	Subject string `yaml:"subject"`
+}
+
+type alertRuleConfig struct {
+	Name      string        `yaml:"name"`
+	Sensor    string        `yaml:"sensor"`
+	Location  string        `yaml:"location"`
+	Metric    string        `yaml:"metric"`
+	Condition string        `yaml:"condition"`
+	Threshold float64       `yaml:"threshold"`
+	For       time.Duration `yaml:"for"`
+}
+
+// loadConfig reads the configuration file at the given path. The file is
+// decrypted if it is encrypted with SOPS and the references to secrets of its
+// values are resolved.
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = resolveSecrets(path, data); err != nil {
+		return nil, err
+	}
+
+	var c config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("cannot parse config %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// resolveSecrets decrypts the config file if it is encrypted with SOPS and
+// replaces the references to secrets of its values, see the secrets package.
+// The file is returned as it is if it has neither, so the errors of the
+// decoding keep its line numbers.
+func resolveSecrets(path string, data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || doc.Kind == 0 {
+		// the decoding reports the errors and the empty files
+		return data, nil
+	}
+	resolver, err := newSecretResolver()
+	if err != nil {
+		return nil, err
+	}
+	if secrets.IsSOPS(&doc) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		data, err = resolver.DecryptSOPS(ctx, path)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		doc = yaml.Node{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("cannot parse decrypted config %s: %w", path, err)
+		}
+	}
+	n, err := resolver.ResolveNode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve secrets of config %s: %w", path, err)
+	}
+	if n == 0 {
+		return data, nil
+	}
+	return yaml.Marshal(&doc)
+}
+
+// parseSensors parses a list of sensor objects of the configuration, given
+// in JSON or YAML.
+func parseSensors(source string, data []byte) ([]sensorConfig, error) {
+	var sensors []sensorConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&sensors); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("cannot parse sensors of %s: %w", source, err)
+	}
+	return sensors, nil
+}
+
+// addSensors adds the sensor objects of --sensors-file and --sensors-json to
+// the sensors of the configuration file.
+func (c *config) addSensors() error {
+	if sensorsFile != "" {
+		data, err := os.ReadFile(sensorsFile)
+		if err != nil {
+			return err
+		}
+		sensors, err := parseSensors(sensorsFile, data)
+		if err != nil {
+			return err
+		}
+		c.Sensors = append(c.Sensors, sensors...)
+	}
+	if sensorsJSON != "" {
+		sensors, err := parseSensors("--sensors-json", []byte(sensorsJSON))
+		if err != nil {
+			return err
+		}
+		c.Sensors = append(c.Sensors, sensors...)
+	}
+	return nil
+}
+
+// validate checks the configuration, including the sensors added from the
+// flags.
+func (c *config) validate() error {
+	accounts := map[string]bool{}
+	for i, a := range c.Accounts {
+		if a.Name == "" {
+			return fmt.Errorf("account #%d: missing name", i)
+		}
+		if accounts[a.Name] {
+			return fmt.Errorf("account %s: configured twice", a.Name)
+		}
+		accounts[a.Name] = true
+		if a.Password != "" && a.PasswordEnv != "" {
+			return fmt.Errorf("account %s: both password and password_env configured", a.Name)
+		}
+		if a.RateLimit < 0 || a.RateBurst < 0 {
+			return fmt.Errorf("account %s: negative rate limit", a.Name)
+		}
+	}
+	if _, err := c.rateLimits(); err != nil {
+		return fmt.Errorf("host_rate_limits: %w", err)
+	}
+
+	names := map[string]bool{}
+	for i, p := range c.Providers {
+		if p.Name == "" {
+			return fmt.Errorf("provider #%d: missing name", i)
+		}
+		if p.Name == egainProvider || names[p.Name] {
+			return fmt.Errorf("provider %s: configured twice", p.Name)
+		}
+		names[p.Name] = true
+		if p.Kind == "" {
+			return fmt.Errorf("provider %s: missing kind", p.Name)
+		}
+	}
+
+	ids := map[string]bool{}
+	for i, s := range c.Sensors {
+		if s.ID == "" {
+			return fmt.Errorf("sensor #%d: missing id", i)
+		}
+		if ids[s.ID] {
+			return fmt.Errorf("sensor %s: configured twice", s.ID)
+		}
+		ids[s.ID] = true
+		if s.Interval < 0 {
+			return fmt.Errorf("sensor %s: negative interval %s", s.ID, s.Interval)
+		}
+		if s.Timeout < 0 {
+			return fmt.Errorf("sensor %s: negative timeout %s", s.ID, s.Timeout)
+		}
+		if s.Schedule != "" {
+			if s.Interval != 0 {
+				return fmt.Errorf("sensor %s: both interval and schedule configured", s.ID)
+			}
+			if _, err := parseSchedule(s.Schedule); err != nil {
+				return fmt.Errorf("sensor %s: %w", s.ID, err)
+			}
+		}
+		if s.Provider != "" && s.Provider != egainProvider {
+			if !names[s.Provider] {
+				return fmt.Errorf("sensor %s: unknown provider %s", s.ID, s.Provider)
+			}
+			if s.Account != "" {
+				return fmt.Errorf("sensor %s: account %s of a sensor of provider %s", s.ID, s.Account, s.Provider)
+			}
+		}
+		if s.Account != "" && !accounts[s.Account] {
+			return fmt.Errorf("sensor %s: unknown account %s", s.ID, s.Account)
+		}
+		if _, err := egain.ParseKind(s.Kind); err != nil {
+			return fmt.Errorf("sensor %s: %w", s.ID, err)
+		}
+		for k := range s.Labels {
+			if k == "" || strings.HasPrefix(k, "sensor.") {
+				return fmt.Errorf("sensor %s: invalid label %q, labels must not be empty or start with sensor.", s.ID, k)
+			}
+		}
+		if s.TimeZone != "" {
+			if _, err := time.LoadLocation(s.TimeZone); err != nil {
+				return fmt.Errorf("sensor %s: invalid time zone: %w", s.ID, err)
+			}
+		}
+	}
+	if _, err := processor.Calibrate(c.calibrations()); err != nil {
+		return err
+	}
+	groups := map[string]bool{}
+	for _, g := range c.groups() {
+		if err := g.Validate(); err != nil {
+			return err
+		}
+		if groups[g.Name] {
+			return fmt.Errorf("group %s: configured twice", g.Name)
+		}
+		groups[g.Name] = true
+		for _, id := range g.SensorIDs {
+			if !ids[id] {
+				return fmt.Errorf("group %s: unknown sensor %s", g.Name, id)
+			}
+		}
+	}
+	// the aggregates of a group need all of its sensors, which the shards
+	// split between the replicas
+	if len(c.Groups) > 0 {
+		s, err := currentShard()
+		if err != nil {
+			return err
+		}
+		if s.Sharded() {
+			return fmt.Errorf("groups cannot be aggregated on the shard %s of the sensors, please remove the groups or --shard", s)
+		}
+	}
+	zoned := map[string]string{}
+	for _, g := range c.Groups {
+		if g.TimeZone == "" {
+			continue
+		}
+		if _, err := time.LoadLocation(g.TimeZone); err != nil {
+			return fmt.Errorf("group %s: invalid time zone: %w", g.Name, err)
+		}
+		for _, id := range g.Sensors {
+			if other, ok := zoned[id]; ok {
+				return fmt.Errorf("group %s: the time zone of sensor %s is already set by group %s", g.Name, id, other)
+			}
+			zoned[id] = g.Name
+		}
+	}
+	scheduled := map[string]string{}
+	for _, g := range c.Groups {
+		if g.Schedule == "" {
+			continue
+		}
+		if _, err := parseSchedule(g.Schedule); err != nil {
+			return fmt.Errorf("group %s: %w", g.Name, err)
+		}
+		for _, id := range g.Sensors {
+			if other, ok := scheduled[id]; ok {
+				return fmt.Errorf("group %s: sensor %s is already scheduled by group %s", g.Name, id, other)
+			}
+			scheduled[id] = g.Name
+		}
+	}
+	if c.Window < 0 {
+		return fmt.Errorf("negative aggregation window %s", c.Window)
+	}
+	if c.MoldRisk != 0 {
+		if _, err := processor.NewMoldRisk(c.MoldRisk); err != nil {
+			return err
+		}
+	}
+	if c.Trend != 0 {
+		if _, err := processor.NewTrend(c.Trend); err != nil {
+			return err
+		}
+	}
+	if c.Summary != nil {
+		if _, err := c.Summary.options(); err != nil {
+			return fmt.Errorf("summary: %w", err)
+		}
+	}
+	bounds := c.bounds()
+	if err := bounds.Validate(); err != nil {
+		return fmt.Errorf("validation: %w", err)
+	}
+	limits := c.limits()
+	if err := limits.Validate(); err != nil {
+		return fmt.Errorf("limits: %w", err)
+	}
+	if _, err := c.views(); err != nil {
+		return err
+	}
+	notifiers, err := c.alertNotifiers()
+	if err != nil {
+		return fmt.Errorf("alerts: %w", err)
+	}
+	if len(c.Alerts.Rules) > 0 && len(notifiers) == 0 {
+		return fmt.Errorf("alert rules configured without a webhook, slack, telegram or email notifier")
+	}
+	for _, r := range c.alertRules() {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if r.Metric == "mold_risk" && c.MoldRisk == 0 {
+			return fmt.Errorf("rule %s: mold_risk is only known if the mold risk is derived with a mold_risk window", r.Name)
+		}
+		if (r.Metric == "temperature_trend" || r.Metric == "humidity_trend") && c.Trend == 0 {
+			return fmt.Errorf("rule %s: %s is only known if the trend is derived with a trend window", r.Name, r.Metric)
+		}
+	}
+	return nil
+}
+
+// alertNotifiers returns the configured notifiers of the alerts.
+func (c *config) alertNotifiers() ([]alert.Notifier, error) {
+	a := c.Alerts
+	tmpl, err := alert.NewTemplate(a.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	var notifiers []alert.Notifier
+	if a.Webhook != "" {
+		notifiers = append(notifiers, alert.NewWebhook(a.Webhook, nil))
+	}
+	if s := a.Slack; s != nil {
+		if s.Webhook == "" {
+			return nil, errors.New("slack: missing webhook")
+		}
+		notifiers = append(notifiers, alert.NewSlack(s.Webhook, tmpl, nil))
+	}
+	if t := a.Telegram; t != nil {
+		if t.Token != "" && t.TokenEnv != "" {
+			return nil, errors.New("telegram: both token and token_env configured")
+		}
+		token := t.Token
+		if t.TokenEnv != "" {
+			token = os.Getenv(t.TokenEnv)
+		}
+		if token == "" || t.ChatID == "" {
+			return nil, errors.New("telegram: missing token or chat_id")
+		}
+		notifiers = append(notifiers, alert.NewTelegram(token, t.ChatID, tmpl, nil))
+	}
+	if m := a.Email; m != nil {
+		if m.Password != "" && m.PasswordEnv != "" {
+			return nil, errors.New("email: both password and password_env configured")
+		}
+		password := m.Password
+		if m.PasswordEnv != "" {
+			password = os.Getenv(m.PasswordEnv)
+		}
+		subject, err := alert.NewTemplate(cmp.Or(m.Subject, alert.DefaultSubject))
+		if err != nil {
+			return nil, fmt.Errorf("email: subject: %w", err)
+		}
+		e, err := alert.NewEmail(m.Address, m.Username, password, m.From, m.To, subject, tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("email: %w", err)
+		}
+		notifiers = append(notifiers, e)
+	}
+	return notifiers, nil
+}
+
+// alertRules returns the configured alert rules.
+func (c *config) alertRules() []alert.Rule {
+	rules := make([]alert.Rule, 0, len(c.Alerts.Rules))
+	for _, r := range c.Alerts.Rules {
+		rules = append(rules, alert.Rule{
+			Name:      r.Name,
+			SensorID:  r.Sensor,
+			Location:  r.Location,
+			Metric:    r.Metric,
+			Condition: r.Condition,
+			Threshold: r.Threshold,
+			For:       r.For,
+		})
+	}
+	return rules
+}
+
+// limits returns the limits of the attributes, the defaults overridden by the
+// configured ones.
+func (c *config) limits() processor.Limits {
+	l := processor.DefaultLimits
+	if c.Limits.MaxLength != 0 {
+		l.MaxLength = c.Limits.MaxLength
+	}
+	if c.Limits.MaxSeries != 0 {
+		l.MaxSeries = c.Limits.MaxSeries
+	}
+	return l
+}
+
+// bounds returns the bounds of plausible readings, the defaults overridden
+// by the configured ones.
+func (c *config) bounds() processor.Bounds {
+	b := processor.DefaultBounds
+	v := c.Validation
+	set := func(dst *float64, src *float64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&b.MinTemperature, v.Temperature.Min)
+	set(&b.MaxTemperature, v.Temperature.Max)
+	set(&b.MinHumidity, v.Humidity.Min)
+	set(&b.MaxHumidity, v.Humidity.Max)
+	if v.Future != nil {
+		b.MaxFuture = *v.Future
+	}
+	return b
+}
+
+// calibrations returns the calibrations of the sensors, keyed by their id.
+func (c *config) calibrations() map[string]processor.Calibration {
+	calibrations := map[string]processor.Calibration{}
+	for _, s := range c.Sensors {
+		if s.Calibration == nil {
+			continue
+		}
+		calibrations[s.ID] = processor.Calibration{
+			Temperature: s.Calibration.Temperature.correction(),
+			Humidity:    s.Calibration.Humidity.correction(),
+		}
+	}
+	return calibrations
+}
+
+// groups returns the configured sensor groups.
+func (c *config) groups() []processor.Group {
+	groups := make([]processor.Group, 0, len(c.Groups))
+	for _, g := range c.Groups {
+		groups = append(groups, processor.Group{Name: g.Name, SensorIDs: g.Sensors, Labels: g.Labels})
+	}
+	return groups
+}
+
+func (c *config) expressions() []processor.Expression {
+	exprs := make([]processor.Expression, 0, len(c.Expressions))
+	for _, e := range c.Expressions {
+		exprs = append(exprs, processor.Expression{Filter: e.Filter, Label: e.Label, Expr: e.Expr, Value: e.Value, Unit: e.Unit})
+	}
+	return exprs
+}
+
+// sensors returns the sensors of the configuration file merged with the
+// sensors given on the command line. Locations given on the command line
+// override the location of the same sensor in the file.
+func (c *config) sensors(flags map[string]string) []egain.Sensor {
+	// the sensors without an own interval or schedule are polled at the
+	// schedule of their group, the sensors without an own time zone are in
+	// the one of their group
+	schedules := map[string]string{}
+	zones := map[string]string{}
+	for _, g := range c.Groups {
+		for _, id := range g.Sensors {
+			if g.Schedule != "" {
+				schedules[id] = g.Schedule
+			}
+			if g.TimeZone != "" {
+				zones[id] = g.TimeZone
+			}
+		}
+	}
+
+	sensors := []egain.Sensor{}
+	seen := map[string]int{}
+	for _, s := range c.Sensors {
+		seen[s.ID] = len(sensors)
+		kind, _ := egain.ParseKind(s.Kind)
+		provider := s.Provider
+		if provider == egainProvider {
+			provider = providers.Default
+		}
+		sched := s.Schedule
+		if sched == "" && s.Interval == 0 {
+			sched = schedules[s.ID]
+		}
+		zone := s.TimeZone
+		if zone == "" {
+			zone = zones[s.ID]
+		}
+		// the time zones are validated with the config
+		var loc *time.Location
+		if zone != "" {
+			loc, _ = time.LoadLocation(zone)
+		}
+		sensors = append(sensors, egain.Sensor{SensorID: s.ID, Location: s.Location, Kind: kind, Account: s.Account, Provider: provider, Interval: s.Interval, Schedule: sched, Timeout: s.Timeout, Labels: s.Labels, TimeZone: loc})
+	}
+	for s, l := range flags {
+		if i, ok := seen[s]; ok {
+			if l != "" {
+				sensors[i].Location = l
+			}
+			continue
+		}
+		sensors = append(sensors, egain.Sensor{SensorID: s, Location: l})
+	}
+	return sensors
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the configuration",
+	}
+
+	validate := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration file and flags and print the effective configuration",
+		Long: `Validate the configuration file and flags and print the effective configuration.
+
+The flags are taken from the command line, the environment, the flags of the
+config file and their defaults, in this order of precedence.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, sensors, err := loadSensors()
+			if err != nil {
+				return err
+			}
+			if len(sensors) == 0 {
+				return errNoSensors
+			}
+
+			// the clients and providers validate their options
+			client, err := newFetcher(zap.NewNop(), cfg, sensors)
+			if err != nil {
+				return err
+			}
+			if _, err := newRouter(zap.NewNop(), cfg, client, sensors); err != nil {
+				return err
+			}
+
+			fmt.Printf("configuration is valid: %d sensors, %d accounts, %d providers, %d groups, %d alert rules\n\n", len(sensors), len(cfg.Accounts)+1, len(cfg.Providers)+1, len(cfg.Groups), len(cfg.Alerts.Rules))
+			return printSettings(os.Stdout, cmd)
+		},
+	}
+	// the flags of the scrape command are validated as well
+	registerScrapeFlags(validate.Flags())
+	cmd.AddCommand(validate)
+	return cmd
+}
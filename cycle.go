@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/schedule"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// the ways of handling the polls which came due while a cycle was running
+const (
+	// overrunQueue polls the overdue sensors right after the cycle, each
+	// sensor once
+	overrunQueue = "queue"
+	// overrunSkip skips the overdue polls, the sensors are polled at their
+	// next time
+	overrunSkip = "skip"
+)
+
+// validateCycle checks the flags of the cycles.
+func validateCycle() error {
+	if cycleTimeout < 0 {
+		return fmt.Errorf("invalid --cycle-timeout %s", cycleTimeout)
+	}
+	switch cycleOverrun {
+	case overrunQueue, overrunSkip:
+		return nil
+	}
+	return fmt.Errorf("invalid --cycle-overrun %q, expected %s or %s", cycleOverrun, overrunQueue, overrunSkip)
+}
+
+// fetchDeadline returns the time by which the sensors due at now have to be
+// fetched, the --cycle-timeout or else the time at which the first of them
+// is due again.
+func fetchDeadline(sched *schedule.Scheduler, due []egain.Sensor, now time.Time) time.Time {
+	if cycleTimeout > 0 {
+		return now.Add(cycleTimeout)
+	}
+	return sched.NextOf(due)
+}
+
+// cycleMetrics counts the cycles which did not fit into the schedule.
+type cycleMetrics struct {
+	skipped  metric.Int64Counter
+	exceeded metric.Int64Counter
+}
+
+func newCycleMetrics(meter metric.Meter) (*cycleMetrics, error) {
+	skipped, err := meter.Int64Counter("again_scraper.cycles.skipped",
+		metric.WithDescription("The number of polls of a sensor which were skipped as they came due while the previous cycle was still running"),
+		metric.WithUnit("{cycle}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	exceeded, err := meter.Int64Counter("again_scraper.cycles.deadline_exceeded",
+		metric.WithDescription("The number of cycles whose fetch was cancelled at their deadline"),
+		metric.WithUnit("{cycle}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &cycleMetrics{skipped: skipped, exceeded: exceeded}, nil
+}
+
+// recordSkipped counts the skipped polls by sensor.
+func (m *cycleMetrics) recordSkipped(ctx context.Context, skipped map[string]int) {
+	for id, n := range skipped {
+		m.skipped.Add(ctx, int64(n), metric.WithAttributes(attribute.String("sensor.id", id)))
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	decodeKind   string
+	decodeOutput string
+	decodeStrict bool
+)
+
+func newDecodeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "decode <file.json|->",
+		Short: "Decode a saved response of the egain API and print its readings",
+		Long: `Decode a saved response of the egain API and print its readings.
+
+The payload is decoded like the scraper decodes the responses, in the v1 or v2
+schema, including the generic values. The fields which do not match the schema
+of the sensor kind are reported on stderr. The payload is read from stdin if
+the file is -, e.g.:
+
+	curl -s https://www.egain.se/api/indoor/my-sensor | again-scraper decode -`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, err := egain.ParseKind(decodeKind)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(outputFormats, decodeOutput) {
+				return fmt.Errorf("unknown output format %q, expected one of %v", decodeOutput, outputFormats)
+			}
+
+			var payload []byte
+			if args[0] == "-" {
+				payload, err = io.ReadAll(cmd.InOrStdin())
+			} else {
+				payload, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return fmt.Errorf("cannot read payload: %w", err)
+			}
+
+			d, err := egain.Decode(kind, payload)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "schema: v%d, kind: %s, readings: %d\n", d.Version, kind, len(d.Readings))
+			if len(d.Unexpected) > 0 {
+				fmt.Fprintf(os.Stderr, "unexpected fields: %s\n", strings.Join(d.Unexpected, ", "))
+			}
+			if len(d.Missing) > 0 {
+				fmt.Fprintf(os.Stderr, "missing fields: %s\n", strings.Join(d.Missing, ", "))
+			}
+			if err := writeReadings(os.Stdout, decodeOutput, d.Readings); err != nil {
+				return err
+			}
+			if decodeStrict && (len(d.Unexpected) > 0 || len(d.Missing) > 0) {
+				return exitCode(1)
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&decodeKind, "kind", "indoor", "Kind of the sensor of the payload (indoor, outdoor, heating)")
+	flags.StringVarP(&decodeOutput, "output", "o", "json", "Output format of the readings (json, csv, openmetrics)")
+	flags.BoolVar(&decodeStrict, "strict", false, "Exit with 1 if the payload does not match the schema of the kind")
+	return cmd
+}
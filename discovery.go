@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/kubernetes"
+	"github.com/spf13/pflag"
+)
+
+var (
+	discoverySelector  string
+	discoveryNamespace string
+	discoveryKey       string
+
+	// discoveredVersion is the resource version of the ConfigMaps of the
+	// last discovery, the watch continues from it
+	discoveredVersion string
+)
+
+// registerDiscoveryFlags defines the flags of the discovery of the sensors
+// from Kubernetes ConfigMaps.
+func registerDiscoveryFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&discoverySelector, "discovery-selector", "", "Label selector of Kubernetes ConfigMaps with sensor objects like the sensors of the --config file (e.g. app.kubernetes.io/component=egain-sensors), their sensors are kept in sync with the cluster, disabled by default")
+	flags.StringVar(&discoveryNamespace, "discovery-namespace", "", "Namespace of the ConfigMaps of --discovery-selector, the namespace of the pod by default")
+	flags.StringVar(&discoveryKey, "discovery-key", "sensors.yaml", "Key of the JSON or YAML list of sensor objects in the ConfigMaps of --discovery-selector")
+	envFlags["discovery-selector"] = "DISCOVERY_SELECTOR"
+	envFlags["discovery-namespace"] = "DISCOVERY_NAMESPACE"
+	envFlags["discovery-key"] = "DISCOVERY_KEY"
+}
+
+// newConfigMaps returns the ConfigMaps of the discovery, nil if the discovery
+// is disabled.
+func newConfigMaps() (*kubernetes.ConfigMaps, error) {
+	if discoverySelector == "" {
+		return nil, nil
+	}
+	client, err := kubernetes.NewInCluster()
+	if err != nil {
+		return nil, fmt.Errorf("cannot discover sensors: %w", err)
+	}
+	return kubernetes.NewConfigMaps(client, discoveryNamespace, discoverySelector)
+}
+
+// discoverSensors adds the sensor objects of the matching ConfigMaps to the
+// sensors of the configuration. ConfigMaps without the key are skipped, so
+// they can be labeled for other purposes as well.
+func (c *config) discoverSensors() error {
+	cms, err := newConfigMaps()
+	if cms == nil || err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	list, version, err := cms.List(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot discover sensors: %w", err)
+	}
+	for _, cm := range list {
+		data, ok := cm.Data[discoveryKey]
+		if !ok {
+			continue
+		}
+		source := "configmap " + cm.Metadata.Namespace + "/" + cm.Metadata.Name
+		sensors, err := parseSensors(source, []byte(data))
+		if err != nil {
+			return err
+		}
+		c.Sensors = append(c.Sensors, sensors...)
+	}
+	discoveredVersion = version
+	return nil
+}
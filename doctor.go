@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter/graphite"
+	"github.com/nimdanitro/again-scraper-go/pkg/providers"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// dialTimeout bounds the connection attempts of the doctor.
+const dialTimeout = 5 * time.Second
+
+func newDoctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the egain API, the credentials, the sensors and the exporters and print a report",
+		Long: `Check the egain API, the credentials, the sensors and the exporters of the
+configuration and print a pass/fail report, e.g. when commissioning a new site.
+
+The hosts of the egain API and of the exporters are connected to, each sensor
+is fetched once and the credentials of an account pass if the API accepts them
+for its sensors. Nothing is exported. The command takes the flags of the scrape
+command and exits with 0 if all checks passed, 1 otherwise.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if failed := runDoctor(cmd.Context(), os.Stdout); failed > 0 {
+				return exitCode(exitFailure)
+			}
+			return nil
+		},
+	}
+	// the flags of the scrape command configure the checks
+	registerScrapeFlags(cmd.Flags())
+	return cmd
+}
+
+// doctorReport prints the results of the checks as a table.
+type doctorReport struct {
+	w      *tabwriter.Writer
+	failed int
+}
+
+func (r *doctorReport) pass(check, target, detail string) {
+	fmt.Fprintf(r.w, "PASS\t%s\t%s\t%s\n", check, target, detail)
+}
+
+func (r *doctorReport) fail(check, target string, err error) {
+	r.failed++
+	fmt.Fprintf(r.w, "FAIL\t%s\t%s\t%s\n", check, target, err)
+}
+
+func (r *doctorReport) skip(check, target, detail string) {
+	fmt.Fprintf(r.w, "SKIP\t%s\t%s\t%s\n", check, target, detail)
+}
+
+// runDoctor runs the checks and prints the report. It returns the number of
+// failed checks.
+func runDoctor(ctx context.Context, out io.Writer) int {
+	r := &doctorReport{w: tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)}
+	doctor(ctx, r)
+	r.w.Flush()
+	if r.failed > 0 {
+		fmt.Fprintf(out, "\n%d checks failed\n", r.failed)
+	} else {
+		fmt.Fprintln(out, "\nall checks passed")
+	}
+	return r.failed
+}
+
+func doctor(ctx context.Context, r *doctorReport) {
+	cfg, sensors, err := loadSensors()
+	if err == nil && len(sensors) == 0 {
+		err = errNoSensors
+	}
+	if err != nil {
+		r.fail("config", configTarget(), err)
+		return
+	}
+	client, err := newFetcher(zap.NewNop(), cfg, sensors)
+	if err != nil {
+		r.fail("config", configTarget(), err)
+		return
+	}
+	fetcher, err := newRouter(zap.NewNop(), cfg, client, sensors)
+	if err != nil {
+		r.fail("config", configTarget(), err)
+		return
+	}
+	r.pass("config", configTarget(), fmt.Sprintf("%d sensors, %d accounts", len(sensors), len(cfg.Accounts)+1))
+
+	egainSensors := providers.Select(sensors, providers.Default)
+	accounts := usedAccounts(cfg, egainSensors)
+	if replayDir != "" {
+		r.skip("egain api", replayDir, "the responses are replayed")
+	} else {
+		for _, a := range accounts {
+			// the hosts are only reached through the proxy, if any
+			if a.proxy != "" {
+				d, err := dial(ctx, a.proxy)
+				if err != nil {
+					r.fail("egain api", redactURL(a.proxy), fmt.Errorf("proxy: %w", err))
+					continue
+				}
+				r.pass("egain api", redactURL(a.proxy), "proxy reachable in "+d.Round(time.Millisecond).String())
+				continue
+			}
+			for _, u := range a.urls {
+				d, err := dial(ctx, u)
+				if err != nil {
+					r.fail("egain api", u, err)
+					continue
+				}
+				r.pass("egain api", u, "reachable in "+d.Round(time.Millisecond).String())
+			}
+		}
+	}
+
+	// each sensor is probed on its own, so the errors are told apart
+	probes := map[string]error{}
+	for _, s := range sensors {
+		readings, err := fetcher.FetchSensors(ctx, []egain.Sensor{s})
+		if err == nil && len(readings) == 0 {
+			err = errors.New("no reading")
+		}
+		probes[s.SensorID] = err
+		if ctx.Err() != nil {
+			r.fail("sensor", s.SensorID, ctx.Err())
+			return
+		}
+		if err != nil {
+			r.fail("sensor", s.SensorID, err)
+			continue
+		}
+		reading := readings[0]
+		if reading.Stale {
+			r.fail("sensor", s.SensorID, fmt.Errorf("stale, the last reading is from %s", reading.Timestamp.Format(time.RFC3339)))
+			continue
+		}
+		r.pass("sensor", s.SensorID, describeProbe(reading))
+	}
+
+	for _, a := range accounts {
+		checkCredentials(r, a, egainSensors, probes)
+	}
+
+	checkExporters(ctx, r, cfg)
+}
+
+func configTarget() string {
+	if configFile == "" {
+		return "flags"
+	}
+	return configFile
+}
+
+// describeProbe summarizes the reading of a probe.
+func describeProbe(r *egain.SensorReading) string {
+	age := max(time.Since(r.Timestamp), 0).Round(time.Second)
+	if h := r.Heating; h != nil {
+		return fmt.Sprintf("flow %.1f, return %.1f, %s old", h.FlowTemperature, h.ReturnTemperature, age)
+	}
+	return fmt.Sprintf("%.1f °C, %.0f %%, %s old", r.Temperature, r.Humidity, age)
+}
+
+// doctorAccount is an account of the egain API with sensors.
+type doctorAccount struct {
+	name        string
+	urls        []string
+	proxy       string
+	credentials bool
+}
+
+// usedAccounts returns the accounts of the sensors, the default account
+// first.
+func usedAccounts(cfg *config, sensors []egain.Sensor) []doctorAccount {
+	used := map[string]bool{}
+	for _, s := range sensors {
+		used[s.Account] = true
+	}
+	var accounts []doctorAccount
+	if used[""] {
+		accounts = append(accounts, doctorAccount{urls: append([]string{baseURL}, mirrors...), proxy: proxyURL})
+	}
+	for _, a := range cfg.Accounts {
+		if !used[a.Name] {
+			continue
+		}
+		da := doctorAccount{name: a.Name, urls: append([]string{baseURL}, mirrors...), proxy: proxyURL, credentials: a.Username != ""}
+		if a.BaseURL != "" {
+			da.urls = append([]string{a.BaseURL}, a.FailoverURLs...)
+		}
+		if a.Proxy != "" {
+			da.proxy = a.Proxy
+		}
+		accounts = append(accounts, da)
+	}
+	return accounts
+}
+
+// checkCredentials passes if the API accepted the credentials of the account
+// for any of its sensors and fails if it rejected them.
+func checkCredentials(r *doctorReport, a doctorAccount, sensors []egain.Sensor, probes map[string]error) {
+	target := a.name
+	if target == "" {
+		target = "default"
+	}
+	accepted := false
+	for _, s := range sensors {
+		if s.Account != a.name {
+			continue
+		}
+		err := probes[s.SensorID]
+		if errors.Is(err, egain.ErrUnauthorized) {
+			r.fail("credentials", target, err)
+			return
+		}
+		accepted = accepted || err == nil
+	}
+	switch {
+	case !accepted:
+		r.skip("credentials", target, "no sensor of the account could be fetched")
+	case a.credentials:
+		r.pass("credentials", target, "accepted")
+	default:
+		r.pass("credentials", target, "none required")
+	}
+}
+
+// checkExporters connects to the hosts of the enabled exporters and checks
+// that the directories of the file exporters exist.
+func checkExporters(ctx context.Context, r *doctorReport, cfg *config) {
+	checkURL := func(name, u string) {
+		d, err := dial(ctx, u)
+		if err != nil {
+			r.fail("exporter "+name, redactURL(u), err)
+			return
+		}
+		r.pass("exporter "+name, redactURL(u), "reachable in "+d.Round(time.Millisecond).String())
+	}
+	checkDir := func(name, path string) {
+		fi, err := os.Stat(path)
+		switch {
+		case err != nil:
+			r.fail("exporter "+name, path, err)
+		case !fi.IsDir():
+			r.fail("exporter "+name, path, errors.New("not a directory"))
+		default:
+			r.pass("exporter "+name, path, "directory exists")
+		}
+	}
+
+	for _, name := range exporterNames(cfg) {
+		switch name {
+		case "influxdb":
+			checkURL(name, influxURL)
+		case "store":
+			checkDir(name, filepath.Dir(storePath))
+		case "csv":
+			checkDir(name, filepath.Dir(csvPath))
+		case "parquet":
+			checkDir(name, parquetDir)
+		case "openmetrics":
+			checkDir(name, filepath.Dir(openMetricsFile))
+		case "postgres":
+			if u, err := url.Parse(postgresURL); err == nil && u.Host != "" {
+				checkURL(name, postgresURL)
+			} else {
+				r.skip("exporter "+name, "", "only connection strings in the URL format are checked")
+			}
+		case "webhook":
+			checkURL(name, webhookURL)
+		case "mqtt":
+			checkURL(name, mqttBroker)
+		case "graphite":
+			if graphiteProtocol == string(graphite.StatsD) {
+				r.skip("exporter "+name, graphiteAddr, "StatsD is sent over UDP")
+			} else {
+				checkURL(name, "tcp://"+graphiteAddr)
+			}
+		case "kafka":
+			for _, b := range kafkaBrokers {
+				checkURL(name, "tcp://"+b)
+			}
+		case "nats":
+			for _, u := range strings.Split(natsURL, ",") {
+				checkURL(name, strings.TrimSpace(u))
+			}
+		case "pushgateway":
+			checkURL(name, pushgatewayURL)
+		case "zabbix":
+			addr := zabbixServer
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				addr = net.JoinHostPort(addr, "10051")
+			}
+			checkURL(name, "tcp://"+addr)
+		case "azure-logs":
+			checkURL(name, azureLogsEndpoint)
+		case "otel", "alert":
+			// the OTel exporter is configured by the OTel SDK and the alert
+			// rules by their notifiers
+		default:
+			r.skip("exporter "+name, "", "authenticated by the credentials of the cloud, not checked")
+		}
+	}
+}
+
+// defaultPorts are the ports of the schemes of the URLs without a port.
+var defaultPorts = map[string]string{
+	"http":       "80",
+	"https":      "443",
+	"ws":         "80",
+	"wss":        "443",
+	"tcp":        "1883",
+	"mqtt":       "1883",
+	"ssl":        "8883",
+	"tls":        "8883",
+	"mqtts":      "8883",
+	"nats":       "4222",
+	"postgres":   "5432",
+	"postgresql": "5432",
+	"socks5":     "1080",
+}
+
+// dial connects to the host of the URL and returns how long it took.
+func dial(ctx context.Context, rawURL string) (time.Duration, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	if u.Host == "" {
+		return 0, fmt.Errorf("invalid url %q, expected a host", u.Redacted())
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		port, ok := defaultPorts[u.Scheme]
+		if !ok {
+			return 0, fmt.Errorf("unknown port of the scheme %q", u.Scheme)
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, err
+	}
+	d := time.Since(start)
+	conn.Close()
+	return d, nil
+}
+
+// redactURL returns the URL with its password redacted.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
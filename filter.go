@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/spf13/pflag"
+)
+
+var (
+	onlySensors    []string
+	excludeSensors []string
+)
+
+// registerFilterFlags defines the flags scoping a run to a subset of the
+// sensors of the config, the discovery and the flags.
+func registerFilterFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&onlySensors, "only", nil, "Only poll the sensors matching any of the filters, a glob of the sensor ID (e.g. ID12*) or a regular expression of the location prefixed by location: (e.g. location:^Basement), e.g. to scope a run to some sensors without editing the config")
+	flags.StringSliceVar(&excludeSensors, "exclude", nil, "Do not poll the sensors matching any of the filters, in the format of --only")
+	envFlags["only"] = "ONLY_SENSORS"
+	envFlags["exclude"] = "EXCLUDE_SENSORS"
+}
+
+// sensorFilter matches the sensors by a glob of their ID or a regular
+// expression of their location.
+type sensorFilter struct {
+	glob     string
+	location *regexp.Regexp
+}
+
+func parseSensorFilter(s string) (sensorFilter, error) {
+	if expr, ok := strings.CutPrefix(s, "location:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return sensorFilter{}, fmt.Errorf("invalid location filter %q: %w", s, err)
+		}
+		return sensorFilter{location: re}, nil
+	}
+	if _, err := path.Match(s, ""); err != nil {
+		return sensorFilter{}, fmt.Errorf("invalid sensor filter %q: %w", s, err)
+	}
+	return sensorFilter{glob: s}, nil
+}
+
+func (f sensorFilter) match(s egain.Sensor) bool {
+	if f.location != nil {
+		return f.location.MatchString(s.Location)
+	}
+	ok, _ := path.Match(f.glob, s.SensorID)
+	return ok
+}
+
+func parseSensorFilters(specs []string) ([]sensorFilter, error) {
+	filters := make([]sensorFilter, 0, len(specs))
+	for _, s := range specs {
+		f, err := parseSensorFilter(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+func matchAny(filters []sensorFilter, s egain.Sensor) bool {
+	for _, f := range filters {
+		if f.match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterSensors keeps the sensors matching any of the --only filters, if
+// any, and none of the --exclude filters. It fails if no sensors are left,
+// so a reload keeps the previous sensors.
+func filterSensors(sensors []egain.Sensor) ([]egain.Sensor, error) {
+	if len(onlySensors) == 0 && len(excludeSensors) == 0 {
+		return sensors, nil
+	}
+	only, err := parseSensorFilters(onlySensors)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --only: %w", err)
+	}
+	exclude, err := parseSensorFilters(excludeSensors)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --exclude: %w", err)
+	}
+
+	filtered := []egain.Sensor{}
+	for _, s := range sensors {
+		if (len(only) == 0 || matchAny(only, s)) && !matchAny(exclude, s) {
+			filtered = append(filtered, s)
+		}
+	}
+	if len(sensors) > 0 && len(filtered) == 0 {
+		return nil, errors.New("no sensors match the --only and --exclude filters")
+	}
+	return filtered, nil
+}
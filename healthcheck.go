@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// healthcheckTimeout is the timeout of the request of the healthcheck.
+const healthcheckTimeout = 5 * time.Second
+
+var healthcheckURL string
+
+func newHealthcheckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "healthcheck",
+		Short: "Check the health of a running scraper and exit with 0 if it is healthy, 1 otherwise",
+		Long: `Check the health of a running scraper and exit with 0 if it is healthy, 1 otherwise.
+
+The /healthz endpoint of the admin server is queried if --admin-listen is set,
+the one of the HTTP API of the serve command otherwise. It is meant for the
+HEALTHCHECK of container images, which do not have to ship curl:
+
+	HEALTHCHECK CMD ["/again-scraper", "healthcheck"]`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := healthcheckURL
+			if u == "" {
+				u = healthzURL()
+			}
+			if err := checkHealth(cmd.Context(), u); err != nil {
+				fmt.Fprintln(os.Stderr, "unhealthy:", err)
+				return exitCode(1)
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&healthcheckURL, "health-url", "", "URL of the health endpoint, derived from --admin-listen or --listen by default")
+	envFlags["health-url"] = "HEALTHCHECK_URL"
+	registerAdminFlags(flags)
+	registerListenFlag(flags)
+	return cmd
+}
+
+// healthzURL returns the URL of the local health endpoint of the admin server
+// or the HTTP API. Listen addresses without a host or on all interfaces are
+// reached on the loopback interface.
+func healthzURL() string {
+	addr := adminListenAddr
+	if addr == "" {
+		addr = listenAddr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, "80"
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/healthz"
+}
+
+// checkHealth returns an error unless the health endpoint responds with 200.
+func checkHealth(ctx context.Context, u string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthcheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response %s from %s", resp.Status, u)
+	}
+	return nil
+}
+
+// healthz responds with ok while the scraper is running. Failing sensors do
+// not make it unhealthy, as a restart would not fix them. With ?verbose the
+// status of each sensor in the registry is listed above, e.g.
+//
+//	[+]sensor ID12312 ok
+//	[-]sensor ID12313 failing: unexpected status 404 Not Found
+//	ok
+func healthz(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	if r.URL.Query().Has("verbose") {
+		for _, st := range registry.Snapshot().Sensors {
+			switch {
+			case st.Err != nil:
+				// a sensor stays on one line
+				fmt.Fprintf(&b, "[-]sensor %s failing: %s\n", st.SensorID, strings.ReplaceAll(st.Err.Error(), "\n", " "))
+			case st.Reading != nil && st.Reading.Stale:
+				fmt.Fprintf(&b, "[-]sensor %s stale\n", st.SensorID)
+			default:
+				fmt.Fprintf(&b, "[+]sensor %s ok\n", st.SensorID)
+			}
+		}
+	}
+	b.WriteString("ok\n")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, b.String())
+}
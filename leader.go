@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/leader"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+var (
+	leaderElection  string
+	leaderFile      string
+	leaderLease     string
+	leaderNamespace string
+	leaderDuration  time.Duration
+)
+
+// registerLeaderFlags defines the flags of the leader election between
+// replicas.
+func registerLeaderFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&leaderElection, "leader-election", "", "Elect a leader among the replicas which fetches the sensors while the others stand by (file, kubernetes), disabled by default")
+	flags.StringVar(&leaderFile, "leader-election-file", "", "Path of the file locked by the leader with --leader-election=file")
+	flags.StringVar(&leaderLease, "leader-election-lease", "again-scraper", "Name of the Kubernetes Lease held by the leader with --leader-election=kubernetes")
+	flags.StringVar(&leaderNamespace, "leader-election-namespace", "", "Namespace of the Kubernetes Lease, the namespace of the pod by default")
+	flags.DurationVar(&leaderDuration, "leader-election-duration", 15*time.Second, "Duration after which a standby takes over the leadership if the leader stopped renewing it")
+	envFlags["leader-election"] = "LEADER_ELECTION"
+	envFlags["leader-election-file"] = "LEADER_ELECTION_FILE"
+	envFlags["leader-election-lease"] = "LEADER_ELECTION_LEASE"
+	envFlags["leader-election-namespace"] = "LEADER_ELECTION_NAMESPACE"
+	envFlags["leader-election-duration"] = "LEADER_ELECTION_DURATION"
+}
+
+// newElector creates the elector of the configured leader election, nil if
+// the leader election is disabled.
+func newElector(logger *zap.Logger) (*leader.Elector, error) {
+	var lock leader.Lock
+	switch leaderElection {
+	case "":
+		return nil, nil
+	case "file":
+		if leaderFile == "" {
+			return nil, fmt.Errorf("please specify the lock file with --leader-election-file")
+		}
+		lock = leader.NewFileLock(leaderFile)
+	case "kubernetes":
+		// the pod name is the hostname
+		identity, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		lock, err = leader.NewLease(leaderLease, leaderNamespace, identity, leaderDuration)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown leader election %q, expected file or kubernetes", leaderElection)
+	}
+	return leader.New(lock, leader.WithLeaseDuration(leaderDuration), leader.WithLogger(logger))
+}
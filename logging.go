@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	logLevelName string
+	logFormat    string
+)
+
+// logLevel is the level of the logger, it can be changed at runtime through
+// the admin server.
+var logLevel = zap.NewAtomicLevel()
+
+// registerLogFlags defines the flags of the logger.
+func registerLogFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&logLevelName, "log-level", "", "Level of the logs (debug, info, warn, error), the default of the command by default")
+	flags.StringVar(&logFormat, "log-format", "json", "Format of the logs (json, console)")
+	envFlags["log-level"] = "LOG_LEVEL"
+	envFlags["log-format"] = "LOG_FORMAT"
+}
+
+// newLogger creates the logger writing to w in the --log-format, at the
+// --log-level or the given level of the command. The extra cores, e.g. of the
+// telemetry, observe the same level.
+func newLogger(w zapcore.WriteSyncer, level zapcore.Level, extra ...zapcore.Core) (*zap.Logger, error) {
+	if logLevelName != "" {
+		var err error
+		if level, err = zapcore.ParseLevel(logLevelName); err != nil {
+			return nil, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", logLevelName)
+		}
+	}
+	logLevel.SetLevel(level)
+
+	var enc zapcore.Encoder
+	switch logFormat {
+	case "json":
+		enc = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	case "console":
+		cfg := zap.NewProductionEncoderConfig()
+		cfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		enc = zapcore.NewConsoleEncoder(cfg)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or console", logFormat)
+	}
+
+	cores := []zapcore.Core{zapcore.NewCore(enc, w, logLevel)}
+	for _, c := range extra {
+		cores = append(cores, levelCore{Core: c, level: logLevel})
+	}
+	return zap.New(zapcore.NewTee(cores...)), nil
+}
+
+// levelCore drops the entries of the core below the level. Unlike
+// zapcore.NewIncreaseLevelCore it accepts cores which do not enable all
+// levels themselves, like the core of the telemetry.
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c levelCore) Enabled(l zapcore.Level) bool {
+	return c.level.Enabled(l) && c.Core.Enabled(l)
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c levelCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(e.Level) {
+		return ce
+	}
+	return c.Core.Check(e, ce)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestSensorsValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"ID1=lab,ID2", map[string]string{"ID1": "lab", "ID2": ""}, false},
+		{"", map[string]string{}, false},
+		{"  ", map[string]string{}, false},
+		{"ID1,,ID2", nil, true},
+		{"=lab", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			v := sensorsValue{}
+			err := v.Set(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Set(%q) succeeded, want an error", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(v, sensorsValue(tt.want)) {
+				t.Errorf("got %v, want %v", v, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+	"github.com/nimdanitro/again-scraper-go/pkg/pipeline"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// The exit codes of a single cycle, besides 0 if all sensors were fetched and
+// exported and 2 for invalid flags.
+const (
+	// exitFailure is returned if no sensor could be fetched or the readings
+	// could not be written or exported.
+	exitFailure = 1
+	// exitPartial is returned if some sensors could not be fetched, the
+	// readings of the others were written or exported.
+	exitPartial = 3
+)
+
+// fetchExitCode returns the exit code of the fetch of a cycle which fetched
+// the given number of readings.
+func fetchExitCode(fetched int, err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case fetched > 0:
+		return exitPartial
+	default:
+		return exitFailure
+	}
+}
+
+// runOnce fetches all sensors a single time and writes the readings to
+// stdout. No telemetry is set up and logs are written to stderr, so the output
+// can be piped into other tools. It returns the exit code of the program,
+// which is non-zero if any sensor could not be fetched.
+func runOnce(ctx context.Context, cfg *config, sensors []egain.Sensor, format string) int {
+	if !slices.Contains(outputFormats, format) {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q, expected one of %v\n", format, outputFormats)
+		return 2
+	}
+	if len(sensors) == 0 {
+		fmt.Fprintln(os.Stderr, "Please specify a comma-separated list of sensor IDs with the --sensors flag or a --config file")
+		return 2
+	}
+
+	logger, err := newLogger(zapcore.AddSync(os.Stderr), zapcore.WarnLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer logger.Sync()
+
+	client, err := newFetcher(logger, cfg, sensors)
+	if err != nil {
+		logger.Error("cannot create fetcher", zap.Error(err))
+		return 1
+	}
+	defer closeArchive(logger)
+	fetcher, err := newRouter(logger, cfg, client, sensors)
+	if err != nil {
+		logger.Error("cannot create providers", zap.Error(err))
+		return 1
+	}
+	// the readings of the sensors which could be fetched are written anyway
+	pipe, err := newPipeline(cfg, logger, noop.Meter{}, fetcher, exporter.Multi{writerExporter{w: os.Stdout, format: format}})
+	if err != nil {
+		logger.Error("cannot create pipeline", zap.Error(err))
+		return 1
+	}
+
+	resolveLocations(ctx, logger, client)
+
+	res, err := pipe.Run(ctx, sensors)
+	if err != nil {
+		logger.Error("cycle failed", zap.Error(err))
+		return 1
+	}
+	if res.FetchErr != nil {
+		logger.Error("Failed to fetch data", zap.Error(res.FetchErr))
+	}
+	return fetchExitCode(res.Fetched, res.FetchErr)
+}
+
+// exportOnce fetches all sensors a single time and exports the readings, e.g.
+// when the scraper is run by cron. The state is saved once the readings are
+// exported, the exporters and the telemetry are flushed by the caller. It
+// returns an exitCode if the cycle failed.
+func exportOnce(ctx context.Context, logger *zap.Logger, pipe *pipeline.Pipeline, sensors []egain.Sensor, saveState func()) error {
+	logger.Info("fetching data from egain once")
+	res, err := pipe.Run(ctx, sensors)
+	if res.FetchErr != nil {
+		// the readings of the other sensors are exported anyway
+		logger.Error("Failed to fetch data", zap.Int("fetched", res.Fetched), zap.Error(res.FetchErr))
+	}
+	if err != nil {
+		logger.Error("cycle failed", zap.Error(err))
+		return exitCode(exitFailure)
+	}
+	logger.Info("exported readings", zap.Int("readings", res.Exported))
+	saveState()
+
+	if code := fetchExitCode(res.Fetched, res.FetchErr); code != 0 {
+		return exitCode(code)
+	}
+	return nil
+}
@@ -2,12 +2,26 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
 
+	"github.com/spf13/pflag"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/log"
@@ -15,11 +29,12 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc/credentials"
 )
 
 // setupOTelSDK bootstraps the OpenTelemetry pipeline.
 // If it does not return an error, make sure to call shutdown for proper cleanup.
-func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, err error) {
+func setupOTelSDK(ctx context.Context, views ...metric.View) (shutdown func(context.Context) error, err error) {
 	var shutdownFuncs []func(context.Context) error
 
 	// shutdown calls cleanup functions registered via shutdownFuncs.
@@ -43,14 +58,19 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 	prop := newPropagator()
 	otel.SetTextMapPropagator(prop)
 
-	res, err := newResource()
+	res, err := newResource(ctx)
+	if err != nil {
+		handleErr(err)
+		return
+	}
+	otlp, err := newOTLPConfig()
 	if err != nil {
 		handleErr(err)
 		return
 	}
 
 	// Set up trace provider.
-	tracerProvider, err := newTraceProvider(ctx, res)
+	tracerProvider, err := newTraceProvider(ctx, res, otlp)
 	if err != nil {
 		handleErr(err)
 		return
@@ -59,7 +79,7 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 	otel.SetTracerProvider(tracerProvider)
 
 	// Set up meter provider.
-	meterProvider, err := newMeterProvider(ctx, res)
+	meterProvider, err := newMeterProvider(ctx, res, otlp, views)
 	if err != nil {
 		handleErr(err)
 		return
@@ -68,7 +88,7 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 	otel.SetMeterProvider(meterProvider)
 
 	// Set up logger provider.
-	loggerProvider, err := newLoggerProvider(ctx, res)
+	loggerProvider, err := newLoggerProvider(ctx, res, otlp)
 	if err != nil {
 		handleErr(err)
 		return
@@ -86,50 +106,315 @@ func newPropagator() propagation.TextMapPropagator {
 	)
 }
 
-func newTraceProvider(ctx context.Context, res *resource.Resource) (*trace.TracerProvider, error) {
-	traceExporter, err := otlptracehttp.New(ctx)
+// newTraceProvider creates the trace provider, spans are still created without
+// an exporter so the readings carry a trace context.
+func newTraceProvider(ctx context.Context, res *resource.Resource, otlp *otlpConfig) (*trace.TracerProvider, error) {
+	opts := []trace.TracerProviderOption{trace.WithResource(res)}
+	exporter, err := otelExporterOf("traces", otelTracesExporter)
 	if err != nil {
 		return nil, err
 	}
 
-	traceProvider := trace.NewTracerProvider(
-		trace.WithResource(res),
-		trace.WithBatcher(traceExporter),
-	)
-	return traceProvider, nil
+	var traceExporter trace.SpanExporter
+	switch exporter {
+	case otelOTLPHTTP:
+		o := []otlptracehttp.Option{}
+		if otlp.endpoint != nil {
+			o = append(o, otlptracehttp.WithEndpointURL(otlp.endpoint.JoinPath("v1/traces").String()))
+		}
+		if len(otlp.headers) > 0 {
+			o = append(o, otlptracehttp.WithHeaders(otlp.headers))
+		}
+		if otlp.tls != nil {
+			o = append(o, otlptracehttp.WithTLSClientConfig(otlp.tls))
+		}
+		traceExporter, err = otlptracehttp.New(ctx, o...)
+	case otelOTLPGRPC:
+		o := []otlptracegrpc.Option{}
+		if otlp.endpoint != nil {
+			o = append(o, otlptracegrpc.WithEndpointURL(otlp.endpoint.String()))
+		}
+		if len(otlp.headers) > 0 {
+			o = append(o, otlptracegrpc.WithHeaders(otlp.headers))
+		}
+		if otlp.tls != nil {
+			o = append(o, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(otlp.tls)))
+		}
+		traceExporter, err = otlptracegrpc.New(ctx, o...)
+	case otelStdout:
+		traceExporter, err = stdouttrace.New()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if traceExporter != nil {
+		opts = append(opts, trace.WithBatcher(traceExporter))
+	}
+	return trace.NewTracerProvider(opts...), nil
 }
 
-func newMeterProvider(ctx context.Context, res *resource.Resource) (*metric.MeterProvider, error) {
-	metricExporter, err := otlpmetrichttp.New(ctx)
+func newMeterProvider(ctx context.Context, res *resource.Resource, otlp *otlpConfig, views []metric.View) (*metric.MeterProvider, error) {
+	opts := []metric.Option{metric.WithResource(res)}
+	if len(views) > 0 {
+		opts = append(opts, metric.WithView(views...))
+	}
+	exporter, err := otelExporterOf("metrics", otelMetricsExporter)
 	if err != nil {
 		return nil, err
 	}
 
-	meterProvider := metric.NewMeterProvider(
-		metric.WithResource(res),
-		metric.WithReader(metric.NewPeriodicReader(metricExporter)),
-	)
-	return meterProvider, nil
+	var metricExporter metric.Exporter
+	switch exporter {
+	case otelOTLPHTTP:
+		o := []otlpmetrichttp.Option{}
+		if otlp.endpoint != nil {
+			o = append(o, otlpmetrichttp.WithEndpointURL(otlp.endpoint.JoinPath("v1/metrics").String()))
+		}
+		if len(otlp.headers) > 0 {
+			o = append(o, otlpmetrichttp.WithHeaders(otlp.headers))
+		}
+		if otlp.tls != nil {
+			o = append(o, otlpmetrichttp.WithTLSClientConfig(otlp.tls))
+		}
+		metricExporter, err = otlpmetrichttp.New(ctx, o...)
+	case otelOTLPGRPC:
+		o := []otlpmetricgrpc.Option{}
+		if otlp.endpoint != nil {
+			o = append(o, otlpmetricgrpc.WithEndpointURL(otlp.endpoint.String()))
+		}
+		if len(otlp.headers) > 0 {
+			o = append(o, otlpmetricgrpc.WithHeaders(otlp.headers))
+		}
+		if otlp.tls != nil {
+			o = append(o, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(otlp.tls)))
+		}
+		metricExporter, err = otlpmetricgrpc.New(ctx, o...)
+	case otelStdout:
+		metricExporter, err = stdoutmetric.New()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if metricExporter != nil {
+		opts = append(opts, metric.WithReader(metric.NewPeriodicReader(metricExporter)))
+	}
+	return metric.NewMeterProvider(opts...), nil
+}
+
+// views returns the views of the meter provider of the config.
+func (c *config) views() ([]metric.View, error) {
+	var views []metric.View
+	for i, v := range c.Views {
+		if v.Instrument == "" {
+			return nil, fmt.Errorf("view #%d: missing instrument", i)
+		}
+		inst := metric.Instrument{Name: v.Instrument}
+		var stream metric.Stream
+		switch {
+		case v.Drop && (v.Rename != "" || len(v.Buckets) > 0 || len(v.Attributes) > 0):
+			return nil, fmt.Errorf("view %s: a dropped instrument cannot be changed", v.Instrument)
+		case v.Drop:
+			stream.Aggregation = metric.AggregationDrop{}
+		}
+		if v.Rename != "" {
+			if strings.ContainsAny(v.Instrument, "*?") {
+				return nil, fmt.Errorf("view %s: the instruments of a wildcard cannot be renamed", v.Instrument)
+			}
+			stream.Name = v.Rename
+		}
+		if len(v.Buckets) > 0 {
+			if !slices.IsSorted(v.Buckets) || len(slices.Compact(slices.Clone(v.Buckets))) != len(v.Buckets) {
+				return nil, fmt.Errorf("view %s: the buckets have to be increasing", v.Instrument)
+			}
+			// the buckets only apply to histograms, whatever the wildcard
+			// matches
+			inst.Kind = metric.InstrumentKindHistogram
+			stream.Aggregation = metric.AggregationExplicitBucketHistogram{Boundaries: v.Buckets}
+		}
+		if len(v.Attributes) > 0 {
+			keys := make([]attribute.Key, len(v.Attributes))
+			for i, k := range v.Attributes {
+				keys[i] = attribute.Key(k)
+			}
+			stream.AttributeFilter = attribute.NewAllowKeysFilter(keys...)
+		}
+		views = append(views, metric.NewView(inst, stream))
+	}
+	return views, nil
 }
 
-func newLoggerProvider(ctx context.Context, res *resource.Resource) (*log.LoggerProvider, error) {
-	logExporter, err := otlploghttp.New(ctx)
+func newLoggerProvider(ctx context.Context, res *resource.Resource, otlp *otlpConfig) (*log.LoggerProvider, error) {
+	opts := []log.LoggerProviderOption{log.WithResource(res)}
+	exporter, err := otelExporterOf("logs", otelLogsExporter)
 	if err != nil {
 		return nil, err
 	}
 
-	loggerProvider := log.NewLoggerProvider(
-		log.WithResource(res),
-		log.WithProcessor(log.NewBatchProcessor(logExporter)),
-	)
-	return loggerProvider, nil
+	var logExporter log.Exporter
+	switch exporter {
+	case otelOTLPHTTP:
+		o := []otlploghttp.Option{}
+		if otlp.endpoint != nil {
+			o = append(o, otlploghttp.WithEndpointURL(otlp.endpoint.JoinPath("v1/logs").String()))
+		}
+		if len(otlp.headers) > 0 {
+			o = append(o, otlploghttp.WithHeaders(otlp.headers))
+		}
+		if otlp.tls != nil {
+			o = append(o, otlploghttp.WithTLSClientConfig(otlp.tls))
+		}
+		logExporter, err = otlploghttp.New(ctx, o...)
+	case otelOTLPGRPC:
+		o := []otlploggrpc.Option{}
+		if otlp.endpoint != nil {
+			o = append(o, otlploggrpc.WithEndpointURL(otlp.endpoint.String()))
+		}
+		if len(otlp.headers) > 0 {
+			o = append(o, otlploggrpc.WithHeaders(otlp.headers))
+		}
+		if otlp.tls != nil {
+			o = append(o, otlploggrpc.WithTLSCredentials(credentials.NewTLS(otlp.tls)))
+		}
+		logExporter, err = otlploggrpc.New(ctx, o...)
+	case otelStdout:
+		logExporter, err = stdoutlog.New()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if logExporter != nil {
+		opts = append(opts, log.WithProcessor(log.NewBatchProcessor(logExporter)))
+	}
+	return log.NewLoggerProvider(opts...), nil
+}
+
+// The exporters of the telemetry, each signal is exported with the exporter
+// of --otel-exporter unless it has its own.
+const (
+	otelOTLPHTTP = "otlp-http"
+	otelOTLPGRPC = "otlp-grpc"
+	otelStdout   = "stdout"
+	otelNone     = "none"
+)
+
+var (
+	otelExporter        string
+	otelTracesExporter  string
+	otelMetricsExporter string
+	otelLogsExporter    string
+	otelEndpoint        string
+	otelHeaders         map[string]string
+	otelTLSCA           string
+	otelTLSCert         string
+	otelTLSKey          string
+	otelSpanEvents      bool
+)
+
+// otelExporterOf returns the exporter of the signal, the exporter names of
+// OTEL_TRACES_EXPORTER and friends are accepted as well.
+func otelExporterOf(signal, exporter string) (string, error) {
+	if exporter == "" {
+		exporter = otelExporter
+	}
+	switch exporter {
+	case "otlp":
+		return otelOTLPHTTP, nil
+	case "console":
+		return otelStdout, nil
+	case otelOTLPHTTP, otelOTLPGRPC, otelStdout, otelNone:
+		return exporter, nil
+	}
+	return "", fmt.Errorf("invalid %s exporter %q, expected %s, %s, %s or %s", signal, exporter, otelOTLPHTTP, otelOTLPGRPC, otelStdout, otelNone)
+}
+
+// otlpConfig are the settings of the OTLP exporters, unset settings are taken
+// from the OTEL_EXPORTER_OTLP_* environment variables by the exporters.
+type otlpConfig struct {
+	endpoint *url.URL
+	headers  map[string]string
+	tls      *tls.Config
+}
+
+// newOTLPConfig returns the settings of the OTLP exporters of the flags.
+func newOTLPConfig() (*otlpConfig, error) {
+	c := &otlpConfig{headers: otelHeaders}
+	if otelEndpoint != "" {
+		u, err := url.Parse(otelEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid OTLP endpoint %q, expected an http or https URL", otelEndpoint)
+		}
+		c.endpoint = u
+	}
+	if otelTLSCA != "" || otelTLSCert != "" || otelTLSKey != "" {
+		c.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+		if otelTLSCA != "" {
+			pool, err := loadCertPool(otelTLSCA)
+			if err != nil {
+				return nil, err
+			}
+			c.tls.RootCAs = pool
+		}
+		if otelTLSCert != "" || otelTLSKey != "" {
+			if otelTLSCert == "" || otelTLSKey == "" {
+				return nil, fmt.Errorf("please specify both --otel-tls-cert and --otel-tls-key")
+			}
+			cert, err := tls.LoadX509KeyPair(otelTLSCert, otelTLSKey)
+			if err != nil {
+				return nil, fmt.Errorf("cannot load OTLP client certificate: %w", err)
+			}
+			c.tls.Certificates = []tls.Certificate{cert}
+		}
+	}
+	return c, nil
 }
 
-func newResource() (*resource.Resource, error) {
-	return resource.Merge(
+// otelResource are the resource attributes set on the command line.
+var otelResource map[string]string
+
+// registerOTelFlags defines the flags of the telemetry.
+func registerOTelFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&otelExporter, "otel-exporter", otelOTLPHTTP, "Exporter of the telemetry (otlp-http, otlp-grpc, stdout, none)")
+	flags.StringVar(&otelTracesExporter, "otel-traces-exporter", "", "Exporter of the traces, the --otel-exporter by default")
+	flags.StringVar(&otelMetricsExporter, "otel-metrics-exporter", "", "Exporter of the metrics, the --otel-exporter by default")
+	flags.StringVar(&otelLogsExporter, "otel-logs-exporter", "", "Exporter of the logs, the --otel-exporter by default")
+	flags.StringVar(&otelEndpoint, "otel-endpoint", "", "Base URL of the OTLP collector, e.g. http://collector:4318, OTEL_EXPORTER_OTLP_ENDPOINT by default")
+	flags.StringToStringVar(&otelHeaders, "otel-headers", nil, "Headers of the OTLP requests as key=value, e.g. authorization=..., OTEL_EXPORTER_OTLP_HEADERS by default")
+	flags.StringVar(&otelTLSCA, "otel-tls-ca", "", "Path of a PEM bundle of the CAs trusted for the OTLP collector instead of the system CAs")
+	flags.StringVar(&otelTLSCert, "otel-tls-cert", "", "Path of the PEM client certificate presented to the OTLP collector (mTLS)")
+	flags.StringVar(&otelTLSKey, "otel-tls-key", "", "Path of the PEM private key of the --otel-tls-cert")
+	flags.BoolVar(&otelSpanEvents, "otel-span-events", false, "Add the warnings and errors of the fetches of the sensors as events to their spans, next to the logs which carry the IDs of the spans")
+	flags.StringToStringVar(&otelResource, "otel-resource", nil, "Resource attributes of the telemetry as key=value, e.g. deployment.environment=production,site=zurich, they take precedence over OTEL_RESOURCE_ATTRIBUTES")
+}
+
+// newResource describes the scraper, the attributes of OTEL_SERVICE_NAME,
+// OTEL_RESOURCE_ATTRIBUTES and the --otel-resource flag take precedence over
+// the defaults, so deployments can be told apart.
+func newResource(ctx context.Context) (*resource.Resource, error) {
+	res, err := resource.Merge(
 		resource.Default(),
 		resource.NewWithAttributes(semconv.SchemaURL,
 			semconv.ServiceName("again-scraper-go"),
 			semconv.ServiceVersion(version),
 		))
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := resource.New(ctx, resource.WithFromEnv())
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES: %w", err)
+	}
+	if res, err = resource.Merge(res, env); err != nil {
+		return nil, err
+	}
+
+	keys := slices.Sorted(maps.Keys(otelResource))
+	attrs := make([]attribute.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		if k == "" {
+			return nil, fmt.Errorf("invalid resource attribute =%s, the key is empty", otelResource[k])
+		}
+		attrs = append(attrs, attribute.String(k, otelResource[k]))
+	}
+	return resource.Merge(res, resource.NewSchemaless(attrs...))
 }
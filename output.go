@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter/openmetrics"
+)
+
+// outputFormats are the formats supported by writeReadings.
+var outputFormats = []string{"json", "csv", "openmetrics"}
+
+// outputReading is the representation of a reading in the output formats.
+type outputReading struct {
+	SensorID    string            `json:"sensorId"`
+	Location    string            `json:"location"`
+	Account     string            `json:"account,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Temperature float64           `json:"temperature"`
+	Humidity    float64           `json:"humidity"`
+	Timestamp   time.Time         `json:"timestamp"`
+
+	TimeZone       string     `json:"timeZone,omitempty"`
+	LocalTimestamp *time.Time `json:"localTimestamp,omitempty"`
+
+	Battery              *float64       `json:"battery,omitempty"`
+	SignalStrength       *float64       `json:"signalStrength,omitempty"`
+	CO2                  *float64       `json:"co2,omitempty"`
+	VOC                  *float64       `json:"voc,omitempty"`
+	ExternalTemperatures []egain.Value  `json:"externalTemperatures,omitempty"`
+	Values               []egain.Value  `json:"values,omitempty"`
+	Weather              *egain.Weather `json:"weather,omitempty"`
+	Heating              *egain.Heating `json:"heating,omitempty"`
+	Comfort              *egain.Comfort `json:"comfort,omitempty"`
+	MoldRisk             *float64       `json:"moldRisk,omitempty"`
+	Trend                *egain.Trend   `json:"trend,omitempty"`
+}
+
+// writerExporter writes the readings to w in the output format, e.g. to
+// print them.
+type writerExporter struct {
+	w      io.Writer
+	format string
+}
+
+func (e writerExporter) Export(_ context.Context, readings []*egain.SensorReading) error {
+	return writeReadings(e.w, e.format, readings)
+}
+
+// writeReadings writes the readings to w in the given format.
+func writeReadings(w io.Writer, format string, readings []*egain.SensorReading) error {
+	out := make([]outputReading, 0, len(readings))
+	for _, r := range readings {
+		o := outputReading{
+			SensorID:    r.SensorID,
+			Location:    r.Location,
+			Account:     r.Account,
+			Labels:      r.Labels,
+			Temperature: r.Temperature,
+			Humidity:    r.Humidity,
+			Timestamp:   r.Timestamp,
+
+			Battery:              r.Battery,
+			SignalStrength:       r.SignalStrength,
+			CO2:                  r.CO2,
+			VOC:                  r.VOC,
+			ExternalTemperatures: r.ExternalTemperatures,
+			Values:               r.Values,
+			Weather:              r.Weather,
+			Heating:              r.Heating,
+			Comfort:              r.Comfort,
+			MoldRisk:             r.MoldRisk,
+			Trend:                r.Trend,
+		}
+		if r.TimeZone != nil {
+			t := r.In(r.Timestamp)
+			o.TimeZone = r.TimeZone.String()
+			o.LocalTimestamp = &t
+		}
+		out = append(out, o)
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"sensorId", "location", "temperature", "humidity", "timestamp"})
+		for _, r := range out {
+			cw.Write([]string{
+				r.SensorID,
+				r.Location,
+				strconv.FormatFloat(r.Temperature, 'f', -1, 64),
+				strconv.FormatFloat(r.Humidity, 'f', -1, 64),
+				r.Timestamp.Format(time.RFC3339),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "openmetrics":
+		return openmetrics.Write(w, readings)
+	default:
+		return fmt.Errorf("unknown output format %q, expected one of %v", format, outputFormats)
+	}
+}
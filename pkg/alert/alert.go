@@ -0,0 +1,300 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.uber.org/zap"
+)
+
+// Rule fires an alert if the metric of a matching sensor fulfills the
+// condition for at least the given duration.
+type Rule struct {
+	Name string
+	// SensorID and Location restrict the rule to the matching sensors, the
+	// rule applies to all sensors if both are empty.
+	SensorID string
+	Location string
+
+	// Metric is the name of the measurement, e.g. "temperature", "co2" in
+	// ppm or "voc" in ppb for the sensors reporting them, "mold_risk" if
+	// the mold risk is derived and "temperature_trend" or
+	// "humidity_trend" for the rates of change per hour if the trend is
+	// derived, e.g. < -2 for a window left open.
+	Metric string
+	// Condition is one of <, <=, > and >=.
+	Condition string
+	Threshold float64
+	For       time.Duration
+}
+
+// metrics maps the metric names usable in rules to their value, ok is false
+// if the reading lacks the metric.
+var metrics = map[string]func(r *egain.SensorReading) (v float64, ok bool){
+	"temperature": func(r *egain.SensorReading) (float64, bool) { return r.Temperature, true },
+	"humidity":    func(r *egain.SensorReading) (float64, bool) { return r.Humidity, true },
+	"co2": func(r *egain.SensorReading) (float64, bool) {
+		if r.CO2 == nil {
+			return 0, false
+		}
+		return *r.CO2, true
+	},
+	"voc": func(r *egain.SensorReading) (float64, bool) {
+		if r.VOC == nil {
+			return 0, false
+		}
+		return *r.VOC, true
+	},
+	"mold_risk": func(r *egain.SensorReading) (float64, bool) {
+		if r.MoldRisk == nil {
+			return 0, false
+		}
+		return *r.MoldRisk, true
+	},
+	"temperature_trend": func(r *egain.SensorReading) (float64, bool) {
+		if r.Trend == nil {
+			return 0, false
+		}
+		return r.Trend.Temperature, true
+	},
+	"humidity_trend": func(r *egain.SensorReading) (float64, bool) {
+		if r.Trend == nil {
+			return 0, false
+		}
+		return r.Trend.Humidity, true
+	},
+}
+
+var conditions = map[string]func(v, threshold float64) bool{
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+}
+
+// Validate checks that the metric and condition of the rule are known.
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("missing rule name")
+	}
+	if _, ok := metrics[r.Metric]; !ok {
+		return fmt.Errorf("rule %s: unknown metric %q", r.Name, r.Metric)
+	}
+	if _, ok := conditions[r.Condition]; !ok {
+		return fmt.Errorf("rule %s: unknown condition %q", r.Name, r.Condition)
+	}
+	if r.For < 0 {
+		return fmt.Errorf("rule %s: negative duration %s", r.Name, r.For)
+	}
+	return nil
+}
+
+func (r *Rule) matches(s *egain.SensorReading) bool {
+	return (r.SensorID == "" || r.SensorID == s.SensorID) &&
+		(r.Location == "" || r.Location == s.Location)
+}
+
+// Status is the status of an alert.
+type Status string
+
+const (
+	StatusFiring   Status = "firing"
+	StatusResolved Status = "resolved"
+)
+
+// Alert is the payload posted to the webhook when a rule fires or resolves,
+// the notifiers render their messages from its fields.
+type Alert struct {
+	Status    Status    `json:"status"`
+	Rule      string    `json:"rule"`
+	SensorID  string    `json:"sensorId"`
+	Location  string    `json:"location"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Condition string    `json:"condition"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// state is the state of a rule for a single sensor, notified marks the
+// notifiers which were notified of the firing alert.
+type state struct {
+	since    time.Time
+	firing   bool
+	notified []bool
+}
+
+type key struct {
+	rule   int
+	sensor string
+}
+
+// Engine evaluates the rules against the readings it is given and sends the
+// alerts to the notifiers. It implements the exporter interface, so it can be
+// wired up next to the other exporters.
+type Engine struct {
+	rules     []Rule
+	notifiers []Notifier
+	log       *zap.Logger
+
+	mu    sync.Mutex
+	state map[key]*state
+}
+
+type Option func(e *Engine) error
+
+// NewEngine creates an engine sending the alerts of the rules to the
+// notifiers.
+func NewEngine(rules []Rule, notifiers []Notifier, opts ...Option) (*Engine, error) {
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(notifiers) == 0 {
+		return nil, errors.New("missing notifiers of the alerts")
+	}
+
+	e := &Engine{
+		rules:     rules,
+		notifiers: notifiers,
+		log:       zap.L(),
+		state:     map[key]*state{},
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+func WithLogger(l *zap.Logger) Option {
+	return func(e *Engine) error {
+		e.log = l
+		return nil
+	}
+}
+
+// Export evaluates the rules for all readings.
+func (e *Engine) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	for _, r := range readings {
+		// the rules cover the climate metrics, which heating systems lack
+		if r.Heating != nil {
+			continue
+		}
+		for i := range e.rules {
+			if err := e.evaluate(ctx, i, r); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// evaluate evaluates a single rule against the reading. The duration of a
+// rule is measured in sensor time, i.e. between the timestamps of readings.
+func (e *Engine) evaluate(ctx context.Context, i int, r *egain.SensorReading) error {
+	rule := &e.rules[i]
+	if !rule.matches(r) {
+		return nil
+	}
+
+	// readings without the metric, e.g. before the mold risk of a sensor is
+	// known, neither fire nor resolve the alert
+	value, ok := metrics[rule.Metric](r)
+	if !ok {
+		return nil
+	}
+
+	k := key{rule: i, sensor: r.SensorID}
+	s, ok := e.state[k]
+	if !ok {
+		s = &state{}
+		e.state[k] = s
+	}
+
+	if !conditions[rule.Condition](value, rule.Threshold) {
+		if s.firing {
+			// the resolved alert goes to the notifiers of the firing one,
+			// it is sent again with the next reading to those which failed
+			if err := e.notify(ctx, s, e.alert(StatusResolved, rule, r, value, s.since)); err != nil {
+				return err
+			}
+		}
+		*s = state{}
+		return nil
+	}
+
+	if s.since.IsZero() {
+		s.since = r.Timestamp
+	}
+	if !s.firing && r.Timestamp.Sub(s.since) < rule.For {
+		return nil
+	}
+
+	// the notifiers are marked once the alert has been delivered, so it is
+	// sent again with the next reading to those which failed
+	s.firing = true
+	return e.notify(ctx, s, e.alert(StatusFiring, rule, r, value, s.since))
+}
+
+// notify sends a firing alert to the notifiers which were not notified yet,
+// or a resolved alert to those which were.
+func (e *Engine) notify(ctx context.Context, s *state, a Alert) error {
+	if s.notified == nil {
+		s.notified = make([]bool, len(e.notifiers))
+	}
+	firing := a.Status == StatusFiring
+
+	var errs []error
+	logged := false
+	for i, n := range e.notifiers {
+		if s.notified[i] == firing {
+			continue
+		}
+		if !logged {
+			e.log.Info("alert "+string(a.Status),
+				zap.String("rule", a.Rule),
+				zap.String("sensorId", a.SensorID),
+				zap.String("location", a.Location),
+				zap.Float64("value", a.Value),
+			)
+			logged = true
+		}
+		if err := n.Notify(ctx, a); err != nil {
+			errs = append(errs, fmt.Errorf("cannot send alert %s to %s: %w", a.Rule, n.Name(), err))
+			continue
+		}
+		s.notified[i] = firing
+	}
+	return errors.Join(errs...)
+}
+
+func (e *Engine) alert(status Status, rule *Rule, r *egain.SensorReading, value float64, since time.Time) Alert {
+	return Alert{
+		Status:    status,
+		Rule:      rule.Name,
+		SensorID:  r.SensorID,
+		Location:  r.Location,
+		Metric:    rule.Metric,
+		Value:     value,
+		Condition: rule.Condition,
+		Threshold: rule.Threshold,
+		Since:     since,
+		Timestamp: r.Timestamp,
+	}
+}
@@ -0,0 +1,144 @@
+package alert
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// DefaultSubject is the template of the subject of the emails unless
+// another one is given.
+const DefaultSubject = `[{{.Status}}] {{.Rule}} {{.SensorID}}`
+
+// Email sends the messages of the alerts by email via SMTP. The connection is
+// upgraded with STARTTLS if the server supports it, port 465 uses implicit
+// TLS.
+type Email struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+	subject  *Template
+	body     *Template
+	timeout  time.Duration
+}
+
+// NewEmail returns the notifier sending from the address to the recipients
+// with the SMTP server at addr, e.g. smtp.example.com:587. The credentials
+// are optional.
+func NewEmail(addr, username, password, from string, to []string, subject, body *Template) (*Email, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid SMTP address: %w", err)
+	}
+	if from == "" || len(to) == 0 {
+		return nil, errors.New("missing sender or recipients of the alert emails")
+	}
+	for _, a := range append([]string{from}, to...) {
+		if strings.ContainsAny(a, "\r\n") {
+			return nil, fmt.Errorf("invalid email address %q", a)
+		}
+	}
+	return &Email{
+		addr:     addr,
+		username: username,
+		password: password,
+		from:     from,
+		to:       to,
+		subject:  subject,
+		body:     body,
+		timeout:  30 * time.Second,
+	}, nil
+}
+
+func (e *Email) Name() string { return "email" }
+
+func (e *Email) Notify(ctx context.Context, a Alert) error {
+	subject, err := e.subject.Render(a)
+	if err != nil {
+		return err
+	}
+	body, err := e.body.Render(a)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	host, port, _ := net.SplitHostPort(e.addr)
+
+	var conn net.Conn
+	if port == "465" {
+		d := tls.Dialer{Config: &tls.Config{ServerName: host}}
+		conn, err = d.DialContext(ctx, "tcp", e.addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", e.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %w", e.addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	// PlainAuth refuses to send the credentials without TLS, except to
+	// localhost
+	if e.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.message(subject, body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message returns the message with its headers.
+func (e *Email) message(subject, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + e.from + "\r\n")
+	b.WriteString("To: " + strings.Join(e.to, ", ") + "\r\n")
+	// the subject may hold any character of the template and the readings
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
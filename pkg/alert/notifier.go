@@ -0,0 +1,111 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Notifier delivers the alerts of the engine, e.g. to a chat or by email.
+type Notifier interface {
+	// Name names the notifier in errors and logs, e.g. "slack".
+	Name() string
+	Notify(ctx context.Context, a Alert) error
+}
+
+// DefaultTemplate is the template of the messages of the notifiers unless
+// another one is given.
+const DefaultTemplate = `[{{.Status}}] {{.Rule}}: {{.Metric}} of {{.SensorID}}{{with .Location}} ({{.}}){{end}} is {{.Value}}, threshold {{.Condition}} {{.Threshold}}`
+
+// Template renders the messages of the alerts, the fields of the Alert are
+// available, e.g. {{.SensorID}}, {{.Location}}, {{.Value}} and
+// {{.Threshold}}.
+type Template struct {
+	tmpl *template.Template
+}
+
+// NewTemplate parses the text of a template, DefaultTemplate if empty.
+func NewTemplate(text string) (*Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	t, err := template.New("alert").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert template: %w", err)
+	}
+	// an invalid field is only reported on execution
+	if err := t.Execute(io.Discard, Alert{}); err != nil {
+		return nil, fmt.Errorf("invalid alert template: %w", err)
+	}
+	return &Template{tmpl: t}, nil
+}
+
+// Render renders the message of the alert.
+func (t *Template) Render(a Alert) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, a); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// defaultClient is the HTTP client of the notifiers.
+var defaultClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 30 * time.Second}
+
+// postJSON posts the payload and checks the status of the response.
+func postJSON(ctx context.Context, client *http.Client, url string, payload any) error {
+	// keep the conditions readable instead of escaping < and >
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(payload); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Webhook posts the alerts as JSON to a URL.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook returns the notifier posting to the URL, the HTTP client is the
+// default one if nil.
+func NewWebhook(url string, client *http.Client) *Webhook {
+	if client == nil {
+		client = defaultClient
+	}
+	return &Webhook{url: url, client: client}
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, w.client, w.url, a)
+}
@@ -0,0 +1,33 @@
+package alert
+
+import (
+	"context"
+	"net/http"
+)
+
+// Slack posts the messages of the alerts to an incoming webhook of Slack, or
+// of a compatible chat like Mattermost.
+type Slack struct {
+	url    string
+	tmpl   *Template
+	client *http.Client
+}
+
+// NewSlack returns the notifier posting to the incoming webhook, the HTTP
+// client is the default one if nil.
+func NewSlack(webhook string, tmpl *Template, client *http.Client) *Slack {
+	if client == nil {
+		client = defaultClient
+	}
+	return &Slack{url: webhook, tmpl: tmpl, client: client}
+}
+
+func (s *Slack) Name() string { return "slack" }
+
+func (s *Slack) Notify(ctx context.Context, a Alert) error {
+	text, err := s.tmpl.Render(a)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": text})
+}
@@ -0,0 +1,44 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// telegramAPI is the base URL of the Telegram bot API.
+const telegramAPI = "https://api.telegram.org"
+
+// Telegram sends the messages of the alerts to a chat with a Telegram bot.
+type Telegram struct {
+	url    string
+	chatID string
+	tmpl   *Template
+	client *http.Client
+}
+
+// NewTelegram returns the notifier sending to the chat with the bot of the
+// token, the HTTP client is the default one if nil.
+func NewTelegram(token, chatID string, tmpl *Template, client *http.Client) *Telegram {
+	if client == nil {
+		client = defaultClient
+	}
+	return &Telegram{url: telegramAPI + "/bot" + token + "/sendMessage", chatID: chatID, tmpl: tmpl, client: client}
+}
+
+func (t *Telegram) Name() string { return "telegram" }
+
+func (t *Telegram) Notify(ctx context.Context, a Alert) error {
+	text, err := t.tmpl.Render(a)
+	if err != nil {
+		return err
+	}
+	err = postJSON(ctx, t.client, t.url, map[string]string{"chat_id": t.chatID, "text": text})
+	// the URL holds the token of the bot
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = telegramAPI + "/bot***/sendMessage"
+	}
+	return err
+}
@@ -0,0 +1,5 @@
+// Package egainv1 contains the gRPC API of the scraper, generated from
+// readings.proto.
+package egainv1
+
+//go:generate protoc -I ../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative pkg/api/egainv1/readings.proto
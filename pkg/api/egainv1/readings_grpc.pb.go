@@ -0,0 +1,171 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pkg/api/egainv1/readings.proto
+
+package egainv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ReadingsService_GetLatestReadings_FullMethodName = "/egain.v1.ReadingsService/GetLatestReadings"
+	ReadingsService_StreamReadings_FullMethodName    = "/egain.v1.ReadingsService/StreamReadings"
+)
+
+// ReadingsServiceClient is the client API for ReadingsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ReadingsService serves the latest readings the scraper fetched.
+type ReadingsServiceClient interface {
+	// GetLatestReadings returns the latest reading of each sensor.
+	GetLatestReadings(ctx context.Context, in *GetLatestReadingsRequest, opts ...grpc.CallOption) (*GetLatestReadingsResponse, error)
+	// StreamReadings streams every new reading as soon as it is fetched.
+	StreamReadings(ctx context.Context, in *StreamReadingsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Reading], error)
+}
+
+type readingsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReadingsServiceClient(cc grpc.ClientConnInterface) ReadingsServiceClient {
+	return &readingsServiceClient{cc}
+}
+
+func (c *readingsServiceClient) GetLatestReadings(ctx context.Context, in *GetLatestReadingsRequest, opts ...grpc.CallOption) (*GetLatestReadingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetLatestReadingsResponse)
+	err := c.cc.Invoke(ctx, ReadingsService_GetLatestReadings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *readingsServiceClient) StreamReadings(ctx context.Context, in *StreamReadingsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Reading], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ReadingsService_ServiceDesc.Streams[0], ReadingsService_StreamReadings_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamReadingsRequest, Reading]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReadingsService_StreamReadingsClient = grpc.ServerStreamingClient[Reading]
+
+// ReadingsServiceServer is the server API for ReadingsService service.
+// All implementations must embed UnimplementedReadingsServiceServer
+// for forward compatibility.
+//
+// ReadingsService serves the latest readings the scraper fetched.
+type ReadingsServiceServer interface {
+	// GetLatestReadings returns the latest reading of each sensor.
+	GetLatestReadings(context.Context, *GetLatestReadingsRequest) (*GetLatestReadingsResponse, error)
+	// StreamReadings streams every new reading as soon as it is fetched.
+	StreamReadings(*StreamReadingsRequest, grpc.ServerStreamingServer[Reading]) error
+	mustEmbedUnimplementedReadingsServiceServer()
+}
+
+// UnimplementedReadingsServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedReadingsServiceServer struct{}
+
+func (UnimplementedReadingsServiceServer) GetLatestReadings(context.Context, *GetLatestReadingsRequest) (*GetLatestReadingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLatestReadings not implemented")
+}
+func (UnimplementedReadingsServiceServer) StreamReadings(*StreamReadingsRequest, grpc.ServerStreamingServer[Reading]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamReadings not implemented")
+}
+func (UnimplementedReadingsServiceServer) mustEmbedUnimplementedReadingsServiceServer() {}
+func (UnimplementedReadingsServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeReadingsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReadingsServiceServer will
+// result in compilation errors.
+type UnsafeReadingsServiceServer interface {
+	mustEmbedUnimplementedReadingsServiceServer()
+}
+
+func RegisterReadingsServiceServer(s grpc.ServiceRegistrar, srv ReadingsServiceServer) {
+	// If the following call pancis, it indicates UnimplementedReadingsServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ReadingsService_ServiceDesc, srv)
+}
+
+func _ReadingsService_GetLatestReadings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestReadingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReadingsServiceServer).GetLatestReadings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReadingsService_GetLatestReadings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReadingsServiceServer).GetLatestReadings(ctx, req.(*GetLatestReadingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReadingsService_StreamReadings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamReadingsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReadingsServiceServer).StreamReadings(m, &grpc.GenericServerStream[StreamReadingsRequest, Reading]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReadingsService_StreamReadingsServer = grpc.ServerStreamingServer[Reading]
+
+// ReadingsService_ServiceDesc is the grpc.ServiceDesc for ReadingsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ReadingsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "egain.v1.ReadingsService",
+	HandlerType: (*ReadingsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLatestReadings",
+			Handler:    _ReadingsService_GetLatestReadings_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReadings",
+			Handler:       _ReadingsService_StreamReadings_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pkg/api/egainv1/readings.proto",
+}
@@ -0,0 +1,298 @@
+// Package archive keeps the raw responses of an HTTP API on disk for a
+// while, so a reading which looks wrong days later can be traced back to
+// exactly what the API returned.
+//
+// An archive is a directory of gzip compressed files of JSON lines, one
+// Record per response. The files are named by the time they were started, a
+// file is rotated once it reaches the maximum file size and the oldest files
+// are deleted once the archive exceeds its maximum size.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the Archive.
+const (
+	DefaultMaxFileSize = 16 << 20
+	DefaultMaxSize     = 256 << 20
+)
+
+const (
+	prefix = "responses-"
+	suffix = ".jsonl.gz"
+	// layout of the time in the names of the files, which sort in the order
+	// they were started
+	layout = "20060102T150405.000000000Z"
+)
+
+// Record is an archived response.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Sensors are the ids of the sensors of the response, several for a
+	// batch request
+	Sensors []string `json:"sensors"`
+	Account string   `json:"account,omitempty"`
+	// Request is the kind of the request, e.g. fetch or batch
+	Request string      `json:"request"`
+	URL     string      `json:"url"`
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header,omitempty"`
+	Body    string      `json:"body"`
+}
+
+// skipHeaders are not archived, as they may hold credentials.
+var skipHeaders = []string{"Set-Cookie", "Www-Authenticate"}
+
+// Archive writes the records to the files of the directory.
+type Archive struct {
+	dir         string
+	maxFileSize int64
+	maxSize     int64
+	now         func() time.Time
+
+	mu   sync.Mutex
+	f    *os.File
+	gz   *gzip.Writer
+	size int64
+}
+
+type Option func(a *Archive) error
+
+// New creates an archive in the directory, which is created if needed. A new
+// file is started, the files of previous runs are kept within the maximum
+// size.
+func New(dir string, opts ...Option) (*Archive, error) {
+	if dir == "" {
+		return nil, errors.New("missing directory of the archive")
+	}
+	a := &Archive{
+		dir:         dir,
+		maxFileSize: DefaultMaxFileSize,
+		maxSize:     DefaultMaxSize,
+		now:         time.Now,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.maxFileSize > a.maxSize {
+		return nil, fmt.Errorf("the maximum file size %d of the archive is larger than its maximum size %d", a.maxFileSize, a.maxSize)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create archive: %w", err)
+	}
+	return a, nil
+}
+
+// WithMaxFileSize rotates the files once their compressed size reaches n
+// bytes, DefaultMaxFileSize by default.
+func WithMaxFileSize(n int64) Option {
+	return func(a *Archive) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid maximum file size %d of the archive", n)
+		}
+		a.maxFileSize = n
+		return nil
+	}
+}
+
+// WithMaxSize deletes the oldest files once the files of the archive exceed n
+// bytes, DefaultMaxSize by default.
+func WithMaxSize(n int64) Option {
+	return func(a *Archive) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid maximum size %d of the archive", n)
+		}
+		a.maxSize = n
+		return nil
+	}
+}
+
+// Write archives the response with its body, which the caller has read. The
+// time of the record is set if it is zero.
+func (a *Archive) Write(r *Record) error {
+	if r.Time.IsZero() {
+		r.Time = a.now()
+	}
+	if r.Header != nil {
+		r.Header = r.Header.Clone()
+		for _, h := range skipHeaders {
+			r.Header.Del(h)
+		}
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f != nil && a.size >= a.maxFileSize {
+		if err := a.close(); err != nil {
+			return fmt.Errorf("cannot rotate archive: %w", err)
+		}
+	}
+	if a.f == nil {
+		if err := a.open(); err != nil {
+			return err
+		}
+	}
+	if _, err := a.gz.Write(data); err != nil {
+		return fmt.Errorf("cannot archive response: %w", err)
+	}
+	// the record is flushed, so it survives a crash of the scraper
+	if err := a.gz.Flush(); err != nil {
+		return fmt.Errorf("cannot archive response: %w", err)
+	}
+	st, err := a.f.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot archive response: %w", err)
+	}
+	a.size = st.Size()
+	return nil
+}
+
+// open starts a new file and deletes the oldest files beyond the maximum
+// size, counting the new file at its maximum size.
+func (a *Archive) open() error {
+	if err := a.prune(a.maxSize - a.maxFileSize); err != nil {
+		return err
+	}
+	name := filepath.Join(a.dir, prefix+a.now().UTC().Format(layout)+suffix)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot create archive file: %w", err)
+	}
+	a.f, a.gz, a.size = f, gzip.NewWriter(f), 0
+	return nil
+}
+
+func (a *Archive) close() error {
+	err := errors.Join(a.gz.Close(), a.f.Close())
+	a.f, a.gz = nil, nil
+	return err
+}
+
+// prune deletes the oldest files until the files take up at most n bytes.
+func (a *Archive) prune(n int64) error {
+	names, err := files(a.dir)
+	if err != nil {
+		return err
+	}
+	sizes := make([]int64, len(names))
+	var total int64
+	for i, name := range names {
+		st, err := os.Stat(filepath.Join(a.dir, name))
+		if err != nil {
+			return fmt.Errorf("cannot prune archive: %w", err)
+		}
+		sizes[i] = st.Size()
+		total += sizes[i]
+	}
+	for i := 0; i < len(names) && total > n; i++ {
+		if err := os.Remove(filepath.Join(a.dir, names[i])); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("cannot prune archive: %w", err)
+		}
+		total -= sizes[i]
+	}
+	return nil
+}
+
+// Close closes the current file of the archive.
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return nil
+	}
+	return a.close()
+}
+
+// files returns the names of the files of the archive in the directory, the
+// oldest first.
+func files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read archive: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), suffix) {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Read calls fn with the records of the archive in the directory from the
+// oldest file to the newest, until fn returns false. A file which was cut
+// short, e.g. by a crash, is read up to its last complete record.
+func Read(dir string, fn func(r *Record) bool) error {
+	names, err := files(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		more, err := readFile(filepath.Join(dir, name), fn)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+func readFile(name string, fn func(r *Record) bool) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, fmt.Errorf("cannot read archive: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if errors.Is(err, io.EOF) {
+		// the file was just started
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	defer gz.Close()
+
+	sc := bufio.NewScanner(gz)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return false, fmt.Errorf("cannot read %s: %w", name, err)
+		}
+		if !fn(&r) {
+			return false, nil
+		}
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	return true, nil
+}
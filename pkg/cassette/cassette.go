@@ -0,0 +1,235 @@
+// Package cassette records the responses of an HTTP API to disk and replays
+// them, so responses seen in production can be reproduced deterministically
+// in tests without network access.
+//
+// A cassette is a directory holding one JSON file per response, numbered in
+// the order they were recorded. The responses are matched by the method, path
+// and query of their request, the host is left out so a cassette recorded
+// against one deployment can be replayed with any base URL.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned by the Replayer for requests without a recorded
+// response.
+var ErrNotRecorded = errors.New("no recorded response")
+
+// Interaction is a recorded response along with its request.
+type Interaction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// key matches the interaction with requests.
+func (i *Interaction) key() string {
+	return i.Method + " " + i.URL
+}
+
+// requestKey returns the key of the interactions of the request.
+func requestKey(req *http.Request) string {
+	return req.Method + " " + req.URL.RequestURI()
+}
+
+// skipHeaders are not recorded, as they may hold credentials.
+var skipHeaders = []string{"Set-Cookie", "Www-Authenticate"}
+
+// Recorder is a RoundTripper which records the responses of the wrapped
+// RoundTripper to the cassette.
+type Recorder struct {
+	dir  string
+	next http.RoundTripper
+
+	mu sync.Mutex
+	n  int
+}
+
+var _ http.RoundTripper = (*Recorder)(nil)
+
+// NewRecorder records the responses of next to the directory, which is created
+// if needed. The responses are appended to an existing cassette.
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create cassette: %w", err)
+	}
+	names, err := files(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{dir: dir, next: next}
+	if len(names) > 0 {
+		r.n, _ = strconv.Atoi(strings.TrimSuffix(names[len(names)-1], ".json"))
+	}
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	i := Interaction{
+		Method: req.Method,
+		URL:    req.URL.RequestURI(),
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+		Body:   string(body),
+	}
+	for _, h := range skipHeaders {
+		i.Header.Del(h)
+	}
+	if err := r.write(&i); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) write(i *Interaction) error {
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// several recorders may record to the same cassette, e.g. the clients of
+	// several accounts, existing responses are skipped
+	for {
+		r.n++
+		f, err := os.OpenFile(filepath.Join(r.dir, fmt.Sprintf("%06d.json", r.n)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot record response: %w", err)
+		}
+		_, err = f.Write(data)
+		if err := errors.Join(err, f.Close()); err != nil {
+			return fmt.Errorf("cannot record response: %w", err)
+		}
+		return nil
+	}
+}
+
+// Replayer is a RoundTripper serving the responses of a cassette. The
+// responses of a request are served in the order they were recorded, the last
+// one is repeated once they are used up.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions map[string][]*Interaction
+	served       map[string]int
+}
+
+var _ http.RoundTripper = (*Replayer)(nil)
+
+// NewReplayer loads the cassette in the directory.
+func NewReplayer(dir string) (*Replayer, error) {
+	interactions, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replayer{interactions: map[string][]*Interaction{}, served: map[string]int{}}
+	for _, i := range interactions {
+		r.interactions[i.key()] = append(r.interactions[i.key()], i)
+	}
+	return r, nil
+}
+
+// Load returns the interactions of the cassette in the directory in the order
+// they were recorded.
+func Load(dir string) ([]*Interaction, error) {
+	names, err := files(dir)
+	if err != nil {
+		return nil, err
+	}
+	interactions := make([]*Interaction, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var i Interaction
+		if err := json.Unmarshal(data, &i); err != nil {
+			return nil, fmt.Errorf("invalid recorded response %s: %w", name, err)
+		}
+		interactions = append(interactions, &i)
+	}
+	return interactions, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	k := requestKey(req)
+	r.mu.Lock()
+	recorded := r.interactions[k]
+	n := r.served[k]
+	r.served[k]++
+	r.mu.Unlock()
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("%w for %s", ErrNotRecorded, k)
+	}
+
+	i := recorded[min(n, len(recorded)-1)]
+	header := i.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(i.Body)),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}, nil
+}
+
+// files returns the names of the recorded responses in the directory in the
+// order they were recorded.
+func files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cassette: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
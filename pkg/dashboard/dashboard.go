@@ -0,0 +1,75 @@
+// Package dashboard serves a small web UI of the readings, e.g. for facility
+// staff without access to Grafana. The UI is a static page which polls the
+// readings and the history of the REST API.
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// settings are the settings of the UI, which are served to the page.
+type settings struct {
+	Title           string `json:"title"`
+	TemperatureUnit string `json:"temperatureUnit"`
+	// History enables the sparklines, the history of the REST API is only
+	// registered with a store.
+	History bool `json:"history"`
+	// Refresh is the interval of polling the readings in seconds.
+	Refresh int `json:"refreshSeconds"`
+}
+
+type Option func(s *settings)
+
+// WithTitle sets the title of the page, "Sensors" by default.
+func WithTitle(title string) Option {
+	return func(s *settings) {
+		s.Title = title
+	}
+}
+
+// WithTemperatureUnit sets the unit the temperatures are shown in, e.g. °C.
+func WithTemperatureUnit(unit string) Option {
+	return func(s *settings) {
+		s.TemperatureUnit = unit
+	}
+}
+
+// WithHistory shows sparklines of the last day of each sensor, queried from
+// the history of the REST API.
+func WithHistory() Option {
+	return func(s *settings) {
+		s.History = true
+	}
+}
+
+// Register registers the routes of the UI on the mux:
+//
+//	GET /                      the page of the dashboard
+//	GET /dashboard/            the assets of the page
+//	GET /dashboard/settings    the settings of the page
+//
+// The page requires the routes of restapi.Register, and those of
+// restapi.RegisterHistory for the sparklines.
+func Register(mux *http.ServeMux, opts ...Option) {
+	s := settings{Title: "Sensors", Refresh: 30}
+	// apply the options
+	for _, o := range opts {
+		o(&s)
+	}
+
+	assets, _ := fs.Sub(static, "static")
+	files := http.FileServerFS(assets)
+	mux.Handle("GET /{$}", files)
+	mux.Handle("GET /dashboard/", http.StripPrefix("/dashboard", files))
+	mux.HandleFunc("GET /dashboard/settings", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		json.NewEncoder(w).Encode(s)
+	})
+}
@@ -0,0 +1,130 @@
+// The dashboard polls the latest readings of the REST API and, if the
+// scraper has a store, the history of the last day for the sparklines.
+"use strict";
+
+const historyRefresh = 5 * 60 * 1000;
+
+let settings = { title: "Sensors", temperatureUnit: "", history: false, refreshSeconds: 30 };
+// sparklines holds the points of the sparkline of each sensor
+const sparklines = new Map();
+let historyFetched = 0;
+
+async function getJSON(url) {
+  const resp = await fetch(url, { headers: { Accept: "application/json" } });
+  if (!resp.ok) {
+    throw new Error(`${url}: ${resp.status} ${resp.statusText}`);
+  }
+  return resp.json();
+}
+
+function formatAge(seconds) {
+  if (seconds < 60) return `${Math.max(0, Math.round(seconds))}s ago`;
+  if (seconds < 3600) return `${Math.round(seconds / 60)} min ago`;
+  if (seconds < 86400) return `${Math.round(seconds / 3600)} h ago`;
+  return `${Math.round(seconds / 86400)} days ago`;
+}
+
+// points returns the points of the polyline of the average temperatures.
+function points(buckets) {
+  const values = buckets.map((b) => b.temperature.avg);
+  if (values.length < 2) return "";
+  const min = Math.min(...values);
+  const range = Math.max(...values) - min || 1;
+  return values
+    .map((v, i) => `${(i / (values.length - 1)) * 100},${22 - ((v - min) / range) * 20}`)
+    .join(" ");
+}
+
+async function refreshHistory(readings) {
+  if (!settings.history || Date.now() - historyFetched < historyRefresh) return;
+  historyFetched = Date.now();
+  await Promise.all(readings.map(async (r) => {
+    try {
+      const h = await getJSON(`/api/v1/history?sensor=${encodeURIComponent(r.sensorId)}&step=1h`);
+      sparklines.set(r.sensorId, points(h.points));
+    } catch {
+      sparklines.delete(r.sensorId);
+    }
+  }));
+}
+
+function health(r) {
+  if (r.status === "failing") return ["failing", "Not reachable"];
+  if (r.stale) return ["stale", "Stale"];
+  return ["ok", "OK"];
+}
+
+function render(readings) {
+  const container = document.getElementById("sensors");
+  const tmpl = document.getElementById("sensor");
+  const unit = settings.temperatureUnit ? ` ${settings.temperatureUnit}` : "";
+  let failing = 0;
+
+  const cards = readings.map((r) => {
+    const card = tmpl.content.firstElementChild.cloneNode(true);
+    const [state, label] = health(r);
+    if (state !== "ok") failing++;
+    card.classList.add(state);
+    card.querySelector(".location").textContent = r.location || r.sensorId;
+    card.querySelector(".health").textContent = label;
+    card.querySelector(".id").textContent = r.sensorId;
+
+    if (r.heating) {
+      card.querySelector(".temperature").textContent = `${r.heating.flowTemperature.toFixed(1)}${unit}`;
+      card.querySelector(".humidity").textContent = "flow";
+    } else if (r.temperature !== undefined) {
+      card.querySelector(".temperature").textContent = `${r.temperature.toFixed(1)}${unit}`;
+      card.querySelector(".humidity").textContent = `${Math.round(r.humidity)} %`;
+    } else {
+      card.querySelector(".temperature").textContent = "–";
+    }
+    if (r.ageSeconds !== undefined) {
+      card.querySelector(".age").textContent = formatAge(r.ageSeconds);
+    }
+    if (r.error) {
+      const e = card.querySelector(".error");
+      e.textContent = r.error;
+      e.hidden = false;
+    }
+    const line = sparklines.get(r.sensorId);
+    if (line) {
+      const svg = card.querySelector(".sparkline");
+      svg.querySelector("polyline").setAttribute("points", line);
+      svg.hidden = false;
+    }
+    return card;
+  });
+  container.replaceChildren(...cards);
+
+  document.getElementById("summary").textContent =
+    `${readings.length} sensors, ${failing} need attention, updated ${new Date().toLocaleTimeString()}`;
+}
+
+async function refresh() {
+  const message = document.getElementById("message");
+  try {
+    const readings = await getJSON("/api/v1/readings");
+    readings.sort((a, b) => (a.location || a.sensorId).localeCompare(b.location || b.sensorId));
+    await refreshHistory(readings);
+    render(readings);
+    message.hidden = readings.length > 0;
+    message.textContent = "No readings yet.";
+  } catch (err) {
+    message.hidden = false;
+    message.textContent = `Cannot load the readings: ${err.message}`;
+  }
+}
+
+async function start() {
+  try {
+    settings = await getJSON("/dashboard/settings");
+  } catch {
+    // the defaults are good enough
+  }
+  document.title = settings.title;
+  document.getElementById("title").textContent = settings.title;
+  await refresh();
+  setInterval(refresh, settings.refreshSeconds * 1000);
+}
+
+start();
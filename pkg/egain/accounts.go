@@ -0,0 +1,183 @@
+package egain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Accounts fetches the sensors of several egain accounts, each with a client
+// of its own with its credentials and rate limit. The sensors are routed to
+// the client of their Account, the client of the empty name is the default
+// account.
+type Accounts struct {
+	clients map[string]*Client
+}
+
+// NewAccounts returns the accounts of the clients by their name and
+// distributes the sensors to them.
+func NewAccounts(clients map[string]*Client, sensors []Sensor) (*Accounts, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("no egain accounts")
+	}
+	a := &Accounts{clients: clients}
+	if err := a.SetSensors(sensors); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// client returns the client of the account of the sensor.
+func (a *Accounts) client(s Sensor) (*Client, error) {
+	c, ok := a.clients[s.Account]
+	if !ok {
+		return nil, fmt.Errorf("sensor %s: unknown account %q", s.SensorID, s.Account)
+	}
+	return c, nil
+}
+
+// Sensors returns a copy of the sensors of all accounts.
+func (a *Accounts) Sensors() []Sensor {
+	var sensors []Sensor
+	for _, c := range a.clients {
+		sensors = append(sensors, c.Sensors()...)
+	}
+	return sensors
+}
+
+// LastReadings returns the timestamps of the last readings of the sensors of
+// all accounts, see Client.LastReadings.
+func (a *Accounts) LastReadings() map[string]time.Time {
+	last := map[string]time.Time{}
+	for _, c := range a.clients {
+		maps.Copy(last, c.LastReadings())
+	}
+	return last
+}
+
+// RestoreLastReadings restores the timestamps of the last readings of the
+// sensors of all accounts, see Client.RestoreLastReadings.
+func (a *Accounts) RestoreLastReadings(last map[string]time.Time) {
+	for _, c := range a.clients {
+		c.RestoreLastReadings(last)
+	}
+}
+
+// SetSensors replaces the sensors of all accounts, see Client.SetSensors.
+// Nothing is replaced if a sensor belongs to an unknown account or has an
+// invalid ID.
+func (a *Accounts) SetSensors(s []Sensor) error {
+	if err := ValidateSensors(s); err != nil {
+		return err
+	}
+	byAccount := make(map[string][]Sensor, len(a.clients))
+	for _, sensor := range s {
+		if _, err := a.client(sensor); err != nil {
+			return err
+		}
+		byAccount[sensor.Account] = append(byAccount[sensor.Account], sensor)
+	}
+	for name, c := range a.clients {
+		c.SetSensors(byAccount[name])
+	}
+	return nil
+}
+
+// ValidateInterval validates the interval against the rate limit of each
+// account, see Client.ValidateInterval, and against the limits of the hosts
+// shared by the accounts.
+func (a *Accounts) ValidateInterval(def time.Duration) error {
+	shared := map[*RateLimits][]*Client{}
+	for name, c := range a.clients {
+		if err := c.ValidateInterval(def); err != nil {
+			if name != "" {
+				return fmt.Errorf("account %s: %w", name, err)
+			}
+			return err
+		}
+		shared[c.limits] = append(shared[c.limits], c)
+	}
+	for limits, clients := range shared {
+		if err := limits.validateHosts(clients, def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fetch fetches the readings of the sensors of all accounts.
+func (a *Accounts) Fetch(ctx context.Context) ([]*SensorReading, error) {
+	return a.FetchSensors(ctx, a.Sensors())
+}
+
+// FetchSensors fetches the given sensors, the accounts are fetched
+// concurrently as they are rate limited independently. Like
+// Client.FetchSensors, it returns partial results alongside the joined
+// errors.
+func (a *Accounts) FetchSensors(ctx context.Context, sensors []Sensor) ([]*SensorReading, error) {
+	byAccount := make(map[*Client][]Sensor, len(a.clients))
+	var errs []error
+	for _, s := range sensors {
+		c, err := a.client(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		byAccount[c] = append(byAccount[c], s)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		readings []*SensorReading
+	)
+	for c, sensors := range byAccount {
+		wg.Add(1)
+		go func(c *Client, sensors []Sensor) {
+			defer wg.Done()
+			r, err := c.FetchSensors(ctx, sensors)
+
+			mu.Lock()
+			defer mu.Unlock()
+			readings = append(readings, r...)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(c, sensors)
+	}
+	wg.Wait()
+	return readings, errors.Join(errs...)
+}
+
+// RefreshMetadata refreshes the metadata of the sensors of all accounts, see
+// Client.RefreshMetadata.
+func (a *Accounts) RefreshMetadata(ctx context.Context) error {
+	var errs []error
+	for _, c := range a.clients {
+		errs = append(errs, c.RefreshMetadata(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// VerifySensors verifies the sensors of all accounts, see
+// Client.VerifySensors.
+func (a *Accounts) VerifySensors(ctx context.Context) error {
+	var errs []error
+	for _, c := range a.clients {
+		errs = append(errs, c.VerifySensors(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// FetchHistory fetches the history of the sensor with the client of its
+// account, see Client.FetchHistory.
+func (a *Accounts) FetchHistory(ctx context.Context, sensor Sensor, from, to time.Time) ([]*SensorReading, error) {
+	c, err := a.client(sensor)
+	if err != nil {
+		return nil, err
+	}
+	return c.FetchHistory(ctx, sensor, from, to)
+}
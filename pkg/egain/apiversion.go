@@ -0,0 +1,133 @@
+package egain
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// APIVersion is the version of the schema of the payloads of the egain API.
+type APIVersion int
+
+const (
+	// APIv1 is the current schema, the measurements are fields of the
+	// reading.
+	APIv1 APIVersion = 1
+	// APIv2 is the alternate schema, which wraps the readings in an envelope
+	// and moves the measurements into an object of values with their units:
+	//
+	//	{"version": 2, "data": {"timestamp": "...", "measurements": {"temperature": {"value": 21.3, "unit": "°C"}}}}
+	APIv2 APIVersion = 2
+)
+
+// versionHeader announces the schema of a response.
+const versionHeader = "X-Api-Version"
+
+// mediaTypes are the media types of the schemas, they are sent in the Accept
+// header to request a schema.
+var mediaTypes = map[APIVersion]string{
+	APIv1: "application/json",
+	APIv2: "application/vnd.egain.v2+json",
+}
+
+// WithAPIVersion requests the schema of the payloads from the API. The
+// payloads are decoded according to the schema the API actually responds
+// with, the requested one is only a preference.
+func WithAPIVersion(v APIVersion) Option {
+	return func(c *Client) error {
+		mt, ok := mediaTypes[v]
+		if !ok {
+			return fmt.Errorf("unknown API version %d", v)
+		}
+		c.header.Set("Accept", mt)
+		return nil
+	}
+}
+
+// detectVersion returns the schema of the response from its headers, or the
+// shape of the payload if the headers do not tell.
+func detectVersion(h http.Header, payload []byte) APIVersion {
+	if v, err := strconv.Atoi(h.Get(versionHeader)); err == nil {
+		return APIVersion(v)
+	}
+	if mt, _, err := mime.ParseMediaType(h.Get("Content-Type")); err == nil && mt == mediaTypes[APIv2] {
+		return APIv2
+	}
+
+	var envelope struct {
+		Version APIVersion      `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(payload, &envelope) == nil && envelope.Version == APIv2 && len(envelope.Data) > 0 {
+		return APIv2
+	}
+	return APIv1
+}
+
+// v1Payload converts the payload of the response into the current schema, so
+// the decoders and the schema checks only deal with one schema. It works on
+// single readings as well as the arrays of the history and the objects of the
+// batch requests.
+func (c *Client) v1Payload(h http.Header, payload []byte) ([]byte, error) {
+	switch v := detectVersion(h, payload); v {
+	case APIv1:
+		return payload, nil
+	case APIv2:
+		if !c.v2.Swap(true) {
+			c.log.Info("egain API responds with the v2 schema")
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported API version %d", ErrDecode, v)
+	}
+	return unwrapV2(payload)
+}
+
+// unwrapV2 converts a payload of the v2 schema into the current schema.
+func unwrapV2(payload []byte) ([]byte, error) {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	var data any
+	if err := json.Unmarshal(envelope.Data, &data); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return json.Marshal(flattenV2(data))
+}
+
+// flattenV2 moves the values of the measurements of v2 readings into the
+// readings, like the unit of v1 is implied by the field. Arrays and objects
+// of readings are flattened element by element.
+func flattenV2(v any) any {
+	switch v := v.(type) {
+	case []any:
+		for i := range v {
+			v[i] = flattenV2(v[i])
+		}
+	case map[string]any:
+		measurements, ok := v["measurements"].(map[string]any)
+		if !ok {
+			for k := range v {
+				if r, ok := v[k].(map[string]any); ok {
+					if _, ok := r["measurements"]; ok {
+						v[k] = flattenV2(r)
+					}
+				}
+			}
+			return v
+		}
+		delete(v, "measurements")
+		for name, m := range measurements {
+			if m, ok := m.(map[string]any); ok {
+				v[name] = m["value"]
+				continue
+			}
+			v[name] = m
+		}
+	}
+	return v
+}
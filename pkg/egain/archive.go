@@ -0,0 +1,59 @@
+package egain
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/archive"
+	"go.uber.org/zap"
+)
+
+// WithArchive keeps the raw responses of the readings of the sensors in the
+// archive, including the error responses, e.g. to inspect what the API
+// returned for a reading which looks wrong days later. The archive may be
+// shared by the clients of several accounts.
+func WithArchive(a *archive.Archive) Option {
+	return func(c *Client) error {
+		c.archive = a
+		return nil
+	}
+}
+
+// archiveResponse archives the response with its body. A failure is only
+// logged, the archive is for debugging and never fails a fetch.
+func (c *Client) archiveResponse(resp *http.Response, request string, sensors []string, body []byte) {
+	if c.archive == nil {
+		return
+	}
+	err := c.archive.Write(&archive.Record{
+		Sensors: sensors,
+		Account: c.account,
+		Request: request,
+		URL:     resp.Request.URL.Redacted(),
+		Status:  resp.StatusCode,
+		Header:  resp.Header,
+		Body:    strings.ToValidUTF8(string(body), "�"),
+	})
+	if err != nil {
+		c.log.Warn("cannot archive response", zap.String("request", request), zap.Error(err))
+	}
+}
+
+// archiveError archives an error response, whose body is restored for the
+// StatusError.
+func (c *Client) archiveError(resp *http.Response, request string, sensors []string) {
+	if c.archive == nil {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBodySize))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	if err != nil {
+		return
+	}
+	c.archiveResponse(resp, request, sensors, body)
+}
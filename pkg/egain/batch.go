@@ -0,0 +1,178 @@
+package egain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// The public API documents no batch endpoint, batch requests are only made
+// for indoor sensors. The batch requests expect
+// GET /api/indoor?ids=<id>,<id> to answer with an object mapping the sensor
+// ids to their readings, deployments without it answer with one of these
+// statuses and the client falls back to one request per sensor.
+var batchUnsupported = map[int]bool{
+	http.StatusBadRequest:       true,
+	http.StatusNotFound:         true,
+	http.StatusMethodNotAllowed: true,
+	http.StatusNotImplemented:   true,
+}
+
+// WithBatchSize fetches up to n sensors in a single request. Sensors missing
+// in a batch response are fetched individually, if the API does not support
+// batch requests at all they are disabled after the first attempt. A size of
+// 0, the default, disables batch requests.
+func WithBatchSize(n int) Option {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("invalid batch size %d", n)
+		}
+		c.batchSize = n
+		return nil
+	}
+}
+
+// fetchBatches fetches the sensors in batches and returns their readings
+// along with the sensors which have to be fetched individually.
+func (c *Client) fetchBatches(ctx context.Context, all []Sensor) (readings []*SensorReading, rest []Sensor) {
+	var sensors []Sensor
+	for _, s := range all {
+		if s.Kind != "" && s.Kind != KindIndoor {
+			rest = append(rest, s)
+			continue
+		}
+		sensors = append(sensors, s)
+	}
+
+	for start := 0; start < len(sensors); start += c.batchSize {
+		batch := sensors[start:min(start+c.batchSize, len(sensors))]
+		if c.noBatch.Load() {
+			rest = append(rest, batch...)
+			continue
+		}
+
+		start := time.Now()
+		data, sc, err := c.fetchBatch(ctx, batch)
+		for i := range batch {
+			attrs := c.metrics.sensorAttributes(&batch[i])
+			c.metrics.attempts.Add(ctx, 1, attrs)
+			c.metrics.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+		}
+		c.metrics.recordError(ctx, err)
+		if err != nil {
+			if !c.noBatch.Load() {
+				c.log.Warn("cannot fetch batch, fetching the sensors individually", zap.Int("sensors", len(batch)), zap.Error(err))
+			}
+			rest = append(rest, batch...)
+			continue
+		}
+
+		for _, s := range batch {
+			d, ok := data[s.SensorID]
+			if !ok {
+				rest = append(rest, s)
+				continue
+			}
+			r := &SensorReading{indoorData: d, Sensor: s, SpanContext: sc}
+			r.CO2, r.VOC = airQuality(d.Values)
+			c.track(ctx, r)
+			c.checkStaleness(r)
+			c.checkClockSkew(r)
+			c.recordFetch(s.SensorID, true, !r.Stale)
+			readings = append(readings, r)
+		}
+	}
+	return readings, rest
+}
+
+// fetchBatch fetches the readings of the sensors in a single request. It
+// returns the span context of the request along with the readings.
+func (c *Client) fetchBatch(ctx context.Context, sensors []Sensor) (data map[string]indoorData, sc trace.SpanContext, err error) {
+	ctx, span := c.tracer.Start(ctx, "egain.FetchBatch", trace.WithAttributes(attribute.Int("sensors", len(sensors))))
+	defer func() { endSpan(span, err) }()
+	sc = span.SpanContext()
+
+	if err := c.throttle.wait(ctx); err != nil {
+		return nil, sc, err
+	}
+
+	// the batch waits for its slowest sensor
+	timeout := c.timeout
+	for _, s := range sensors {
+		timeout = max(timeout, c.timeoutOf(s))
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ids := make([]string, len(sensors))
+	for i := range sensors {
+		ids[i] = sensors[i].SensorID
+	}
+	u := c.baseURL.JoinPath("api", "indoor")
+	u.RawQuery = "ids=" + strings.Join(ids, ",")
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, sc, err
+	}
+	c.setHeaders(req)
+
+	if err := c.waitLimit(ctx, "batch"); err != nil {
+		return nil, sc, err
+	}
+
+	resp, err := c.do(req, "batch", "")
+	if err != nil {
+		return nil, sc, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		c.archiveError(resp, "batch", ids)
+		err := newStatusError(resp)
+		switch {
+		case batchUnsupported[resp.StatusCode]:
+			c.noBatch.Store(true)
+			c.log.Info("egain API does not support batch requests, fetching sensors individually", zap.Int("status", resp.StatusCode))
+		case err.throttled():
+			until := c.throttle.pause(err.RetryAfter)
+			c.metrics.throttled.Add(ctx, 1, c.metrics.account)
+			c.log.Warn("egain API is throttling requests, pausing", zap.Int("status", resp.StatusCode), zap.Time("until", until))
+		}
+		return nil, sc, err
+	}
+
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, sc, err
+	}
+	c.archiveResponse(resp, "batch", ids, body)
+	if body, err = c.v1Payload(resp.Header, body); err != nil {
+		return nil, sc, err
+	}
+	var payloads map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payloads); err != nil {
+		return nil, sc, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	// readings which fail the schema check are left out, so their sensors
+	// are fetched and fail individually
+	data = make(map[string]indoorData, len(payloads))
+	for i := range sensors {
+		payload, ok := payloads[sensors[i].SensorID]
+		if !ok || c.checkSchema(ctx, &sensors[i], payload) != nil {
+			continue
+		}
+		var d indoorData
+		if err := json.Unmarshal(payload, &d); err != nil {
+			return nil, sc, fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+		data[sensors[i].SensorID] = d
+	}
+	return data, sc, nil
+}
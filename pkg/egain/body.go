@@ -0,0 +1,69 @@
+package egain
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// DefaultMaxBodySize is the default maximum size of the body of a response.
+const DefaultMaxBodySize = 10 << 20
+
+// WithMaxBodySize limits the size of the bodies of the responses, larger
+// responses fail with ErrResponseTooLarge instead of being read into memory.
+func WithMaxBodySize(n int64) Option {
+	return func(c *Client) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid maximum body size %d", n)
+		}
+		c.maxBodySize = n
+		return nil
+	}
+}
+
+// readBody reads the body of the response up to the maximum body size.
+func (c *Client) readBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > c.maxBodySize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, c.maxBodySize)
+	}
+	return body, nil
+}
+
+// maxPooledBody is the capacity above which a buffer is not pooled, so a
+// single large response does not stay in memory.
+const maxPooledBody = 64 << 10
+
+// bodyBuffers are the buffers of the bodies of the fetches, which are reused
+// once the readings are decoded.
+var bodyBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// readPooledBody reads the body of the response like readBody into a pooled
+// buffer, which is released with releaseBody once nothing refers to its bytes
+// anymore.
+func (c *Client) readPooledBody(resp *http.Response) (*bytes.Buffer, error) {
+	b := bodyBuffers.Get().(*bytes.Buffer)
+	b.Reset()
+	if _, err := b.ReadFrom(io.LimitReader(resp.Body, c.maxBodySize+1)); err != nil {
+		releaseBody(b)
+		return nil, err
+	}
+	if int64(b.Len()) > c.maxBodySize {
+		releaseBody(b)
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, c.maxBodySize)
+	}
+	return b, nil
+}
+
+// releaseBody returns the buffer to the pool.
+func releaseBody(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBody {
+		return
+	}
+	bodyBuffers.Put(b)
+}
@@ -0,0 +1,76 @@
+package egain
+
+import (
+	"net/http"
+	"sync"
+)
+
+// cachedResponse is the validator and decoded payload of the last response
+// for a sensor.
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	data         SensorReading
+}
+
+// responseCache caches the last response per sensor, so requests can be made
+// conditional and a 304 Not Modified can reuse the previous payload.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+// setConditional adds the conditional headers for the cached response of
+// the sensor, if any.
+func (rc *responseCache) setConditional(req *http.Request, sensorID string) {
+	rc.mu.Lock()
+	e, ok := rc.entries[sensorID]
+	rc.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if e.etag != "" {
+		req.Header.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		req.Header.Set("If-Modified-Since", e.lastModified)
+	}
+}
+
+// get returns the cached payload of the sensor.
+func (rc *responseCache) get(sensorID string) (SensorReading, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	e, ok := rc.entries[sensorID]
+	if !ok {
+		return SensorReading{}, false
+	}
+	return e.data, true
+}
+
+// put caches the payload of the response if it carries a validator.
+func (rc *responseCache) put(sensorID string, resp *http.Response, data SensorReading) {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if etag == "" && lastModified == "" {
+		delete(rc.entries, sensorID)
+		return
+	}
+	e := &cachedResponse{etag: etag, lastModified: lastModified, data: data}
+	if rc.entries == nil {
+		rc.entries = map[string]*cachedResponse{}
+	}
+	rc.entries[sensorID] = e
+}
+
+// remove drops the cached response of the sensor.
+func (rc *responseCache) remove(sensorID string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.entries, sensorID)
+}
@@ -0,0 +1,65 @@
+package egain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Decoded is a payload of the API decoded with Decode.
+type Decoded struct {
+	// Readings are the readings of the payload, one for a reading and
+	// several for the arrays of the history.
+	Readings []*SensorReading
+	// Version is the schema of the payload.
+	Version APIVersion
+	// Unexpected and Missing are the fields of the readings which do not
+	// match the schema of the kind.
+	Unexpected []string
+	Missing    []string
+}
+
+// Decode decodes a saved response of the API with the decoder of the sensor
+// kind, like the client decodes the responses, e.g. to debug questions about
+// the schema offline. The schema of the payload is detected from its shape.
+func Decode(k Kind, payload []byte) (*Decoded, error) {
+	d := &Decoded{Version: detectVersion(http.Header{}, payload)}
+	if d.Version == APIv2 {
+		var err error
+		if payload, err = unwrapV2(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	// the history responds with arrays of readings
+	elements := []json.RawMessage{payload}
+	if trimmed := bytes.TrimSpace(payload); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &elements); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+	}
+
+	spec := k.spec()
+	for i, e := range elements {
+		if unexpected, missing, err := spec.schema.drift(e); err == nil {
+			d.Unexpected = append(d.Unexpected, unexpected...)
+			d.Missing = append(d.Missing, missing...)
+		}
+		r, err := spec.decode(e)
+		if err != nil {
+			if len(elements) > 1 {
+				return nil, fmt.Errorf("%w: reading #%d: %w", ErrDecode, i, err)
+			}
+			return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+		r.Kind = k
+		d.Readings = append(d.Readings, &r)
+	}
+	slices.Sort(d.Unexpected)
+	d.Unexpected = slices.Compact(d.Unexpected)
+	slices.Sort(d.Missing)
+	d.Missing = slices.Compact(d.Missing)
+	return d, nil
+}
@@ -0,0 +1,99 @@
+package egain_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain/egaintest"
+	"golang.org/x/time/rate"
+)
+
+// fixtureTransport answers every request with a fixture without a network
+// round trip, so a benchmark measures the client only.
+type fixtureTransport string
+
+func (t fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(t))),
+		Request:    req,
+	}, nil
+}
+
+// BenchmarkFetch fetches a large fleet of indoor sensors, reporting the
+// allocations of the fetch path, from the API stub and with an in-memory
+// transport without the allocations of the stub and the network.
+func BenchmarkFetch(b *testing.B) {
+	sensors := make([]egain.Sensor, 500)
+	for i := range sensors {
+		id := fmt.Sprintf("ID%05d", i)
+		sensors[i] = egain.Sensor{SensorID: id, Location: "room " + id}
+	}
+
+	b.Run("server", func(b *testing.B) {
+		srv := egaintest.NewServer()
+		defer srv.Close()
+		for _, s := range sensors {
+			srv.SetReading(s.SensorID, egaintest.IndoorWithProbes)
+		}
+		benchmarkFetch(b, sensors, egain.WithBaseURL(srv.URL))
+	})
+	b.Run("transport", func(b *testing.B) {
+		benchmarkFetch(b, sensors, egain.WithHTTPClient(&http.Client{Transport: fixtureTransport(egaintest.IndoorWithProbes)}))
+	})
+}
+
+func benchmarkFetch(b *testing.B, sensors []egain.Sensor, opts ...egain.Option) {
+	client, err := egain.NewFetcher(append(opts,
+		egain.WithSensors(sensors),
+		egain.WithRateLimit(rate.Inf, 1),
+	)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		readings, err := client.Fetch(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(readings) != len(sensors) {
+			b.Fatalf("got %d readings, want %d", len(readings), len(sensors))
+		}
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	srv := egaintest.NewServer()
+	defer srv.Close()
+	srv.SetMetadata("ID1", `{"name":"living room","model":"T1","firmwareVersion":"2.1","apartment":"A1"}`)
+
+	sensor := egain.Sensor{SensorID: "ID1", Kind: egain.KindIndoor}
+	client, err := egain.NewFetcher(egain.WithBaseURL(srv.URL), egain.WithSensors([]egain.Sensor{sensor}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := client.FetchMetadata(context.Background(), sensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := egain.Metadata{Name: "living room", Model: "T1", FirmwareVersion: "2.1", Apartment: "A1"}
+	if *m != want {
+		t.Errorf("got %+v, want %+v", *m, want)
+	}
+	if got := srv.Requests("ID1/info"); got != 1 {
+		t.Errorf("%d requests of the metadata, want 1", got)
+	}
+	if got := srv.Requests("ID1"); got != 0 {
+		t.Errorf("%d requests of the reading, want 0", got)
+	}
+}
@@ -0,0 +1,74 @@
+package egaintest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Fetcher is a fake egain.Fetcher returning the configured readings.
+type Fetcher struct {
+	mu       sync.Mutex
+	readings []*egain.SensorReading
+	err      error
+	calls    int
+
+	// FetchFunc replaces the configured readings if set.
+	FetchFunc func(ctx context.Context) ([]*egain.SensorReading, error)
+}
+
+var _ egain.Fetcher = (*Fetcher)(nil)
+
+// NewFetcher creates a fake returning the given readings.
+func NewFetcher(readings ...*egain.SensorReading) *Fetcher {
+	return &Fetcher{readings: readings}
+}
+
+// SetReadings replaces the readings returned by the next fetches.
+func (f *Fetcher) SetReadings(readings ...*egain.SensorReading) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.readings = readings
+}
+
+// SetError sets the error returned along with the readings, e.g. an
+// *egain.SensorError for partial failures.
+func (f *Fetcher) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Calls returns the number of fetches so far.
+func (f *Fetcher) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *Fetcher) Fetch(ctx context.Context) ([]*egain.SensorReading, error) {
+	f.mu.Lock()
+	f.calls++
+	fn, readings, err := f.FetchFunc, f.readings, f.err
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return readings, err
+}
+
+// Reading creates a reading of the sensor.
+func Reading(sensor egain.Sensor, temperature, humidity float64, timestamp time.Time) *egain.SensorReading {
+	r := &egain.SensorReading{Sensor: sensor}
+	r.Temperature = temperature
+	r.Humidity = humidity
+	r.Timestamp = timestamp
+	r.Installed = true
+	return r
+}
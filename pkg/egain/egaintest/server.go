@@ -0,0 +1,95 @@
+package egaintest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Response is a canned response of the API stub.
+type Response struct {
+	Status int
+	Body   string
+	Header http.Header
+}
+
+// Server is an httptest based stub of the egain API. Sensors without a
+// response are answered with 404 Not Found, like unknown sensors of the real
+// API.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]Response
+	requests  map[string]int
+}
+
+// NewServer starts an API stub, it has to be closed by the caller. Use the
+// URL of the server as the base URL of the client.
+func NewServer() *Server {
+	s := &Server{
+		responses: map[string]Response{},
+		requests:  map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// SetReading answers requests for the sensor with the given payload, e.g.
+// one of the fixtures.
+func (s *Server) SetReading(sensorID, body string) {
+	s.SetResponse(sensorID, Response{Status: http.StatusOK, Body: body})
+}
+
+// SetMetadata answers requests for the metadata of the sensor with the given
+// payload.
+func (s *Server) SetMetadata(sensorID, body string) {
+	s.SetResponse(sensorID+"/info", Response{Status: http.StatusOK, Body: body})
+}
+
+// SetResponse answers requests for the sensor with the given response.
+func (s *Server) SetResponse(sensorID string, r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[sensorID] = r
+}
+
+// Requests returns the number of requests for the sensor so far.
+func (s *Server) Requests(sensorID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[sensorID]
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	// the sensors of all kinds are served, e.g. /api/indoor/<id>
+	rest, ok := strings.CutPrefix(req.URL.Path, "/api/")
+	_, id, found := strings.Cut(rest, "/")
+	if !ok || !found || req.Method != http.MethodGet {
+		http.NotFound(w, req)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[id]++
+	r, ok := s.responses[id]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	for k, v := range r.Header {
+		w.Header()[k] = v
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(r.Body))
+}
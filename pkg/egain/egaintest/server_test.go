@@ -0,0 +1,88 @@
+package egaintest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServerRouting(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetReading("ID1", Indoor)
+	srv.SetMetadata("ID1", `{"name":"living room","model":"T1"}`)
+	srv.SetResponse("ID2", Response{Status: http.StatusTooManyRequests})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"reading", http.MethodGet, "/api/indoor/ID1", http.StatusOK, Indoor},
+		{"reading of another kind", http.MethodGet, "/api/outdoor/ID1", http.StatusOK, Indoor},
+		{"metadata", http.MethodGet, "/api/indoor/ID1/info", http.StatusOK, `{"name":"living room","model":"T1"}`},
+		{"canned response", http.MethodGet, "/api/indoor/ID2", http.StatusTooManyRequests, ""},
+		{"metadata without a response", http.MethodGet, "/api/indoor/ID2/info", http.StatusNotFound, ""},
+		{"unknown sensor", http.MethodGet, "/api/indoor/ID3", http.StatusNotFound, ""},
+		{"no sensor", http.MethodGet, "/api/indoor", http.StatusNotFound, ""},
+		{"outside the API", http.MethodGet, "/indoor/ID1", http.StatusNotFound, ""},
+		{"not a GET", http.MethodPost, "/api/indoor/ID1", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && string(body) != tt.wantBody {
+				t.Errorf("body %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	if got := srv.Requests("ID1/info"); got != 1 {
+		t.Errorf("%d requests of the metadata, want 1", got)
+	}
+	// the readings of all kinds count, but not the rejected POST
+	if got := srv.Requests("ID1"); got != 2 {
+		t.Errorf("%d requests of the reading, want 2", got)
+	}
+}
+
+func TestServerHeaders(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetResponse("ID1", Response{Status: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"5"}}})
+	srv.SetReading("ID2", Indoor)
+
+	resp, err := http.Get(srv.URL + "/api/indoor/ID1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After %q, want 5", got)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/indoor/ID2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// the payloads are JSON unless the response sets another content type
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Errorf("Content-Type %q, want application/json", got)
+	}
+}
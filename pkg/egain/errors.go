@@ -0,0 +1,134 @@
+package egain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrSensorNotFound is returned if the API does not know the sensor.
+	ErrSensorNotFound = errors.New("sensor not found")
+	// ErrRateLimited is returned if the API rejected the request because of
+	// too many requests.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUnauthorized is returned if the API rejected the credentials of the
+	// request.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrDecode is returned if the response of the API cannot be decoded. It
+	// is joined with the underlying cause.
+	ErrDecode = errors.New("cannot decode response")
+	// ErrResponseTooLarge is returned if the body of a response exceeds the
+	// maximum body size, e.g. the page of a captive portal.
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+// SensorError is the error of fetching a single sensor. The errors returned
+// by Fetch and FetchSensors join the SensorErrors of all failed sensors, so
+// errors.As can be used to find out which sensors failed.
+type SensorError struct {
+	SensorID string
+	Location string
+	Err      error
+}
+
+func (e *SensorError) Error() string {
+	return fmt.Sprintf("sensor %s: %v", e.SensorID, e.Err)
+}
+
+func (e *SensorError) Unwrap() error {
+	return e.Err
+}
+
+// FailedSensors returns the IDs of the sensors whose SensorError is contained
+// in err.
+func FailedSensors(err error) []string {
+	var ids []string
+	for _, e := range SensorErrors(err) {
+		ids = append(ids, e.SensorID)
+	}
+	return ids
+}
+
+// SensorErrors returns the SensorErrors contained in err, e.g. in the joined
+// errors of FetchSensors.
+func SensorErrors(err error) []*SensorError {
+	var errs []*SensorError
+	var walk func(err error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case *SensorError:
+			errs = append(errs, e)
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return errs
+}
+
+// maxErrorBody is the maximum number of bytes of a response body included in
+// a StatusError.
+const maxErrorBody = 256
+
+// StatusError is returned if the API responds with a non-2xx status code.
+// Common status codes match the corresponding sentinel errors with errors.Is.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Body is the beginning of the response body.
+	Body string
+	// RetryAfter is the pause requested by the API for throttled responses.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected response %s", e.Status)
+	}
+	return fmt.Sprintf("unexpected response %s: %s", e.Status, e.Body)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrSensorNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.throttled()
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// throttled returns whether the API asked to slow down.
+func (e *StatusError) throttled() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		(e.StatusCode == http.StatusServiceUnavailable && e.RetryAfter > 0)
+}
+
+// newStatusError reads the beginning of the body of the response.
+func newStatusError(resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody+1))
+	truncated := len(body) > maxErrorBody
+	if truncated {
+		body = body[:maxErrorBody]
+	}
+	b := strings.TrimSpace(strings.ToValidUTF8(string(body), "?"))
+	if truncated {
+		b += "..."
+	}
+	e := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: b}
+	if resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "") {
+		e.RetryAfter = retryAfter(resp)
+	}
+	return e
+}
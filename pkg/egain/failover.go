@@ -0,0 +1,163 @@
+package egain
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// failoverThreshold is the number of consecutive failed requests after
+	// which the client fails over to the next endpoint.
+	failoverThreshold = 3
+	// probeInterval is the interval at which the primary endpoint is probed
+	// for recovery once the client failed over.
+	probeInterval = time.Minute
+)
+
+// WithFailoverURLs adds mirrors of the egain API, e.g. of other regions. The
+// base URL is the primary endpoint, if it fails repeatedly the client fails
+// over to the next mirror and probes the primary every minute, returning to
+// it once it recovered. Requests fail if they time out, cannot be sent or are
+// answered with a 5xx status.
+func WithFailoverURLs(urls ...string) Option {
+	return func(c *Client) error {
+		for _, u := range urls {
+			mirror, err := url.Parse(u)
+			if err != nil {
+				return fmt.Errorf("invalid failover url: %w", err)
+			}
+			if mirror.Scheme != "http" && mirror.Scheme != "https" {
+				return fmt.Errorf("invalid failover url %q: expected an http or https url", u)
+			}
+			c.mirrors = append(c.mirrors, mirror)
+		}
+		return nil
+	}
+}
+
+// failover is a RoundTripper sending the requests, which are built for the
+// primary endpoint, to the active endpoint.
+type failover struct {
+	next      http.RoundTripper
+	endpoints []*url.URL
+	log       *zap.Logger
+
+	mu        sync.Mutex
+	active    int
+	failures  int
+	probing   bool
+	nextProbe time.Time
+}
+
+// activeEndpoint returns the endpoint the requests are sent to.
+func (c *Client) activeEndpoint() *url.URL {
+	if c.failover == nil {
+		return c.baseURL
+	}
+	c.failover.mu.Lock()
+	defer c.failover.mu.Unlock()
+	return c.failover.endpoints[c.failover.active]
+}
+
+func (f *failover) RoundTrip(req *http.Request) (*http.Response, error) {
+	i, probe := f.pick()
+	if i != 0 {
+		req = req.Clone(req.Context())
+		req.URL = f.rewrite(req.URL, f.endpoints[i])
+		req.Host = ""
+	}
+
+	resp, err := f.next.RoundTrip(req)
+	switch {
+	case req.Context().Err() != nil:
+		// cancelled requests tell nothing about the endpoint
+		f.cancelled(probe)
+	case err != nil:
+		f.done(i, probe, err)
+	case resp.StatusCode >= 500:
+		f.done(i, probe, fmt.Errorf("unexpected response %s", resp.Status))
+	default:
+		f.done(i, probe, nil)
+	}
+	return resp, err
+}
+
+// pick returns the endpoint of the next request, the primary is probed
+// instead of the active endpoint once the probe interval passed.
+func (f *failover) pick() (i int, probe bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active != 0 && !f.probing && !time.Now().Before(f.nextProbe) {
+		f.probing = true
+		return 0, true
+	}
+	return f.active, false
+}
+
+// cancelled releases the probe of a cancelled request, so the primary is
+// probed again with the next request.
+func (f *failover) cancelled(probe bool) {
+	if !probe {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.probing = false
+}
+
+// done records the outcome of a request to the endpoint.
+func (f *failover) done(i int, probe bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if probe {
+		f.probing = false
+		if err != nil {
+			f.nextProbe = time.Now().Add(probeInterval)
+			f.log.Debug("primary egain endpoint is still failing", zap.String("endpoint", f.endpoints[0].Redacted()), zap.Error(err))
+			return
+		}
+		f.log.Info("primary egain endpoint recovered, failing back", zap.String("endpoint", f.endpoints[0].Redacted()))
+		f.active, f.failures = 0, 0
+		return
+	}
+
+	if i != f.active {
+		// the client failed over while the request was in flight
+		return
+	}
+	if err == nil {
+		f.failures = 0
+		return
+	}
+	f.failures++
+	if f.failures < failoverThreshold {
+		return
+	}
+	next := (f.active + 1) % len(f.endpoints)
+	f.log.Warn("egain endpoint is failing, failing over",
+		zap.String("endpoint", f.endpoints[f.active].Redacted()),
+		zap.String("next", f.endpoints[next].Redacted()),
+		zap.Int("failures", f.failures),
+		zap.Error(err),
+	)
+	f.active, f.failures = next, 0
+	f.nextProbe = time.Now().Add(probeInterval)
+}
+
+// rewrite replaces the primary endpoint of the URL with the given one,
+// including its path prefix.
+func (f *failover) rewrite(u, endpoint *url.URL) *url.URL {
+	rest := strings.TrimPrefix(u.Path, strings.TrimSuffix(f.endpoints[0].Path, "/"))
+	r := *endpoint
+	r.Path = strings.TrimSuffix(endpoint.Path, "/") + rest
+	r.RawPath = ""
+	r.RawQuery = u.RawQuery
+	return &r
+}
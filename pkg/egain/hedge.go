@@ -0,0 +1,147 @@
+package egain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// The outcomes of the hedged requests.
+const (
+	// hedgeWon is a hedged request which was answered before the original
+	hedgeWon = "won"
+	// hedgeLost is a hedged request which was cancelled as the original was
+	// answered first
+	hedgeLost = "lost"
+	// hedgeLimited is a hedged request which was not sent, as the rate
+	// limit had no token left for it
+	hedgeLimited = "limited"
+)
+
+// WithHedging sends a second attempt of the requests which got no response
+// after the delay, e.g. as a single request occasionally hangs while a retry
+// is answered right away. The first response wins and the other attempt is
+// cancelled. The hedged requests are bounded by the rate limiters, they are
+// only sent if the limiters of the account and the host allow a request right
+// away and the API is not throttling, 0 disables the hedging, which is the
+// default.
+func WithHedging(delay time.Duration) Option {
+	return func(c *Client) error {
+		if delay < 0 {
+			return fmt.Errorf("invalid hedging delay %s", delay)
+		}
+		c.hedgeDelay = delay
+		return nil
+	}
+}
+
+type hedgeResult struct {
+	resp *http.Response
+	err  error
+	// attempt is the index of the attempt, 1 for the hedged request
+	attempt int
+}
+
+// send sends the request, hedged after the hedging delay. Only requests
+// without a body are hedged, as their attempts are identical.
+func (c *Client) send(req *http.Request, request string) (*http.Response, error) {
+	if c.hedgeDelay <= 0 || req.Body != nil && req.Body != http.NoBody {
+		return c.client.Do(req)
+	}
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	attempt := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		r := hedgeResult{attempt: len(cancels) - 1}
+		go func() {
+			r.resp, r.err = c.client.Do(req.Clone(ctx))
+			results <- r
+		}()
+	}
+	attempt()
+
+	timer := time.NewTimer(c.hedgeDelay)
+	defer timer.Stop()
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			if c.throttle.active() || !c.allowHedge() {
+				c.log.Debug("request is slow, but the rate limit allows no hedged request", zap.String("request", request), zap.Duration("after", c.hedgeDelay))
+				c.recordHedge(req.Context(), request, hedgeLimited)
+				continue
+			}
+			c.log.Debug("request is slow, sending a hedged request", zap.String("request", request), zap.Duration("after", c.hedgeDelay))
+			attempt()
+			pending++
+		case r := <-results:
+			pending--
+			// a failed attempt waits for the other one
+			if r.err != nil && pending > 0 {
+				continue
+			}
+
+			for i, cancel := range cancels {
+				if i != r.attempt {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go drainHedge(results, pending)
+			}
+			if len(cancels) > 1 {
+				outcome := hedgeLost
+				if r.attempt == 1 {
+					outcome = hedgeWon
+				}
+				c.recordHedge(req.Context(), request, outcome)
+			}
+			if r.err != nil {
+				cancels[r.attempt]()
+				return nil, r.err
+			}
+			// the attempt is cancelled once its body was read
+			r.resp.Body = &cancelBody{ReadCloser: r.resp.Body, cancel: cancels[r.attempt]}
+			return r.resp, nil
+		}
+	}
+}
+
+// drainHedge closes the responses of the cancelled attempts.
+func drainHedge(results <-chan hedgeResult, n int) {
+	for range n {
+		if r := <-results; r.resp != nil {
+			r.resp.Body.Close()
+		}
+	}
+}
+
+// recordHedge records the outcome of a hedged request.
+func (c *Client) recordHedge(ctx context.Context, request, outcome string) {
+	attrs := []attribute.KeyValue{attribute.String("request", request), attribute.String("outcome", outcome)}
+	if c.account != "" {
+		attrs = append(attrs, attribute.String("account", c.account))
+	}
+	c.metrics.hedged.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// cancelBody cancels the context of the request of the body once it is
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
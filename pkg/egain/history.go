@@ -0,0 +1,103 @@
+package egain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// FetchHistory fetches the historical readings of the sensor between from and
+// to from the history endpoint, ordered by their timestamp. The readings are
+// not tracked as the last reading of the sensor, so they do not affect the
+// change and staleness detection of the regular fetches.
+func (c *Client) FetchHistory(ctx context.Context, sensor Sensor, from, to time.Time) (r []*SensorReading, err error) {
+	ctx, span := c.tracer.Start(ctx, "egain.FetchHistory", trace.WithAttributes(
+		attribute.String("sensor.id", sensor.SensorID),
+		attribute.String("sensor.location", sensor.Location),
+	))
+	defer func() {
+		c.metrics.recordError(ctx, err)
+		span.SetAttributes(attribute.Int("readings", len(r)))
+		endSpan(span, err)
+	}()
+
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid history range %s to %s", from, to)
+	}
+	if err := c.throttle.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeoutOf(sensor))
+	defer cancel()
+
+	u := c.baseURL.JoinPath("api", sensor.Kind.spec().endpoint, sensor.SensorID, "history")
+	u.RawQuery = url.Values{
+		"from": {from.UTC().Format(time.RFC3339)},
+		"to":   {to.UTC().Format(time.RFC3339)},
+	}.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+
+	if err := c.waitLimit(ctx, "history"); err != nil {
+		return nil, err
+	}
+
+	c.log.Debug("fetching history of sensor", zap.String("sensorId", sensor.SensorID), zap.Time("from", from), zap.Time("to", to))
+	resp, err := c.do(req, "history", sensor.SensorID)
+	if err != nil {
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := newStatusError(resp)
+		if err.throttled() {
+			c.throttle.pause(err.RetryAfter)
+			c.metrics.throttled.Add(ctx, 1, c.metrics.account)
+		}
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+	}
+
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+	}
+	if body, err = c.v1Payload(resp.Header, body); err != nil {
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+	}
+	var data []json.RawMessage
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: fmt.Errorf("%w: %w", ErrDecode, err)}
+	}
+
+	decode := sensor.Kind.spec().decode
+	for _, raw := range data {
+		if err := c.checkSchema(ctx, &sensor, raw); err != nil {
+			return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+		}
+		d, err := decode(raw)
+		if err != nil {
+			return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: fmt.Errorf("%w: %w", ErrDecode, err)}
+		}
+		if d.Timestamp.Before(from) || d.Timestamp.After(to) {
+			continue
+		}
+		d.Sensor = sensor
+		d.SpanContext = span.SpanContext()
+		r = append(r, &d)
+	}
+	slices.SortFunc(r, func(a, b *SensorReading) int { return a.Timestamp.Compare(b.Timestamp) })
+	return r, nil
+}
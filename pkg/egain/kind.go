@@ -0,0 +1,172 @@
+package egain
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of a sensor, which determines its endpoint and the schema
+// of its readings.
+type Kind string
+
+const (
+	// KindIndoor are the indoor climate sensors, the default kind.
+	KindIndoor Kind = "indoor"
+	// KindOutdoor are the outdoor and weather sensors.
+	KindOutdoor Kind = "outdoor"
+	// KindHeating are the heating systems of installations which expose
+	// their flow temperatures and valves.
+	KindHeating Kind = "heating"
+)
+
+// kind describes the endpoint of a sensor kind and how to decode its
+// readings.
+type kind struct {
+	// endpoint is the path of the sensors below /api
+	endpoint string
+	decode   func(payload []byte) (SensorReading, error)
+	schema   schema
+}
+
+var kinds = map[Kind]kind{
+	KindIndoor: {
+		endpoint: "indoor",
+		decode:   decodeIndoor,
+		schema:   newSchema(indoorData{}, "temperature", "humidity", "timestamp"),
+	},
+	KindOutdoor: {
+		endpoint: "outdoor",
+		decode:   decodeOutdoor,
+		schema:   newSchema(outdoorData{}, "temperature", "humidity", "timestamp"),
+	},
+	KindHeating: {
+		endpoint: "heating",
+		decode:   decodeHeating,
+		schema:   newSchema(heatingData{}, "timestamp", "flowTemperature"),
+	},
+}
+
+// ParseKind parses the name of a sensor kind, an empty name is the indoor
+// kind.
+func ParseKind(s string) (Kind, error) {
+	if s == "" {
+		return KindIndoor, nil
+	}
+	if _, ok := kinds[Kind(s)]; !ok {
+		names := make([]string, 0, len(kinds))
+		for k := range kinds {
+			names = append(names, string(k))
+		}
+		slices.Sort(names)
+		return "", fmt.Errorf("unknown sensor kind %q, expected one of %v", s, names)
+	}
+	return Kind(s), nil
+}
+
+func (k Kind) spec() kind {
+	if s, ok := kinds[k]; ok {
+		return s
+	}
+	return kinds[KindIndoor]
+}
+
+func (k Kind) String() string {
+	if k == "" {
+		return string(KindIndoor)
+	}
+	return string(k)
+}
+
+func decodeIndoor(payload []byte) (SensorReading, error) {
+	var data indoorData
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return SensorReading{}, err
+	}
+	r := SensorReading{indoorData: data}
+	r.CO2, r.VOC = airQuality(data.Values)
+	return r, nil
+}
+
+// airQuality returns the CO2 and VOC among the values by their units, ppm
+// and ppb, the first value of a unit wins.
+func airQuality(values []Value) (co2, voc *float64) {
+	for _, v := range values {
+		switch {
+		case co2 == nil && strings.EqualFold(v.Unit, "ppm"):
+			co2 = &v.Value
+		case voc == nil && strings.EqualFold(v.Unit, "ppb"):
+			voc = &v.Value
+		}
+	}
+	return co2, voc
+}
+
+// Weather holds the additional measurements of outdoor sensors.
+type Weather struct {
+	// WindSpeed in m/s
+	WindSpeed float64 `json:"windSpeed"`
+	// WindDirection in degrees
+	WindDirection float64 `json:"windDirection"`
+	// Pressure in hPa
+	Pressure float64 `json:"pressure"`
+	// Precipitation in mm
+	Precipitation float64 `json:"precipitation"`
+}
+
+type outdoorData struct {
+	Temperature float64   `json:"temperature"`
+	Humidity    float64   `json:"humidity"`
+	Timestamp   time.Time `json:"timestamp"`
+	Weather
+	DeviceHealth
+}
+
+func decodeOutdoor(payload []byte) (SensorReading, error) {
+	var data outdoorData
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return SensorReading{}, err
+	}
+	reading := SensorReading{Weather: &data.Weather}
+	reading.Temperature = data.Temperature
+	reading.Humidity = data.Humidity
+	reading.Timestamp = data.Timestamp
+	reading.DeviceHealth = data.DeviceHealth
+	reading.Installed = true
+	return reading, nil
+}
+
+// Heating holds the readings of heating systems.
+type Heating struct {
+	// FlowTemperature and ReturnTemperature are the temperatures of the
+	// heating water in °C, FlowSetpoint is the target flow temperature.
+	FlowTemperature   float64 `json:"flowTemperature"`
+	ReturnTemperature float64 `json:"returnTemperature"`
+	FlowSetpoint      float64 `json:"flowSetpoint"`
+	Valves            []Valve `json:"valves"`
+}
+
+// Valve is a valve of a heating system.
+type Valve struct {
+	Name string `json:"name"`
+	// Position is the opening of the valve in percent.
+	Position float64 `json:"position"`
+}
+
+type heatingData struct {
+	Timestamp time.Time `json:"timestamp"`
+	Heating
+}
+
+func decodeHeating(payload []byte) (SensorReading, error) {
+	var data heatingData
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return SensorReading{}, err
+	}
+	reading := SensorReading{Heating: &data.Heating}
+	reading.Timestamp = data.Timestamp
+	reading.Installed = true
+	return reading, nil
+}
@@ -0,0 +1,86 @@
+package egain
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithSpanEvents adds the warnings and errors of the fetches as events to
+// their spans, so a trace shows why a fetch failed without the logs.
+func WithSpanEvents() Option {
+	return func(c *Client) error {
+		c.spanEvents = true
+		return nil
+	}
+}
+
+// logSensor logs the entry correlated with the span of the context and the
+// sensor, if any. The entry gets the trace and span IDs and the context
+// itself, which the OTel log bridge emits the record in, so the logs of a
+// failed fetch are linked to its trace. With span events the entry is added
+// to the span as well.
+func (c *Client) logSensor(ctx context.Context, level zapcore.Level, s *Sensor, msg string, fields ...zap.Field) {
+	if s != nil {
+		// sensorID as in the other warnings and errors of the client
+		fields = append(fields, zap.String("sensorID", s.SensorID), zap.String("location", s.Location))
+		if s.Account != "" {
+			fields = append(fields, zap.String("account", s.Account))
+		}
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if c.spanEvents && span.IsRecording() {
+		span.AddEvent(msg, trace.WithAttributes(eventAttributes(level, fields)...))
+	}
+
+	ce := c.log.Check(level, msg)
+	if ce == nil {
+		return
+	}
+	if sc := span.SpanContext(); sc.IsValid() {
+		fields = append(fields, zap.String("traceId", sc.TraceID().String()), zap.String("spanId", sc.SpanID().String()))
+	}
+	ce.Write(append(fields, contextField(ctx))...)
+}
+
+// contextField passes the context to the cores of the logger. It is skipped
+// by the encoders, while the OTel log bridge takes the span of the record from
+// it.
+func contextField(ctx context.Context) zap.Field {
+	return zap.Field{Key: "context", Type: zapcore.SkipType, Interface: ctx}
+}
+
+// eventAttributes converts the fields of a log entry to the attributes of a
+// span event.
+func eventAttributes(level zapcore.Level, fields []zap.Field) []attribute.KeyValue {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	attrs := make([]attribute.KeyValue, 0, len(enc.Fields)+1)
+	attrs = append(attrs, attribute.String("log.severity", level.String()))
+	for _, k := range slices.Sorted(maps.Keys(enc.Fields)) {
+		switch v := enc.Fields[k].(type) {
+		case string:
+			attrs = append(attrs, attribute.String(k, v))
+		case bool:
+			attrs = append(attrs, attribute.Bool(k, v))
+		case int64:
+			attrs = append(attrs, attribute.Int64(k, v))
+		case int:
+			attrs = append(attrs, attribute.Int(k, v))
+		case float64:
+			attrs = append(attrs, attribute.Float64(k, v))
+		default:
+			attrs = append(attrs, attribute.String(k, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,81 @@
+package egain
+
+import "strconv"
+
+// Measurement is a single named measurement of a reading with its unit.
+type Measurement struct {
+	// Name is the name of the measurement in snake case, e.g. humidity or
+	// flow_temperature.
+	Name  string
+	Value float64
+	// Unit is the symbol of the unit, e.g. °C or %rH.
+	Unit string
+}
+
+// Metrics returns the measurements of the reading, so the exporters do not
+// each pick the fields of the reading by hand. Heating readings carry the
+// temperatures of the heating system and the positions of the valves instead
+// of the temperature and humidity, the other readings their optional weather,
+// device health and derived measurements if they are set. The external
+// temperatures and the additional values are not included, as they are
+// indexed.
+func (r *SensorReading) Metrics() []Measurement {
+	t := r.TemperatureUnit
+	if t == "" {
+		t = "°C"
+	}
+
+	if h := r.Heating; h != nil {
+		m := []Measurement{
+			{"flow_temperature", h.FlowTemperature, t},
+			{"return_temperature", h.ReturnTemperature, t},
+			{"flow_setpoint", h.FlowSetpoint, t},
+		}
+		for i, v := range h.Valves {
+			m = append(m, Measurement{"valve_" + strconv.Itoa(i) + "_position", v.Position, "%"})
+		}
+		return m
+	}
+
+	m := []Measurement{
+		{"temperature", r.Temperature, t},
+		{"humidity", r.Humidity, "%rH"},
+	}
+	if w := r.Weather; w != nil {
+		m = append(m,
+			Measurement{"wind_speed", w.WindSpeed, "m/s"},
+			Measurement{"wind_direction", w.WindDirection, "deg"},
+			Measurement{"pressure", w.Pressure, "hPa"},
+			Measurement{"precipitation", w.Precipitation, "mm"},
+		)
+	}
+	if r.Battery != nil {
+		m = append(m, Measurement{"battery", *r.Battery, "%"})
+	}
+	if r.SignalStrength != nil {
+		m = append(m, Measurement{"signal_strength", *r.SignalStrength, "dBm"})
+	}
+	if r.CO2 != nil {
+		m = append(m, Measurement{"co2", *r.CO2, "ppm"})
+	}
+	if r.VOC != nil {
+		m = append(m, Measurement{"voc", *r.VOC, "ppb"})
+	}
+	if c := r.Comfort; c != nil {
+		m = append(m,
+			Measurement{"dew_point", c.DewPoint, t},
+			Measurement{"absolute_humidity", c.AbsoluteHumidity, "g/m3"},
+			Measurement{"heat_index", c.HeatIndex, t},
+		)
+	}
+	if r.MoldRisk != nil {
+		m = append(m, Measurement{"mold_risk", *r.MoldRisk, "%"})
+	}
+	if tr := r.Trend; tr != nil {
+		m = append(m,
+			Measurement{"temperature_trend", tr.Temperature, t + "/h"},
+			Measurement{"humidity_trend", tr.Humidity, "%/h"},
+		)
+	}
+	return m
+}
@@ -0,0 +1,122 @@
+package egain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Metadata is the inventory information of a sensor device.
+type Metadata struct {
+	Name            string `json:"name"`
+	Model           string `json:"model"`
+	FirmwareVersion string `json:"firmwareVersion"`
+	// Apartment is the reference of the apartment the sensor is installed
+	// in.
+	Apartment string `json:"apartment"`
+}
+
+// FetchMetadata fetches the metadata of the sensor from its info endpoint,
+// e.g. /api/indoor/<id>/info.
+func (c *Client) FetchMetadata(ctx context.Context, sensor Sensor) (m *Metadata, err error) {
+	ctx, span := c.tracer.Start(ctx, "egain.FetchMetadata", trace.WithAttributes(
+		attribute.String("sensor.id", sensor.SensorID),
+	))
+	defer func() {
+		c.metrics.recordError(ctx, err)
+		endSpan(span, err)
+	}()
+
+	if err := c.throttle.wait(ctx); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeoutOf(sensor))
+	defer cancel()
+
+	u := c.baseURL.JoinPath("api", sensor.Kind.spec().endpoint, sensor.SensorID, "info")
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+
+	if err := c.waitLimit(ctx, "metadata"); err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req, "metadata", sensor.SensorID)
+	if err != nil {
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := newStatusError(resp)
+		if err.throttled() {
+			c.throttle.pause(err.RetryAfter)
+			c.metrics.throttled.Add(ctx, 1, c.metrics.account)
+		}
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+	}
+
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+	}
+	var data Metadata
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: fmt.Errorf("%w: %w", ErrDecode, err)}
+	}
+	return &data, nil
+}
+
+// RefreshMetadata fetches the metadata of all configured sensors and attaches
+// it to the sensors, so it is part of their readings. Sensors whose metadata
+// cannot be fetched keep their previous metadata, their errors are joined.
+// Sensors unknown to the info endpoint are skipped.
+func (c *Client) RefreshMetadata(ctx context.Context) error {
+	c.mu.Lock()
+	sensors := slices.Clone(c.sensors)
+	c.mu.Unlock()
+
+	var errs []error
+	for _, s := range sensors {
+		m, err := c.FetchMetadata(ctx, s)
+		if errors.Is(err, ErrSensorNotFound) {
+			c.log.Debug("no metadata of sensor", zap.String("sensorId", s.SensorID))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		c.mu.Lock()
+		if i, ok := c.index[s.SensorID]; ok {
+			c.sensors[i].Metadata = m
+			c.sensors[i].resolveLocation()
+		}
+		c.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// resolveLocation locates a sensor without a configured location by the name
+// of its metadata, or by its apartment if it has no name.
+func (s *Sensor) resolveLocation() {
+	if (s.Location != "" && !s.resolvedLocation) || s.Metadata == nil {
+		return
+	}
+	location := s.Metadata.Name
+	if location == "" {
+		location = s.Metadata.Apartment
+	}
+	s.Location = location
+	s.resolvedLocation = location != ""
+}
@@ -0,0 +1,335 @@
+package egain
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// metrics are the instruments the client uses to report on itself.
+type metrics struct {
+	throttled    metric.Int64Counter
+	attempts     metric.Int64Counter
+	failures     metric.Int64Counter
+	duration     metric.Float64Histogram
+	decodeErrors metric.Int64Counter
+	schemaDrift  metric.Int64Counter
+	limitWait    metric.Float64Histogram
+	// requestLatency and requests are recorded per request of the API
+	requestLatency metric.Float64Histogram
+	requests       metric.Int64Counter
+	// hedged counts the hedged requests by their outcome
+	hedged metric.Int64Counter
+	// readingInterval is the time between the distinct readings of a sensor
+	readingInterval metric.Float64Histogram
+
+	// account holds the attributes of the account of the client, if any
+	account metric.MeasurementOption
+
+	// attrs caches the attribute sets of the measurements
+	attrsMu sync.Mutex
+	attrs   map[attrKey]metric.MeasurementOption
+}
+
+func newMetrics(c *Client, mp metric.MeterProvider) (*metrics, error) {
+	var (
+		m     metrics
+		err   error
+		meter = mp.Meter(instrumentationName)
+	)
+	m.attrs = map[attrKey]metric.MeasurementOption{}
+	m.account = metric.WithAttributes()
+	if c.account != "" {
+		m.account = metric.WithAttributes(attribute.String("account", c.account))
+	}
+
+	m.throttled, err = meter.Int64Counter("egain.requests.throttled",
+		metric.WithDescription("The number of requests the egain API responded to with a throttling status"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.attempts, err = meter.Int64Counter("egain.fetch.attempts",
+		metric.WithDescription("The number of requests for the readings of a sensor, including retries and batch requests"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.failures, err = meter.Int64Counter("egain.fetch.failures",
+		metric.WithDescription("The number of fetches of a sensor which failed, i.e. returned no reading"),
+		metric.WithUnit("{fetch}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.duration, err = meter.Float64Histogram("egain.fetch.duration",
+		metric.WithDescription("The duration of the fetches of a sensor, including the rate limit and retries"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.decodeErrors, err = meter.Int64Counter("egain.decode.errors",
+		metric.WithDescription("The number of API responses which could not be decoded"),
+		metric.WithUnit("{response}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.schemaDrift, err = meter.Int64Counter("egain.schema.drift",
+		metric.WithDescription("The number of unexpected and missing fields in the payloads of the API"),
+		metric.WithUnit("{field}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.limitWait, err = meter.Float64Histogram("egain.ratelimit.wait",
+		metric.WithDescription("The time the requests to the egain API waited for the rate limiter"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.requestLatency, err = meter.Float64Histogram("egain.request.duration",
+		metric.WithDescription("The latency of the requests to the egain API until the response headers arrived, without the rate limit"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.requests, err = meter.Int64Counter("egain.requests",
+		metric.WithDescription("The number of requests to the egain API by the class of the status of their response, or the type of the error without a response"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.hedged, err = meter.Int64Counter("egain.requests.hedged",
+		metric.WithDescription("The number of hedged requests to the egain API by their outcome, won if it was answered before the original request, lost if not, limited if the rate limit did not allow it"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sensors report every few minutes up to a few hours, the default
+	// boundaries are meant for latencies
+	m.readingInterval, err = meter.Float64Histogram("sensor.reading.interval",
+		metric.WithDescription("The time between the timestamps of the distinct readings of the sensor"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(60, 120, 300, 600, 900, 1800, 3600, 7200, 14400, 43200, 86400),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the limiters may be shared with other clients
+	if err := c.limits.registerMetrics(meter); err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableGauge("egain.sensors",
+		metric.WithDescription("The number of sensors the client is configured with"),
+		metric.WithUnit("{sensor}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			o.Observe(int64(len(c.sensors)), m.account)
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sensors are only reported once they were fetched, so they are not
+	// down right after the start
+	_, err = meter.Int64ObservableGauge("sensor.up",
+		metric.WithDescription("Whether the last fetch of the sensor succeeded with a fresh reading (1) or not (0)"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for i := range c.sensors {
+				s := &c.sensors[i]
+				if !s.fetched {
+					continue
+				}
+				var v int64
+				if s.up {
+					v = 1
+				}
+				o.Observe(v, m.sensorAttributes(s))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the consecutive failures are reset by a successful fetch, so they are
+	// observed as a gauge
+	_, err = meter.Int64ObservableGauge("sensor.consecutive_failures",
+		metric.WithDescription("The number of consecutive failed fetches of the sensor since its last successful fetch"),
+		metric.WithUnit("{fetch}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for i := range c.sensors {
+				if s := &c.sensors[i]; s.fetched {
+					o.Observe(s.failures, m.sensorAttributes(s))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Float64ObservableGauge("sensor.clock_skew",
+		metric.WithDescription("How far the timestamp of the last reading of the sensor was ahead of the local clock"),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for i := range c.sensors {
+				if s := &c.sensors[i]; !s.lastReading.IsZero() {
+					o.Observe(s.skew.Seconds(), m.sensorAttributes(s))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableGauge("sensor.installed",
+		metric.WithDescription("Whether the last reading of the sensor reported it as installed (1) or not (0)"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for i := range c.sensors {
+				s := &c.sensors[i]
+				if !s.reported {
+					continue
+				}
+				var v int64
+				if s.installed {
+					v = 1
+				}
+				o.Observe(v, m.sensorAttributes(s))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableGauge("sensor.info",
+		metric.WithDescription("The metadata of the sensor device as attributes, the value is always 1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for _, s := range c.sensors {
+				m := s.Metadata
+				if m == nil {
+					continue
+				}
+				attrs := []attribute.KeyValue{
+					attribute.String("sensor.id", s.SensorID),
+					attribute.String("sensor.location", s.Location),
+					attribute.String("name", m.Name),
+					attribute.String("model", m.Model),
+					attribute.String("firmware", m.FirmwareVersion),
+					attribute.String("apartment", m.Apartment),
+				}
+				if s.Account != "" {
+					attrs = append(attrs, attribute.String("account", s.Account))
+				}
+				o.Observe(1, metric.WithAttributes(attrs...))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableGauge("egain.throttled",
+		metric.WithDescription("Whether requests to the egain API are paused because of throttling (1) or not (0)"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			var v int64
+			if c.throttle.active() {
+				v = 1
+			}
+			o.Observe(v, m.account)
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// attrKey identifies the attributes of a measurement, empty values are left
+// out.
+type attrKey struct {
+	request, sensorID, account, statusClass, errorType string
+}
+
+// attributes returns the attributes of the key. The attribute sets are
+// cached, as building them for every measurement was a large part of the
+// allocations of the fetches of many sensors.
+func (m *metrics) attributes(k attrKey) metric.MeasurementOption {
+	m.attrsMu.Lock()
+	defer m.attrsMu.Unlock()
+	if o, ok := m.attrs[k]; ok {
+		return o
+	}
+
+	var attrs []attribute.KeyValue
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, attribute.String(key, value))
+		}
+	}
+	add("request", k.request)
+	add("sensor.id", k.sensorID)
+	add("account", k.account)
+	add("status_class", k.statusClass)
+	add("error.type", k.errorType)
+	o := metric.WithAttributeSet(attribute.NewSet(attrs...))
+	m.attrs[k] = o
+	return o
+}
+
+// sensorAttributes returns the attributes of the instruments of the sensor.
+func (m *metrics) sensorAttributes(sensor *Sensor) metric.MeasurementOption {
+	return m.attributes(attrKey{sensorID: sensor.SensorID, account: sensor.Account})
+}
+
+// recordError counts the error if it is a decode error.
+func (m *metrics) recordError(ctx context.Context, err error) {
+	if errors.Is(err, ErrDecode) {
+		m.decodeErrors.Add(ctx, 1, m.account)
+	}
+}
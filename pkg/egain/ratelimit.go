@@ -0,0 +1,248 @@
+package egain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit is the rate and burst of the requests of a partition.
+type RateLimit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+func (l RateLimit) validate() error {
+	if l.Rate <= 0 {
+		return fmt.Errorf("invalid rate limit %v", l.Rate)
+	}
+	if l.Burst < 1 {
+		return fmt.Errorf("invalid rate limit burst %d", l.Burst)
+	}
+	return nil
+}
+
+// The partitions of the rate limiters.
+const (
+	// partitionAccount limits the requests of an account, whatever host
+	// they are sent to.
+	partitionAccount = "account"
+	// partitionHost limits the requests to a host of the API, whatever
+	// account they belong to.
+	partitionHost = "host"
+)
+
+// RateLimits is a registry of the rate limiters of the requests, partitioned
+// by account and by the host of the API. A request waits for the limiter of
+// its account and the limiter of the host it is sent to, so the clients of
+// several accounts share the limit of a host, and the failover to a mirror
+// is subject to the limit of the mirror. Hosts without a limit are not
+// limited.
+type RateLimits struct {
+	hosts       map[string]RateLimit
+	defaultHost *RateLimit
+
+	mu       sync.Mutex
+	limiters map[partitionKey]*rate.Limiter
+	// metrics is set once the gauges of the limiters are registered
+	metrics bool
+}
+
+type partitionKey struct {
+	partition, name string
+}
+
+type RateLimitsOption func(l *RateLimits) error
+
+// NewRateLimits creates a registry of rate limiters, which can be shared by
+// clients with WithRateLimits.
+func NewRateLimits(opts ...RateLimitsOption) (*RateLimits, error) {
+	l := &RateLimits{hosts: map[string]RateLimit{}, limiters: map[partitionKey]*rate.Limiter{}}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(l)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// WithHostRateLimit limits the requests to the host of the API, e.g.
+// api.egain.io, across all clients of the registry. The limit of the empty
+// host applies to the hosts without a limit of their own.
+func WithHostRateLimit(host string, limit RateLimit) RateLimitsOption {
+	return func(l *RateLimits) error {
+		if err := limit.validate(); err != nil {
+			return fmt.Errorf("host %s: %w", host, err)
+		}
+		if host == "" {
+			l.defaultHost = &limit
+			return nil
+		}
+		l.hosts[host] = limit
+		return nil
+	}
+}
+
+// account returns the limiter of the account, which is created with the
+// limit if the account has none yet.
+func (l *RateLimits) account(name string, limit RateLimit) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := partitionKey{partitionAccount, name}
+	if lim, ok := l.limiters[k]; ok {
+		return lim
+	}
+	lim := rate.NewLimiter(limit.Rate, limit.Burst)
+	l.limiters[k] = lim
+	return lim
+}
+
+// hostLimit returns the limit of the host, ok is false if it is not limited.
+func (l *RateLimits) hostLimit(host string) (RateLimit, bool) {
+	if limit, ok := l.hosts[host]; ok {
+		return limit, true
+	}
+	if l.defaultHost != nil {
+		return *l.defaultHost, true
+	}
+	return RateLimit{}, false
+}
+
+// host returns the limiter of the host, or nil if it is not limited.
+func (l *RateLimits) host(name string) *rate.Limiter {
+	limit, ok := l.hostLimit(name)
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := partitionKey{partitionHost, name}
+	lim, ok := l.limiters[k]
+	if !ok {
+		lim = rate.NewLimiter(limit.Rate, limit.Burst)
+		l.limiters[k] = lim
+	}
+	return lim
+}
+
+// registerMetrics registers the gauge of the tokens of the limiters on the
+// meter, once for all clients of the registry.
+func (l *RateLimits) registerMetrics(meter metric.Meter) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.metrics {
+		return nil
+	}
+
+	// the tokens are negative while requests are waiting for them, so the
+	// rate limit is the bottleneck while the gauge stays below one
+	_, err := meter.Float64ObservableGauge("egain.ratelimit.tokens",
+		metric.WithDescription("The number of requests the rate limiter of the partition allows right away"),
+		metric.WithUnit("{request}"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			for k, lim := range l.limiters {
+				o.Observe(lim.Tokens(), metric.WithAttributes(k.attributes()...))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	l.metrics = true
+	return nil
+}
+
+// attributes returns the attributes of the metrics of the partition.
+func (k partitionKey) attributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("ratelimit.partition", k.partition)}
+	switch {
+	case k.partition == partitionHost:
+		attrs = append(attrs, attribute.String("server.address", k.name))
+	case k.name != "":
+		attrs = append(attrs, attribute.String("account", k.name))
+	}
+	return attrs
+}
+
+// WithRateLimits makes the client take its limiters from the registry, e.g.
+// to share the limits of the hosts with the clients of other accounts. The
+// limit of the account of the client is the one of WithRateLimit, unless
+// the registry has a limiter of the account already.
+func WithRateLimits(l *RateLimits) Option {
+	return func(c *Client) error {
+		c.limits = l
+		return nil
+	}
+}
+
+// waitLimit waits for the rate limiters of the account and the active host
+// and records the wait of the request, e.g. fetch or metadata.
+func (c *Client) waitLimit(ctx context.Context, request string) error {
+	start := time.Now()
+	err := c.limit.Wait(ctx)
+	if err == nil {
+		if host := c.limits.host(c.activeEndpoint().Host); host != nil {
+			err = host.Wait(ctx)
+		}
+	}
+	c.metrics.limitWait.Record(ctx, time.Since(start).Seconds(), c.metrics.attributes(attrKey{request: request, account: c.account}))
+	return err
+}
+
+// allowHedge takes the tokens of a hedged request from the limiters of the
+// account and the active host, if both allow a request right away.
+func (c *Client) allowHedge() bool {
+	now := time.Now()
+	account := c.limit.ReserveN(now, 1)
+	if !account.OK() || account.DelayFrom(now) > 0 {
+		account.CancelAt(now)
+		return false
+	}
+	if host := c.limits.host(c.activeEndpoint().Host); host != nil {
+		r := host.ReserveN(now, 1)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			account.CancelAt(now)
+			return false
+		}
+	}
+	return true
+}
+
+// validateHosts checks that the sensors of all clients can be polled at their
+// interval without exceeding the limits of the hosts of the registry. The
+// requests of a client are accounted to the host of its base URL.
+func (l *RateLimits) validateHosts(clients []*Client, def time.Duration) error {
+	needed := map[string]float64{}
+	for _, c := range clients {
+		n, err := c.neededRate(def)
+		if err != nil {
+			return err
+		}
+		needed[c.baseURL.Host] += n
+	}
+	hosts := make([]string, 0, len(needed))
+	for h := range needed {
+		hosts = append(hosts, h)
+	}
+	slices.Sort(hosts)
+	for _, h := range hosts {
+		limit, ok := l.hostLimit(h)
+		if ok && limit.Rate != rate.Inf && needed[h] > float64(limit.Rate) {
+			return fmt.Errorf("polling the sensors of host %s needs %.3f requests/s, but the rate limit of the host allows %.3f requests/s", h, needed[h], float64(limit.Rate))
+		}
+	}
+	return nil
+}
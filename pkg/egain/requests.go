@@ -0,0 +1,110 @@
+package egain
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newRequest creates a request of the path below the base URL, e.g.
+// api/indoor/ID123. The URL is derived from the base URL instead of being
+// joined and parsed again, which took most of the allocations of a request.
+func (c *Client) newRequest(ctx context.Context, method string, elem ...string) *http.Request {
+	u := *c.baseURL
+	n := len(u.Path)
+	for _, e := range elem {
+		n += len(e) + 1
+	}
+	var b strings.Builder
+	b.Grow(n)
+	b.WriteString(strings.TrimSuffix(u.Path, "/"))
+	for _, e := range elem {
+		b.WriteByte('/')
+		b.WriteString(e)
+	}
+	u.Path, u.RawPath = b.String(), ""
+
+	req := &http.Request{
+		Method:     method,
+		URL:        &u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header, len(c.header)+2),
+		Host:       u.Host,
+	}
+	return req.WithContext(ctx)
+}
+
+// do sends the request of the kind, e.g. fetch or metadata, for the sensor,
+// empty for requests of several sensors, and records its latency and outcome.
+// The latency is the time until the headers of the response arrived, so a
+// degrading API is visible apart from the gaps of the sensors. A hedged
+// request is recorded once, with the latency of the first response.
+func (c *Client) do(req *http.Request, request, sensorID string) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.send(req, request)
+	latency := time.Since(start)
+
+	k := attrKey{request: request, sensorID: sensorID, account: c.account}
+	if err != nil {
+		k.statusClass, k.errorType = "none", errorType(err)
+	} else {
+		k.statusClass = statusClass(resp.StatusCode)
+	}
+	attrs := c.metrics.attributes(k)
+	ctx := req.Context()
+	c.metrics.requestLatency.Record(ctx, latency.Seconds(), attrs)
+	c.metrics.requests.Add(ctx, 1, attrs)
+	return resp, err
+}
+
+// statusClass returns the class of the status code, e.g. 2xx.
+func statusClass(code int) string {
+	switch code / 100 {
+	case 1:
+		return "1xx"
+	case 2:
+		return "2xx"
+	case 3:
+		return "3xx"
+	case 4:
+		return "4xx"
+	case 5:
+		return "5xx"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// errorType classifies the errors of requests which got no response.
+func errorType(err error) string {
+	var (
+		dnsErr  *net.DNSError
+		opErr   *net.OpError
+		netErr  net.Error
+		certErr *tls.CertificateVerificationError
+		authErr x509.UnknownAuthorityError
+		hostErr x509.HostnameError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &certErr), errors.As(err, &authErr), errors.As(err, &hostErr):
+		return "tls"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "connection"
+	case errors.As(err, &opErr):
+		return "network"
+	}
+	return "other"
+}
@@ -0,0 +1,334 @@
+package egain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Defaults of the CachingResolver.
+const (
+	DefaultDNSMinTTL = 30 * time.Second
+	DefaultDNSMaxTTL = time.Hour
+)
+
+// CachingResolver caches the addresses of the hosts of the API for the TTL of
+// their DNS records, so not every connection re-resolves the host on slow or
+// flaky DNS. The TTLs are bounded by the minimum and maximum TTL, addresses
+// from the hosts file are cached for the maximum TTL. An expired entry is
+// served if the host cannot be resolved again, as a stale address beats no
+// address. Hosts which do not exist are cached for the negative TTL.
+type CachingResolver struct {
+	minTTL, maxTTL time.Duration
+	negativeTTL    time.Duration
+	log            *zap.Logger
+	dialer         net.Dialer
+	now            func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+	// lookups deduplicates the concurrent lookups of a host
+	lookups map[string]*dnsLookup
+}
+
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+type dnsLookup struct {
+	done  chan struct{}
+	entry *dnsEntry
+}
+
+type ResolverOption func(r *CachingResolver) error
+
+// NewCachingResolver creates a caching resolver, which is used by the
+// connections of the clients with the Resolver of their ConnectionPool.
+func NewCachingResolver(opts ...ResolverOption) (*CachingResolver, error) {
+	r := &CachingResolver{
+		minTTL:  DefaultDNSMinTTL,
+		maxTTL:  DefaultDNSMaxTTL,
+		log:     zap.L(),
+		now:     time.Now,
+		entries: map[string]*dnsEntry{},
+		lookups: map[string]*dnsLookup{},
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.minTTL > r.maxTTL {
+		return nil, fmt.Errorf("the minimum DNS TTL %s is longer than the maximum %s", r.minTTL, r.maxTTL)
+	}
+	return r, nil
+}
+
+// WithTTLBounds bounds the TTLs of the DNS records, DefaultDNSMinTTL and
+// DefaultDNSMaxTTL by default.
+func WithTTLBounds(min, max time.Duration) ResolverOption {
+	return func(r *CachingResolver) error {
+		if min < 0 || max <= 0 {
+			return fmt.Errorf("invalid DNS TTL bounds %s and %s", min, max)
+		}
+		r.minTTL, r.maxTTL = min, max
+		return nil
+	}
+}
+
+// WithNegativeTTL caches the hosts which do not exist for the duration, 0
+// disables the negative cache, which is the default.
+func WithNegativeTTL(d time.Duration) ResolverOption {
+	return func(r *CachingResolver) error {
+		if d < 0 {
+			return fmt.Errorf("invalid negative DNS TTL %s", d)
+		}
+		r.negativeTTL = d
+		return nil
+	}
+}
+
+// WithResolverLogger sets the logger of the resolver.
+func WithResolverLogger(l *zap.Logger) ResolverOption {
+	return func(r *CachingResolver) error {
+		r.log = l
+		return nil
+	}
+}
+
+// LookupIPAddr returns the addresses of the host from the cache, or resolves
+// it if its entry expired.
+func (r *CachingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	r.mu.Lock()
+	e, cached := r.entries[host]
+	if cached && r.now().Before(e.expires) {
+		r.mu.Unlock()
+		return e.addrs, e.err
+	}
+	l, ok := r.lookups[host]
+	if !ok {
+		l = &dnsLookup{done: make(chan struct{})}
+		r.lookups[host] = l
+		go r.lookup(host, l, e)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.entry.addrs, l.entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lookup resolves the host for the waiting lookups, the lookup is not bound
+// to the context of a single dial. The previous entry is served if the host
+// cannot be resolved.
+func (r *CachingResolver) lookup(host string, l *dnsLookup, previous *dnsEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	addrs, ttl, err := r.resolve(ctx, host)
+	entry := &dnsEntry{addrs: addrs, err: err, expires: r.now().Add(ttl)}
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		entry.expires = r.now().Add(r.negativeTTL)
+	case previous != nil && previous.err == nil:
+		r.log.Warn("cannot resolve the host, using the previous addresses", zap.String("host", host), zap.Error(err))
+		entry = &dnsEntry{addrs: previous.addrs, expires: r.now().Add(r.minTTL)}
+	default:
+		// other failures are not cached
+		entry.expires = time.Time{}
+	}
+
+	r.mu.Lock()
+	if !entry.expires.IsZero() {
+		r.entries[host] = entry
+	} else {
+		delete(r.entries, host)
+	}
+	delete(r.lookups, host)
+	r.mu.Unlock()
+
+	l.entry = entry
+	close(l.done)
+}
+
+// resolve resolves the host with the Go resolver, whose DNS responses are
+// inspected for the TTL of their answers.
+func (r *CachingResolver) resolve(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	ttl := &ttlCapture{}
+	res := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := r.dialer.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			c := &ttlConn{Conn: conn, ttl: ttl}
+			// the Go resolver frames the messages by the type of the
+			// connection, so packet connections stay packet connections
+			if pc, ok := conn.(net.PacketConn); ok {
+				return &ttlPacketConn{ttlConn: c, pc: pc}, nil
+			}
+			c.stream = true
+			return c, nil
+		},
+	}
+	addrs, err := res.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	d, ok := ttl.get()
+	if !ok {
+		// the host is in the hosts file
+		d = r.maxTTL
+	}
+	d = min(max(d, r.minTTL), r.maxTTL)
+	r.log.Debug("resolved host", zap.String("host", host), zap.Int("addresses", len(addrs)), zap.Duration("ttl", d))
+	return addrs, d, nil
+}
+
+// DialContext resolves the host of the address and dials its addresses in
+// turn, like the dialer of http.Transport.
+func (r *CachingResolver) DialContext(d *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return d.DialContext(ctx, network, address)
+		}
+		addrs, err := r.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		var errs []error
+		for _, a := range addrs {
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(a.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if len(errs) == 0 {
+			return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// ttlCapture holds the lowest TTL of the answers of the DNS responses of a
+// lookup.
+type ttlCapture struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	ok  bool
+}
+
+func (t *ttlCapture) add(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.ok || d < t.ttl {
+		t.ttl, t.ok = d, true
+	}
+}
+
+func (t *ttlCapture) get() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ttl, t.ok
+}
+
+// ttlConn is a connection to a DNS server which captures the TTLs of the
+// answers of the responses read from it. The responses of stream
+// connections are prefixed with their length.
+type ttlConn struct {
+	net.Conn
+	stream bool
+	ttl    *ttlCapture
+	buf    []byte
+}
+
+func (c *ttlConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		if !c.stream {
+			c.capture(b[:n])
+		} else {
+			c.buf = append(c.buf, b[:n]...)
+			for len(c.buf) >= 2 {
+				size := int(c.buf[0])<<8 | int(c.buf[1])
+				if len(c.buf) < 2+size {
+					break
+				}
+				c.capture(c.buf[2 : 2+size])
+				c.buf = c.buf[2+size:]
+			}
+		}
+	}
+	return n, err
+}
+
+// ttlPacketConn is a ttlConn of a packet connection, e.g. UDP.
+type ttlPacketConn struct {
+	*ttlConn
+	pc net.PacketConn
+}
+
+func (c *ttlPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.pc.ReadFrom(b)
+	if n > 0 {
+		c.capture(b[:n])
+	}
+	return n, addr, err
+}
+
+func (c *ttlPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.pc.WriteTo(b, addr)
+}
+
+// capture captures the TTLs of the address records of the response.
+func (c *ttlConn) capture(msg []byte) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return
+	}
+	for {
+		h, err := p.AnswerHeader()
+		if err != nil {
+			return
+		}
+		if h.Type == dnsmessage.TypeA || h.Type == dnsmessage.TypeAAAA || h.Type == dnsmessage.TypeCNAME {
+			c.ttl.add(time.Duration(h.TTL) * time.Second)
+		}
+		if err := p.SkipAnswer(); err != nil {
+			return
+		}
+	}
+}
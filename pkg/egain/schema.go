@@ -0,0 +1,122 @@
+package egain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrSchemaDrift is returned in strict mode if a payload of the API has
+// unexpected fields or misses expected ones. It is joined with ErrDecode.
+var ErrSchemaDrift = errors.New("schema drift")
+
+// schema are the fields of the payloads of a sensor kind.
+type schema struct {
+	// fields are the lowercase names of all known fields, the API is
+	// matched case-insensitively like encoding/json does
+	fields map[string]bool
+	// required are the fields without which a reading is meaningless
+	required []string
+}
+
+// newSchema collects the JSON fields of the payload struct, including the
+// fields of its embedded structs.
+func newSchema(payload any, required ...string) schema {
+	s := schema{fields: map[string]bool{}, required: required}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if f.Anonymous && name == "" {
+				walk(f.Type)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			s.fields[strings.ToLower(name)] = true
+		}
+	}
+	walk(reflect.TypeOf(payload))
+	return s
+}
+
+// drift returns the unexpected and the missing fields of the payload. Null
+// values of required fields count as missing.
+func (s schema) drift(payload []byte) (unexpected, missing []string, err error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, nil, err
+	}
+	present := map[string]bool{}
+	for name, v := range fields {
+		lower := strings.ToLower(name)
+		if !s.fields[lower] {
+			unexpected = append(unexpected, name)
+		}
+		if string(v) != "null" {
+			present[lower] = true
+		}
+	}
+	for _, name := range s.required {
+		if !present[strings.ToLower(name)] {
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(unexpected)
+	return unexpected, missing, nil
+}
+
+// WithStrictDecoding rejects payloads of the API with unexpected or missing
+// fields with ErrSchemaDrift, instead of only counting and logging them, so
+// changes of the API surface immediately instead of as zero values.
+func WithStrictDecoding() Option {
+	return func(c *Client) error {
+		c.strict = true
+		return nil
+	}
+}
+
+// checkSchema compares the payload of the sensor with the schema of its kind.
+// The drift is counted and logged once per field, in strict mode it fails the
+// decoding. Payloads which are no JSON objects are left to the decoder.
+func (c *Client) checkSchema(ctx context.Context, s *Sensor, payload []byte) error {
+	unexpected, missing, err := s.Kind.spec().schema.drift(payload)
+	if err != nil {
+		return nil
+	}
+
+	report := func(drift string, fields []string) {
+		for _, f := range fields {
+			c.metrics.schemaDrift.Add(ctx, 1, c.metrics.account, metric.WithAttributes(
+				attribute.String("sensor.kind", s.Kind.String()),
+				attribute.String("field", f),
+				attribute.String("drift", drift),
+			))
+			if _, seen := c.drift.LoadOrStore(s.Kind.String()+" "+drift+" "+f, true); !seen {
+				c.logSensor(ctx, zapcore.WarnLevel, s, "the egain API payload does not match the expected schema",
+					zap.String("kind", s.Kind.String()),
+					zap.String("field", f),
+					zap.String("drift", drift),
+				)
+			}
+		}
+	}
+	report("unexpected", unexpected)
+	report("missing", missing)
+
+	if c.strict && (len(unexpected) > 0 || len(missing) > 0) {
+		return fmt.Errorf("%w: %w, unexpected fields %v, missing fields %v", ErrDecode, ErrSchemaDrift, unexpected, missing)
+	}
+	return nil
+}
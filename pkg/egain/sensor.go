@@ -1,27 +1,161 @@
 package egain
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
 
 type Sensor struct {
-	Location    string
-	SensorID    string
+	Location string
+	SensorID string
+	// Kind is the kind of the sensor, an indoor sensor if empty.
+	Kind Kind
+	// Account is the name of the egain account the sensor belongs to, empty
+	// for the default account.
+	Account string
+	// Provider is the name of the provider of the sensor if it is not an
+	// egain sensor, see the providers package.
+	Provider string
+	// Labels are free-form labels of the sensor like its building or floor,
+	// which are attached to its metrics.
+	Labels map[string]string
+	// Metadata is the inventory information of the sensor, it is set once
+	// it was fetched with RefreshMetadata. Sensors without a location are
+	// located by the name or apartment of their metadata.
+	Metadata         *Metadata
+	resolvedLocation bool
+	// Interval overrides the polling interval for this sensor, if set.
+	Interval time.Duration
+	// Schedule is a cron expression overriding the polling interval for
+	// this sensor, if set, e.g. 0 8-18 * * MON-FRI.
+	Schedule string
+	// Timeout overrides the timeout of the client for the requests of this
+	// sensor, if set, e.g. for sensors behind slow gateways.
+	Timeout time.Duration
+	// TimeZone is the time zone of the location of the sensor, if set, e.g.
+	// of a building in another country than the scraper.
+	TimeZone    *time.Location
 	lastReading time.Time
+	// up is set if the last fetch succeeded with a fresh reading, fetched
+	// once the sensor was fetched at all and failures counts the fetches
+	// which failed since the last successful one
+	up       bool
+	fetched  bool
+	failures int64
+	// skew is how far the last reading was ahead of the local clock
+	skew time.Duration
+	// installed is the installed flag of the last reading, reported once a
+	// reading was tracked, uninstalled counts the consecutive readings of a
+	// sensor which is not installed and skipped is set once it exceeded the
+	// limit of the client
+	installed   bool
+	reported    bool
+	uninstalled int
+	skipped     bool
+}
+
+// In returns t in the time zone of the sensor, t as it is if it has none.
+func (s Sensor) In(t time.Time) time.Time {
+	if s.TimeZone == nil {
+		return t
+	}
+	return t.In(s.TimeZone)
 }
 
 type SensorReading struct {
 	indoorData
 	Sensor
+
+	// Unchanged is set if the API returned the same reading as on the
+	// previous fetch of the sensor, i.e. the timestamp did not change.
+	Unchanged bool
+	// Stale is set if the reading is older than the max staleness of the
+	// client.
+	Stale bool
+	// TemperatureUnit is the symbol of the unit of the temperatures, which
+	// are in °C as reported by the API if it is empty.
+	TemperatureUnit string
+
+	// Weather holds the additional measurements of outdoor sensors, it is
+	// nil for the other kinds.
+	Weather *Weather
+	// Heating holds the readings of heating systems, it is nil for the
+	// other kinds. Heating readings have no temperature and humidity.
+	Heating *Heating
+	// Comfort holds the comfort metrics derived from the temperature and
+	// humidity, it is only set by processors.
+	Comfort *Comfort
+	// CO2 is the carbon dioxide concentration in ppm and VOC the
+	// concentration of volatile organic compounds in ppb, which newer
+	// indoor sensors report among their Values. They are nil if the sensor
+	// does not report them.
+	CO2 *float64
+	VOC *float64
+	// MoldRisk is the share of the recent time in percent in which the
+	// conditions allowed mold growth, it is only set by processors.
+	MoldRisk *float64
+	// Trend holds the rates of change of the temperature and humidity, it
+	// is only set by processors.
+	Trend *Trend
+
+	// SpanContext is the span context of the fetch which produced the
+	// reading, it links the metrics of the reading to its trace.
+	SpanContext trace.SpanContext
+}
+
+// Trend are the rates of change per hour of a sensor, the temperature in the
+// unit of the reading and the relative humidity in percentage points.
+type Trend struct {
+	Temperature float64 `json:"temperature"`
+	Humidity    float64 `json:"humidity"`
+}
+
+// Comfort are the comfort metrics derived from a reading, the temperatures
+// are in the unit of the reading.
+type Comfort struct {
+	DewPoint float64 `json:"dewPoint"`
+	// AbsoluteHumidity in g/m³
+	AbsoluteHumidity float64 `json:"absoluteHumidity"`
+	HeatIndex        float64 `json:"heatIndex"`
 }
 
 type indoorData struct {
-	ExternalTemperatures []any     `json:"externalTemperatures"`
+	ExternalTemperatures []Value   `json:"externalTemperatures"`
 	Humidity             float64   `json:"humidity"`
 	Installed            bool      `json:"installed"`
 	Temperature          float64   `json:"temperature"`
 	Timestamp            time.Time `json:"timestamp"`
-	Values               []struct {
-		Value     float64   `json:"value"`
-		Unit      string    `json:"unit"`
-		Timestamp time.Time `json:"timestamp"`
-	} `json:"values"`
+	Values               []Value   `json:"values"`
+	DeviceHealth
+}
+
+// DeviceHealth holds the health of the sensor device, which is only reported
+// by some sensors.
+type DeviceHealth struct {
+	// Battery is the battery level in percent.
+	Battery *float64 `json:"battery,omitempty"`
+	// SignalStrength is the RSSI of the radio link in dBm.
+	SignalStrength *float64 `json:"rssi,omitempty"`
+}
+
+// Value is a single measurement with its unit, e.g. of an external
+// temperature probe or one of the additional values a sensor reports.
+type Value struct {
+	Value     float64   `json:"value"`
+	Unit      string    `json:"unit"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UnmarshalJSON accepts both the object form of a value and plain numbers.
+func (v *Value) UnmarshalJSON(b []byte) error {
+	var f float64
+	if err := json.Unmarshal(b, &f); err == nil {
+		*v = Value{Value: f}
+		return nil
+	}
+
+	type value Value
+	return json.Unmarshal(b, (*value)(v))
 }
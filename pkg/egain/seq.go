@@ -0,0 +1,59 @@
+package egain
+
+import (
+	"context"
+	"iter"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FetchSeq fetches the readings of all configured sensors and yields each
+// reading as soon as it has been fetched, so early readings can be exported
+// while slow sensors are still being fetched. The sensors are fetched
+// concurrently within the rate limit of the client. A failed sensor yields a
+// nil reading with its SensorError. The remaining fetches are cancelled once
+// the loop stops early.
+func (c *Client) FetchSeq(ctx context.Context) iter.Seq2[*SensorReading, error] {
+	return func(yield func(*SensorReading, error) bool) {
+		c.mu.Lock()
+		sensors := slices.Clone(c.sensors)
+		c.mu.Unlock()
+
+		ctx, span := c.tracer.Start(ctx, "egain.FetchSeq", trace.WithAttributes(attribute.Int("sensors", len(sensors))))
+		defer span.End()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		if c.batchSize > 0 {
+			var readings []*SensorReading
+			readings, sensors = c.fetchBatches(ctx, sensors)
+			for _, r := range readings {
+				if !yield(r, nil) {
+					return
+				}
+			}
+		}
+
+		type result struct {
+			reading *SensorReading
+			err     error
+		}
+		// the channel is buffered so the fetches finish even if the loop
+		// stopped early
+		results := make(chan result, len(sensors))
+		for _, sensor := range sensors {
+			go func() {
+				r, err := c.fetch(ctx, &sensor)
+				results <- result{r, err}
+			}()
+		}
+		for range sensors {
+			res := <-results
+			if !yield(res.reading, res.err) {
+				return
+			}
+		}
+	}
+}
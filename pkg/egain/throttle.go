@@ -0,0 +1,76 @@
+package egain
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRetryAfter is the pause after a throttled response without a
+	// Retry-After header.
+	defaultRetryAfter = 30 * time.Second
+	// maxRetryAfter caps the pause requested by the API.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// throttle pauses all requests after the API signalled that it is
+// overloaded.
+type throttle struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// pause pauses the requests for the given duration, unless they are already
+// paused for longer.
+func (t *throttle) pause(d time.Duration) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(t.until) {
+		t.until = until
+	}
+	return t.until
+}
+
+// active returns whether the requests are paused.
+func (t *throttle) active() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Now().Before(t.until)
+}
+
+// wait blocks until the pause is over or the context is done.
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	d := time.Until(t.until)
+	t.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryAfter returns the pause requested by the Retry-After header of the
+// response, which is either a number of seconds or an HTTP date.
+func retryAfter(resp *http.Response) time.Duration {
+	d := defaultRetryAfter
+	if h := resp.Header.Get("Retry-After"); h != "" {
+		if s, err := strconv.Atoi(h); err == nil && s >= 0 {
+			d = time.Duration(s) * time.Second
+		} else if t, err := http.ParseTime(h); err == nil {
+			d = time.Until(t)
+		}
+	}
+	return min(max(d, 0), maxRetryAfter)
+}
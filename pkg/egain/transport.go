@@ -0,0 +1,64 @@
+package egain
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ConnectionPool tunes the connections to the egain API, zero values keep
+// the settings of http.DefaultTransport. With many sensors on a single host
+// more idle connections avoid reconnecting for most requests.
+type ConnectionPool struct {
+	// MaxIdleConnsPerHost is the number of idle connections kept per host.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is the time after which idle connections are closed.
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval of the TCP keep-alive probes, negative to
+	// disable them.
+	KeepAlive time.Duration
+	// DisableHTTP2 only uses HTTP/1.1, even if the API supports HTTP/2.
+	DisableHTTP2 bool
+	// Resolver resolves the hosts of the connections from its cache instead
+	// of the system resolver, nil to resolve every connection.
+	Resolver *CachingResolver
+}
+
+// WithConnectionPool tunes the connections of the default http client.
+func WithConnectionPool(p ConnectionPool) Option {
+	return func(c *Client) error {
+		if p.MaxIdleConnsPerHost < 0 || p.IdleConnTimeout < 0 {
+			return errors.New("invalid connection pool, the idle connections and their timeout must not be negative")
+		}
+		c.pool = &p
+		return nil
+	}
+}
+
+// tune applies the connection pool to the transport.
+func (p *ConnectionPool) tune(t *http.Transport) {
+	if p.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+		t.MaxIdleConns = max(t.MaxIdleConns, p.MaxIdleConnsPerHost)
+	}
+	if p.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = p.IdleConnTimeout
+	}
+	if p.KeepAlive != 0 || p.Resolver != nil {
+		// the dialer of http.DefaultTransport with another keep-alive
+		d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: p.KeepAlive}
+		if p.KeepAlive == 0 {
+			d.KeepAlive = 30 * time.Second
+		}
+		t.DialContext = d.DialContext
+		if p.Resolver != nil {
+			t.DialContext = p.Resolver.DialContext(d)
+		}
+	}
+	if p.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
@@ -0,0 +1,95 @@
+package egain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sensorIDPattern is the format of the sensor IDs, they are part of the URLs
+// of the API and must not contain separators like slashes.
+var sensorIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// ValidateSensors checks the format of the IDs of the sensors, so mistakes in
+// the configuration fail at startup instead of at every fetch. The errors of
+// all invalid IDs are joined.
+func ValidateSensors(sensors []Sensor) error {
+	var errs []error
+	for _, s := range sensors {
+		if !sensorIDPattern.MatchString(s.SensorID) {
+			errs = append(errs, fmt.Errorf("invalid sensor id %q, expected up to 64 letters, digits, '.', '_' or '-'", s.SensorID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// VerifySensors checks that the API knows the configured sensors and accepts
+// the credentials, with a HEAD request per sensor. The SensorErrors of the
+// unknown and forbidden sensors are joined, other failures are only logged
+// as they do not prove the sensor wrong.
+func (c *Client) VerifySensors(ctx context.Context) error {
+	c.mu.Lock()
+	sensors := slices.Clone(c.sensors)
+	c.mu.Unlock()
+
+	var errs []error
+	for _, s := range sensors {
+		err := c.verifySensor(ctx, s)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrSensorNotFound), errors.Is(err, ErrUnauthorized):
+			errs = append(errs, err)
+		case ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			c.logSensor(ctx, zapcore.WarnLevel, &s, "cannot verify sensor", zap.Error(err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Client) verifySensor(ctx context.Context, sensor Sensor) (err error) {
+	ctx, span := c.tracer.Start(ctx, "egain.VerifySensor", trace.WithAttributes(
+		attribute.String("sensor.id", sensor.SensorID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := c.throttle.wait(ctx); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeoutOf(sensor))
+	defer cancel()
+
+	u := c.baseURL.JoinPath("api", sensor.Kind.spec().endpoint, sensor.SensorID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+
+	if err := c.waitLimit(ctx, "verify"); err != nil {
+		return err
+	}
+	resp, err := c.do(req, "verify", sensor.SensorID)
+	if err != nil {
+		return &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: err}
+	}
+	resp.Body.Close()
+
+	// deployments which do not support HEAD requests cannot verify sensors
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &SensorError{SensorID: sensor.SensorID, Location: sensor.Location, Err: newStatusError(resp)}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package azuremonitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// defaultIngestionEndpoint is the ingestion endpoint of connection strings
+// without one.
+const defaultIngestionEndpoint = "https://dc.services.visualstudio.com/"
+
+// AppInsights sends the sensor readings as custom metrics of Azure Monitor
+// to the Application Insights resource of a connection string. The metrics
+// are named like the fields of the readings, e.g. temperature, and have the
+// sensor_id, location, account and the labels of the sensor as dimensions.
+type AppInsights struct {
+	client   *http.Client
+	url      string
+	iKey     string
+	roleName string
+}
+
+type AppInsightsOption func(a *AppInsights) error
+
+// NewAppInsights creates an exporter sending to the Application Insights
+// resource of the connection string, e.g.
+// InstrumentationKey=...;IngestionEndpoint=https://westeurope-5.in.applicationinsights.azure.com/.
+func NewAppInsights(connectionString string, opts ...AppInsightsOption) (*AppInsights, error) {
+	fields := map[string]string{}
+	for _, part := range strings.Split(connectionString, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			fields[strings.ToLower(k)] = v
+		}
+	}
+	if fields["instrumentationkey"] == "" {
+		return nil, errors.New("missing InstrumentationKey in the Application Insights connection string")
+	}
+	endpoint := fields["ingestionendpoint"]
+	if endpoint == "" {
+		endpoint = defaultIngestionEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid IngestionEndpoint %q in the Application Insights connection string", endpoint)
+	}
+
+	a := &AppInsights{
+		client:   &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 30 * time.Second},
+		url:      u.JoinPath("v2.1", "track").String(),
+		iKey:     fields["instrumentationkey"],
+		roleName: "again-scraper-go",
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// WithRoleName sets the cloud role of the telemetry, again-scraper-go by
+// default.
+func WithRoleName(name string) AppInsightsOption {
+	return func(a *AppInsights) error {
+		a.roleName = name
+		return nil
+	}
+}
+
+type envelope struct {
+	Name string            `json:"name"`
+	Time time.Time         `json:"time"`
+	IKey string            `json:"iKey"`
+	Tags map[string]string `json:"tags"`
+	Data envelopeData      `json:"data"`
+}
+
+type envelopeData struct {
+	BaseType string     `json:"baseType"`
+	BaseData metricData `json:"baseData"`
+}
+
+type metricData struct {
+	Ver        int               `json:"ver"`
+	Metrics    []dataPoint       `json:"metrics"`
+	Properties map[string]string `json:"properties"`
+}
+
+type dataPoint struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Count int     `json:"count"`
+}
+
+// trackResponse is the response of the track API.
+type trackResponse struct {
+	ItemsReceived int `json:"itemsReceived"`
+	ItemsAccepted int `json:"itemsAccepted"`
+	Errors        []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (a *AppInsights) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var envelopes []envelope
+	for _, r := range readings {
+		// unchanged readings would be counted twice in the aggregations
+		if r.Unchanged {
+			continue
+		}
+		for _, m := range metrics(r) {
+			envelopes = append(envelopes, a.envelope(r, m))
+		}
+	}
+	if len(envelopes) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(envelopes)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send readings to Application Insights: %w", err)
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		var tr trackResponse
+		json.Unmarshal(msg, &tr)
+		reason := ""
+		if len(tr.Errors) > 0 {
+			reason = ": " + tr.Errors[0].Message
+		}
+		return fmt.Errorf("cannot send all readings to Application Insights: %d of %d metrics accepted%s", tr.ItemsAccepted, tr.ItemsReceived, reason)
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("cannot send readings to Application Insights: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// envelope returns the envelope of a metric of the reading.
+func (a *AppInsights) envelope(r *egain.SensorReading, m dataPoint) envelope {
+	props := map[string]string{"sensor_id": r.SensorID, "kind": r.Kind.String()}
+	for k, v := range r.Labels {
+		props[k] = v
+	}
+	if r.Location != "" {
+		props["location"] = r.Location
+	}
+	if r.Account != "" {
+		props["account"] = r.Account
+	}
+	return envelope{
+		Name: "Microsoft.ApplicationInsights." + strings.ReplaceAll(a.iKey, "-", "") + ".Metric",
+		Time: r.Timestamp.UTC(),
+		IKey: a.iKey,
+		Tags: map[string]string{"ai.cloud.role": a.roleName},
+		Data: envelopeData{
+			BaseType: "MetricData",
+			BaseData: metricData{Ver: 2, Metrics: []dataPoint{m}, Properties: props},
+		},
+	}
+}
+
+// metrics returns the metrics of the reading, the measurements of its
+// Metrics.
+func metrics(r *egain.SensorReading) []dataPoint {
+	var m []dataPoint
+	for _, v := range r.Metrics() {
+		m = append(m, dataPoint{v.Name, v.Value, 1})
+	}
+	return m
+}
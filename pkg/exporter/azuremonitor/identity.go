@@ -0,0 +1,120 @@
+package azuremonitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// imdsEndpoint is the token endpoint of the instance metadata service of
+// virtual machines, scale sets and AKS nodes.
+const imdsEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// ManagedIdentity gets the access tokens of the managed identity of the
+// Azure resource the scraper runs on, from the identity endpoint of App
+// Service and Container Apps if set or else from the instance metadata
+// service.
+type ManagedIdentity struct {
+	client *http.Client
+	// clientID selects a user-assigned identity, the system-assigned one is
+	// used if empty
+	clientID string
+
+	mu      sync.Mutex
+	tokens  map[string]string
+	expires map[string]time.Time
+}
+
+// NewManagedIdentity returns the managed identity of the client ID of a
+// user-assigned identity, or of the system-assigned identity if the client
+// ID is empty.
+func NewManagedIdentity(clientID string) *ManagedIdentity {
+	return &ManagedIdentity{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		clientID: clientID,
+		tokens:   map[string]string{},
+		expires:  map[string]time.Time{},
+	}
+}
+
+// Token returns an access token of the resource, e.g.
+// https://monitor.azure.com. Tokens are cached until shortly before they
+// expire.
+func (m *ManagedIdentity) Token(ctx context.Context, resource string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.tokens[resource]; ok && time.Until(m.expires[resource]) > 5*time.Minute {
+		return t, nil
+	}
+
+	req, err := m.request(ctx, resource)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot get a token of the managed identity: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("cannot get a token of the managed identity: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot get a token of the managed identity: %s: %s", resp.Status, body)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		// ExpiresOn is a number of seconds as string
+		ExpiresOn json.Number `json:"expires_on"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("invalid token of the managed identity: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("empty token of the managed identity")
+	}
+	expires := time.Now().Add(time.Hour)
+	if s, err := strconv.ParseInt(token.ExpiresOn.String(), 10, 64); err == nil {
+		expires = time.Unix(s, 0)
+	}
+	m.tokens[resource], m.expires[resource] = token.AccessToken, expires
+	return token.AccessToken, nil
+}
+
+// request returns the token request of the endpoint of the environment.
+func (m *ManagedIdentity) request(ctx context.Context, resource string) (*http.Request, error) {
+	q := url.Values{"resource": {resource}}
+	if m.clientID != "" {
+		q.Set("client_id", m.clientID)
+	}
+
+	// App Service and Container Apps
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		q.Set("api-version", "2019-08-01")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-IDENTITY-HEADER", header)
+		return req, nil
+	}
+
+	q.Set("api-version", "2018-02-01")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imdsEndpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	return req, nil
+}
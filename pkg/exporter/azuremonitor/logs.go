@@ -0,0 +1,207 @@
+package azuremonitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	// DefaultStream is the stream of the data collection rule the readings
+	// are sent to unless WithStream is given.
+	DefaultStream = "Custom-EgainReadings"
+	// monitorResource is the resource of the tokens of the ingestion API
+	monitorResource = "https://monitor.azure.com"
+	// maxBody is the maximum size of a request to the ingestion API
+	maxBody = 1 << 20
+	// maxAge is how old a TimeGenerated may be for the ingestion API to
+	// accept the row
+	maxAge = 48 * time.Hour
+)
+
+// TokenSource returns access tokens of a resource, e.g. a ManagedIdentity.
+type TokenSource interface {
+	Token(ctx context.Context, resource string) (string, error)
+}
+
+// Logs sends the sensor readings as rows to a Log Analytics workspace with
+// the Logs Ingestion API and a data collection rule. The rows have the
+// columns TimeGenerated, SensorId, Location, Account, Kind, Temperature,
+// Humidity, Battery, SignalStrength and Labels, which the stream of the rule
+// needs to declare.
+type Logs struct {
+	client *http.Client
+	url    *url.URL
+	stream string
+	tokens TokenSource
+}
+
+type LogsOption func(l *Logs) error
+
+// NewLogs creates an exporter sending to the data collection rule of the
+// immutable ID at the data collection endpoint, e.g.
+// https://my-dce-abcd.westeurope-1.ingest.monitor.azure.com. The tokens are
+// those of the system-assigned managed identity unless WithTokenSource is
+// given.
+func NewLogs(endpoint, ruleID string, opts ...LogsOption) (*Logs, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid data collection endpoint %q", endpoint)
+	}
+	if ruleID == "" {
+		return nil, errors.New("missing immutable ID of the data collection rule")
+	}
+
+	l := &Logs{
+		client: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 30 * time.Second},
+		url:    u.JoinPath("dataCollectionRules", ruleID, "streams"),
+		stream: DefaultStream,
+		tokens: NewManagedIdentity(""),
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(l)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l.url = l.url.JoinPath(l.stream)
+	l.url.RawQuery = url.Values{"api-version": {"2023-01-01"}}.Encode()
+	return l, nil
+}
+
+// WithStream sets the stream of the data collection rule, DefaultStream by
+// default.
+func WithStream(stream string) LogsOption {
+	return func(l *Logs) error {
+		if stream == "" {
+			return errors.New("empty stream of the data collection rule")
+		}
+		l.stream = stream
+		return nil
+	}
+}
+
+// WithTokenSource replaces the managed identity the tokens are taken from.
+func WithTokenSource(ts TokenSource) LogsOption {
+	return func(l *Logs) error {
+		l.tokens = ts
+		return nil
+	}
+}
+
+// row is a row of the stream.
+type row struct {
+	TimeGenerated  time.Time         `json:"TimeGenerated"`
+	SensorID       string            `json:"SensorId"`
+	Location       string            `json:"Location"`
+	Account        string            `json:"Account,omitempty"`
+	Kind           string            `json:"Kind"`
+	Temperature    *float64          `json:"Temperature,omitempty"`
+	Humidity       *float64          `json:"Humidity,omitempty"`
+	Battery        *float64          `json:"Battery,omitempty"`
+	SignalStrength *float64          `json:"SignalStrength,omitempty"`
+	Labels         map[string]string `json:"Labels,omitempty"`
+}
+
+// KeepsTimestamps returns true, the rows have the timestamps of the readings
+// as TimeGenerated.
+func (l *Logs) KeepsTimestamps() (bool, time.Duration) {
+	return true, maxAge
+}
+
+func (l *Logs) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var rows []row
+	for _, r := range readings {
+		// the rows are appended, so unchanged readings would be duplicates
+		if r.Unchanged {
+			continue
+		}
+		rw := row{
+			TimeGenerated:  r.Timestamp.UTC(),
+			SensorID:       r.SensorID,
+			Location:       r.Location,
+			Account:        r.Account,
+			Kind:           r.Kind.String(),
+			Battery:        r.Battery,
+			SignalStrength: r.SignalStrength,
+			Labels:         r.Labels,
+		}
+		// heating systems have no temperature and humidity
+		if r.Heating == nil {
+			rw.Temperature, rw.Humidity = &r.Temperature, &r.Humidity
+		}
+		rows = append(rows, rw)
+	}
+
+	// the requests are split to stay below the limit of the API
+	for len(rows) > 0 {
+		body, n, err := encodeRows(rows)
+		if err != nil {
+			return err
+		}
+		if err := l.send(ctx, body); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// encodeRows encodes as many rows as fit into a request, at least one.
+func encodeRows(rows []row) ([]byte, int, error) {
+	buf := bytes.NewBufferString("[")
+	n := 0
+	for _, r := range rows {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n > 0 && buf.Len()+len(b)+2 > maxBody {
+			break
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(b)
+		n++
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), n, nil
+}
+
+func (l *Logs) send(ctx context.Context, body []byte) error {
+	token, err := l.tokens.Token(ctx, monitorResource)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send readings to Azure Monitor: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("cannot send readings to Azure Monitor: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
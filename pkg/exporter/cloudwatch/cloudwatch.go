@@ -0,0 +1,141 @@
+package cloudwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// maxBatch is the maximum number of metrics of a PutMetricData request.
+const maxBatch = 1000
+
+// maxAge is how old a data point may be for CloudWatch to accept it.
+const maxAge = 14 * 24 * time.Hour
+
+// API is the part of the CloudWatch client the exporter uses.
+type API interface {
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// Exporter writes the sensor readings as custom CloudWatch metrics of a
+// namespace, with the dimensions SensorId and, if set, Location and Account,
+// so alarms can be defined on them.
+type Exporter struct {
+	client    API
+	namespace string
+}
+
+type Option func(e *Exporter) error
+
+// New creates an exporter writing to the namespace with the given AWS
+// configuration, e.g. of the default credential chain.
+func New(cfg aws.Config, namespace string, opts ...Option) (*Exporter, error) {
+	if namespace == "" {
+		return nil, errors.New("missing CloudWatch namespace")
+	}
+	if strings.HasPrefix(namespace, "AWS/") {
+		return nil, fmt.Errorf("invalid CloudWatch namespace %q, the AWS/ namespaces are reserved", namespace)
+	}
+
+	e := &Exporter{
+		client:    cloudwatch.NewFromConfig(cfg),
+		namespace: namespace,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// WithClient replaces the CloudWatch client.
+func WithClient(c API) Option {
+	return func(e *Exporter) error {
+		e.client = c
+		return nil
+	}
+}
+
+// KeepsTimestamps returns true, the data points have the timestamps of the
+// readings.
+func (e *Exporter) KeepsTimestamps() (bool, time.Duration) {
+	return true, maxAge
+}
+
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var data []types.MetricDatum
+	for _, r := range readings {
+		// unchanged readings would be counted twice in the statistics
+		if r.Unchanged {
+			continue
+		}
+		data = append(data, metrics(r)...)
+	}
+
+	for len(data) > 0 {
+		n := min(len(data), maxBatch)
+		_, err := e.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(e.namespace),
+			MetricData: data[:n],
+		})
+		if err != nil {
+			return fmt.Errorf("cannot put CloudWatch metrics: %w", err)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// metrics returns the metrics of the measurements of the Metrics of the
+// reading.
+func metrics(r *egain.SensorReading) []types.MetricDatum {
+	dims := []types.Dimension{{Name: aws.String("SensorId"), Value: aws.String(r.SensorID)}}
+	if r.Location != "" {
+		dims = append(dims, types.Dimension{Name: aws.String("Location"), Value: aws.String(r.Location)})
+	}
+	if r.Account != "" {
+		dims = append(dims, types.Dimension{Name: aws.String("Account"), Value: aws.String(r.Account)})
+	}
+	datum := func(name string, value float64, unit types.StandardUnit) types.MetricDatum {
+		return types.MetricDatum{
+			MetricName: aws.String(name),
+			Dimensions: dims,
+			Timestamp:  aws.Time(r.Timestamp),
+			Value:      aws.Float64(value),
+			Unit:       unit,
+		}
+	}
+
+	var m []types.MetricDatum
+	for _, v := range r.Metrics() {
+		unit := types.StandardUnitNone
+		if v.Unit == "%" || v.Unit == "%rH" {
+			unit = types.StandardUnitPercent
+		}
+		m = append(m, datum(metricName(v.Name), v.Value, unit))
+	}
+	return m
+}
+
+// metricName returns the CloudWatch name of a measurement, e.g.
+// SignalStrength for signal_strength.
+func metricName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
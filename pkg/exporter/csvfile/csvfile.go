@@ -0,0 +1,206 @@
+package csvfile
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// header is the first row of each file.
+var header = []string{"sensorId", "location", "temperature", "humidity", "timestamp"}
+
+// Exporter appends the readings as CSV rows to a file. The file is rotated
+// once it exceeds the max size or a new day begins, the rotated files are
+// renamed with the time of the rotation, e.g. readings.20240102-150405.csv.
+type Exporter struct {
+	path    string
+	maxSize int64
+	daily   bool
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+	closed bool
+}
+
+type Option func(e *Exporter) error
+
+// New opens the file at the given path, new rows are appended to an existing
+// file.
+func New(path string, opts ...Option) (*Exporter, error) {
+	e := &Exporter{path: path}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.open(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WithMaxSize rotates the file once it is larger than n bytes.
+func WithMaxSize(n int64) Option {
+	return func(e *Exporter) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid max size %d", n)
+		}
+		e.maxSize = n
+		return nil
+	}
+}
+
+// WithDailyRotation rotates the file at the first export of each day, in
+// local time.
+func WithDailyRotation() Option {
+	return func(e *Exporter) error {
+		e.daily = true
+		return nil
+	}
+}
+
+// open opens the file and writes the header if it is empty.
+func (e *Exporter) open() error {
+	f, err := os.OpenFile(e.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	e.f = f
+	e.size = info.Size()
+	// an existing file is rotated on the day it was last written to
+	e.opened = info.ModTime()
+	if e.size == 0 {
+		e.opened = time.Now()
+		return e.write([][]string{header})
+	}
+	return nil
+}
+
+// rotate renames the current file and opens a new one.
+func (e *Exporter) rotate(now time.Time) error {
+	err := e.f.Close()
+	e.f = nil
+	if err != nil {
+		return err
+	}
+
+	// the current file is appended to if it cannot be renamed
+	ext := filepath.Ext(e.path)
+	rotated := strings.TrimSuffix(e.path, ext) + "." + now.Format("20060102-150405") + ext
+	if err := os.Rename(e.path, rotated); err != nil {
+		return errors.Join(fmt.Errorf("cannot rotate %s: %w", e.path, err), e.open())
+	}
+	return e.open()
+}
+
+// due returns whether the file has to be rotated before writing.
+func (e *Exporter) due(now time.Time) bool {
+	if e.maxSize > 0 && e.size >= e.maxSize {
+		return true
+	}
+	if e.daily {
+		y, m, d := e.opened.Date()
+		ny, nm, nd := now.Date()
+		return y != ny || m != nm || d != nd
+	}
+	return false
+}
+
+// write writes the rows and keeps track of the size of the file.
+func (e *Exporter) write(rows [][]string) error {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	n, err := e.f.WriteString(b.String())
+	e.size += int64(n)
+	return err
+}
+
+// KeepsTimestamps returns true, the rows have the timestamps of the readings.
+func (e *Exporter) KeepsTimestamps() (bool, time.Duration) {
+	return true, 0
+}
+
+// Export appends the readings to the file. Unchanged readings are already in
+// the file and heating systems have no temperature and humidity, both are
+// skipped.
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var rows [][]string
+	for _, r := range readings {
+		if r.Unchanged || r.Heating != nil {
+			continue
+		}
+		rows = append(rows, []string{
+			r.SensorID,
+			r.Location,
+			strconv.FormatFloat(r.Temperature, 'f', -1, 64),
+			strconv.FormatFloat(r.Humidity, 'f', -1, 64),
+			r.Timestamp.Format(time.RFC3339),
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return errors.New("CSV file is closed")
+	}
+	if e.f == nil {
+		// the file could not be reopened after the last rotation
+		if err := e.open(); err != nil {
+			return err
+		}
+	}
+
+	// the rows are written to the current file if the rotation failed
+	var err error
+	if now := time.Now(); e.due(now) {
+		err = e.rotate(now)
+	}
+	if e.f == nil {
+		return err
+	}
+	return errors.Join(err, e.write(rows))
+}
+
+// Close closes the file.
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	if e.f == nil {
+		return nil
+	}
+	err := e.f.Close()
+	e.f = nil
+	return err
+}
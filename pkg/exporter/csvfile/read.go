@@ -0,0 +1,62 @@
+package csvfile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Read reads the readings of a file written by the exporter, e.g. to replay
+// them.
+func Read(path string) ([]*egain.SensorReading, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(header)
+	first, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV file %s: %w", path, err)
+	}
+	if !slices.Equal(first, header) {
+		return nil, fmt.Errorf("invalid CSV file %s: unexpected header %v", path, first)
+	}
+
+	var readings []*egain.SensorReading
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return readings, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV file %s: %w", path, err)
+		}
+		line, _ := r.FieldPos(0)
+
+		reading := &egain.SensorReading{}
+		reading.SensorID, reading.Location = row[0], row[1]
+		if reading.Temperature, err = strconv.ParseFloat(row[2], 64); err != nil {
+			return nil, fmt.Errorf("invalid temperature in line %d of %s: %w", line, path, err)
+		}
+		if reading.Humidity, err = strconv.ParseFloat(row[3], 64); err != nil {
+			return nil, fmt.Errorf("invalid humidity in line %d of %s: %w", line, path, err)
+		}
+		if reading.Timestamp, err = time.Parse(time.RFC3339, row[4]); err != nil {
+			return nil, fmt.Errorf("invalid timestamp in line %d of %s: %w", line, path, err)
+		}
+		readings = append(readings, reading)
+	}
+}
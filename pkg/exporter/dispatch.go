@@ -0,0 +1,361 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// Policy is the way the readings are handed to an exporter which may be slow
+// or down.
+type Policy string
+
+const (
+	// PolicyBlock exports the readings in the cycle, which waits for the
+	// exporter and fails along with it.
+	PolicyBlock Policy = "block"
+	// PolicyBuffer queues the readings and exports them in the background.
+	// Failed exports stay queued and are retried with a backoff, the oldest
+	// readings are dropped once the queue is full.
+	PolicyBuffer Policy = "buffer"
+	// PolicyDrop exports the readings in the background. Readings which
+	// arrive while the exporter is still busy and failed exports are dropped.
+	PolicyDrop Policy = "drop"
+)
+
+// ParsePolicy parses the name of a policy.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyBlock, PolicyBuffer, PolicyDrop:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid policy %q, expected %s, %s or %s", s, PolicyBlock, PolicyBuffer, PolicyDrop)
+}
+
+// the backoff of the retries of a buffered exporter
+const (
+	minRetryBackoff = time.Second
+	maxRetryBackoff = time.Minute
+)
+
+// Dispatcher hands the readings to the exporters by their policies, so a
+// slow or failing exporter does not stall the cycles.
+type Dispatcher struct {
+	log          *zap.Logger
+	policies     map[string]Policy
+	queueSize    int
+	drainTimeout time.Duration
+
+	dropped metric.Int64Counter
+
+	mu     sync.Mutex
+	queues []*queue
+}
+
+type DispatcherOption func(d *Dispatcher) error
+
+// NewDispatcher creates a dispatcher recording the depth of its queues and
+// the dropped readings on the meter.
+func NewDispatcher(meter metric.Meter, opts ...DispatcherOption) (*Dispatcher, error) {
+	d := &Dispatcher{
+		log:          zap.L(),
+		policies:     map[string]Policy{},
+		queueSize:    100,
+		drainTimeout: 30 * time.Second,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	_, err = meter.Int64ObservableGauge("again_scraper.exporter.queue.depth",
+		metric.WithDescription("The number of readings queued for an exporter running in the background"),
+		metric.WithUnit("{reading}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			for _, q := range d.queues {
+				o.Observe(int64(q.depth()), metric.WithAttributes(attribute.String("exporter", q.name)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.dropped, err = meter.Int64Counter("again_scraper.exporter.dropped",
+		metric.WithDescription("The number of readings dropped by an exporter running in the background, by the reason"),
+		metric.WithUnit("{reading}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// WithPolicies sets the policies of the exporters by their names, the
+// exporters without a policy block.
+func WithPolicies(policies map[string]Policy) DispatcherOption {
+	return func(d *Dispatcher) error {
+		d.policies = policies
+		return nil
+	}
+}
+
+// WithQueueSize sets the number of exports, i.e. batches of readings, queued
+// for a buffered exporter, 100 by default.
+func WithQueueSize(n int) DispatcherOption {
+	return func(d *Dispatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid queue size %d", n)
+		}
+		d.queueSize = n
+		return nil
+	}
+}
+
+// WithDrainTimeout sets how long the queued readings are exported on close
+// before they are dropped, 30 seconds by default.
+func WithDrainTimeout(t time.Duration) DispatcherOption {
+	return func(d *Dispatcher) error {
+		d.drainTimeout = t
+		return nil
+	}
+}
+
+func WithDispatchLogger(l *zap.Logger) DispatcherOption {
+	return func(d *Dispatcher) error {
+		d.log = l
+		return nil
+	}
+}
+
+// Wrap returns the named exporter handed the readings by its policy. It is
+// returned as it is if it blocks, also by a nil dispatcher.
+func (d *Dispatcher) Wrap(name string, e Exporter) Exporter {
+	if d == nil {
+		return e
+	}
+	policy, ok := d.policies[name]
+	if !ok || policy == PolicyBlock {
+		return e
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &queue{
+		name:    name,
+		next:    e,
+		policy:  policy,
+		size:    d.queueSize,
+		drain:   d.drainTimeout,
+		log:     d.log.With(zap.String("exporter", name)),
+		dropped: d.dropped,
+		wake:    make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		stopped: make(chan struct{}),
+		cancel:  cancel,
+	}
+	if policy == PolicyDrop {
+		q.size = 1
+	}
+	d.mu.Lock()
+	d.queues = append(d.queues, q)
+	d.mu.Unlock()
+
+	d.log.Info("exporting readings in the background", zap.String("exporter", name), zap.String("policy", string(policy)))
+	go q.run(ctx)
+	return q
+}
+
+// queue exports the readings of the cycles in the background.
+type queue struct {
+	name    string
+	next    Exporter
+	policy  Policy
+	size    int
+	drain   time.Duration
+	log     *zap.Logger
+	dropped metric.Int64Counter
+
+	mu      sync.Mutex
+	batches [][]*egain.SensorReading
+	busy    bool
+	closed  bool
+
+	wake    chan struct{}
+	closing chan struct{}
+	stopped chan struct{}
+	cancel  context.CancelFunc
+}
+
+// Export queues the readings and returns right away.
+func (q *queue) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	switch {
+	case q.closed:
+		q.mu.Unlock()
+		return fmt.Errorf("exporter %s is closed", q.name)
+	case q.policy == PolicyDrop && (q.busy || len(q.batches) > 0):
+		q.mu.Unlock()
+		q.drop(ctx, readings, "busy")
+		return nil
+	}
+	q.batches = append(q.batches, readings)
+	var full [][]*egain.SensorReading
+	if n := len(q.batches) - q.size; n > 0 {
+		full = q.batches[:n]
+		q.batches = q.batches[n:]
+	}
+	q.mu.Unlock()
+
+	for _, b := range full {
+		q.drop(ctx, b, "full")
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// RecordError passes the error to the exporter if it records errors.
+func (q *queue) RecordError(ctx context.Context, err error) {
+	if r, ok := q.next.(ErrorRecorder); ok {
+		r.RecordError(ctx, err)
+	}
+}
+
+// Close exports the queued readings within the drain timeout, drops the
+// rest and closes the exporter.
+func (q *queue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mu.Unlock()
+	close(q.closing)
+
+	select {
+	case <-q.stopped:
+	case <-time.After(q.drain):
+		q.cancel()
+		<-q.stopped
+	}
+	q.cancel()
+
+	var err error
+	if n := q.depth(); n > 0 {
+		err = fmt.Errorf("exporter %s dropped %d queued readings on close", q.name, n)
+	}
+	if c, ok := q.next.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// run exports the queued readings until the queue is closed and drained.
+func (q *queue) run(ctx context.Context) {
+	defer close(q.stopped)
+
+	backoff := minRetryBackoff
+	for {
+		q.mu.Lock()
+		if len(q.batches) == 0 {
+			closed := q.closed
+			q.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-q.wake:
+			case <-q.closing:
+			}
+			continue
+		}
+		batch := q.batches[0]
+		q.batches = q.batches[1:]
+		q.busy = true
+		q.mu.Unlock()
+
+		err := q.next.Export(ctx, batch)
+
+		q.mu.Lock()
+		q.busy = false
+		closed := q.closed
+		retry := err != nil && q.policy == PolicyBuffer && !closed && ctx.Err() == nil
+		// the failed readings are the oldest, they are dropped first if the
+		// queue filled up in the meantime
+		full := false
+		if retry {
+			if full = len(q.batches) >= q.size; !full {
+				q.batches = append([][]*egain.SensorReading{batch}, q.batches...)
+			}
+		}
+		q.mu.Unlock()
+
+		switch {
+		case err == nil:
+			backoff = minRetryBackoff
+		case full:
+			q.log.Warn("cannot export readings", zap.Error(err))
+			q.drop(ctx, batch, "full")
+		case retry:
+			q.log.Warn("cannot export readings, retrying", zap.Int("readings", len(batch)), zap.Duration("backoff", backoff), zap.Error(err))
+			select {
+			case <-time.After(backoff):
+			case <-q.closing:
+			}
+			backoff = min(2*backoff, maxRetryBackoff)
+		default:
+			q.log.Warn("cannot export readings", zap.Error(err))
+			q.drop(ctx, batch, "failed")
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// depth returns the number of queued readings.
+func (q *queue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, b := range q.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func (q *queue) drop(ctx context.Context, readings []*egain.SensorReading, reason string) {
+	q.log.Warn("dropping readings", zap.Int("readings", len(readings)), zap.String("reason", reason))
+	q.dropped.Add(ctx, int64(len(readings)), metric.WithAttributes(
+		attribute.String("exporter", q.name),
+		attribute.String("reason", reason),
+	))
+}
+
+// KeepsTimestamps returns whether the exporter keeps the timestamps of the
+// readings, which the queue does not change.
+func (q *queue) KeepsTimestamps() (bool, time.Duration) {
+	return KeepsTimestamps(q.next)
+}
@@ -0,0 +1,85 @@
+package exporter
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.uber.org/zap"
+)
+
+// DryRun logs the readings instead of exporting them, along with the
+// exporters which would have been invoked.
+type DryRun struct {
+	log       *zap.Logger
+	exporters []string
+}
+
+// NewDryRun creates an exporter logging the readings on behalf of the named
+// exporters.
+func NewDryRun(logger *zap.Logger, exporters []string) *DryRun {
+	return &DryRun{log: logger, exporters: exporters}
+}
+
+func (d *DryRun) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	for _, r := range readings {
+		fields := []zap.Field{
+			zap.String("sensorId", r.SensorID),
+			zap.String("location", r.Location),
+			zap.Strings("exporters", d.exporters),
+			zap.Bool("stale", r.Stale),
+		}
+		// mirror the OTel exporter, unchanged readings are only counted
+		if r.Unchanged {
+			d.log.Info("dry run: would count suppressed reading", fields...)
+			continue
+		}
+
+		if h := r.Heating; h != nil {
+			fields = append(fields,
+				zap.Float64("sensor.heating.flow_temperature", h.FlowTemperature),
+				zap.Float64("sensor.heating.return_temperature", h.ReturnTemperature),
+				zap.Float64("sensor.heating.flow_setpoint", h.FlowSetpoint),
+			)
+			for i, v := range h.Valves {
+				fields = append(fields, zap.Float64("sensor.heating.valve.position."+strconv.Itoa(i), v.Position))
+			}
+			d.log.Info("dry run: would record reading", fields...)
+			continue
+		}
+
+		fields = append(fields,
+			zap.Float64("sensor.temperature", r.Temperature),
+			zap.Float64("sensor.humidity", r.Humidity),
+		)
+		if w := r.Weather; w != nil {
+			fields = append(fields,
+				zap.Float64("sensor.wind.speed", w.WindSpeed),
+				zap.Float64("sensor.wind.direction", w.WindDirection),
+				zap.Float64("sensor.pressure", w.Pressure),
+				zap.Float64("sensor.precipitation", w.Precipitation),
+			)
+		}
+		if r.Battery != nil {
+			fields = append(fields, zap.Float64("sensor.battery", *r.Battery))
+		}
+		if r.SignalStrength != nil {
+			fields = append(fields, zap.Float64("sensor.signal_strength", *r.SignalStrength))
+		}
+		if c := r.Comfort; c != nil {
+			fields = append(fields,
+				zap.Float64("sensor.dew_point", c.DewPoint),
+				zap.Float64("sensor.absolute_humidity", c.AbsoluteHumidity),
+				zap.Float64("sensor.heat_index", c.HeatIndex),
+			)
+		}
+		for i, t := range r.ExternalTemperatures {
+			fields = append(fields, zap.Float64("sensor.external_temperature."+strconv.Itoa(i), t.Value))
+		}
+		for i, v := range r.Values {
+			fields = append(fields, zap.Float64("sensor.value."+unitName(v.Unit)+"."+strconv.Itoa(i), v.Value))
+		}
+		d.log.Info("dry run: would record reading", fields...)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Exporter sends sensor readings to a backend like a metrics pipeline, a
+// database or a message bus.
+type Exporter interface {
+	Export(ctx context.Context, readings []*egain.SensorReading) error
+}
+
+// ErrorRecorder is implemented by exporters which keep track of the failed
+// fetches. They are given the error of each fetch, which joins the
+// *egain.SensorError of the failed sensors.
+type ErrorRecorder interface {
+	RecordError(ctx context.Context, err error)
+}
+
+// Multi fans out the readings to all of its exporters concurrently.
+type Multi []Exporter
+
+// Export calls all exporters with the readings and waits for them to finish.
+// The errors of the exporters are joined.
+func (m Multi) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	errs := make([]error, len(m))
+
+	var wg sync.WaitGroup
+	for i, e := range m {
+		wg.Add(1)
+		go func(i int, e Exporter) {
+			defer wg.Done()
+			errs[i] = e.Export(ctx, readings)
+		}(i, e)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Close closes all exporters which hold resources, i.e. implement io.Closer.
+func (m Multi) Close() error {
+	var errs []error
+	for _, e := range m {
+		if c, ok := e.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RecordError passes the error to all exporters which record errors.
+func (m Multi) RecordError(ctx context.Context, err error) {
+	for _, e := range m {
+		if r, ok := e.(ErrorRecorder); ok {
+			r.RecordError(ctx, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package gcpmonitoring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// DefaultMetricPrefix is the prefix of the metric types unless
+	// WithMetricPrefix is given.
+	DefaultMetricPrefix = "custom.googleapis.com/egain"
+	// DefaultLocation is the location of the monitored resources unless
+	// WithLocation is given.
+	DefaultLocation = "global"
+
+	apiURL = "https://monitoring.googleapis.com/v3/"
+	scope  = "https://www.googleapis.com/auth/monitoring.write"
+	// maxBatch is the maximum number of time series of a create request
+	maxBatch = 200
+)
+
+// Exporter writes the sensor readings as custom metrics of Google Cloud
+// Monitoring. Each sensor is a generic_node resource whose node_id is the ID
+// of the sensor and whose namespace is its location, e.g. as resolved from
+// its metadata. The kind, account, model and firmware are labels of the
+// metrics.
+type Exporter struct {
+	client   *http.Client
+	url      string
+	prefix   string
+	location string
+}
+
+type Option func(e *Exporter) error
+
+// New creates an exporter writing to the project with the Application
+// Default Credentials. The project of the credentials is used if the project
+// is empty.
+func New(ctx context.Context, project string, opts ...Option) (*Exporter, error) {
+	creds, err := google.FindDefaultCredentials(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the Application Default Credentials: %w", err)
+	}
+	if project == "" {
+		project = creds.ProjectID
+	}
+	if project == "" {
+		return nil, errors.New("missing Google Cloud project, the Application Default Credentials have none")
+	}
+
+	e := &Exporter{
+		url:      apiURL + "projects/" + url.PathEscape(project) + "/timeSeries",
+		prefix:   DefaultMetricPrefix,
+		location: DefaultLocation,
+	}
+	e.client, err = google.DefaultClient(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// WithMetricPrefix sets the prefix of the metric types, DefaultMetricPrefix by
+// default, e.g. custom.googleapis.com/egain/temperature.
+func WithMetricPrefix(p string) Option {
+	return func(e *Exporter) error {
+		p = strings.TrimSuffix(p, "/")
+		if !strings.HasPrefix(p, "custom.googleapis.com/") && !strings.HasPrefix(p, "workload.googleapis.com/") {
+			return fmt.Errorf("invalid metric prefix %q, expected custom.googleapis.com/ or workload.googleapis.com/", p)
+		}
+		e.prefix = p
+		return nil
+	}
+}
+
+// WithLocation sets the location of the monitored resources, a Google Cloud
+// region or zone, DefaultLocation by default.
+func WithLocation(l string) Option {
+	return func(e *Exporter) error {
+		if l == "" {
+			return errors.New("empty location of the monitored resources")
+		}
+		e.location = l
+		return nil
+	}
+}
+
+// WithHTTPClient replaces the authenticated HTTP client.
+func WithHTTPClient(c *http.Client) Option {
+	return func(e *Exporter) error {
+		e.client = c
+		return nil
+	}
+}
+
+type timeSeries struct {
+	Metric     typedLabels `json:"metric"`
+	Resource   typedLabels `json:"resource"`
+	MetricKind string      `json:"metricKind"`
+	ValueType  string      `json:"valueType"`
+	Points     []point     `json:"points"`
+}
+
+type typedLabels struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type point struct {
+	Interval struct {
+		EndTime time.Time `json:"endTime"`
+	} `json:"interval"`
+	Value struct {
+		DoubleValue float64 `json:"doubleValue"`
+	} `json:"value"`
+}
+
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	// the points of a time series have to be written in order and once per
+	// request, so only the last reading of a sensor is written and unchanged
+	// readings are skipped
+	last := map[string]*egain.SensorReading{}
+	var ids []string
+	for _, r := range readings {
+		if r.Unchanged {
+			continue
+		}
+		if p, ok := last[r.SensorID]; !ok {
+			ids = append(ids, r.SensorID)
+		} else if r.Timestamp.Before(p.Timestamp) {
+			continue
+		}
+		last[r.SensorID] = r
+	}
+	var series []timeSeries
+	for _, id := range ids {
+		series = append(series, e.timeSeries(last[id])...)
+	}
+
+	for len(series) > 0 {
+		n := min(len(series), maxBatch)
+		if err := e.create(ctx, series[:n]); err != nil {
+			return err
+		}
+		series = series[n:]
+	}
+	return nil
+}
+
+func (e *Exporter) create(ctx context.Context, series []timeSeries) error {
+	body, err := json.Marshal(map[string]any{"timeSeries": series})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot write to Cloud Monitoring: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var status struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(msg, &status) == nil && status.Error.Message != "" {
+			msg = []byte(status.Error.Message)
+		}
+		return fmt.Errorf("cannot write to Cloud Monitoring: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// timeSeries returns the time series of the measurements of the Metrics of
+// the reading.
+func (e *Exporter) timeSeries(r *egain.SensorReading) []timeSeries {
+	resource := typedLabels{
+		Type: "generic_node",
+		Labels: map[string]string{
+			"location":  e.location,
+			"namespace": r.Location,
+			"node_id":   r.SensorID,
+		},
+	}
+	labels := map[string]string{"kind": r.Kind.String()}
+	if r.Account != "" {
+		labels["account"] = r.Account
+	}
+	if m := r.Metadata; m != nil {
+		if m.Model != "" {
+			labels["model"] = m.Model
+		}
+		if m.FirmwareVersion != "" {
+			labels["firmware"] = m.FirmwareVersion
+		}
+	}
+
+	gauge := func(name string, v float64) timeSeries {
+		ts := timeSeries{
+			Metric:     typedLabels{Type: e.prefix + "/" + name, Labels: labels},
+			Resource:   resource,
+			MetricKind: "GAUGE",
+			ValueType:  "DOUBLE",
+			Points:     make([]point, 1),
+		}
+		ts.Points[0].Interval.EndTime = r.Timestamp.UTC()
+		ts.Points[0].Value.DoubleValue = v
+		return ts
+	}
+
+	var s []timeSeries
+	for _, m := range r.Metrics() {
+		s = append(s, gauge(m.Name, m.Value))
+	}
+	return s
+}
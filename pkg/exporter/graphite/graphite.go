@@ -0,0 +1,222 @@
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Protocol is the wire format of the exporter.
+type Protocol string
+
+const (
+	// Plaintext is the Graphite plaintext protocol over TCP, the readings
+	// keep their timestamps.
+	Plaintext Protocol = "plaintext"
+	// StatsD sends the readings as StatsD gauges over UDP.
+	StatsD Protocol = "statsd"
+)
+
+// Exporter sends the temperature and humidity of the sensor readings to
+// Graphite or a StatsD daemon. The metric path is made of the prefix, the
+// path template with the tags of the sensor and the name of the metric, e.g.
+// egain.livingroom.ID123.temperature.
+type Exporter struct {
+	addr     string
+	protocol Protocol
+	prefix   string
+	path     []string
+	timeout  time.Duration
+}
+
+type Option func(e *Exporter) error
+
+// New creates an exporter sending to the given address, e.g.
+// localhost:2003 for Graphite or localhost:8125 for StatsD.
+func New(addr string, opts ...Option) (*Exporter, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid Graphite address: %w", err)
+	}
+
+	e := &Exporter{
+		addr:     addr,
+		protocol: Plaintext,
+		prefix:   "egain",
+		path:     []string{"{sensor_id}"},
+		timeout:  10 * time.Second,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// WithProtocol sets the protocol, Plaintext by default.
+func WithProtocol(p Protocol) Option {
+	return func(e *Exporter) error {
+		if p != Plaintext && p != StatsD {
+			return fmt.Errorf("unknown Graphite protocol %q, expected %s or %s", p, Plaintext, StatsD)
+		}
+		e.protocol = p
+		return nil
+	}
+}
+
+// WithPrefix sets the prefix of the metric paths, "egain" by default. An
+// empty prefix omits it.
+func WithPrefix(p string) Option {
+	return func(e *Exporter) error {
+		e.prefix = strings.Trim(p, ".")
+		return nil
+	}
+}
+
+// WithPath sets the template mapping the tags of a sensor to the metric path,
+// "{sensor_id}" by default. The template consists of dot separated nodes with
+// the placeholders {sensor_id}, {location} and {kind}, e.g.
+// "{location}.{sensor_id}".
+func WithPath(template string) Option {
+	return func(e *Exporter) error {
+		nodes := strings.Split(strings.Trim(template, "."), ".")
+		for _, n := range nodes {
+			if n == "" {
+				return fmt.Errorf("empty node in Graphite path %q", template)
+			}
+		}
+		e.path = nodes
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of sending the readings, 10 seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Exporter) error {
+		if d <= 0 {
+			return errors.New("invalid Graphite timeout")
+		}
+		e.timeout = d
+		return nil
+	}
+}
+
+// KeepsTimestamps returns true for the plaintext protocol, StatsD gauges are
+// stored at the time they are received.
+func (e *Exporter) KeepsTimestamps() (bool, time.Duration) {
+	return e.protocol != StatsD, 0
+}
+
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var lines []string
+	for _, r := range readings {
+		path := e.metricPath(r)
+		for _, m := range r.Metrics() {
+			lines = append(lines, e.line(path+"."+m.Name, m.Value, r.Timestamp))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	network := "tcp"
+	if e.protocol == StatsD {
+		network = "udp"
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, e.addr)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %w", e.addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	for _, packet := range e.packets(lines) {
+		if _, err := conn.Write(packet); err != nil {
+			return fmt.Errorf("cannot send readings to %s: %w", e.addr, err)
+		}
+	}
+	return nil
+}
+
+// maxPacket is the maximum size of a StatsD datagram, which is safe to send
+// without fragmentation.
+const maxPacket = 1432
+
+// packets groups the lines into the writes to the connection. Graphite gets
+// all lines at once, StatsD datagrams are kept below maxPacket. The lines of
+// a metric, which may be a reset and a negative value, are never split.
+func (e *Exporter) packets(lines []string) [][]byte {
+	if e.protocol != StatsD {
+		return [][]byte{[]byte(strings.Join(lines, ""))}
+	}
+
+	var (
+		packets [][]byte
+		buf     bytes.Buffer
+	)
+	for _, l := range lines {
+		if buf.Len() > 0 && buf.Len()+len(l) > maxPacket {
+			packets = append(packets, bytes.Clone(buf.Bytes()))
+			buf.Reset()
+		}
+		buf.WriteString(l)
+	}
+	return append(packets, buf.Bytes())
+}
+
+func (e *Exporter) line(path string, value float64, ts time.Time) string {
+	v := strconv.FormatFloat(value, 'f', -1, 64)
+	if e.protocol == StatsD {
+		// a signed value changes a StatsD gauge by it, so a negative value
+		// is set by resetting the gauge to 0 first, in the same datagram
+		if value < 0 {
+			return path + ":0|g\n" + path + ":" + v + "|g\n"
+		}
+		return path + ":" + v + "|g\n"
+	}
+	return path + " " + v + " " + strconv.FormatInt(ts.Unix(), 10) + "\n"
+}
+
+// metricPath returns the path of the metrics of the reading.
+func (e *Exporter) metricPath(r *egain.SensorReading) string {
+	tags := strings.NewReplacer(
+		"{sensor_id}", node(r.SensorID),
+		"{location}", node(r.Location),
+		"{kind}", node(r.Kind.String()),
+	)
+
+	nodes := make([]string, 0, len(e.path)+1)
+	if e.prefix != "" {
+		nodes = append(nodes, e.prefix)
+	}
+	for _, n := range e.path {
+		nodes = append(nodes, tags.Replace(n))
+	}
+	return strings.Join(nodes, ".")
+}
+
+// node sanitizes a tag value for use as a node of a metric path.
+func node(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return nodeEscaper.Replace(s)
+}
+
+var nodeEscaper = strings.NewReplacer(".", "_", " ", "_", "/", "_", ":", "_", "|", "_", "@", "_", "\n", "_")
@@ -0,0 +1,132 @@
+package graphite
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain/egaintest"
+)
+
+func TestStatsDNegativeGauge(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	e, err := New(conn.LocalAddr().String(), WithProtocol(StatsD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := egaintest.Reading(egain.Sensor{SensorID: "ID1"}, -3.4, 80, time.Now())
+	if err := e.Export(context.Background(), []*egain.SensorReading{r}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, maxPacket)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(buf[:n])
+	// the gauge is reset before the negative value, as a signed value would
+	// decrement it
+	if want := "egain.ID1.temperature:0|g\negain.ID1.temperature:-3.4|g\n"; !strings.Contains(got, want) {
+		t.Errorf("datagram %q does not contain %q", got, want)
+	}
+	if want := "egain.ID1.humidity:80|g\n"; !strings.Contains(got, want) {
+		t.Errorf("datagram %q does not contain %q", got, want)
+	}
+}
+
+func TestPacketsKeepResetWithValue(t *testing.T) {
+	e := &Exporter{protocol: StatsD}
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, e.line("egain.ID12345.temperature", -1.5, time.Time{}))
+	}
+	for _, p := range e.packets(lines) {
+		if len(p) > maxPacket {
+			t.Errorf("packet of %d bytes exceeds %d", len(p), maxPacket)
+		}
+		// every datagram starts with a reset, so no value is sent without it
+		if !strings.HasPrefix(string(p), "egain.ID12345.temperature:0|g\n") {
+			t.Errorf("packet starts without a reset: %q", p[:40])
+		}
+	}
+}
+
+// exportPlaintext exports the readings to a Graphite stub and returns the
+// lines it received.
+func exportPlaintext(t *testing.T, readings ...*egain.SensorReading) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	e, err := New(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Export(context.Background(), readings); err != nil {
+		t.Fatal(err)
+	}
+	return <-received
+}
+
+func TestFixtures(t *testing.T) {
+	sensor := egain.Sensor{SensorID: "ID1", Location: "lab"}
+	tests := []struct {
+		name    string
+		kind    egain.Kind
+		fixture string
+		want    string
+	}{
+		{"indoor", egain.KindIndoor, egaintest.Indoor, `egain.ID1.temperature 21.3 1705312800
+egain.ID1.humidity 42.5 1705312800
+`},
+		{"indoor with probes", egain.KindIndoor, egaintest.IndoorWithProbes, `egain.ID1.temperature 20.8 1705312800
+egain.ID1.humidity 38 1705312800
+egain.ID1.co2 612 1705312800
+`},
+		{"outdoor", egain.KindOutdoor, egaintest.Outdoor, `egain.ID1.temperature 3.4 1705312800
+egain.ID1.humidity 81 1705312800
+egain.ID1.wind_speed 5.2 1705312800
+egain.ID1.wind_direction 240 1705312800
+egain.ID1.pressure 1008.3 1705312800
+egain.ID1.precipitation 0.4 1705312800
+`},
+		{"heating", egain.KindHeating, egaintest.Heating, `egain.ID1.flow_temperature 45.2 1705312800
+egain.ID1.return_temperature 38.7 1705312800
+egain.ID1.flow_setpoint 46 1705312800
+egain.ID1.valve_0_position 62.5 1705312800
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exportPlaintext(t, egaintest.MustDecode(tt.kind, tt.fixture, sensor))
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
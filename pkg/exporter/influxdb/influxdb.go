@@ -0,0 +1,190 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Exporter writes the sensor readings to the InfluxDB v2 write API using the
+// line protocol.
+type Exporter struct {
+	client      *http.Client
+	writeURL    string
+	token       string
+	measurement string
+}
+
+type Option func(e *Exporter) error
+
+// New creates an exporter writing to the given bucket of the InfluxDB server
+// at the given url.
+func New(serverURL, org, bucket string, opts ...Option) (*Exporter, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB url: %w", err)
+	}
+	if bucket == "" {
+		return nil, errors.New("missing InfluxDB bucket")
+	}
+
+	u = u.JoinPath("api", "v2", "write")
+	q := u.Query()
+	q.Set("org", org)
+	q.Set("bucket", bucket)
+	q.Set("precision", "s")
+	u.RawQuery = q.Encode()
+
+	e := &Exporter{
+		client:      &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		writeURL:    u.String(),
+		measurement: "sensor",
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// WithToken sets the API token used to authenticate against InfluxDB.
+func WithToken(token string) Option {
+	return func(e *Exporter) error {
+		e.token = token
+		return nil
+	}
+}
+
+// WithMeasurement sets the name of the measurement, "sensor" by default.
+func WithMeasurement(m string) Option {
+	return func(e *Exporter) error {
+		if m == "" {
+			return errors.New("empty measurement name")
+		}
+		e.measurement = m
+		return nil
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client.
+func WithHTTPClient(c *http.Client) Option {
+	return func(e *Exporter) error {
+		e.client = c
+		return nil
+	}
+}
+
+// KeepsTimestamps returns true, the points are written at the timestamps of the
+// readings.
+func (e *Exporter) KeepsTimestamps() (bool, time.Duration) {
+	return true, 0
+}
+
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	for _, r := range readings {
+		e.writeLine(&body, r)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.writeURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.token != "" {
+		req.Header.Set("Authorization", "Token "+e.token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot write to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("cannot write to InfluxDB: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// field is a field of the line protocol.
+type field struct {
+	key   string
+	value float64
+}
+
+// writeLine writes a single reading in line protocol.
+func (e *Exporter) writeLine(w *bytes.Buffer, r *egain.SensorReading) {
+	w.WriteString(measurementEscaper.Replace(e.measurement))
+	// tags have to be sorted by their key for the best performance, tags
+	// without a value are invalid
+	tags := map[string]string{"sensor_id": r.SensorID, "location": r.Location, "account": r.Account}
+	for k, v := range r.Labels {
+		switch k {
+		case "sensor_id", "location", "account":
+			// the labels of the sensor cannot override the built-in ones
+		default:
+			tags[k] = v
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		if k == "" || tags[k] == "" {
+			continue
+		}
+		w.WriteByte(',')
+		w.WriteString(tagEscaper.Replace(k))
+		w.WriteByte('=')
+		w.WriteString(tagEscaper.Replace(tags[k]))
+	}
+
+	for i, f := range fields(r) {
+		if i == 0 {
+			w.WriteByte(' ')
+		} else {
+			w.WriteByte(',')
+		}
+		w.WriteString(f.key)
+		w.WriteByte('=')
+		w.WriteString(strconv.FormatFloat(f.value, 'f', -1, 64))
+	}
+	w.WriteByte(' ')
+	w.WriteString(strconv.FormatInt(r.Timestamp.Unix(), 10))
+	w.WriteByte('\n')
+}
+
+// fields returns the fields of the reading, the measurements of its Metrics.
+func fields(r *egain.SensorReading) []field {
+	m := r.Metrics()
+	f := make([]field, len(m))
+	for i, m := range m {
+		f[i] = field{m.Name, m.Value}
+	}
+	return f
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
@@ -0,0 +1,86 @@
+package influxdb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain/egaintest"
+)
+
+// export writes the readings to a stub of the write API and returns the body
+// of the request.
+func export(t *testing.T, readings ...*egain.SensorReading) string {
+	t.Helper()
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	e, err := New(srv.URL, "org", "bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Export(context.Background(), readings); err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestLabels(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sensor := egain.Sensor{
+		SensorID: "ID1",
+		Location: "Living Room",
+		Account:  "b",
+		Labels: map[string]string{
+			// sorted together with the built-in tags
+			"building": "main",
+			"zone":     "north",
+			// empty values are invalid line protocol
+			"floor": "",
+			// the built-in tags are not overridden or duplicated
+			"account":   "x",
+			"location":  "y",
+			"sensor_id": "z",
+		},
+	}
+	got := export(t, egaintest.Reading(sensor, 21.5, 40, ts))
+	want := `sensor,account=b,building=main,location=Living\ Room,sensor_id=ID1,zone=north temperature=21.5,humidity=40 1705312800` + "\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	sensor := egain.Sensor{SensorID: "ID1", Location: "lab"}
+	tests := []struct {
+		name    string
+		kind    egain.Kind
+		fixture string
+		want    string
+	}{
+		{"indoor", egain.KindIndoor, egaintest.Indoor,
+			"sensor,location=lab,sensor_id=ID1 temperature=21.3,humidity=42.5 1705312800\n"},
+		{"indoor with probes", egain.KindIndoor, egaintest.IndoorWithProbes,
+			"sensor,location=lab,sensor_id=ID1 temperature=20.8,humidity=38,co2=612 1705312800\n"},
+		{"outdoor", egain.KindOutdoor, egaintest.Outdoor,
+			"sensor,location=lab,sensor_id=ID1 temperature=3.4,humidity=81,wind_speed=5.2,wind_direction=240,pressure=1008.3,precipitation=0.4 1705312800\n"},
+		{"heating", egain.KindHeating, egaintest.Heating,
+			"sensor,location=lab,sensor_id=ID1 flow_temperature=45.2,return_temperature=38.7,flow_setpoint=46,valve_0_position=62.5 1705312800\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := export(t, egaintest.MustDecode(tt.kind, tt.fixture, sensor))
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Mechanism is a SASL mechanism to authenticate with.
+type Mechanism string
+
+const (
+	Plain       Mechanism = "plain"
+	ScramSHA256 Mechanism = "scram-sha-256"
+	ScramSHA512 Mechanism = "scram-sha-512"
+)
+
+// Exporter publishes the sensor readings as JSON messages to a Kafka topic,
+// keyed by the sensor id so the readings of a sensor stay in order.
+type Exporter struct {
+	client  *kgo.Client
+	topic   string
+	timeout time.Duration
+
+	clientID string
+	tls      *tls.Config
+	sasl     sasl.Mechanism
+}
+
+type Option func(e *Exporter) error
+
+// New connects to the given seed brokers, e.g. localhost:9092.
+func New(brokers []string, topic string, opts ...Option) (*Exporter, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("no Kafka brokers")
+	}
+	if topic == "" {
+		return nil, errors.New("empty Kafka topic")
+	}
+
+	e := &Exporter{
+		topic:    topic,
+		timeout:  10 * time.Second,
+		clientID: "again-scraper-go",
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ko := []kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.ClientID(e.clientID),
+		kgo.RecordDeliveryTimeout(e.timeout),
+	}
+	if e.tls != nil {
+		ko = append(ko, kgo.DialTLSConfig(e.tls))
+	}
+	if e.sasl != nil {
+		ko = append(ko, kgo.SASL(e.sasl))
+	}
+
+	client, err := kgo.NewClient(ko...)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("cannot connect to Kafka brokers %s: %w", strings.Join(brokers, ","), err)
+	}
+	e.client = client
+	return e, nil
+}
+
+// WithClientID sets the Kafka client id.
+func WithClientID(id string) Option {
+	return func(e *Exporter) error {
+		e.clientID = id
+		return nil
+	}
+}
+
+// WithTLS connects to the brokers with TLS.
+func WithTLS(c *tls.Config) Option {
+	return func(e *Exporter) error {
+		e.tls = c
+		return nil
+	}
+}
+
+// WithSASL authenticates with the given mechanism and credentials.
+func WithSASL(m Mechanism, username, password string) Option {
+	return func(e *Exporter) error {
+		switch m {
+		case Plain:
+			e.sasl = plain.Auth{User: username, Pass: password}.AsMechanism()
+		case ScramSHA256:
+			e.sasl = scram.Auth{User: username, Pass: password}.AsSha256Mechanism()
+		case ScramSHA512:
+			e.sasl = scram.Auth{User: username, Pass: password}.AsSha512Mechanism()
+		default:
+			return fmt.Errorf("unknown SASL mechanism %q, expected %s, %s or %s", m, Plain, ScramSHA256, ScramSHA512)
+		}
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of connecting and of delivering the messages,
+// 10 seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Exporter) error {
+		if d <= 0 {
+			return errors.New("invalid Kafka timeout")
+		}
+		e.timeout = d
+		return nil
+	}
+}
+
+// Export publishes the readings and waits until the brokers acknowledged
+// them. Unchanged readings were published before and are skipped.
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var records []*kgo.Record
+	for _, r := range readings {
+		if r.Unchanged {
+			continue
+		}
+		value, err := json.Marshal(exporter.NewMessage(r))
+		if err != nil {
+			return err
+		}
+		records = append(records, &kgo.Record{Key: []byte(r.SensorID), Value: value, Timestamp: r.Timestamp})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, res := range e.client.ProduceSync(ctx, records...) {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("cannot publish reading of sensor %s: %w", res.Record.Key, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close flushes the pending messages and disconnects from the brokers.
+func (e *Exporter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	err := e.client.Flush(ctx)
+	e.client.Close()
+	return err
+}
@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Message is the JSON representation of a reading published to message
+// brokers.
+type Message struct {
+	SensorID    string            `json:"sensorId"`
+	Location    string            `json:"location"`
+	Kind        string            `json:"kind"`
+	Account     string            `json:"account,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Temperature float64           `json:"temperature"`
+	Humidity    float64           `json:"humidity"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Stale       bool              `json:"stale"`
+	// TemperatureUnit is the unit of the temperatures of the message, e.g.
+	// °F with --temperature-unit F.
+	TemperatureUnit string `json:"temperatureUnit"`
+
+	// TimeZone is the time zone of the sensor, if set, and LocalTimestamp
+	// the timestamp in it.
+	TimeZone       string     `json:"timeZone,omitempty"`
+	LocalTimestamp *time.Time `json:"localTimestamp,omitempty"`
+
+	Battery        *float64 `json:"battery,omitempty"`
+	SignalStrength *float64 `json:"signalStrength,omitempty"`
+	CO2            *float64 `json:"co2,omitempty"`
+	VOC            *float64 `json:"voc,omitempty"`
+
+	ExternalTemperatures []egain.Value  `json:"externalTemperatures,omitempty"`
+	Values               []egain.Value  `json:"values,omitempty"`
+	Weather              *egain.Weather `json:"weather,omitempty"`
+	Heating              *egain.Heating `json:"heating,omitempty"`
+	Comfort              *egain.Comfort `json:"comfort,omitempty"`
+	MoldRisk             *float64       `json:"moldRisk,omitempty"`
+	Trend                *egain.Trend   `json:"trend,omitempty"`
+}
+
+// NewMessage returns the message of the reading.
+func NewMessage(r *egain.SensorReading) Message {
+	m := Message{
+		SensorID:    r.SensorID,
+		Location:    r.Location,
+		Kind:        r.Kind.String(),
+		Account:     r.Account,
+		Labels:      r.Labels,
+		Temperature: r.Temperature,
+		Humidity:    r.Humidity,
+		Timestamp:   r.Timestamp,
+		Stale:       r.Stale,
+
+		Battery:        r.Battery,
+		SignalStrength: r.SignalStrength,
+		CO2:            r.CO2,
+		VOC:            r.VOC,
+
+		ExternalTemperatures: r.ExternalTemperatures,
+		Values:               r.Values,
+		Weather:              r.Weather,
+		Heating:              r.Heating,
+		Comfort:              r.Comfort,
+		MoldRisk:             r.MoldRisk,
+		Trend:                r.Trend,
+	}
+	m.TemperatureUnit = r.TemperatureUnit
+	if m.TemperatureUnit == "" {
+		// the unit the API reports the temperatures in
+		m.TemperatureUnit = "°C"
+	}
+	if r.TimeZone != nil {
+		t := r.In(r.Timestamp)
+		m.TimeZone = r.TimeZone.String()
+		m.LocalTimestamp = &t
+	}
+	return m
+}
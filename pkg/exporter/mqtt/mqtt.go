@@ -0,0 +1,300 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.uber.org/zap"
+)
+
+// Exporter publishes the sensor readings to an MQTT broker. For every sensor
+// a Home Assistant discovery config is published once, so the sensors show up
+// as temperature and humidity entities automatically.
+type Exporter struct {
+	client          paho.Client
+	log             *zap.Logger
+	topicPrefix     string
+	discoveryPrefix string
+	qos             byte
+	temperatureUnit string
+	timeout         time.Duration
+	clientID        string
+	username        string
+	password        string
+
+	mu         sync.Mutex
+	discovered map[string]bool
+}
+
+type Option func(e *Exporter) error
+
+// New connects to the given broker, e.g. tcp://localhost:1883.
+func New(broker string, opts ...Option) (*Exporter, error) {
+	e := &Exporter{
+		log:             zap.L(),
+		topicPrefix:     "egain",
+		discoveryPrefix: "homeassistant",
+		timeout:         10 * time.Second,
+		discovered:      map[string]bool{},
+		clientID:        "again-scraper-go",
+		temperatureUnit: "°C",
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	co := paho.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(e.clientID).
+		SetUsername(e.username).
+		SetPassword(e.password).
+		SetAutoReconnect(true)
+
+	// the discovery configs are retained, but publish them again after a
+	// reconnect in case the broker lost them
+	co.SetOnConnectHandler(func(paho.Client) {
+		e.mu.Lock()
+		e.discovered = map[string]bool{}
+		e.mu.Unlock()
+	})
+
+	e.client = paho.NewClient(co)
+	if err := e.wait(e.client.Connect()); err != nil {
+		return nil, fmt.Errorf("cannot connect to MQTT broker %s: %w", broker, err)
+	}
+	return e, nil
+}
+
+// WithCredentials sets the username and password used to connect.
+func WithCredentials(username, password string) Option {
+	return func(e *Exporter) error {
+		e.username = username
+		e.password = password
+		return nil
+	}
+}
+
+// WithClientID sets the MQTT client id.
+func WithClientID(id string) Option {
+	return func(e *Exporter) error {
+		e.clientID = id
+		return nil
+	}
+}
+
+// WithTopicPrefix sets the prefix of the state topics, "egain" by default.
+// Readings are published to <prefix>/<sensor id>/state.
+func WithTopicPrefix(p string) Option {
+	return func(e *Exporter) error {
+		p = strings.Trim(p, "/")
+		if p == "" {
+			return errors.New("empty MQTT topic prefix")
+		}
+		e.topicPrefix = p
+		return nil
+	}
+}
+
+// WithDiscoveryPrefix sets the Home Assistant discovery prefix,
+// "homeassistant" by default. An empty prefix disables discovery.
+func WithDiscoveryPrefix(p string) Option {
+	return func(e *Exporter) error {
+		e.discoveryPrefix = strings.Trim(p, "/")
+		return nil
+	}
+}
+
+// WithQoS sets the quality of service of the published messages.
+func WithQoS(qos byte) Option {
+	return func(e *Exporter) error {
+		if qos > 2 {
+			return fmt.Errorf("invalid MQTT QoS %d", qos)
+		}
+		e.qos = qos
+		return nil
+	}
+}
+
+// WithTemperatureUnit sets the unit of the temperature entities, °C by
+// default. The readings have to be converted to the unit beforehand.
+func WithTemperatureUnit(unit string) Option {
+	return func(e *Exporter) error {
+		if unit == "" {
+			return errors.New("empty temperature unit")
+		}
+		e.temperatureUnit = unit
+		return nil
+	}
+}
+
+func WithLogger(l *zap.Logger) Option {
+	return func(e *Exporter) error {
+		e.log = l
+		return nil
+	}
+}
+
+// state is the payload published to the state topic of a sensor.
+type state struct {
+	Temperature float64   `json:"temperature"`
+	Humidity    float64   `json:"humidity"`
+	CO2         *float64  `json:"co2,omitempty"`
+	VOC         *float64  `json:"voc,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Location    string    `json:"location,omitempty"`
+	// TemperatureUnit is the unit of the temperature entities
+	TemperatureUnit string `json:"temperatureUnit"`
+}
+
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var errs []error
+	for _, r := range readings {
+		// only the climate readings are published, heating systems have no
+		// temperature and humidity
+		if r.Heating != nil {
+			continue
+		}
+		if err := e.discover(r); err != nil {
+			errs = append(errs, err)
+		}
+
+		payload, err := json.Marshal(state{
+			Temperature: r.Temperature,
+			Humidity:    r.Humidity,
+			CO2:         r.CO2,
+			VOC:         r.VOC,
+			Timestamp:   r.Timestamp,
+			Location:    r.Location,
+
+			TemperatureUnit: e.temperatureUnit,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := e.wait(e.client.Publish(e.stateTopic(r.SensorID), e.qos, false, payload)); err != nil {
+			errs = append(errs, fmt.Errorf("cannot publish reading of sensor %s: %w", r.SensorID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close disconnects from the broker.
+func (e *Exporter) Close() error {
+	e.client.Disconnect(uint(e.timeout.Milliseconds()))
+	return nil
+}
+
+func (e *Exporter) stateTopic(sensorID string) string {
+	return e.topicPrefix + "/" + topicEscaper.Replace(sensorID) + "/state"
+}
+
+// discoveryConfig is the Home Assistant MQTT discovery payload of an entity.
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	StateTopic        string          `json:"state_topic"`
+	DeviceClass       string          `json:"device_class"`
+	StateClass        string          `json:"state_class"`
+	UnitOfMeasurement string          `json:"unit_of_measurement"`
+	ValueTemplate     string          `json:"value_template"`
+	Device            discoveryDevice `json:"device"`
+}
+
+type discoveryDevice struct {
+	Identifiers   []string `json:"identifiers"`
+	Name          string   `json:"name"`
+	Manufacturer  string   `json:"manufacturer"`
+	SuggestedArea string   `json:"suggested_area,omitempty"`
+}
+
+// discover publishes the discovery configs of the sensor of the reading, if
+// not done yet.
+func (e *Exporter) discover(r *egain.SensorReading) error {
+	if e.discoveryPrefix == "" {
+		return nil
+	}
+
+	e.mu.Lock()
+	done := e.discovered[r.SensorID]
+	e.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	name := r.Location
+	if name == "" {
+		name = r.SensorID
+	}
+	id := "egain_" + topicEscaper.Replace(r.SensorID)
+	device := discoveryDevice{
+		Identifiers:   []string{id},
+		Name:          "eGain " + name,
+		Manufacturer:  "eGain",
+		SuggestedArea: r.Location,
+	}
+
+	type entity struct {
+		key, name, class, unit string
+	}
+	entities := []entity{
+		{"temperature", "Temperature", "temperature", e.temperatureUnit},
+		{"humidity", "Humidity", "humidity", "%"},
+	}
+	// the air quality entities only exist for the sensors reporting it
+	if r.CO2 != nil {
+		entities = append(entities, entity{"co2", "CO2", "carbon_dioxide", "ppm"})
+	}
+	if r.VOC != nil {
+		entities = append(entities, entity{"voc", "VOC", "volatile_organic_compounds_parts", "ppb"})
+	}
+	for _, entity := range entities {
+		payload, err := json.Marshal(discoveryConfig{
+			Name:              entity.name,
+			UniqueID:          id + "_" + entity.key,
+			StateTopic:        e.stateTopic(r.SensorID),
+			DeviceClass:       entity.class,
+			StateClass:        "measurement",
+			UnitOfMeasurement: entity.unit,
+			ValueTemplate:     "{{ value_json." + entity.key + " }}",
+			Device:            device,
+		})
+		if err != nil {
+			return err
+		}
+
+		topic := e.discoveryPrefix + "/sensor/" + id + "_" + entity.key + "/config"
+		if err := e.wait(e.client.Publish(topic, e.qos, true, payload)); err != nil {
+			return fmt.Errorf("cannot publish discovery config of sensor %s: %w", r.SensorID, err)
+		}
+	}
+
+	e.log.Debug("published Home Assistant discovery config", zap.String("sensorId", r.SensorID))
+	e.mu.Lock()
+	e.discovered[r.SensorID] = true
+	e.mu.Unlock()
+	return nil
+}
+
+// wait waits for the token to complete within the timeout of the exporter.
+func (e *Exporter) wait(t paho.Token) error {
+	if !t.WaitTimeout(e.timeout) {
+		return errors.New("timeout")
+	}
+	return t.Error()
+}
+
+// topicEscaper replaces characters which are not allowed in topic levels.
+var topicEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_", " ", "_")
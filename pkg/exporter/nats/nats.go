@@ -0,0 +1,189 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+)
+
+// Exporter publishes the sensor readings as JSON messages to NATS subjects
+// made of the tags of their sensor, e.g. sensors.livingroom.ID123. With
+// JetStream the messages are acknowledged by the stream capturing the
+// subjects and deduplicated by the sensor and timestamp of the reading.
+type Exporter struct {
+	conn      *nats.Conn
+	js        jetstream.JetStream
+	subject   []string
+	jetStream bool
+	timeout   time.Duration
+	opts      []nats.Option
+}
+
+type Option func(e *Exporter) error
+
+// New connects to the given server, e.g. nats://localhost:4222.
+func New(url string, opts ...Option) (*Exporter, error) {
+	e := &Exporter{
+		subject: []string{"sensors", "{location}", "{id}"},
+		timeout: 10 * time.Second,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := nats.Connect(url, append(e.opts,
+		nats.Name("again-scraper-go"),
+		nats.Timeout(e.timeout),
+		nats.MaxReconnects(-1),
+	)...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to NATS server %s: %w", url, err)
+	}
+	if e.jetStream {
+		e.js, err = jetstream.New(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	e.conn = conn
+	return e, nil
+}
+
+// WithSubject sets the template mapping the tags of a sensor to the subject,
+// "sensors.{location}.{id}" by default. The template consists of dot
+// separated tokens with the placeholders {id}, {location} and {kind}.
+func WithSubject(template string) Option {
+	return func(e *Exporter) error {
+		tokens := strings.Split(template, ".")
+		for _, t := range tokens {
+			if t == "" || strings.ContainsAny(t, "*> \t") {
+				return fmt.Errorf("invalid NATS subject %q", template)
+			}
+		}
+		e.subject = tokens
+		return nil
+	}
+}
+
+// WithJetStream publishes the readings to JetStream and waits for their
+// acknowledgement. A stream capturing the subjects has to exist.
+func WithJetStream() Option {
+	return func(e *Exporter) error {
+		e.jetStream = true
+		return nil
+	}
+}
+
+// WithUserInfo authenticates with a username and password.
+func WithUserInfo(username, password string) Option {
+	return func(e *Exporter) error {
+		e.opts = append(e.opts, nats.UserInfo(username, password))
+		return nil
+	}
+}
+
+// WithCredentialsFile authenticates with the user JWT and seed of the given
+// credentials file.
+func WithCredentialsFile(path string) Option {
+	return func(e *Exporter) error {
+		e.opts = append(e.opts, nats.UserCredentials(path))
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of connecting and of publishing the readings,
+// 10 seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Exporter) error {
+		if d <= 0 {
+			return errors.New("invalid NATS timeout")
+		}
+		e.timeout = d
+		return nil
+	}
+}
+
+// tokenEscaper replaces the characters which are not allowed in the tokens
+// of a subject.
+var tokenEscaper = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "\t", "_")
+
+func token(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return tokenEscaper.Replace(s)
+}
+
+// subjectOf returns the subject of the readings of the sensor.
+func (e *Exporter) subjectOf(r *egain.SensorReading) string {
+	tags := strings.NewReplacer(
+		"{id}", token(r.SensorID),
+		"{location}", token(r.Location),
+		"{kind}", token(r.Kind.String()),
+	)
+	tokens := make([]string, len(e.subject))
+	for i, t := range e.subject {
+		tokens[i] = tags.Replace(t)
+	}
+	return strings.Join(tokens, ".")
+}
+
+// Export publishes the readings. Unchanged readings were published before and
+// are skipped.
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var errs []error
+	for _, r := range readings {
+		if r.Unchanged {
+			continue
+		}
+		payload, err := json.Marshal(exporter.NewMessage(r))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		subject := e.subjectOf(r)
+		if e.js != nil {
+			id := r.SensorID + "-" + strconv.FormatInt(r.Timestamp.UnixMilli(), 10)
+			_, err = e.js.Publish(ctx, subject, payload, jetstream.WithMsgID(id))
+		} else {
+			err = e.conn.Publish(subject, payload)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot publish reading of sensor %s: %w", r.SensorID, err))
+		}
+	}
+
+	// core NATS only reports errors once the messages are flushed
+	if e.js == nil {
+		if err := e.conn.FlushWithContext(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close flushes the pending messages and disconnects from the server.
+func (e *Exporter) Close() error {
+	err := e.conn.FlushTimeout(e.timeout)
+	e.conn.Close()
+	return err
+}
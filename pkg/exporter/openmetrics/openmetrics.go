@@ -0,0 +1,224 @@
+package openmetrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Gauge is a gauge of a reading.
+type Gauge struct {
+	Name  string
+	Help  string
+	Value float64
+}
+
+// measurementGauges are the names and help texts of the gauges of the
+// measurements of the readings by the names of the measurements.
+var measurementGauges = map[string]struct{ name, help string }{
+	"temperature":        {"egain_sensor_temperature", "The temperature of the sensor in the exported temperature unit"},
+	"humidity":           {"egain_sensor_humidity_percent", "The relative humidity of the sensor"},
+	"wind_speed":         {"egain_sensor_wind_speed_meters_per_second", "The wind speed of the outdoor sensor"},
+	"wind_direction":     {"egain_sensor_wind_direction_degrees", "The wind direction of the outdoor sensor"},
+	"pressure":           {"egain_sensor_pressure_hectopascals", "The air pressure of the outdoor sensor"},
+	"precipitation":      {"egain_sensor_precipitation_millimeters", "The precipitation of the outdoor sensor"},
+	"battery":            {"egain_sensor_battery_percent", "The battery level of the sensor"},
+	"signal_strength":    {"egain_sensor_signal_strength_dbm", "The signal strength of the radio link of the sensor"},
+	"co2":                {"egain_sensor_co2_ppm", "The carbon dioxide concentration of the sensor"},
+	"voc":                {"egain_sensor_voc_ppb", "The concentration of volatile organic compounds of the sensor"},
+	"dew_point":          {"egain_sensor_dew_point", "The dew point of the sensor in the exported temperature unit"},
+	"absolute_humidity":  {"egain_sensor_absolute_humidity_grams_per_cubic_meter", "The absolute humidity of the sensor"},
+	"heat_index":         {"egain_sensor_heat_index", "The heat index of the sensor in the exported temperature unit"},
+	"mold_risk":          {"egain_sensor_mold_risk_percent", "The rolling mold risk of the sensor"},
+	"temperature_trend":  {"egain_sensor_temperature_trend_per_hour", "The rate of change of the temperature over the trend window per hour"},
+	"humidity_trend":     {"egain_sensor_humidity_trend_percent_per_hour", "The rate of change of the relative humidity over the trend window per hour"},
+	"flow_temperature":   {"egain_sensor_flow_temperature", "The flow temperature of the heating system"},
+	"return_temperature": {"egain_sensor_return_temperature", "The return temperature of the heating system"},
+	"flow_setpoint":      {"egain_sensor_flow_setpoint", "The flow setpoint of the heating system"},
+}
+
+// Gauges returns the gauges of the measurements of the reading, see
+// egain.SensorReading.Metrics, and of its timestamp and staleness. The
+// measurements without a name, e.g. the valve positions, are named after
+// the measurement and its unit, e.g. egain_sensor_valve_0_position_percent.
+func Gauges(r *egain.SensorReading) []Gauge {
+	m := []Gauge{
+		{"egain_sensor_last_reading_timestamp_seconds", "The timestamp of the last reading of the sensor", float64(r.Timestamp.Unix())},
+		{"egain_sensor_stale", "Whether the last reading of the sensor is older than the max staleness (1) or not (0)", boolValue(r.Stale)},
+	}
+	for _, measurement := range r.Metrics() {
+		f, ok := measurementGauges[measurement.Name]
+		if !ok {
+			f.name = "egain_sensor_" + labelName(measurement.Name)
+			f.help = "The measurement " + measurement.Name + " of the sensor"
+			if measurement.Unit == "%" {
+				f.name += "_percent"
+			}
+			if measurement.Unit != "" {
+				f.help += " in " + measurement.Unit
+			}
+		}
+		m = append(m, Gauge{f.name, f.help, measurement.Value})
+	}
+	return m
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Labels returns the label set of the metrics of the reading, e.g.
+// {location="office",kind="indoor"}. The sensor_id is left out if the
+// labels of the sensor are given elsewhere, e.g. by the group of a
+// Pushgateway.
+func Labels(r *egain.SensorReading, sensorID bool) string {
+	var pairs []string
+	add := func(k, v string) {
+		if v != "" {
+			pairs = append(pairs, k+`="`+labelEscaper.Replace(v)+`"`)
+		}
+	}
+	if sensorID {
+		add("sensor_id", r.SensorID)
+	}
+	add("location", r.Location)
+	add("kind", r.Kind.String())
+	add("account", r.Account)
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		switch name := labelName(k); name {
+		case "", "location", "kind", "account", "sensor_id", "job", "instance":
+			// the labels of the sensor cannot override the built-in ones
+		default:
+			add(name, r.Labels[k])
+		}
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelName sanitizes the name of a label of a sensor.
+func labelName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Write writes the last reading of each sensor in the OpenMetrics text
+// format, which the Prometheus text parsers read as well. The samples have no
+// timestamps, as the textfile collector of node_exporter rejects them, the
+// time of a reading is its egain_sensor_last_reading_timestamp_seconds.
+func Write(w io.Writer, readings []*egain.SensorReading) error {
+	last := map[string]*egain.SensorReading{}
+	var ids []string
+	for _, r := range readings {
+		if p, ok := last[r.SensorID]; !ok {
+			ids = append(ids, r.SensorID)
+		} else if r.Timestamp.Before(p.Timestamp) {
+			continue
+		}
+		last[r.SensorID] = r
+	}
+
+	// the samples of a metric are grouped below its metadata, in the order
+	// the metrics first appear
+	type family struct {
+		help    string
+		samples []string
+	}
+	var (
+		names    []string
+		families = map[string]*family{}
+	)
+	for _, id := range ids {
+		r := last[id]
+		labels := Labels(r, true)
+		for _, g := range Gauges(r) {
+			f, ok := families[g.Name]
+			if !ok {
+				f = &family{help: g.Help}
+				families[g.Name] = f
+				names = append(names, g.Name)
+			}
+			f.samples = append(f.samples, g.Name+labels+" "+strconv.FormatFloat(g.Value, 'f', -1, 64))
+		}
+	}
+
+	var b bytes.Buffer
+	for _, name := range names {
+		f := families[name]
+		b.WriteString("# HELP " + name + " " + f.help + "\n")
+		b.WriteString("# TYPE " + name + " gauge\n")
+		for _, s := range f.samples {
+			b.WriteString(s + "\n")
+		}
+	}
+	b.WriteString("# EOF\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Exporter writes the readings of each cycle to a file in the OpenMetrics
+// text format, e.g. for the textfile collector of node_exporter on hosts
+// which allow no other listener. The file is replaced on every export, so it
+// only holds the sensors of the last cycle.
+type Exporter struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// New creates an exporter writing to the file at the given path, which should
+// end in .prom for the textfile collector.
+func New(path string) (*Exporter, error) {
+	if path == "" {
+		return nil, errors.New("missing path of the OpenMetrics file")
+	}
+	return &Exporter{path: path}, nil
+}
+
+// Export replaces the file with the readings. The file is written to a
+// temporary file first, so the collector never reads a partial file, which
+// it ignores as it does not end in .prom.
+func (e *Exporter) Export(_ context.Context, readings []*egain.SensorReading) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var b bytes.Buffer
+	if err := Write(&b, readings); err != nil {
+		return err
+	}
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", e.path, err)
+	}
+	if err := os.Rename(tmp, e.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("cannot write %s: %w", e.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package openmetrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain/egaintest"
+)
+
+func TestFixtures(t *testing.T) {
+	sensor := egain.Sensor{SensorID: "ID1", Location: "lab"}
+	tests := []struct {
+		name    string
+		kind    egain.Kind
+		fixture string
+		want    string
+	}{
+		{"indoor", egain.KindIndoor, egaintest.Indoor, `# HELP egain_sensor_last_reading_timestamp_seconds The timestamp of the last reading of the sensor
+# TYPE egain_sensor_last_reading_timestamp_seconds gauge
+egain_sensor_last_reading_timestamp_seconds{sensor_id="ID1",location="lab",kind="indoor"} 1705312800
+# HELP egain_sensor_stale Whether the last reading of the sensor is older than the max staleness (1) or not (0)
+# TYPE egain_sensor_stale gauge
+egain_sensor_stale{sensor_id="ID1",location="lab",kind="indoor"} 0
+# HELP egain_sensor_temperature The temperature of the sensor in the exported temperature unit
+# TYPE egain_sensor_temperature gauge
+egain_sensor_temperature{sensor_id="ID1",location="lab",kind="indoor"} 21.3
+# HELP egain_sensor_humidity_percent The relative humidity of the sensor
+# TYPE egain_sensor_humidity_percent gauge
+egain_sensor_humidity_percent{sensor_id="ID1",location="lab",kind="indoor"} 42.5
+# EOF
+`},
+		{"indoor with probes", egain.KindIndoor, egaintest.IndoorWithProbes, `# HELP egain_sensor_last_reading_timestamp_seconds The timestamp of the last reading of the sensor
+# TYPE egain_sensor_last_reading_timestamp_seconds gauge
+egain_sensor_last_reading_timestamp_seconds{sensor_id="ID1",location="lab",kind="indoor"} 1705312800
+# HELP egain_sensor_stale Whether the last reading of the sensor is older than the max staleness (1) or not (0)
+# TYPE egain_sensor_stale gauge
+egain_sensor_stale{sensor_id="ID1",location="lab",kind="indoor"} 0
+# HELP egain_sensor_temperature The temperature of the sensor in the exported temperature unit
+# TYPE egain_sensor_temperature gauge
+egain_sensor_temperature{sensor_id="ID1",location="lab",kind="indoor"} 20.8
+# HELP egain_sensor_humidity_percent The relative humidity of the sensor
+# TYPE egain_sensor_humidity_percent gauge
+egain_sensor_humidity_percent{sensor_id="ID1",location="lab",kind="indoor"} 38
+# HELP egain_sensor_co2_ppm The carbon dioxide concentration of the sensor
+# TYPE egain_sensor_co2_ppm gauge
+egain_sensor_co2_ppm{sensor_id="ID1",location="lab",kind="indoor"} 612
+# EOF
+`},
+		{"outdoor", egain.KindOutdoor, egaintest.Outdoor, `# HELP egain_sensor_last_reading_timestamp_seconds The timestamp of the last reading of the sensor
+# TYPE egain_sensor_last_reading_timestamp_seconds gauge
+egain_sensor_last_reading_timestamp_seconds{sensor_id="ID1",location="lab",kind="outdoor"} 1705312800
+# HELP egain_sensor_stale Whether the last reading of the sensor is older than the max staleness (1) or not (0)
+# TYPE egain_sensor_stale gauge
+egain_sensor_stale{sensor_id="ID1",location="lab",kind="outdoor"} 0
+# HELP egain_sensor_temperature The temperature of the sensor in the exported temperature unit
+# TYPE egain_sensor_temperature gauge
+egain_sensor_temperature{sensor_id="ID1",location="lab",kind="outdoor"} 3.4
+# HELP egain_sensor_humidity_percent The relative humidity of the sensor
+# TYPE egain_sensor_humidity_percent gauge
+egain_sensor_humidity_percent{sensor_id="ID1",location="lab",kind="outdoor"} 81
+# HELP egain_sensor_wind_speed_meters_per_second The wind speed of the outdoor sensor
+# TYPE egain_sensor_wind_speed_meters_per_second gauge
+egain_sensor_wind_speed_meters_per_second{sensor_id="ID1",location="lab",kind="outdoor"} 5.2
+# HELP egain_sensor_wind_direction_degrees The wind direction of the outdoor sensor
+# TYPE egain_sensor_wind_direction_degrees gauge
+egain_sensor_wind_direction_degrees{sensor_id="ID1",location="lab",kind="outdoor"} 240
+# HELP egain_sensor_pressure_hectopascals The air pressure of the outdoor sensor
+# TYPE egain_sensor_pressure_hectopascals gauge
+egain_sensor_pressure_hectopascals{sensor_id="ID1",location="lab",kind="outdoor"} 1008.3
+# HELP egain_sensor_precipitation_millimeters The precipitation of the outdoor sensor
+# TYPE egain_sensor_precipitation_millimeters gauge
+egain_sensor_precipitation_millimeters{sensor_id="ID1",location="lab",kind="outdoor"} 0.4
+# EOF
+`},
+		{"heating", egain.KindHeating, egaintest.Heating, `# HELP egain_sensor_last_reading_timestamp_seconds The timestamp of the last reading of the sensor
+# TYPE egain_sensor_last_reading_timestamp_seconds gauge
+egain_sensor_last_reading_timestamp_seconds{sensor_id="ID1",location="lab",kind="heating"} 1705312800
+# HELP egain_sensor_stale Whether the last reading of the sensor is older than the max staleness (1) or not (0)
+# TYPE egain_sensor_stale gauge
+egain_sensor_stale{sensor_id="ID1",location="lab",kind="heating"} 0
+# HELP egain_sensor_flow_temperature The flow temperature of the heating system
+# TYPE egain_sensor_flow_temperature gauge
+egain_sensor_flow_temperature{sensor_id="ID1",location="lab",kind="heating"} 45.2
+# HELP egain_sensor_return_temperature The return temperature of the heating system
+# TYPE egain_sensor_return_temperature gauge
+egain_sensor_return_temperature{sensor_id="ID1",location="lab",kind="heating"} 38.7
+# HELP egain_sensor_flow_setpoint The flow setpoint of the heating system
+# TYPE egain_sensor_flow_setpoint gauge
+egain_sensor_flow_setpoint{sensor_id="ID1",location="lab",kind="heating"} 46
+# HELP egain_sensor_valve_0_position_percent The measurement valve_0_position of the sensor in %
+# TYPE egain_sensor_valve_0_position_percent gauge
+egain_sensor_valve_0_position_percent{sensor_id="ID1",location="lab",kind="heating"} 62.5
+# EOF
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := Write(&b, []*egain.SensorReading{egaintest.MustDecode(tt.kind, tt.fixture, sensor)}); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGaugesOfAllMeasurements(t *testing.T) {
+	outdoor := &egain.SensorReading{
+		Sensor:  egain.Sensor{SensorID: "ID1", Kind: egain.KindOutdoor},
+		Weather: &egain.Weather{WindSpeed: 5.2, WindDirection: 240, Pressure: 1008.3, Precipitation: 0.4},
+		Comfort: &egain.Comfort{DewPoint: 1, AbsoluteHumidity: 5.1, HeatIndex: 3},
+	}
+	heating := &egain.SensorReading{
+		Sensor:  egain.Sensor{SensorID: "ID2", Kind: egain.KindHeating},
+		Heating: &egain.Heating{Valves: []egain.Valve{{Position: 62.5}, {Position: 10}}},
+	}
+
+	got := map[string]float64{}
+	for _, r := range []*egain.SensorReading{outdoor, heating} {
+		for _, g := range Gauges(r) {
+			got[g.Name] = g.Value
+		}
+		// every measurement is exported, next to the timestamp and staleness
+		if n, want := len(Gauges(r)), len(r.Metrics())+2; n != want {
+			t.Errorf("%s: %d gauges, want %d", r.SensorID, n, want)
+		}
+	}
+	for name, want := range map[string]float64{
+		"egain_sensor_wind_speed_meters_per_second":            5.2,
+		"egain_sensor_wind_direction_degrees":                  240,
+		"egain_sensor_pressure_hectopascals":                   1008.3,
+		"egain_sensor_precipitation_millimeters":               0.4,
+		"egain_sensor_absolute_humidity_grams_per_cubic_meter": 5.1,
+		"egain_sensor_heat_index":                              3,
+		"egain_sensor_valve_0_position_percent":                62.5,
+		"egain_sensor_valve_1_position_percent":                10,
+	} {
+		if v, ok := got[name]; !ok || v != want {
+			t.Errorf("%s = %v (%t), want %v", name, v, ok, want)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// OTel records the sensor readings as OpenTelemetry metrics. Readings which
+// are unchanged since the previous fetch are not recorded again, only counted
+// as suppressed.
+type OTel struct {
+	temperature metric.Float64Gauge
+	humidity    metric.Float64Gauge
+	lastReading metric.Float64Histogram
+	suppressed  metric.Int64Counter
+	stale       metric.Int64Gauge
+	external    metric.Float64Gauge
+
+	// the weather of outdoor sensors
+	windSpeed     metric.Float64Gauge
+	windDirection metric.Float64Gauge
+	pressure      metric.Float64Gauge
+	precipitation metric.Float64Gauge
+
+	// the air quality of indoor sensors
+	co2 metric.Float64Gauge
+	voc metric.Float64Gauge
+
+	// the derived comfort metrics
+	dewPoint         metric.Float64Gauge
+	absoluteHumidity metric.Float64Gauge
+	heatIndex        metric.Float64Gauge
+	moldRisk         metric.Float64Gauge
+	temperatureTrend metric.Float64Gauge
+	humidityTrend    metric.Float64Gauge
+
+	// the health of the devices
+	battery        metric.Float64Gauge
+	signalStrength metric.Float64Gauge
+
+	// the heating systems
+	flowTemperature   metric.Float64Gauge
+	returnTemperature metric.Float64Gauge
+	flowSetpoint      metric.Float64Gauge
+	valvePosition     metric.Float64Gauge
+
+	// gauges are the instruments of the measurements of the Metrics of the
+	// readings by their name
+	gauges map[string]metric.Float64Gauge
+
+	// temperatureUnit is the unit of the temperature instruments
+	temperatureUnit string
+
+	// values holds the instruments of the generic values array, which are
+	// created on demand per unit
+	meter  metric.Meter
+	mu     sync.Mutex
+	values map[string]metric.Float64Gauge
+	// missing are the measurements without a gauge which were logged
+	missing map[string]bool
+
+	log *zap.Logger
+}
+
+type OTelOption func(o *OTel) error
+
+// NewOTel creates the instruments on the given meter.
+func NewOTel(meter metric.Meter, opts ...OTelOption) (*OTel, error) {
+	var (
+		o   = OTel{meter: meter, values: map[string]metric.Float64Gauge{}, missing: map[string]bool{}, temperatureUnit: "°C", log: zap.L()}
+		err error
+	)
+
+	// apply the options
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	o.temperature, err = meter.Float64Gauge("sensor.temperature",
+		metric.WithUnit(o.temperatureUnit),
+		metric.WithDescription("Indoor temperature in "+o.temperatureUnit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.humidity, err = meter.Float64Gauge("sensor.humidity",
+		metric.WithUnit("%rH"),
+		metric.WithDescription("Indoor relative humidity as a percentage"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.lastReading, err = meter.Float64Histogram(
+		"sensor.lastReading.duration",
+		metric.WithDescription("The duration since the last sensor reading in minutes"),
+		metric.WithUnit("min"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.suppressed, err = meter.Int64Counter(
+		"sensor.readings.suppressed",
+		metric.WithDescription("The number of unchanged readings which were not recorded again"),
+		metric.WithUnit("{reading}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.stale, err = meter.Int64Gauge(
+		"sensor.stale",
+		metric.WithDescription("Whether the last reading of the sensor is older than the max staleness (1) or not (0)"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.external, err = meter.Float64Gauge("sensor.external_temperature",
+		metric.WithUnit(o.temperatureUnit),
+		metric.WithDescription("Temperature of the external probes of the sensor in "+o.temperatureUnit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.windSpeed, err = meter.Float64Gauge("sensor.wind.speed",
+		metric.WithUnit("m/s"),
+		metric.WithDescription("Wind speed measured by outdoor sensors"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.windDirection, err = meter.Float64Gauge("sensor.wind.direction",
+		metric.WithUnit("deg"),
+		metric.WithDescription("Wind direction measured by outdoor sensors in degrees"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.pressure, err = meter.Float64Gauge("sensor.pressure",
+		metric.WithUnit("hPa"),
+		metric.WithDescription("Air pressure measured by outdoor sensors"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.precipitation, err = meter.Float64Gauge("sensor.precipitation",
+		metric.WithUnit("mm"),
+		metric.WithDescription("Precipitation measured by outdoor sensors"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.dewPoint, err = meter.Float64Gauge("sensor.dew_point",
+		metric.WithUnit(o.temperatureUnit),
+		metric.WithDescription("Dew point derived from the temperature and humidity in "+o.temperatureUnit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.absoluteHumidity, err = meter.Float64Gauge("sensor.absolute_humidity",
+		metric.WithUnit("g/m3"),
+		metric.WithDescription("Absolute humidity derived from the temperature and humidity"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.heatIndex, err = meter.Float64Gauge("sensor.heat_index",
+		metric.WithUnit(o.temperatureUnit),
+		metric.WithDescription("Heat index derived from the temperature and humidity in "+o.temperatureUnit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.co2, err = meter.Float64Gauge("sensor.co2",
+		metric.WithUnit("ppm"),
+		metric.WithDescription("Carbon dioxide concentration in ppm"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.voc, err = meter.Float64Gauge("sensor.voc",
+		metric.WithUnit("ppb"),
+		metric.WithDescription("Concentration of volatile organic compounds in ppb"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.moldRisk, err = meter.Float64Gauge("sensor.mold_risk",
+		metric.WithUnit("%"),
+		metric.WithDescription("Share of the recent time in which the conditions at the surfaces allowed mold growth"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.temperatureTrend, err = meter.Float64Gauge("sensor.temperature.trend",
+		metric.WithUnit(o.temperatureUnit+"/h"),
+		metric.WithDescription("Rate of change of the temperature over the trend window in "+o.temperatureUnit+" per hour"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.humidityTrend, err = meter.Float64Gauge("sensor.humidity.trend",
+		metric.WithUnit("%/h"),
+		metric.WithDescription("Rate of change of the relative humidity over the trend window in percentage points per hour"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.battery, err = meter.Float64Gauge("sensor.battery",
+		metric.WithUnit("%"),
+		metric.WithDescription("Battery level of the sensor device as a percentage"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.signalStrength, err = meter.Float64Gauge("sensor.signal_strength",
+		metric.WithUnit("dBm"),
+		metric.WithDescription("Signal strength (RSSI) of the radio link of the sensor device"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.flowTemperature, err = meter.Float64Gauge("sensor.heating.flow_temperature",
+		metric.WithUnit(o.temperatureUnit),
+		metric.WithDescription("Flow temperature of the heating system in "+o.temperatureUnit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.returnTemperature, err = meter.Float64Gauge("sensor.heating.return_temperature",
+		metric.WithUnit(o.temperatureUnit),
+		metric.WithDescription("Return temperature of the heating system in "+o.temperatureUnit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.flowSetpoint, err = meter.Float64Gauge("sensor.heating.flow_setpoint",
+		metric.WithUnit(o.temperatureUnit),
+		metric.WithDescription("Target flow temperature of the heating system in "+o.temperatureUnit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.valvePosition, err = meter.Float64Gauge("sensor.heating.valve.position",
+		metric.WithUnit("%"),
+		metric.WithDescription("Opening of the valves of the heating system in percent"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.gauges = map[string]metric.Float64Gauge{
+		"temperature":        o.temperature,
+		"humidity":           o.humidity,
+		"wind_speed":         o.windSpeed,
+		"wind_direction":     o.windDirection,
+		"pressure":           o.pressure,
+		"precipitation":      o.precipitation,
+		"battery":            o.battery,
+		"signal_strength":    o.signalStrength,
+		"co2":                o.co2,
+		"voc":                o.voc,
+		"dew_point":          o.dewPoint,
+		"absolute_humidity":  o.absoluteHumidity,
+		"heat_index":         o.heatIndex,
+		"mold_risk":          o.moldRisk,
+		"temperature_trend":  o.temperatureTrend,
+		"humidity_trend":     o.humidityTrend,
+		"flow_temperature":   o.flowTemperature,
+		"return_temperature": o.returnTemperature,
+		"flow_setpoint":      o.flowSetpoint,
+	}
+	return &o, nil
+}
+
+// WithTemperatureUnit sets the unit of the temperature instruments, °C by
+// default. The readings have to be converted to the unit beforehand.
+func WithTemperatureUnit(unit string) OTelOption {
+	return func(o *OTel) error {
+		if unit == "" {
+			return errors.New("empty temperature unit")
+		}
+		o.temperatureUnit = unit
+		return nil
+	}
+}
+
+// WithOTelLogger sets the logger of the exporter, the global logger by
+// default.
+func WithOTelLogger(l *zap.Logger) OTelOption {
+	return func(o *OTel) error {
+		o.log = l
+		return nil
+	}
+}
+
+func (o *OTel) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	for _, data := range readings {
+		ctx := readingContext(ctx, data)
+		attrs := metric.WithAttributes(sensorAttributes(data)...)
+		// the age of the reading keeps growing, so it is recorded anyway,
+		// readings ahead of the local clock are recorded as fresh
+		o.lastReading.Record(ctx, max(time.Since(data.Timestamp), 0).Minutes(), attrs)
+		o.stale.Record(ctx, boolToInt(data.Stale), attrs)
+		if data.Unchanged {
+			o.suppressed.Add(ctx, 1, attrs)
+			continue
+		}
+		// the valves are recorded with their names below
+		for _, m := range data.Metrics() {
+			if g, ok := o.gauges[m.Name]; ok {
+				g.Record(ctx, m.Value, attrs)
+			} else if !isValvePosition(m.Name) {
+				o.logMissing(m.Name)
+			}
+		}
+		if h := data.Heating; h != nil {
+			o.recordValves(ctx, data, h)
+			continue
+		}
+		for i, t := range data.ExternalTemperatures {
+			o.external.Record(ctx, t.Value, metric.WithAttributes(append(sensorAttributes(data), attribute.Int("sensor.probe", i))...))
+		}
+		for i, v := range data.Values {
+			g, err := o.valueGauge(v.Unit)
+			if err != nil {
+				return err
+			}
+			g.Record(ctx, v.Value, metric.WithAttributes(append(sensorAttributes(data), attribute.Int("sensor.value.index", i))...))
+		}
+	}
+	return nil
+}
+
+// isValvePosition returns whether the measurement is the position of a valve,
+// which is recorded with the name of the valve by recordValves.
+func isValvePosition(name string) bool {
+	return strings.HasPrefix(name, "valve_") && strings.HasSuffix(name, "_position")
+}
+
+// logMissing logs a measurement without a gauge once, it is a bug of the
+// exporter which would otherwise drop the measurement silently.
+func (o *OTel) logMissing(name string) {
+	o.mu.Lock()
+	logged := o.missing[name]
+	o.missing[name] = true
+	o.mu.Unlock()
+	if !logged {
+		o.log.Error("no gauge for the measurement, it is not recorded", zap.String("measurement", name))
+	}
+}
+
+// recordValves records the positions of the valves of a heating system.
+func (o *OTel) recordValves(ctx context.Context, data *egain.SensorReading, h *egain.Heating) {
+	for i, v := range h.Valves {
+		name := v.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		o.valvePosition.Record(ctx, v.Position, metric.WithAttributes(append(sensorAttributes(data), attribute.String("sensor.valve", name))...))
+	}
+}
+
+// readingContext returns the context the metrics of the reading are recorded
+// with. It carries the span context of the fetch of the reading, so sampled
+// fetches are attached to the metrics as exemplars.
+func readingContext(ctx context.Context, data *egain.SensorReading) context.Context {
+	if !data.SpanContext.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithSpanContext(ctx, data.SpanContext)
+}
+
+// sensorAttributes returns the attributes identifying the sensor of the
+// reading, including its labels.
+func sensorAttributes(data *egain.SensorReading) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 3+len(data.Labels))
+	attrs = append(attrs,
+		attribute.String("sensor.id", data.SensorID),
+		attribute.String("sensor.location", data.Location),
+	)
+	if data.Account != "" {
+		attrs = append(attrs, attribute.String("account", data.Account))
+	}
+	if data.TimeZone != nil {
+		attrs = append(attrs, attribute.String("sensor.timezone", data.TimeZone.String()))
+	}
+	for k, v := range data.Labels {
+		// the labels of the sensor cannot override the built-in ones, the
+		// sensor. prefix is rejected by the config already
+		if k == "account" || strings.HasPrefix(k, "sensor.") {
+			continue
+		}
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return attrs
+}
+
+// valueGauge returns the gauge for values with the given unit, creating it if
+// needed.
+func (o *OTel) valueGauge(unit string) (metric.Float64Gauge, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if g, ok := o.values[unit]; ok {
+		return g, nil
+	}
+	g, err := o.meter.Float64Gauge("sensor.value."+unitName(unit),
+		metric.WithUnit(unit),
+		metric.WithDescription("Additional value reported by the sensor in "+unit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	o.values[unit] = g
+	return g, nil
+}
+
+// unitNames are the instrument names of well-known units.
+var unitNames = map[string]string{
+	"":   "unknown",
+	"%":  "percent",
+	"°C": "celsius",
+	"°F": "fahrenheit",
+}
+
+// unitName derives a valid instrument name from the unit.
+func unitName(unit string) string {
+	if n, ok := unitNames[unit]; ok {
+		return n
+	}
+	n := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, unit)
+	if n = strings.Trim(n, "_"); n == "" {
+		return "unknown"
+	}
+	return n
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
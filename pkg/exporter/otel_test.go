@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSensorAttributesLabels(t *testing.T) {
+	r := &egain.SensorReading{Sensor: egain.Sensor{
+		SensorID: "ID1",
+		Location: "lab",
+		Account:  "b",
+		Labels:   map[string]string{"account": "x", "building": "main"},
+	}}
+	set := attribute.NewSet(sensorAttributes(r)...)
+	// the labels of the sensor cannot override the built-in attributes
+	if v, _ := set.Value("account"); v.AsString() != "b" {
+		t.Errorf("account = %q, want b", v.AsString())
+	}
+	if v, _ := set.Value("building"); v.AsString() != "main" {
+		t.Errorf("building = %q, want main", v.AsString())
+	}
+}
+
+func TestGaugesOfAllMetrics(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	o, err := NewOTel(noop.NewMeterProvider().Meter("test"), WithOTelLogger(zap.New(core)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := 1.0
+	indoor := &egain.SensorReading{
+		Sensor:   egain.Sensor{SensorID: "ID1", Kind: egain.KindIndoor},
+		Weather:  &egain.Weather{},
+		Comfort:  &egain.Comfort{},
+		Trend:    &egain.Trend{},
+		CO2:      &v,
+		VOC:      &v,
+		MoldRisk: &v,
+	}
+	indoor.Timestamp = time.Now()
+	indoor.Battery = &v
+	indoor.SignalStrength = &v
+	heating := &egain.SensorReading{
+		Sensor:  egain.Sensor{SensorID: "ID2", Kind: egain.KindHeating},
+		Heating: &egain.Heating{Valves: []egain.Valve{{Name: "north", Position: 50}}},
+	}
+	heating.Timestamp = time.Now()
+
+	// every measurement a reading can have is recorded by its gauge, the
+	// valves by recordValves
+	for _, r := range []*egain.SensorReading{indoor, heating} {
+		for _, m := range r.Metrics() {
+			if _, ok := o.gauges[m.Name]; !ok && !isValvePosition(m.Name) {
+				t.Errorf("no gauge for the measurement %s", m.Name)
+			}
+		}
+	}
+	if err := o.Export(context.Background(), []*egain.SensorReading{indoor, heating}); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range logs.All() {
+		t.Errorf("unexpected log %q %v", e.Message, e.ContextMap())
+	}
+}
+
+func TestMissingGaugeIsLogged(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	o, err := NewOTel(noop.NewMeterProvider().Meter("test"), WithOTelLogger(zap.New(core)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(o.gauges, "humidity")
+
+	r := &egain.SensorReading{Sensor: egain.Sensor{SensorID: "ID1", Kind: egain.KindIndoor}}
+	r.Timestamp = time.Now()
+	for i := 0; i < 2; i++ {
+		if err := o.Export(context.Background(), []*egain.SensorReading{r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a missing gauge is logged once, not on every export
+	if got := logs.FilterField(zap.String("measurement", "humidity")).Len(); got != 1 {
+		t.Errorf("got %d logs of the missing gauge, want 1", got)
+	}
+}
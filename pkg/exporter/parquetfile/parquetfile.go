@@ -0,0 +1,158 @@
+package parquetfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/parquet-go/parquet-go"
+)
+
+// row is the schema of the Parquet files.
+type row struct {
+	SensorID    string    `parquet:"sensor_id,dict"`
+	Location    string    `parquet:"location,dict"`
+	Kind        string    `parquet:"kind,dict"`
+	Temperature float64   `parquet:"temperature"`
+	Humidity    float64   `parquet:"humidity"`
+	Timestamp   time.Time `parquet:"timestamp,timestamp(millisecond)"`
+}
+
+// Exporter buffers the readings and writes them to Parquet files partitioned
+// by the day of their timestamp, e.g. dir/date=2024-01-02/readings-....parquet,
+// so they can be queried with DuckDB or Athena. The buffer is flushed to new
+// files once the rotation interval has passed and on Close.
+type Exporter struct {
+	dir      string
+	rotation time.Duration
+
+	mu      sync.Mutex
+	rows    []row
+	flushed time.Time
+}
+
+type Option func(e *Exporter) error
+
+// New creates an exporter writing to the given directory, which is created if
+// necessary.
+func New(dir string, opts ...Option) (*Exporter, error) {
+	e := &Exporter{
+		dir:      dir,
+		rotation: time.Hour,
+		flushed:  time.Now(),
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WithRotation sets the interval at which the buffered readings are written
+// to new files, one hour by default.
+func WithRotation(d time.Duration) Option {
+	return func(e *Exporter) error {
+		if d <= 0 {
+			return fmt.Errorf("invalid Parquet rotation %s", d)
+		}
+		e.rotation = d
+		return nil
+	}
+}
+
+// KeepsTimestamps returns true, the rows have the timestamps of the readings.
+func (e *Exporter) KeepsTimestamps() (bool, time.Duration) {
+	return true, 0
+}
+
+// Export buffers the readings and flushes the buffer if the rotation is due.
+// Unchanged readings are already buffered and heating systems have no
+// temperature and humidity, both are skipped.
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, r := range readings {
+		if r.Unchanged || r.Heating != nil {
+			continue
+		}
+		e.rows = append(e.rows, row{
+			SensorID:    r.SensorID,
+			Location:    r.Location,
+			Kind:        r.Kind.String(),
+			Temperature: r.Temperature,
+			Humidity:    r.Humidity,
+			Timestamp:   r.Timestamp,
+		})
+	}
+
+	if now := time.Now(); now.Sub(e.flushed) >= e.rotation {
+		return e.flush(now)
+	}
+	return nil
+}
+
+// flush writes the buffered rows to one file per day. The rows are kept for
+// the next flush if they cannot be written.
+func (e *Exporter) flush(now time.Time) error {
+	e.flushed = now
+	if len(e.rows) == 0 {
+		return nil
+	}
+
+	days := map[string][]row{}
+	for _, r := range e.rows {
+		day := r.Timestamp.UTC().Format(time.DateOnly)
+		days[day] = append(days[day], r)
+	}
+
+	var (
+		errs   []error
+		failed []row
+		name   = "readings-" + now.UTC().Format("20060102T150405.000Z") + ".parquet"
+	)
+	for day, rows := range days {
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Timestamp.Before(rows[j].Timestamp) })
+		if err := writeFile(filepath.Join(e.dir, "date="+day, name), rows); err != nil {
+			errs = append(errs, err)
+			failed = append(failed, rows...)
+		}
+	}
+	e.rows = failed
+	return errors.Join(errs...)
+}
+
+// writeFile writes the rows to a temporary file which is renamed once it is
+// complete, so no partial files are read.
+func writeFile(path string, rows []row) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := parquet.WriteFile(tmp, rows, parquet.Compression(&parquet.Zstd)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Close flushes the buffered readings.
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.flush(time.Now())
+}
@@ -0,0 +1,228 @@
+package pushgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter/openmetrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// DefaultJob is the job of the pushed groups unless WithJob is given.
+const DefaultJob = "again-scraper-go"
+
+// Exporter pushes the last values of the sensor readings to a Prometheus
+// Pushgateway, e.g. for --once runs which cannot be scraped. Each sensor is
+// pushed as its own group of the job, instance and sensor_id, which replaces
+// the values pushed for the sensor before.
+type Exporter struct {
+	client   *http.Client
+	url      *url.URL
+	job      string
+	instance string
+	timeout  time.Duration
+	// deleteOnClose deletes the pushed groups on Close
+	deleteOnClose bool
+
+	mu     sync.Mutex
+	pushed map[string]bool
+}
+
+type Option func(e *Exporter) error
+
+// New creates an exporter pushing to the Pushgateway at the given url, e.g.
+// http://localhost:9091.
+func New(gatewayURL string, opts ...Option) (*Exporter, error) {
+	u, err := url.Parse(gatewayURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Pushgateway url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Pushgateway url %q, expected an http or https url", gatewayURL)
+	}
+
+	e := &Exporter{
+		client:  &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		url:     u,
+		job:     DefaultJob,
+		timeout: 10 * time.Second,
+		pushed:  map[string]bool{},
+	}
+	e.instance, _ = os.Hostname()
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// WithJob sets the job of the pushed groups, DefaultJob by default.
+func WithJob(job string) Option {
+	return func(e *Exporter) error {
+		if job == "" {
+			return errors.New("empty Pushgateway job")
+		}
+		e.job = job
+		return nil
+	}
+}
+
+// WithInstance sets the instance of the pushed groups, the hostname by
+// default.
+func WithInstance(instance string) Option {
+	return func(e *Exporter) error {
+		e.instance = instance
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of a push, 10 seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Exporter) error {
+		if d <= 0 {
+			return errors.New("invalid Pushgateway timeout")
+		}
+		e.timeout = d
+		return nil
+	}
+}
+
+// WithDeleteOnClose deletes the groups of the pushed sensors on Close, so the
+// Pushgateway does not keep serving their last values once the scraper
+// stopped. It should only be set if the scraper is closed after a successful
+// run, a new run pushes the groups again.
+func WithDeleteOnClose() Option {
+	return func(e *Exporter) error {
+		e.deleteOnClose = true
+		return nil
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client.
+func WithHTTPClient(c *http.Client) Option {
+	return func(e *Exporter) error {
+		e.client = c
+		return nil
+	}
+}
+
+// Export pushes the readings of each sensor, only the last reading of a
+// sensor is pushed as the gauges have no timestamps.
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	last := map[string]*egain.SensorReading{}
+	var ids []string
+	for _, r := range readings {
+		if p, ok := last[r.SensorID]; !ok {
+			ids = append(ids, r.SensorID)
+		} else if r.Timestamp.Before(p.Timestamp) {
+			continue
+		}
+		last[r.SensorID] = r
+	}
+
+	var errs []error
+	for _, id := range ids {
+		var body bytes.Buffer
+		writeMetrics(&body, last[id])
+		if err := e.do(ctx, http.MethodPut, id, &body); err != nil {
+			errs = append(errs, fmt.Errorf("sensor %s: %w", id, err))
+			continue
+		}
+		e.mu.Lock()
+		e.pushed[id] = true
+		e.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Close deletes the pushed groups if WithDeleteOnClose is set.
+func (e *Exporter) Close() error {
+	if !e.deleteOnClose {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	for id := range e.pushed {
+		if err := e.do(context.Background(), http.MethodDelete, id, nil); err != nil {
+			errs = append(errs, fmt.Errorf("sensor %s: %w", id, err))
+			continue
+		}
+		delete(e.pushed, id)
+	}
+	return errors.Join(errs...)
+}
+
+// do sends a request for the group of the sensor.
+func (e *Exporter) do(ctx context.Context, method, sensorID string, body io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, e.groupURL(sensorID), body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot push to the Pushgateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("cannot push to the Pushgateway: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// groupURL returns the url of the group of the sensor.
+func (e *Exporter) groupURL(sensorID string) string {
+	u := *e.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/metrics" +
+		groupLabel("job", e.job) + groupLabel("instance", e.instance) + groupLabel("sensor_id", sensorID)
+	u.RawPath = ""
+	return u.String()
+}
+
+// groupLabel returns the path segments of a grouping label, values which
+// cannot be part of a path are base64 encoded.
+func groupLabel(name, value string) string {
+	if value == "" {
+		return "/" + name + "@base64/="
+	}
+	if strings.ContainsAny(value, "/%?#") || url.PathEscape(value) != value {
+		return "/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return "/" + name + "/" + value
+}
+
+// writeMetrics writes the gauges of the reading in the text format of
+// Prometheus, the sensor_id is a label of the group.
+func writeMetrics(w *bytes.Buffer, r *egain.SensorReading) {
+	labels := openmetrics.Labels(r, false)
+	for _, m := range openmetrics.Gauges(r) {
+		w.WriteString("# HELP " + m.Name + " " + m.Help + "\n")
+		w.WriteString("# TYPE " + m.Name + " gauge\n")
+		w.WriteString(m.Name + labels + " " + strconv.FormatFloat(m.Value, 'f', -1, 64) + "\n")
+	}
+}
@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.uber.org/zap"
+)
+
+// Timestamper is implemented by the exporters which store the readings at
+// their own Timestamp rather than at the time of the export, so historical and
+// delayed readings are stored at the time they were taken.
+type Timestamper interface {
+	// KeepsTimestamps returns whether the readings keep their timestamps,
+	// which may depend on the configuration of the exporter, and how old a
+	// reading may be for the backend to accept it, 0 if there is no limit.
+	KeepsTimestamps() (ok bool, maxAge time.Duration)
+}
+
+// KeepsTimestamps returns whether the exporter keeps the timestamps of the
+// readings and their maximum age, see Timestamper. The other exporters, e.g.
+// gauges recorded at the time of the collection, do not.
+func KeepsTimestamps(e Exporter) (bool, time.Duration) {
+	if t, ok := e.(Timestamper); ok {
+		return t.KeepsTimestamps()
+	}
+	return false, 0
+}
+
+// Timestamped returns the exporters which keep the timestamps of the
+// readings, e.g. for a backfill. The readings older than the maximum age of an
+// exporter are left out of its exports, as its backend would reject them.
+func (m Multi) Timestamped(log *zap.Logger) Multi {
+	var exporters Multi
+	for _, e := range m {
+		ok, maxAge := KeepsTimestamps(e)
+		switch {
+		case !ok:
+			continue
+		case maxAge > 0:
+			exporters = append(exporters, &ageLimit{next: e, maxAge: maxAge, log: log, now: time.Now})
+		default:
+			exporters = append(exporters, e)
+		}
+	}
+	return exporters
+}
+
+// ageLimit exports the readings which are not older than the maximum age.
+type ageLimit struct {
+	next   Exporter
+	maxAge time.Duration
+	log    *zap.Logger
+	now    func() time.Time
+}
+
+func (a *ageLimit) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	oldest := a.now().Add(-a.maxAge)
+	recent := make([]*egain.SensorReading, 0, len(readings))
+	for _, r := range readings {
+		if !r.Timestamp.Before(oldest) {
+			recent = append(recent, r)
+		}
+	}
+	if n := len(readings) - len(recent); n > 0 {
+		a.log.Warn("skipping readings older than the exporter accepts", zap.Int("readings", n), zap.Duration("maxAge", a.maxAge))
+	}
+	if len(recent) == 0 {
+		return nil
+	}
+	return a.next.Export(ctx, recent)
+}
+
+func (a *ageLimit) KeepsTimestamps() (bool, time.Duration) {
+	return true, a.maxAge
+}
+
+func (a *ageLimit) RecordError(ctx context.Context, err error) {
+	if r, ok := a.next.(ErrorRecorder); ok {
+		r.RecordError(ctx, err)
+	}
+}
+
+func (a *ageLimit) Close() error {
+	if c, ok := a.next.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package timestream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite/types"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// maxBatch is the maximum number of records of a WriteRecords request.
+const maxBatch = 100
+
+// API is the part of the Timestream write client the exporter uses.
+type API interface {
+	WriteRecords(ctx context.Context, params *timestreamwrite.WriteRecordsInput, optFns ...func(*timestreamwrite.Options)) (*timestreamwrite.WriteRecordsOutput, error)
+}
+
+// Exporter writes the sensor readings to a Timestream table as multi-measure
+// records of the measure reading, with the sensor_id, location, account, kind
+// and the labels of the sensor as dimensions.
+type Exporter struct {
+	client   API
+	database string
+	table    string
+}
+
+type Option func(e *Exporter) error
+
+// New creates an exporter writing to the table of the database with the
+// given AWS configuration, e.g. of the default credential chain.
+func New(cfg aws.Config, database, table string, opts ...Option) (*Exporter, error) {
+	if database == "" || table == "" {
+		return nil, errors.New("missing Timestream database or table")
+	}
+
+	e := &Exporter{
+		client:   timestreamwrite.NewFromConfig(cfg),
+		database: database,
+		table:    table,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// WithClient replaces the Timestream write client.
+func WithClient(c API) Option {
+	return func(e *Exporter) error {
+		e.client = c
+		return nil
+	}
+}
+
+// KeepsTimestamps returns true. Timestream rejects the records older than the
+// memory store retention of the table, which fail the export.
+func (e *Exporter) KeepsTimestamps() (bool, time.Duration) {
+	return true, 0
+}
+
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var records []types.Record
+	for _, r := range readings {
+		// Timestream rejects the same record with other values, unchanged
+		// readings are the same record
+		if r.Unchanged {
+			continue
+		}
+		records = append(records, record(r))
+	}
+
+	for len(records) > 0 {
+		n := min(len(records), maxBatch)
+		_, err := e.client.WriteRecords(ctx, &timestreamwrite.WriteRecordsInput{
+			DatabaseName: aws.String(e.database),
+			TableName:    aws.String(e.table),
+			Records:      records[:n],
+		})
+		var rejected *types.RejectedRecordsException
+		if errors.As(err, &rejected) {
+			return fmt.Errorf("cannot write Timestream records: %d of %d were rejected: %s", len(rejected.RejectedRecords), n, reason(rejected))
+		}
+		if err != nil {
+			return fmt.Errorf("cannot write Timestream records: %w", err)
+		}
+		records = records[n:]
+	}
+	return nil
+}
+
+// reason returns the reason of the first rejected record.
+func reason(e *types.RejectedRecordsException) string {
+	for _, r := range e.RejectedRecords {
+		if r.Reason != nil {
+			return *r.Reason
+		}
+	}
+	return e.ErrorMessage()
+}
+
+// record returns the record of the reading with the measurements of its
+// Metrics as measure values.
+func record(r *egain.SensorReading) types.Record {
+	dims := []types.Dimension{
+		{Name: aws.String("sensor_id"), Value: aws.String(r.SensorID)},
+		{Name: aws.String("kind"), Value: aws.String(r.Kind.String())},
+	}
+	if r.Location != "" {
+		dims = append(dims, types.Dimension{Name: aws.String("location"), Value: aws.String(r.Location)})
+	}
+	if r.Account != "" {
+		dims = append(dims, types.Dimension{Name: aws.String("account"), Value: aws.String(r.Account)})
+	}
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		switch k {
+		case "sensor_id", "kind", "location", "account":
+			// the labels of the sensor cannot override the built-in ones
+		default:
+			if v := r.Labels[k]; v != "" {
+				dims = append(dims, types.Dimension{Name: aws.String(k), Value: aws.String(v)})
+			}
+		}
+	}
+
+	var values []types.MeasureValue
+	add := func(name string, v float64) {
+		values = append(values, types.MeasureValue{
+			Name:  aws.String(name),
+			Value: aws.String(strconv.FormatFloat(v, 'f', -1, 64)),
+			Type:  types.MeasureValueTypeDouble,
+		})
+	}
+	for _, m := range r.Metrics() {
+		add(m.Name, m.Value)
+	}
+
+	return types.Record{
+		Dimensions:       dims,
+		MeasureName:      aws.String("reading"),
+		MeasureValueType: types.MeasureValueTypeMulti,
+		MeasureValues:    values,
+		Time:             aws.String(strconv.FormatInt(r.Timestamp.UnixMilli(), 10)),
+		TimeUnit:         types.TimeUnitMilliseconds,
+	}
+}
@@ -0,0 +1,269 @@
+// Package webhook posts the readings as JSON to an HTTP endpoint, so simple
+// integrations can consume them without a message broker.
+//
+// Each request carries a batch of readings in the format of the messages of
+// the brokers:
+//
+//	{"readings": [{"sensorId": "ID12312", "temperature": 21.5, ...}]}
+//
+// If a secret is set, the requests are signed with an HMAC-SHA256 of the
+// timestamp of the request and the body, i.e. of
+// <X-Egain-Timestamp>.<body>, in the header
+//
+//	X-Egain-Signature: sha256=<hex>
+//
+// so the receiver can verify the readings came from the scraper and reject
+// replayed requests by their timestamp.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+)
+
+// Headers of the signed requests.
+const (
+	TimestampHeader = "X-Egain-Timestamp"
+	SignatureHeader = "X-Egain-Signature"
+)
+
+// Exporter posts the readings to the URL of the webhook.
+type Exporter struct {
+	url       string
+	client    *http.Client
+	log       *zap.Logger
+	secret    []byte
+	header    http.Header
+	batchSize int
+	retries   int
+	backoff   time.Duration
+	now       func() time.Time
+}
+
+type Option func(e *Exporter) error
+
+// payload is the body of a request.
+type payload struct {
+	Readings []exporter.Message `json:"readings"`
+}
+
+// New creates an exporter posting to the HTTP(S) URL.
+func New(rawURL string, opts ...Option) (*Exporter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid webhook url %q, expected http or https", u.Redacted())
+	}
+	e := &Exporter{
+		url:       rawURL,
+		client:    &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 30 * time.Second},
+		log:       zap.L(),
+		header:    http.Header{},
+		batchSize: 500,
+		retries:   3,
+		backoff:   time.Second,
+		now:       time.Now,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// WithSecret signs the requests with the secret, see the package.
+func WithSecret(secret string) Option {
+	return func(e *Exporter) error {
+		e.secret = []byte(secret)
+		return nil
+	}
+}
+
+// WithHeader sets a header of the requests, e.g. an Authorization header.
+func WithHeader(key, value string) Option {
+	return func(e *Exporter) error {
+		if key == "" {
+			return errors.New("empty webhook header name")
+		}
+		e.header.Add(key, value)
+		return nil
+	}
+}
+
+// WithBatchSize sets the maximum number of readings posted in a single
+// request, 500 by default.
+func WithBatchSize(n int) Option {
+	return func(e *Exporter) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid webhook batch size %d", n)
+		}
+		e.batchSize = n
+		return nil
+	}
+}
+
+// WithRetries sets how often a failed request is retried, 3 times by
+// default. The backoff between the attempts doubles every time, unless the
+// endpoint asks for a longer one with a Retry-After header.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(e *Exporter) error {
+		if n < 0 || backoff < 0 {
+			return errors.New("invalid webhook retries")
+		}
+		e.retries = n
+		e.backoff = backoff
+		return nil
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client, which times out after 30
+// seconds.
+func WithHTTPClient(c *http.Client) Option {
+	return func(e *Exporter) error {
+		e.client = c
+		return nil
+	}
+}
+
+func WithLogger(l *zap.Logger) Option {
+	return func(e *Exporter) error {
+		e.log = l
+		return nil
+	}
+}
+
+// URL returns the URL of the webhook with its password redacted.
+func (e *Exporter) URL() string {
+	u, _ := url.Parse(e.url)
+	return u.Redacted()
+}
+
+// KeepsTimestamps returns true, the messages carry the timestamps of the
+// readings.
+func (e *Exporter) KeepsTimestamps() (bool, time.Duration) {
+	return true, 0
+}
+
+// Export posts the readings in batches. Unchanged readings were posted before
+// and are skipped.
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	var messages []exporter.Message
+	for _, r := range readings {
+		if r.Unchanged {
+			continue
+		}
+		messages = append(messages, exporter.NewMessage(r))
+	}
+
+	var errs []error
+	for start := 0; start < len(messages); start += e.batchSize {
+		batch := messages[start:min(start+e.batchSize, len(messages))]
+		if err := e.post(ctx, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post posts a batch, retrying transient errors.
+func (e *Exporter) post(ctx context.Context, messages []exporter.Message) error {
+	body, err := json.Marshal(payload{Readings: messages})
+	if err != nil {
+		return err
+	}
+
+	backoff := e.backoff
+	for attempt := 0; ; attempt++ {
+		wait, err := e.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= e.retries || wait < 0 {
+			return fmt.Errorf("cannot post %d readings to the webhook: %w", len(messages), err)
+		}
+
+		wait = max(wait, backoff)
+		e.log.Warn("cannot post readings to the webhook, retrying", zap.Int("readings", len(messages)), zap.Duration("backoff", wait), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// send sends a single request. On failure it returns the wait the endpoint
+// asked for with a Retry-After header, 0 if it did not and -1 if the request
+// is not retried, i.e. it was rejected or the context is done.
+func (e *Exporter) send(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	for k, v := range e.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(e.secret) > 0 {
+		ts := strconv.FormatInt(e.now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(e.secret, ts, body))
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode/100 == 2 {
+		return 0, nil
+	}
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	// the endpoint may be overloaded or restarting, other client errors are
+	// not fixed by a retry
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode/100 != 5 {
+		return -1, err
+	}
+	if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && s > 0 {
+		return time.Duration(s) * time.Second, err
+	}
+	return 0, err
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp and the body with
+// the secret, as sent in the SignatureHeader.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
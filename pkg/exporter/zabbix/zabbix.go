@@ -0,0 +1,246 @@
+package zabbix
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+const (
+	defaultPort = "10051"
+	// maxResponse bounds the response of the server, which is a short status
+	maxResponse = 1 << 16
+)
+
+// header is the header of the packets of the Zabbix protocol, version 1.
+var header = []byte("ZBXD\x01")
+
+// Exporter sends the temperature and humidity of the sensor readings to the
+// trapper items of a Zabbix server or proxy, like zabbix_sender. The items
+// of a sensor are keyed by its ID, e.g. egain.temperature[ID123], and belong
+// to a single host in Zabbix.
+type Exporter struct {
+	addr    string
+	host    string
+	prefix  string
+	timeout time.Duration
+}
+
+type Option func(e *Exporter) error
+
+// New creates an exporter sending to the Zabbix server at the given address,
+// the port defaults to 10051.
+func New(addr string, opts ...Option) (*Exporter, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultPort)
+	}
+
+	e := &Exporter{
+		addr:    addr,
+		prefix:  "egain",
+		timeout: 10 * time.Second,
+	}
+	e.host, _ = os.Hostname()
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if e.host == "" {
+		return nil, errors.New("missing Zabbix host")
+	}
+	return e, nil
+}
+
+// WithHost sets the name of the host of the items in Zabbix, the hostname by
+// default.
+func WithHost(host string) Option {
+	return func(e *Exporter) error {
+		if host == "" {
+			return errors.New("empty Zabbix host")
+		}
+		e.host = host
+		return nil
+	}
+}
+
+var keyPattern = regexp.MustCompile(`^[0-9A-Za-z_.-]+$`)
+
+// WithKeyPrefix sets the prefix of the item keys, "egain" by default, e.g.
+// egain.temperature[ID123].
+func WithKeyPrefix(p string) Option {
+	return func(e *Exporter) error {
+		p = strings.Trim(p, ".")
+		if p != "" && !keyPattern.MatchString(p) {
+			return fmt.Errorf("invalid Zabbix key prefix %q, expected letters, digits, '.', '_' or '-'", p)
+		}
+		e.prefix = p
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of sending the readings, 10 seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Exporter) error {
+		if d <= 0 {
+			return errors.New("invalid Zabbix timeout")
+		}
+		e.timeout = d
+		return nil
+	}
+}
+
+// item is a value of a trapper item.
+type item struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+	NS    int    `json:"ns"`
+}
+
+type request struct {
+	Request string `json:"request"`
+	Data    []item `json:"data"`
+	Clock   int64  `json:"clock"`
+	NS      int    `json:"ns"`
+}
+
+type response struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+// KeepsTimestamps returns true, the values are sent with the clock of the
+// readings.
+func (e *Exporter) KeepsTimestamps() (bool, time.Duration) {
+	return true, 0
+}
+
+func (e *Exporter) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	// heating systems have no temperature and humidity
+	var items []item
+	for _, r := range readings {
+		if r.Heating != nil {
+			continue
+		}
+		items = append(items,
+			e.item("temperature", r, r.Temperature),
+			e.item("humidity", r, r.Humidity),
+		)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(request{Request: "sender data", Data: items, Clock: now.Unix(), NS: now.Nanosecond()})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %w", e.addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(packet(payload)); err != nil {
+		return fmt.Errorf("cannot send readings to %s: %w", e.addr, err)
+	}
+	resp, err := readResponse(conn)
+	if err != nil {
+		return fmt.Errorf("cannot read the response of %s: %w", e.addr, err)
+	}
+	if resp.Response != "success" {
+		return fmt.Errorf("zabbix server %s rejected the readings: %s", e.addr, resp.Info)
+	}
+	// items which are unknown or not trapper items are failed
+	if failed := processed("failed", resp.Info); failed > 0 {
+		return fmt.Errorf("zabbix server %s failed %d of %d items, are the trapper items of host %s configured?", e.addr, failed, len(items), e.host)
+	}
+	return nil
+}
+
+// item returns the item of the metric of the reading.
+func (e *Exporter) item(metric string, r *egain.SensorReading, value float64) item {
+	key := metric + "[" + r.SensorID + "]"
+	if e.prefix != "" {
+		key = e.prefix + "." + key
+	}
+	return item{
+		Host:  e.host,
+		Key:   key,
+		Value: strconv.FormatFloat(value, 'f', -1, 64),
+		Clock: r.Timestamp.Unix(),
+		NS:    r.Timestamp.Nanosecond(),
+	}
+}
+
+// packet frames the payload with the header and its length.
+func packet(payload []byte) []byte {
+	b := make([]byte, 0, len(header)+8+len(payload))
+	b = append(b, header...)
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(payload)))
+	return append(b, payload...)
+}
+
+// readResponse reads the response packet of the server.
+func readResponse(r io.Reader) (*response, error) {
+	h := make([]byte, len(header)+8)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(h[:len(header)], header) {
+		return nil, errors.New("invalid response header")
+	}
+	n := binary.LittleEndian.Uint64(h[len(header):])
+	if n > maxResponse {
+		return nil, fmt.Errorf("response of %d bytes is too large", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+// processed returns the count of the field of the info of a response, e.g.
+// "processed: 2; failed: 0; total: 2; seconds spent: 0.000055".
+func processed(field, info string) int {
+	for _, part := range strings.Split(info, ";") {
+		k, v, ok := strings.Cut(part, ":")
+		if !ok || strings.TrimSpace(k) != field {
+			continue
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(v))
+		return n
+	}
+	return 0
+}
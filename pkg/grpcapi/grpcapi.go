@@ -0,0 +1,69 @@
+// Package grpcapi implements the gRPC API of the scraper on top of its
+// in-memory state.
+package grpcapi
+
+import (
+	"context"
+	"slices"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/api/egainv1"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/state"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server serves the readings of the registry.
+type Server struct {
+	egainv1.UnimplementedReadingsServiceServer
+
+	state *state.Registry
+}
+
+var _ egainv1.ReadingsServiceServer = (*Server)(nil)
+
+// NewServer creates a server for the readings of the registry.
+func NewServer(s *state.Registry) *Server {
+	return &Server{state: s}
+}
+
+func (s *Server) GetLatestReadings(ctx context.Context, req *egainv1.GetLatestReadingsRequest) (*egainv1.GetLatestReadingsResponse, error) {
+	resp := &egainv1.GetLatestReadingsResponse{}
+	for _, r := range s.state.Latest() {
+		if matches(req.GetSensorIds(), r) {
+			resp.Readings = append(resp.Readings, toProto(r))
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) StreamReadings(req *egainv1.StreamReadingsRequest, stream egainv1.ReadingsService_StreamReadingsServer) error {
+	ctx := stream.Context()
+	for r := range s.state.Subscribe(ctx) {
+		if !matches(req.GetSensorIds(), r) {
+			continue
+		}
+		if err := stream.Send(toProto(r)); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// matches returns whether the reading is of one of the sensors, all sensors
+// match if none is given.
+func matches(sensorIDs []string, r *egain.SensorReading) bool {
+	return len(sensorIDs) == 0 || slices.Contains(sensorIDs, r.SensorID)
+}
+
+func toProto(r *egain.SensorReading) *egainv1.Reading {
+	return &egainv1.Reading{
+		SensorId:    r.SensorID,
+		Location:    r.Location,
+		Kind:        r.Kind.String(),
+		Temperature: r.Temperature,
+		Humidity:    r.Humidity,
+		Timestamp:   timestamppb.New(r.Timestamp),
+		Unchanged:   r.Unchanged,
+		Stale:       r.Stale,
+	}
+}
@@ -0,0 +1,183 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// watchRetry is the delay before a failed watch is started again.
+const watchRetry = 5 * time.Second
+
+// ConfigMap is a ConfigMap of the core/v1 API.
+type ConfigMap struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+}
+
+// ConfigMaps are the ConfigMaps of a namespace matching a label selector. The
+// service account of the pod needs the list and watch permissions on
+// configmaps.
+type ConfigMaps struct {
+	client    *Client
+	namespace string
+	selector  string
+}
+
+// NewConfigMaps returns the ConfigMaps of the namespace matching the label
+// selector, e.g. app.kubernetes.io/component=egain-sensors. The namespace of
+// the pod is used if the namespace is empty.
+func NewConfigMaps(client *Client, namespace, selector string) (*ConfigMaps, error) {
+	if namespace == "" {
+		namespace = client.Namespace()
+	}
+	if namespace == "" {
+		return nil, errors.New("cannot read the namespace of the pod, please specify the namespace of the configmaps")
+	}
+	return &ConfigMaps{client: client, namespace: namespace, selector: selector}, nil
+}
+
+func (c *ConfigMaps) path(query url.Values) string {
+	query.Set("labelSelector", c.selector)
+	return "/api/v1/namespaces/" + url.PathEscape(c.namespace) + "/configmaps?" + query.Encode()
+}
+
+// List returns the matching ConfigMaps sorted by name, and the resource
+// version of the list to watch for changes from.
+func (c *ConfigMaps) List(ctx context.Context) ([]ConfigMap, string, error) {
+	resp, err := c.client.Do(ctx, http.MethodGet, c.path(url.Values{}), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", StatusError(resp)
+	}
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []ConfigMap `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("cannot decode configmaps: %w", err)
+	}
+	slices.SortFunc(list.Items, func(a, b ConfigMap) int {
+		return strings.Compare(a.Metadata.Name, b.Metadata.Name)
+	})
+	return list.Items, list.Metadata.ResourceVersion, nil
+}
+
+// Watch notifies changed whenever a matching ConfigMap is added, modified or
+// deleted after the resource version, until the context is done. Failed or
+// expired watches are started again from a new list, which is notified as a
+// change as events may have been missed.
+func (c *ConfigMaps) Watch(ctx context.Context, logger *zap.Logger, version string, changed chan<- struct{}) {
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	for {
+		if version == "" {
+			_, v, err := c.List(ctx)
+			if err != nil {
+				logger.Warn("cannot list configmaps", zap.String("namespace", c.namespace), zap.String("selector", c.selector), zap.Error(err))
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(watchRetry):
+				}
+				continue
+			}
+			version = v
+			notify()
+		}
+
+		var err error
+		version, err = c.watch(ctx, version, notify)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warn("cannot watch configmaps", zap.String("namespace", c.namespace), zap.String("selector", c.selector), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRetry):
+			}
+		}
+	}
+}
+
+// watch streams the events from the resource version until the server ends
+// the watch. It returns the resource version to continue from, empty if it
+// expired.
+func (c *ConfigMaps) watch(ctx context.Context, version string, notify func()) (string, error) {
+	resp, err := c.client.Stream(ctx, c.path(url.Values{
+		"watch":           {"true"},
+		"resourceVersion": {version},
+	}))
+	if err != nil {
+		return version, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return version, StatusError(resp)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := dec.Decode(&event); err != nil {
+			// the server ends watches after a while
+			if errors.Is(err, io.EOF) {
+				return version, nil
+			}
+			return version, fmt.Errorf("cannot decode watch event: %w", err)
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED", "DELETED":
+			var cm ConfigMap
+			if err := json.Unmarshal(event.Object, &cm); err != nil {
+				return version, fmt.Errorf("cannot decode configmap: %w", err)
+			}
+			version = cm.Metadata.ResourceVersion
+			notify()
+		case "ERROR":
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				// the resource version is too old, list again
+				return "", nil
+			}
+			return version, fmt.Errorf("watch failed: %s", status.Message)
+		}
+	}
+}
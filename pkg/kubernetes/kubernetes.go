@@ -0,0 +1,102 @@
+// Package kubernetes is a minimal client of the Kubernetes API for pods,
+// authenticating with the service account of the pod.
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccount is the directory of the credentials mounted into pods.
+const serviceAccount = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// requestTimeout is the timeout of the requests, except for watches.
+const requestTimeout = 10 * time.Second
+
+// Client sends requests to the API server of the cluster of the pod.
+type Client struct {
+	server    string
+	namespace string
+	client    *http.Client
+	stream    *http.Client
+}
+
+// NewInCluster creates a client connecting to the API server with the service
+// account of the pod.
+func NewInCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	// the namespace is only needed if the callers do not specify one
+	ns, _ := os.ReadFile(serviceAccount + "/namespace")
+
+	ca, err := os.ReadFile(serviceAccount + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the CA of the cluster: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates in the CA of the cluster")
+	}
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	return &Client{
+		server:    "https://" + net.JoinHostPort(host, port),
+		namespace: strings.TrimSpace(string(ns)),
+		client:    &http.Client{Timeout: requestTimeout, Transport: transport},
+		// watches stream until the server ends them
+		stream: &http.Client{Transport: transport},
+	}, nil
+}
+
+// Namespace returns the namespace of the pod, empty if it is unknown.
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// Do sends a request to the path of the API, the body is sent as JSON if it
+// is not nil.
+func (c *Client) Do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	return c.do(ctx, c.client, method, path, body)
+}
+
+// Stream sends a GET request to the path of the API without a timeout, for
+// watches.
+func (c *Client) Stream(ctx context.Context, path string) (*http.Response, error) {
+	return c.do(ctx, c.stream, http.MethodGet, path, nil)
+}
+
+func (c *Client) do(ctx context.Context, client *http.Client, method, path string, body []byte) (*http.Response, error) {
+	// the token is rotated by the kubelet, so it is read for every request
+	token, err := os.ReadFile(serviceAccount + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the service account token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return client.Do(req)
+}
+
+// StatusError returns the error of an unexpected response of the API.
+func StatusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %s from the Kubernetes API: %s", resp.Status, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package leader
+
+import (
+	"context"
+	"errors"
+)
+
+// FileLock is not supported on this platform.
+type FileLock struct{}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{}
+}
+
+func (l *FileLock) TryAcquire(ctx context.Context) (bool, error) {
+	return false, errors.New("file locks are not supported on this platform")
+}
+
+func (l *FileLock) Release(ctx context.Context) error {
+	return nil
+}
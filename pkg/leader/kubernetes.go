@@ -0,0 +1,198 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/kubernetes"
+)
+
+// microTime is the format of the timestamps of a Lease.
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// Lease is a Kubernetes Lease of the coordination.k8s.io/v1 API. It is
+// acquired if it does not exist, is held by nobody or was not renewed for
+// its lease duration, as observed by this instance so the clocks of the
+// replicas do not matter. The service account of the pod needs the get,
+// create and update permissions on leases.
+type Lease struct {
+	name, namespace string
+	identity        string
+	duration        time.Duration
+	client          *kubernetes.Client
+
+	mu       sync.Mutex
+	observed leaseSpec
+	version  string
+	seen     time.Time
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+type lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec leaseSpec `json:"spec"`
+}
+
+// NewLease creates a lease with the given name, held by the identity for the
+// lease duration. It connects to the API server with the service account of
+// the pod, the namespace of the pod is used if the namespace is empty.
+func NewLease(name, namespace, identity string, duration time.Duration) (*Lease, error) {
+	if name == "" || identity == "" {
+		return nil, errors.New("missing lease name or identity")
+	}
+	client, err := kubernetes.NewInCluster()
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		namespace = client.Namespace()
+	}
+	if namespace == "" {
+		return nil, errors.New("cannot read the namespace of the pod, please specify the namespace of the lease")
+	}
+
+	return &Lease{
+		name:      name,
+		namespace: namespace,
+		identity:  identity,
+		duration:  duration,
+		client:    client,
+	}, nil
+}
+
+func (l *Lease) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current, err := l.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if current == nil {
+		created := &lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		created.Metadata.Name = l.name
+		created.Metadata.Namespace = l.namespace
+		created.Spec = l.spec(now, now, 0)
+		return l.write(ctx, http.MethodPost, l.collection(), created)
+	}
+
+	// the lease expires once it was not renewed for its duration since this
+	// instance saw it change
+	if current.Spec != l.observed || current.Metadata.ResourceVersion != l.version {
+		l.observed = current.Spec
+		l.version = current.Metadata.ResourceVersion
+		l.seen = now
+	}
+	held := current.Spec.HolderIdentity
+	expired := now.Sub(l.seen) >= time.Duration(current.Spec.LeaseDurationSeconds)*time.Second
+	if held != "" && held != l.identity && !expired {
+		return false, nil
+	}
+
+	if held == l.identity {
+		acquired, _ := time.Parse(microTime, current.Spec.AcquireTime)
+		current.Spec = l.spec(acquired, now, current.Spec.LeaseTransitions)
+	} else {
+		current.Spec = l.spec(now, now, current.Spec.LeaseTransitions+1)
+	}
+	return l.write(ctx, http.MethodPut, l.collection()+"/"+l.name, current)
+}
+
+func (l *Lease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current, err := l.get(ctx)
+	if err != nil || current == nil || current.Spec.HolderIdentity != l.identity {
+		return err
+	}
+	current.Spec.HolderIdentity = ""
+	current.Spec.LeaseDurationSeconds = 1
+	_, err = l.write(ctx, http.MethodPut, l.collection()+"/"+l.name, current)
+	return err
+}
+
+func (l *Lease) spec(acquired, renewed time.Time, transitions int) leaseSpec {
+	return leaseSpec{
+		HolderIdentity:       l.identity,
+		LeaseDurationSeconds: int(max(l.duration/time.Second, 1)),
+		AcquireTime:          acquired.UTC().Format(microTime),
+		RenewTime:            renewed.UTC().Format(microTime),
+		LeaseTransitions:     transitions,
+	}
+}
+
+func (l *Lease) collection() string {
+	return "/apis/coordination.k8s.io/v1/namespaces/" + l.namespace + "/leases"
+}
+
+// get returns the lease, nil if it does not exist.
+func (l *Lease) get(ctx context.Context) (*lease, error) {
+	resp, err := l.client.Do(ctx, http.MethodGet, l.collection()+"/"+l.name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var out lease
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("cannot decode lease %s: %w", l.name, err)
+		}
+		return &out, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, kubernetes.StatusError(resp)
+	}
+}
+
+// write creates or updates the lease, it returns false if another instance
+// changed the lease in the meantime.
+func (l *Lease) write(ctx context.Context, method, url string, ls *lease) (bool, error) {
+	body, err := json.Marshal(ls)
+	if err != nil {
+		return false, err
+	}
+	resp, err := l.client.Do(ctx, method, url, body)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		var out lease
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return false, fmt.Errorf("cannot decode lease %s: %w", l.name, err)
+		}
+		l.observed = out.Spec
+		l.version = out.Metadata.ResourceVersion
+		l.seen = time.Now()
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, kubernetes.StatusError(resp)
+	}
+}
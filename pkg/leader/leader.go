@@ -0,0 +1,168 @@
+// Package leader elects a single active instance among the replicas of the
+// scraper, so only the leader fetches the sensors while the others stand by.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Lock is a lock held by at most one instance, e.g. a file lock or a
+// Kubernetes Lease.
+type Lock interface {
+	// TryAcquire acquires the lock or renews it if it is already held. It
+	// returns false if the lock is held by another instance.
+	TryAcquire(ctx context.Context) (bool, error)
+	// Release releases the lock if it is held, so a standby can take over
+	// right away.
+	Release(ctx context.Context) error
+}
+
+// Elector acquires and renews the lock in the background until it is
+// stopped.
+type Elector struct {
+	lock          Lock
+	log           *zap.Logger
+	leaseDuration time.Duration
+	retryPeriod   time.Duration
+
+	mu      sync.Mutex
+	renewed time.Time
+	leader  bool
+}
+
+type Option func(e *Elector) error
+
+// New creates an elector for the lock.
+func New(lock Lock, opts ...Option) (*Elector, error) {
+	e := &Elector{
+		lock:          lock,
+		log:           zap.L(),
+		leaseDuration: 15 * time.Second,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if e.retryPeriod == 0 {
+		e.retryPeriod = e.leaseDuration / 3
+	}
+	if e.retryPeriod >= e.leaseDuration {
+		return nil, fmt.Errorf("retry period %s has to be less than the lease duration %s", e.retryPeriod, e.leaseDuration)
+	}
+	return e, nil
+}
+
+// WithLeaseDuration sets how long the leadership lasts without being renewed,
+// 15 seconds by default. A standby takes over a Kubernetes Lease once it was
+// not renewed for this duration.
+func WithLeaseDuration(d time.Duration) Option {
+	return func(e *Elector) error {
+		if d <= 0 {
+			return errors.New("invalid lease duration")
+		}
+		e.leaseDuration = d
+		return nil
+	}
+}
+
+// WithRetryPeriod sets the interval of acquiring and renewing the lock, a
+// third of the lease duration by default.
+func WithRetryPeriod(d time.Duration) Option {
+	return func(e *Elector) error {
+		if d <= 0 {
+			return errors.New("invalid retry period")
+		}
+		e.retryPeriod = d
+		return nil
+	}
+}
+
+func WithLogger(l *zap.Logger) Option {
+	return func(e *Elector) error {
+		e.log = l
+		return nil
+	}
+}
+
+// IsLeader returns whether this instance is the leader. The leadership ends
+// once it could not be renewed within the lease duration, even if the lock
+// could not be reached to find out whether another instance took over.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && time.Since(e.renewed) < e.leaseDuration
+}
+
+// Run acquires and renews the lock until the context is done and releases
+// it then.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.retryPeriod)
+	defer ticker.Stop()
+
+	for {
+		e.try(ctx)
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) try(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.retryPeriod)
+	defer cancel()
+
+	start := time.Now()
+	acquired, err := e.lock.TryAcquire(ctx)
+	if err != nil {
+		e.log.Warn("cannot acquire the leader lock", zap.Error(err))
+	}
+	wasLeader := e.IsLeader()
+
+	e.mu.Lock()
+	if err == nil {
+		e.leader = acquired
+		if acquired {
+			e.renewed = start
+		}
+	}
+	e.mu.Unlock()
+
+	switch isLeader := e.IsLeader(); {
+	case isLeader && !wasLeader:
+		e.log.Info("became the leader, fetching the sensors")
+	case !isLeader && wasLeader:
+		e.log.Warn("lost the leadership, standing by")
+	}
+}
+
+func (e *Elector) release() {
+	e.mu.Lock()
+	leader := e.leader
+	e.leader = false
+	e.mu.Unlock()
+	if !leader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.retryPeriod)
+	defer cancel()
+	if err := e.lock.Release(ctx); err != nil {
+		e.log.Warn("cannot release the leader lock", zap.Error(err))
+		return
+	}
+	e.log.Info("released the leadership")
+}
@@ -0,0 +1,144 @@
+// Package pipeline runs the cycles of the scraper: the readings of a fetcher
+// are passed through a chain of processors and fanned out to the exporters.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+	"github.com/nimdanitro/again-scraper-go/pkg/processor"
+)
+
+// Fetcher is the source of the readings, e.g. the egain client. Like the
+// client, it returns the readings of the sensors which could be fetched
+// alongside the errors of the others.
+type Fetcher interface {
+	FetchSensors(ctx context.Context, sensors []egain.Sensor) ([]*egain.SensorReading, error)
+}
+
+// Pipeline fetches, processes and exports the readings of the sensors.
+type Pipeline struct {
+	fetcher    Fetcher
+	processors processor.Chain
+	exporters  exporter.Multi
+}
+
+type Option func(p *Pipeline) error
+
+// New creates a pipeline of the readings of the fetcher.
+func New(fetcher Fetcher, opts ...Option) (*Pipeline, error) {
+	if fetcher == nil {
+		return nil, errors.New("missing fetcher")
+	}
+	p := &Pipeline{fetcher: fetcher}
+
+	// apply the options
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// WithProcessors appends stages to the processors, they run in order.
+func WithProcessors(processors ...processor.Processor) Option {
+	return func(p *Pipeline) error {
+		p.processors = append(p.processors, processors...)
+		return nil
+	}
+}
+
+// WithExporters adds sinks of the processed readings.
+func WithExporters(exporters ...exporter.Exporter) Option {
+	return func(p *Pipeline) error {
+		p.exporters = append(p.exporters, exporters...)
+		return nil
+	}
+}
+
+// Result is the outcome of a cycle.
+type Result struct {
+	// Fetched is the number of readings which were fetched.
+	Fetched int
+	// Exported is the number of readings the processors passed on to the
+	// exporters.
+	Exported int
+	// FetchErr joins the errors of the sensors which could not be fetched,
+	// the readings of the others are processed and exported anyway.
+	FetchErr error
+	// DeadlineExceeded is set if the fetch was cancelled at the deadline of
+	// the cycle, the sensors which were not fetched by then are failed in
+	// FetchErr.
+	DeadlineExceeded bool
+}
+
+// Run fetches the sensors, processes their readings and exports them. The
+// fetch errors are recorded to the exporters and returned in the result, the
+// error is the one of the processors or the exporters.
+func (p *Pipeline) Run(ctx context.Context, sensors []egain.Sensor) (Result, error) {
+	return p.RunUntil(ctx, sensors, time.Time{})
+}
+
+// RunUntil is Run with the fetch cancelled at the deadline, unless it is
+// zero. The readings fetched by then are processed and exported without the
+// deadline, so a slow cycle still exports what it has.
+func (p *Pipeline) RunUntil(ctx context.Context, sensors []egain.Sensor, deadline time.Time) (Result, error) {
+	fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+	if !deadline.IsZero() {
+		fetchCtx, cancel = context.WithDeadline(ctx, deadline)
+	}
+	readings, err := p.fetcher.FetchSensors(fetchCtx, sensors)
+	res := Result{
+		Fetched:          len(readings),
+		FetchErr:         err,
+		DeadlineExceeded: err != nil && ctx.Err() == nil && errors.Is(fetchCtx.Err(), context.DeadlineExceeded),
+	}
+	cancel()
+	if err != nil {
+		p.exporters.RecordError(ctx, err)
+	}
+
+	readings, err = p.processors.Process(ctx, readings)
+	if err != nil {
+		return res, &StageError{Stage: StageProcess, Err: err}
+	}
+	res.Exported = len(readings)
+	if err := p.exporters.Export(ctx, readings); err != nil {
+		return res, &StageError{Stage: StageExport, Err: err}
+	}
+	return res, nil
+}
+
+// Close closes the exporters.
+func (p *Pipeline) Close() error {
+	return p.exporters.Close()
+}
+
+// Stage is a stage of the pipeline which can fail.
+type Stage string
+
+const (
+	// StageProcess fails if a processor fails, nothing was exported.
+	StageProcess Stage = "process"
+	// StageExport fails if any exporter fails, the others exported the
+	// readings.
+	StageExport Stage = "export"
+)
+
+// StageError is the error of a stage of the pipeline.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return "cannot " + string(e.Stage) + " readings: " + e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Correction corrects a measurement by scaling it first and adding the
+// offset afterwards.
+type Correction struct {
+	Offset float64
+	Scale  float64
+}
+
+// NoCorrection leaves the measurement as it is.
+var NoCorrection = Correction{Scale: 1}
+
+func (c Correction) apply(v float64) float64 {
+	return v*c.Scale + c.Offset
+}
+
+// Calibration holds the corrections of the measurements of a sensor.
+type Calibration struct {
+	Temperature Correction
+	Humidity    Correction
+}
+
+// Validate checks that the scale factors are positive.
+func (c *Calibration) Validate() error {
+	if c.Temperature.Scale <= 0 {
+		return fmt.Errorf("invalid temperature scale %g", c.Temperature.Scale)
+	}
+	if c.Humidity.Scale <= 0 {
+		return fmt.Errorf("invalid humidity scale %g", c.Humidity.Scale)
+	}
+	return nil
+}
+
+// Calibrate corrects the temperature and humidity of the sensors with a
+// calibration, keyed by their id. The temperature corrections are in degrees
+// Celsius, so it has to run before the temperatures are converted. Heating
+// systems are not calibrated.
+func Calibrate(calibrations map[string]Calibration) (Processor, error) {
+	for id, c := range calibrations {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("sensor %s: %w", id, err)
+		}
+	}
+
+	return Func(func(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+		out := make([]*egain.SensorReading, len(readings))
+		for i, r := range readings {
+			cal, ok := calibrations[r.SensorID]
+			if !ok || r.Heating != nil {
+				out[i] = r
+				continue
+			}
+
+			c := *r
+			c.Temperature = cal.Temperature.apply(r.Temperature)
+			c.Humidity = cal.Humidity.apply(r.Humidity)
+			out[i] = &c
+		}
+		return out, nil
+	}), nil
+}
@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"context"
+	"math"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// DeriveComfort derives the dew point, absolute humidity and heat index from
+// the temperature and humidity of the readings. The temperatures have to be
+// in degrees Celsius, so it has to run before they are converted. Heating
+// systems and readings without humidity are left as they are.
+func DeriveComfort() Processor {
+	return Func(func(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+		out := make([]*egain.SensorReading, len(readings))
+		for i, r := range readings {
+			if r.Heating != nil || r.Humidity <= 0 {
+				out[i] = r
+				continue
+			}
+
+			c := *r
+			c.Comfort = &egain.Comfort{
+				DewPoint:         dewPoint(r.Temperature, r.Humidity),
+				AbsoluteHumidity: absoluteHumidity(r.Temperature, r.Humidity),
+				HeatIndex:        heatIndex(r.Temperature, r.Humidity),
+			}
+			out[i] = &c
+		}
+		return out, nil
+	})
+}
+
+// dewPoint returns the dew point in °C with the Magnus formula.
+func dewPoint(t, rh float64) float64 {
+	const a, b = 17.62, 243.12
+	gamma := math.Log(rh/100) + a*t/(b+t)
+	return b * gamma / (a - gamma)
+}
+
+// absoluteHumidity returns the water vapour density in g/m³.
+func absoluteHumidity(t, rh float64) float64 {
+	// saturation vapour pressure in hPa
+	saturation := 6.112 * math.Exp(17.67*t/(t+243.5))
+	return saturation * rh * 2.1674 / (273.15 + t)
+}
+
+// heatIndex returns the apparent temperature in °C with the regression of the
+// US National Weather Service.
+func heatIndex(t, rh float64) float64 {
+	f := celsiusToFahrenheit(t)
+	hi := 0.5 * (f + 61 + (f-68)*1.2 + rh*0.094)
+	if (hi+f)/2 >= 80 {
+		hi = -42.379 + 2.04901523*f + 10.14333127*rh -
+			0.22475541*f*rh - 0.00683783*f*f - 0.05481717*rh*rh +
+			0.00122874*f*f*rh + 0.00085282*f*rh*rh - 0.00000199*f*f*rh*rh
+		switch {
+		case rh < 13 && f >= 80 && f <= 112:
+			hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(f-95))/17)
+		case rh > 85 && f >= 80 && f <= 87:
+			hi += (rh - 85) / 10 * (87 - f) / 5
+		}
+	}
+	return (hi - 32) * 5 / 9
+}
@@ -0,0 +1,200 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.uber.org/zap"
+)
+
+// expressionCostLimit bounds the cost of an evaluation, so a mistake in an
+// expression cannot stall the cycles.
+const expressionCostLimit = 10000
+
+// Expression is a CEL expression evaluated on each reading, which is the
+// variable reading with the fields id, location, account, kind, labels,
+// temperature, humidity, timestamp, stale, unchanged, external_temperatures,
+// values and, if reported, battery and signal_strength. The temperatures are
+// in degrees Celsius. Exactly one of Filter, Label and Value has to be set.
+type Expression struct {
+	// Filter drops the readings for which the expression is false, e.g.
+	// reading.humidity > 0.0.
+	Filter string
+	// Label is the name of the label set to the string Expr evaluates to.
+	Label string
+	Expr  string
+	// Value appends the number it evaluates to as a value of the Unit.
+	Value string
+	Unit  string
+}
+
+// expression is a compiled Expression.
+type expression struct {
+	Expression
+	source  string
+	program cel.Program
+}
+
+// Expressions evaluates the expressions in order on each reading. Readings
+// for which an expression fails, e.g. as a label is missing, are logged and
+// passed on unchanged by it.
+type Expressions struct {
+	expressions []expression
+	log         *zap.Logger
+}
+
+// NewExpressions compiles the expressions.
+func NewExpressions(logger *zap.Logger, exprs []Expression) (*Expressions, error) {
+	env, err := cel.NewEnv(cel.Variable("reading", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Expressions{log: logger}
+	for i, x := range exprs {
+		c := expression{Expression: x}
+		var want *cel.Type
+		switch {
+		case x.Filter != "" && x.Label == "" && x.Value == "":
+			c.source, want = x.Filter, cel.BoolType
+		case x.Label != "" && x.Filter == "" && x.Value == "":
+			if x.Expr == "" {
+				return nil, fmt.Errorf("expression #%d: missing expression of label %s", i, x.Label)
+			}
+			c.source, want = x.Expr, cel.StringType
+		case x.Value != "" && x.Filter == "" && x.Label == "":
+			c.source, want = x.Value, cel.DoubleType
+		default:
+			return nil, fmt.Errorf("expression #%d: expected one of filter, label or value", i)
+		}
+
+		ast, iss := env.Compile(c.source)
+		if iss.Err() != nil {
+			return nil, fmt.Errorf("expression #%d: %w", i, iss.Err())
+		}
+		// the fields of the reading are dynamic, so the type can only be
+		// checked at compile time if it is known
+		if t := ast.OutputType(); t != cel.DynType && t != want && !(want == cel.DoubleType && t == cel.IntType) {
+			return nil, fmt.Errorf("expression #%d: expected a %s, got a %s", i, want, t)
+		}
+		c.program, err = env.Program(ast, cel.CostLimit(expressionCostLimit))
+		if err != nil {
+			return nil, fmt.Errorf("expression #%d: %w", i, err)
+		}
+		e.expressions = append(e.expressions, c)
+	}
+	return e, nil
+}
+
+func (e *Expressions) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	out := make([]*egain.SensorReading, 0, len(readings))
+next:
+	for _, r := range readings {
+		for _, x := range e.expressions {
+			v, _, err := x.program.ContextEval(ctx, map[string]any{"reading": readingVars(r)})
+			if err != nil {
+				e.warn(r, x, err)
+				continue
+			}
+
+			switch {
+			case x.Filter != "":
+				keep, ok := v.Value().(bool)
+				if !ok {
+					e.warn(r, x, typeError(v, "bool"))
+					continue
+				}
+				if !keep {
+					continue next
+				}
+			case x.Label != "":
+				label, ok := v.Value().(string)
+				if !ok {
+					e.warn(r, x, typeError(v, "string"))
+					continue
+				}
+				c := *r
+				c.Labels = maps.Clone(r.Labels)
+				if c.Labels == nil {
+					c.Labels = map[string]string{}
+				}
+				c.Labels[x.Label] = label
+				r = &c
+			default:
+				var value float64
+				switch n := v.(type) {
+				case types.Double:
+					value = float64(n)
+				case types.Int:
+					value = float64(n)
+				default:
+					e.warn(r, x, typeError(v, "number"))
+					continue
+				}
+				c := *r
+				c.Values = append(slices.Clip(r.Values), egain.Value{Value: value, Unit: x.Unit, Timestamp: r.Timestamp})
+				r = &c
+			}
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (e *Expressions) warn(r *egain.SensorReading, x expression, err error) {
+	e.log.Warn("cannot evaluate expression",
+		zap.String("sensorId", r.SensorID),
+		zap.String("expression", x.source),
+		zap.Error(err),
+	)
+}
+
+func typeError(v ref.Val, want string) error {
+	return errors.New("expected a " + want + ", got a " + v.Type().TypeName())
+}
+
+// readingVars returns the fields of the reading available to the
+// expressions.
+func readingVars(r *egain.SensorReading) map[string]any {
+	labels := r.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	external := make([]float64, len(r.ExternalTemperatures))
+	for i, t := range r.ExternalTemperatures {
+		external[i] = t.Value
+	}
+	values := make([]map[string]any, len(r.Values))
+	for i, v := range r.Values {
+		values[i] = map[string]any{"value": v.Value, "unit": v.Unit}
+	}
+
+	vars := map[string]any{
+		"id":                    r.SensorID,
+		"location":              r.Location,
+		"account":               r.Account,
+		"kind":                  r.Kind.String(),
+		"labels":                labels,
+		"temperature":           r.Temperature,
+		"humidity":              r.Humidity,
+		"timestamp":             r.Timestamp,
+		"stale":                 r.Stale,
+		"unchanged":             r.Unchanged,
+		"external_temperatures": external,
+		"values":                values,
+	}
+	if r.Battery != nil {
+		vars["battery"] = *r.Battery
+	}
+	if r.SignalStrength != nil {
+		vars["signal_strength"] = *r.SignalStrength
+	}
+	return vars
+}
@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Group is a named group of sensors, e.g. the sensors of a building. A
+// sensor belongs to the group if it is listed in SensorIDs or carries all of
+// the Labels.
+type Group struct {
+	Name      string
+	SensorIDs []string
+	Labels    map[string]string
+}
+
+// Validate checks that the group has a name and selects sensors.
+func (g *Group) Validate() error {
+	if g.Name == "" {
+		return errors.New("missing group name")
+	}
+	if len(g.SensorIDs) == 0 && len(g.Labels) == 0 {
+		return fmt.Errorf("group %s: no sensors or labels", g.Name)
+	}
+	return nil
+}
+
+func (g *Group) contains(s *egain.Sensor) bool {
+	for _, id := range g.SensorIDs {
+		if id == s.SensorID {
+			return true
+		}
+	}
+	if len(g.Labels) == 0 {
+		return false
+	}
+	for k, v := range g.Labels {
+		if s.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Aggregates records the average, minimum and maximum temperature and
+// humidity of the sensors of each group as metrics. The aggregates are
+// computed over the latest reading of each sensor, stale readings and heating
+// systems are left out. The readings are passed on as they are.
+type Aggregates struct {
+	groups      []Group
+	temperature metric.Float64Gauge
+	humidity    metric.Float64Gauge
+	sensors     metric.Int64Gauge
+
+	mu     sync.Mutex
+	latest map[string]*egain.SensorReading
+}
+
+// NewAggregates creates the instruments of the aggregates on the meter. The
+// temperature unit is the unit of the readings the processor is given.
+func NewAggregates(meter metric.Meter, groups []Group, temperatureUnit string) (*Aggregates, error) {
+	for i := range groups {
+		if err := groups[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		a   = Aggregates{groups: groups, latest: map[string]*egain.SensorReading{}}
+		err error
+	)
+	a.temperature, err = meter.Float64Gauge("sensor.group.temperature",
+		metric.WithUnit(temperatureUnit),
+		metric.WithDescription("Average, minimum and maximum temperature of the sensors of a group"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	a.humidity, err = meter.Float64Gauge("sensor.group.humidity",
+		metric.WithUnit("%rH"),
+		metric.WithDescription("Average, minimum and maximum relative humidity of the sensors of a group"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	a.sensors, err = meter.Int64Gauge("sensor.group.sensors",
+		metric.WithUnit("{sensor}"),
+		metric.WithDescription("The number of sensors the aggregates of a group are computed over"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// aggregate is the running aggregate of a measurement.
+type aggregate struct {
+	n             int
+	sum, min, max float64
+}
+
+func (a *aggregate) add(v float64) {
+	if a.n == 0 || v < a.min {
+		a.min = v
+	}
+	if a.n == 0 || v > a.max {
+		a.max = v
+	}
+	a.sum += v
+	a.n++
+}
+
+// stat is a named statistic of an aggregate.
+type stat struct {
+	name  string
+	value float64
+}
+
+// stats returns the average, minimum and maximum of the aggregate.
+func (a *aggregate) stats() []stat {
+	return []stat{
+		{"avg", a.sum / float64(a.n)},
+		{"min", a.min},
+		{"max", a.max},
+	}
+}
+
+func (a *aggregate) record(ctx context.Context, g metric.Float64Gauge, group string) {
+	for _, stat := range a.stats() {
+		g.Record(ctx, stat.value, metric.WithAttributes(
+			attribute.String("group.name", group),
+			attribute.String("aggregation", stat.name),
+		))
+	}
+}
+
+func (a *Aggregates) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, r := range readings {
+		a.latest[r.SensorID] = r
+	}
+
+	for _, g := range a.groups {
+		var temperature, humidity aggregate
+		for _, r := range a.latest {
+			if r.Stale || r.Heating != nil || !g.contains(&r.Sensor) {
+				continue
+			}
+			temperature.add(r.Temperature)
+			humidity.add(r.Humidity)
+		}
+
+		a.sensors.Record(ctx, int64(temperature.n), metric.WithAttributes(attribute.String("group.name", g.Name)))
+		if temperature.n == 0 {
+			continue
+		}
+		temperature.record(ctx, a.temperature, g.Name)
+		humidity.record(ctx, a.humidity, g.Name)
+	}
+	return readings, nil
+}
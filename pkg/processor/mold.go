@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+const (
+	// surfaceCooling is how much colder than the air the coldest surfaces
+	// of a room are assumed to be, e.g. the corners of exterior walls. The
+	// sensors measure the air, mold grows on the surfaces.
+	surfaceCooling = 3.0
+	// maxMoldGap bounds the time a reading is assumed to hold, so the
+	// readings before an outage do not count for the whole outage.
+	maxMoldGap = 2 * time.Hour
+)
+
+// MoldRisk derives the mold risk of each sensor from its recent temperature
+// and humidity history: the share of the time over the window in which the
+// relative humidity at the surfaces of the room was above the critical
+// humidity of mold growth at their temperature. A sustained risk over a few
+// percent indicates conditions in which mold may grow. The temperatures have
+// to be in degrees Celsius, so it has to run before they are converted.
+// Unchanged and stale readings, heating systems and readings without
+// humidity are left as they are.
+type MoldRisk struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	sensors map[string]*moldHistory
+}
+
+// moldHistory is the history of a sensor in hourly buckets.
+type moldHistory struct {
+	buckets []moldBucket
+	last    time.Time
+	growing bool
+}
+
+// moldBucket holds the seconds of an hour which were observed and in which
+// the conditions allowed mold growth.
+type moldBucket struct {
+	hour             time.Time
+	observed, growth float64
+}
+
+// NewMoldRisk creates the mold risk processor over the window, e.g. a week.
+func NewMoldRisk(window time.Duration) (*MoldRisk, error) {
+	if window < time.Hour {
+		return nil, fmt.Errorf("invalid mold risk window %s, it has to be at least an hour", window)
+	}
+	return &MoldRisk{window: window, sensors: map[string]*moldHistory{}}, nil
+}
+
+func (m *MoldRisk) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]*egain.SensorReading, len(readings))
+	for i, r := range readings {
+		if r.Unchanged || r.Stale || r.Heating != nil || r.Humidity <= 0 {
+			out[i] = r
+			continue
+		}
+
+		h := m.sensors[r.SensorID]
+		if h == nil {
+			h = &moldHistory{}
+			m.sensors[r.SensorID] = h
+		}
+		risk, ok := h.add(r.Timestamp, moldGrowth(r.Temperature, r.Humidity), m.window)
+		if !ok {
+			out[i] = r
+			continue
+		}
+		c := *r
+		c.MoldRisk = &risk
+		out[i] = &c
+	}
+	return out, nil
+}
+
+// add adds the reading at t to the history and returns the risk over the
+// window, ok is false until the sensor was observed for some time. The time
+// up to the reading is accounted to the conditions of the previous reading.
+func (h *moldHistory) add(t time.Time, growing bool, window time.Duration) (risk float64, ok bool) {
+	if !h.last.IsZero() && !t.After(h.last) {
+		// late readings are not accounted
+		return 0, false
+	}
+	if !h.last.IsZero() {
+		from := h.last
+		if t.Sub(from) > maxMoldGap {
+			from = t.Add(-maxMoldGap)
+		}
+		for from.Before(t) {
+			hour := from.Truncate(time.Hour)
+			end := hour.Add(time.Hour)
+			if end.After(t) {
+				end = t
+			}
+			h.account(hour, end.Sub(from).Seconds(), h.growing)
+			from = end
+		}
+	}
+	h.last, h.growing = t, growing
+
+	// the buckets which left the window are dropped
+	start := t.Add(-window).Truncate(time.Hour)
+	n := 0
+	for n < len(h.buckets) && h.buckets[n].hour.Before(start) {
+		n++
+	}
+	h.buckets = h.buckets[n:]
+
+	var observed, growth float64
+	for _, b := range h.buckets {
+		observed += b.observed
+		growth += b.growth
+	}
+	if observed == 0 {
+		return 0, false
+	}
+	return 100 * growth / observed, true
+}
+
+func (h *moldHistory) account(hour time.Time, seconds float64, growing bool) {
+	if len(h.buckets) == 0 || h.buckets[len(h.buckets)-1].hour.Before(hour) {
+		h.buckets = append(h.buckets, moldBucket{hour: hour})
+	}
+	b := &h.buckets[len(h.buckets)-1]
+	b.observed += seconds
+	if growing {
+		b.growth += seconds
+	}
+}
+
+// moldGrowth reports whether the air of the temperature and relative
+// humidity allows mold growth at the surfaces of the room, which are assumed
+// to be colder than the air by surfaceCooling.
+func moldGrowth(t, rh float64) bool {
+	surface := t - surfaceCooling
+	// the air at the surface holds the same water vapour, so its relative
+	// humidity rises with the lower saturation vapour pressure
+	surfaceRH := math.Min(100, rh*saturationPressure(t)/saturationPressure(surface))
+	return surfaceRH >= criticalHumidity(surface)
+}
+
+// saturationPressure returns the saturation vapour pressure in hPa with the
+// Magnus formula.
+func saturationPressure(t float64) float64 {
+	return 6.112 * math.Exp(17.62*t/(243.12+t))
+}
+
+// criticalHumidity returns the relative humidity above which mold grows at
+// the temperature, after the VTT model of Hukka and Viitanen. Mold does not
+// grow below freezing.
+func criticalHumidity(t float64) float64 {
+	switch {
+	case t <= 0:
+		return math.Inf(1)
+	case t <= 20:
+		return -0.00267*t*t*t + 0.160*t*t - 3.13*t + 100
+	}
+	return 80
+}
@@ -0,0 +1,38 @@
+// Package processor transforms sensor readings between fetching and
+// exporting them, so all exporters see the same readings.
+package processor
+
+import (
+	"context"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Processor transforms, filters or enriches readings. Processors must not
+// modify the given readings in place, as they may be shared with the client,
+// but return modified copies instead.
+type Processor interface {
+	Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error)
+}
+
+// Func adapts a function to a Processor.
+type Func func(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error)
+
+func (f Func) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	return f(ctx, readings)
+}
+
+// Chain runs its processors in order, each one gets the readings of the
+// previous one.
+type Chain []Processor
+
+func (c Chain) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	for _, p := range c {
+		var err error
+		readings, err = p.Process(ctx, readings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return readings, nil
+}
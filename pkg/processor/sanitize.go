@@ -0,0 +1,163 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// Limits are the limits of the attributes of the readings.
+type Limits struct {
+	// MaxLength is the maximum number of characters of the location and the
+	// label values.
+	MaxLength int
+	// MaxSeries is the maximum number of unique combinations of sensor,
+	// location and labels.
+	MaxSeries int
+}
+
+// DefaultLimits are the limits used unless configured otherwise.
+var DefaultLimits = Limits{
+	MaxLength: 128,
+	MaxSeries: 1000,
+}
+
+// Validate checks that the limits are positive.
+func (l *Limits) Validate() error {
+	if l.MaxLength <= 0 {
+		return fmt.Errorf("invalid maximum attribute length %d", l.MaxLength)
+	}
+	if l.MaxSeries <= 0 {
+		return fmt.Errorf("invalid maximum number of series %d", l.MaxSeries)
+	}
+	return nil
+}
+
+// Sanitizer cleans up the locations and label values of the readings, which
+// become the attributes of the metrics: invalid UTF-8 and control characters
+// are replaced, whitespace is trimmed and collapsed and the values are
+// truncated to the maximum length. Readings of combinations of attributes
+// beyond the maximum number of series are dropped, logged and counted, to
+// protect the metrics backend from mistakes like a changing location.
+type Sanitizer struct {
+	limits  Limits
+	log     *zap.Logger
+	dropped metric.Int64Counter
+
+	mu     sync.Mutex
+	series map[string]bool
+	warned map[string]bool
+}
+
+// NewSanitizer creates a sanitizer with the given limits.
+func NewSanitizer(meter metric.Meter, logger *zap.Logger, limits Limits) (*Sanitizer, error) {
+	if err := limits.Validate(); err != nil {
+		return nil, err
+	}
+
+	dropped, err := meter.Int64Counter("sensor.readings.dropped",
+		metric.WithDescription("The number of readings which were not exported as they exceeded the maximum number of series"),
+		metric.WithUnit("{reading}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Sanitizer{
+		limits:  limits,
+		log:     logger,
+		dropped: dropped,
+		series:  map[string]bool{},
+		warned:  map[string]bool{},
+	}, nil
+}
+
+func (s *Sanitizer) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]*egain.SensorReading, 0, len(readings))
+	for _, r := range readings {
+		r = s.sanitize(r)
+
+		key := seriesKey(r)
+		if !s.series[key] {
+			if len(s.series) >= s.limits.MaxSeries {
+				// the warnings are bounded like the series
+				if !s.warned[key] && len(s.warned) < s.limits.MaxSeries {
+					s.warned[key] = true
+					s.log.Warn("dropping readings of a new series beyond the maximum number of series",
+						zap.String("sensorId", r.SensorID),
+						zap.String("location", r.Location),
+						zap.Int("maxSeries", s.limits.MaxSeries),
+					)
+				}
+				s.dropped.Add(ctx, 1)
+				continue
+			}
+			s.series[key] = true
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// sanitize returns the reading with sanitized attributes, it is copied if
+// any of them changes.
+func (s *Sanitizer) sanitize(r *egain.SensorReading) *egain.SensorReading {
+	location := s.clean(r.Location)
+	changed := location != r.Location
+	labels := make(map[string]string, len(r.Labels))
+	for k, v := range r.Labels {
+		labels[k] = s.clean(v)
+		changed = changed || labels[k] != v
+	}
+	if !changed {
+		return r
+	}
+
+	c := *r
+	c.Location = location
+	if r.Labels != nil {
+		c.Labels = labels
+	}
+	return &c
+}
+
+// clean sanitizes an attribute value.
+func (s *Sanitizer) clean(v string) string {
+	v = strings.ToValidUTF8(v, "�")
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, v)
+	v = strings.Join(strings.Fields(v), " ")
+	if runes := []rune(v); len(runes) > s.limits.MaxLength {
+		v = strings.TrimSpace(string(runes[:s.limits.MaxLength]))
+	}
+	return v
+}
+
+// seriesKey identifies the combination of attributes of the reading.
+func seriesKey(r *egain.SensorReading) string {
+	var b strings.Builder
+	b.WriteString(r.SensorID)
+	b.WriteByte(0)
+	b.WriteString(r.Location)
+	for _, k := range slices.Sorted(maps.Keys(r.Labels)) {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(r.Labels[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,265 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// DailySummary is the summary of the readings of a sensor over a day.
+type DailySummary struct {
+	// Date is the day of the summary, e.g. 2024-01-15, in the TimeZone.
+	Date string `json:"date"`
+	// TimeZone is the time zone of the day, the one of the sensor if it is
+	// set, e.g. Europe/Zurich.
+	TimeZone    string      `json:"timeZone"`
+	SensorID    string      `json:"sensorId"`
+	Location    string      `json:"location"`
+	Temperature SummaryStat `json:"temperature"`
+	Humidity    SummaryStat `json:"humidity"`
+	// Readings is the number of readings of the day.
+	Readings int `json:"readings"`
+}
+
+// SummaryStat is the minimum, maximum and mean of a measurement over a day.
+type SummaryStat struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+// SummaryReporter is given the summaries of the sensors once their day is
+// over, e.g. to write them to a file.
+type SummaryReporter interface {
+	Report(ctx context.Context, summaries []DailySummary) error
+}
+
+// DailySummaries records the minimum, maximum and mean temperature and
+// humidity of each sensor over a day as metrics, and passes the summaries to
+// its reporters. The days are assigned by the timestamp of the readings in
+// the time zone of the sensor, or the location of the summaries if it has
+// none. The day of a sensor is over once a reading of a later time arrives,
+// and a date is reported once it is over for all sensors which had readings
+// on it. Unchanged and stale readings are left out, so a reading is only
+// counted once, as are heating systems. The readings are passed on as they
+// are.
+type DailySummaries struct {
+	log         *zap.Logger
+	location    *time.Location
+	reporters   []SummaryReporter
+	temperature metric.Float64Gauge
+	humidity    metric.Float64Gauge
+	readings    metric.Int64Gauge
+
+	mu sync.Mutex
+	// latest is the latest timestamp of the readings, the days which are
+	// over by it are summarized
+	latest time.Time
+	// days are the current days of the sensors, current their open windows
+	days    map[string]time.Time
+	current map[string]*sensorWindow
+	// pending are the summaries of the dates which are not over for all
+	// sensors yet
+	pending map[string][]DailySummary
+}
+
+type SummaryOption func(s *DailySummaries) error
+
+// WithSummaryLocation sets the time zone of the days of the sensors without a
+// time zone, the local time zone by default.
+func WithSummaryLocation(loc *time.Location) SummaryOption {
+	return func(s *DailySummaries) error {
+		if loc == nil {
+			return errors.New("missing location of the daily summaries")
+		}
+		s.location = loc
+		return nil
+	}
+}
+
+// WithSummaryReporter adds a reporter of the daily summaries.
+func WithSummaryReporter(r SummaryReporter) SummaryOption {
+	return func(s *DailySummaries) error {
+		s.reporters = append(s.reporters, r)
+		return nil
+	}
+}
+
+// NewDailySummaries creates the instruments of the daily summaries on the
+// meter. The temperature unit is the unit of the readings the processor is
+// given.
+func NewDailySummaries(meter metric.Meter, logger *zap.Logger, temperatureUnit string, opts ...SummaryOption) (*DailySummaries, error) {
+	var (
+		s   = DailySummaries{log: logger, location: time.Local, days: map[string]time.Time{}, current: map[string]*sensorWindow{}, pending: map[string][]DailySummary{}}
+		err error
+	)
+
+	// apply the options
+	for _, o := range opts {
+		if err := o(&s); err != nil {
+			return nil, err
+		}
+	}
+
+	s.temperature, err = meter.Float64Gauge("sensor.daily.temperature",
+		metric.WithUnit(temperatureUnit),
+		metric.WithDescription("Minimum, maximum and mean temperature of a sensor over the previous day"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	s.humidity, err = meter.Float64Gauge("sensor.daily.humidity",
+		metric.WithUnit("%rH"),
+		metric.WithDescription("Minimum, maximum and mean relative humidity of a sensor over the previous day"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	s.readings, err = meter.Int64Gauge("sensor.daily.readings",
+		metric.WithUnit("{reading}"),
+		metric.WithDescription("The number of readings of a sensor over the previous day"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *DailySummaries) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range readings {
+		if r.Unchanged || r.Stale || r.Heating != nil {
+			continue
+		}
+		day := s.dayOf(r)
+		last, ok := s.days[r.SensorID]
+		switch {
+		case ok && day.Before(last):
+			// late readings of a day which is already summarized
+			continue
+		case !ok || day.After(last):
+			s.close(ctx, r.SensorID)
+			s.days[r.SensorID] = day
+			s.current[r.SensorID] = &sensorWindow{start: day}
+		}
+		cur := s.current[r.SensorID]
+		if cur == nil {
+			// the day is over by the readings of the other sensors
+			continue
+		}
+		cur.add(r)
+		if r.Timestamp.After(s.latest) {
+			s.latest = r.Timestamp
+		}
+	}
+
+	// the days of the sensors without a reading of a later day are over by
+	// the readings of the other sensors, too
+	for id, cur := range s.current {
+		if !cur.start.AddDate(0, 0, 1).After(s.latest) {
+			s.close(ctx, id)
+		}
+	}
+	s.report(ctx)
+	return readings, nil
+}
+
+// dayOf returns the start of the day of the reading in the time zone of its
+// sensor.
+func (s *DailySummaries) dayOf(r *egain.SensorReading) time.Time {
+	loc := s.location
+	if r.TimeZone != nil {
+		loc = r.TimeZone
+	}
+	y, m, d := r.Timestamp.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// close records the summary of the day of the sensor which is over and
+// keeps it until its date is reported.
+func (s *DailySummaries) close(ctx context.Context, id string) {
+	cur := s.current[id]
+	if cur == nil {
+		return
+	}
+	delete(s.current, id)
+	summary := DailySummary{
+		Date:        cur.start.Format(time.DateOnly),
+		TimeZone:    cur.start.Location().String(),
+		SensorID:    cur.sensor.SensorID,
+		Location:    cur.sensor.Location,
+		Temperature: cur.temperature.summary(),
+		Humidity:    cur.humidity.summary(),
+		Readings:    cur.temperature.n,
+	}
+	s.record(ctx, summary)
+	s.pending[summary.Date] = append(s.pending[summary.Date], summary)
+}
+
+// report reports the summaries of the dates which are over for all sensors,
+// i.e. no sensor has an open window of.
+func (s *DailySummaries) report(ctx context.Context) {
+	open := map[string]bool{}
+	for _, cur := range s.current {
+		open[cur.start.Format(time.DateOnly)] = true
+	}
+	dates := make([]string, 0, len(s.pending))
+	for date := range s.pending {
+		if !open[date] {
+			dates = append(dates, date)
+		}
+	}
+	slices.Sort(dates)
+
+	for _, date := range dates {
+		summaries := s.pending[date]
+		delete(s.pending, date)
+		slices.SortFunc(summaries, func(a, b DailySummary) int {
+			return strings.Compare(a.SensorID, b.SensorID)
+		})
+
+		s.log.Info("summarized the day", zap.String("date", date), zap.Int("sensors", len(summaries)))
+		for _, r := range s.reporters {
+			// a failed report does not fail the readings
+			if err := r.Report(ctx, summaries); err != nil {
+				s.log.Error("cannot report the daily summaries", zap.String("date", date), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (a *aggregate) summary() SummaryStat {
+	return SummaryStat{Min: a.min, Max: a.max, Mean: a.sum / float64(a.n)}
+}
+
+// record records the summary of a sensor.
+func (s *DailySummaries) record(ctx context.Context, summary DailySummary) {
+	attrs := []attribute.KeyValue{
+		attribute.String("sensor.id", summary.SensorID),
+		attribute.String("sensor.location", summary.Location),
+	}
+	s.readings.Record(ctx, int64(summary.Readings), metric.WithAttributes(attrs...))
+	for _, m := range []struct {
+		gauge metric.Float64Gauge
+		stat  SummaryStat
+	}{
+		{s.temperature, summary.Temperature},
+		{s.humidity, summary.Humidity},
+	} {
+		for _, stat := range []stat{{"min", m.stat.Min}, {"max", m.stat.Max}, {"mean", m.stat.Mean}} {
+			m.gauge.Record(ctx, stat.value, metric.WithAttributes(append(attrs, attribute.String("aggregation", stat.name))...))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// TemperatureUnit is the unit temperatures are exported in.
+type TemperatureUnit string
+
+const (
+	Celsius    TemperatureUnit = "C"
+	Fahrenheit TemperatureUnit = "F"
+)
+
+// ParseTemperatureUnit parses C or F, case insensitive.
+func ParseTemperatureUnit(s string) (TemperatureUnit, error) {
+	switch u := TemperatureUnit(strings.ToUpper(s)); u {
+	case Celsius, Fahrenheit:
+		return u, nil
+	default:
+		return "", fmt.Errorf("unknown temperature unit %q, expected C or F", s)
+	}
+}
+
+// Symbol returns the symbol of the unit, e.g. °C.
+func (u TemperatureUnit) Symbol() string {
+	return "°" + string(u)
+}
+
+// ConvertTemperature converts all temperatures of the readings, which the API
+// reports in degrees Celsius, to the unit. This includes the external probes,
+// values in °C, the temperatures of heating systems and the derived comfort
+// metrics. Converting to Celsius returns the readings as they are.
+func ConvertTemperature(u TemperatureUnit) Processor {
+	return Func(func(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+		if u == Celsius {
+			return readings, nil
+		}
+
+		out := make([]*egain.SensorReading, len(readings))
+		for i, r := range readings {
+			out[i] = toFahrenheit(r)
+		}
+		return out, nil
+	})
+}
+
+func celsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+// toFahrenheit returns a converted copy of the reading.
+func toFahrenheit(r *egain.SensorReading) *egain.SensorReading {
+	c := *r
+	c.Temperature = celsiusToFahrenheit(r.Temperature)
+	c.TemperatureUnit = Fahrenheit.Symbol()
+
+	// the external probes carry no unit on older sensors
+	c.ExternalTemperatures = slices.Clone(r.ExternalTemperatures)
+	for i := range c.ExternalTemperatures {
+		v := &c.ExternalTemperatures[i]
+		if v.Unit == "" || v.Unit == Celsius.Symbol() {
+			v.Value = celsiusToFahrenheit(v.Value)
+			v.Unit = Fahrenheit.Symbol()
+		}
+	}
+	c.Values = slices.Clone(r.Values)
+	for i := range c.Values {
+		v := &c.Values[i]
+		if v.Unit == Celsius.Symbol() {
+			v.Value = celsiusToFahrenheit(v.Value)
+			v.Unit = Fahrenheit.Symbol()
+		}
+	}
+
+	if r.Heating != nil {
+		h := *r.Heating
+		h.FlowTemperature = celsiusToFahrenheit(h.FlowTemperature)
+		h.ReturnTemperature = celsiusToFahrenheit(h.ReturnTemperature)
+		h.FlowSetpoint = celsiusToFahrenheit(h.FlowSetpoint)
+		c.Heating = &h
+	}
+	if r.Comfort != nil {
+		m := *r.Comfort
+		m.DewPoint = celsiusToFahrenheit(m.DewPoint)
+		m.HeatIndex = celsiusToFahrenheit(m.HeatIndex)
+		c.Comfort = &m
+	}
+	return &c
+}
@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Trend derives the rates of change per hour of the temperature and humidity
+// of each sensor over the window, e.g. to alert on a window left open or a
+// failed heating before the absolute thresholds are crossed. The rates are
+// the slopes of a least squares fit over the readings of the window, so a
+// single noisy reading does not make a trend. A trend is only derived once
+// the readings span half of the window. Unchanged and stale readings and
+// heating systems are left as they are.
+type Trend struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	sensors map[string][]trendSample
+}
+
+type trendSample struct {
+	t                     time.Time
+	temperature, humidity float64
+}
+
+// NewTrend creates the trend processor over the window, e.g. 30 minutes.
+func NewTrend(window time.Duration) (*Trend, error) {
+	if window < time.Minute {
+		return nil, fmt.Errorf("invalid trend window %s, it has to be at least a minute", window)
+	}
+	return &Trend{window: window, sensors: map[string][]trendSample{}}, nil
+}
+
+func (p *Trend) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]*egain.SensorReading, len(readings))
+	for i, r := range readings {
+		out[i] = r
+		if r.Unchanged || r.Stale || r.Heating != nil {
+			continue
+		}
+
+		samples := p.sensors[r.SensorID]
+		if n := len(samples); n > 0 && !r.Timestamp.After(samples[n-1].t) {
+			// late readings are not accounted
+			continue
+		}
+		samples = append(samples, trendSample{r.Timestamp, r.Temperature, r.Humidity})
+		// the samples which left the window are dropped
+		start := r.Timestamp.Add(-p.window)
+		n := 0
+		for n < len(samples) && samples[n].t.Before(start) {
+			n++
+		}
+		samples = samples[n:]
+		p.sensors[r.SensorID] = samples
+
+		if samples[len(samples)-1].t.Sub(samples[0].t) < p.window/2 {
+			continue
+		}
+		c := *r
+		c.Trend = slopes(samples)
+		out[i] = &c
+	}
+	return out, nil
+}
+
+// slopes fits a line to the temperatures and humidities of the samples and
+// returns their slopes per hour.
+func slopes(samples []trendSample) *egain.Trend {
+	// the hours are relative to the first sample, so the sums keep their
+	// precision
+	var sx, sxx, st, sxt, sh, sxh float64
+	for _, s := range samples {
+		x := s.t.Sub(samples[0].t).Hours()
+		sx += x
+		sxx += x * x
+		st += s.temperature
+		sxt += x * s.temperature
+		sh += s.humidity
+		sxh += x * s.humidity
+	}
+	n := float64(len(samples))
+	d := n*sxx - sx*sx
+	return &egain.Trend{
+		Temperature: (n*sxt - sx*st) / d,
+		Humidity:    (n*sxh - sx*sh) / d,
+	}
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// Bounds are the limits of plausible readings. The temperatures are in
+// degrees Celsius, as reported by the API.
+type Bounds struct {
+	MinTemperature, MaxTemperature float64
+	MinHumidity, MaxHumidity       float64
+	// MaxFuture is the clock skew tolerated for timestamps in the future.
+	MaxFuture time.Duration
+}
+
+// DefaultBounds are the bounds used unless configured otherwise.
+var DefaultBounds = Bounds{
+	MinTemperature: -50,
+	MaxTemperature: 70,
+	MinHumidity:    0,
+	MaxHumidity:    100,
+	MaxFuture:      5 * time.Minute,
+}
+
+// Validate checks that the bounds are not empty.
+func (b *Bounds) Validate() error {
+	if b.MinTemperature >= b.MaxTemperature {
+		return fmt.Errorf("invalid temperature bounds %g to %g", b.MinTemperature, b.MaxTemperature)
+	}
+	if b.MinHumidity >= b.MaxHumidity {
+		return fmt.Errorf("invalid humidity bounds %g to %g", b.MinHumidity, b.MaxHumidity)
+	}
+	if b.MaxFuture < 0 {
+		return fmt.Errorf("negative max future %s", b.MaxFuture)
+	}
+	return nil
+}
+
+// check returns why the reading is implausible, or an empty string if it is
+// plausible. Heating systems have no temperature and humidity, only their
+// timestamp is checked.
+func (b *Bounds) check(r *egain.SensorReading, now time.Time) string {
+	if r.Timestamp.After(now.Add(b.MaxFuture)) {
+		return "future_timestamp"
+	}
+	if r.Heating != nil {
+		return ""
+	}
+	if r.Temperature == 0 && r.Humidity == 0 {
+		return "zero"
+	}
+	if r.Temperature < b.MinTemperature || r.Temperature > b.MaxTemperature {
+		return "temperature"
+	}
+	if r.Humidity < b.MinHumidity || r.Humidity > b.MaxHumidity {
+		return "humidity"
+	}
+	return ""
+}
+
+// Validator drops implausible readings, like a temperature and humidity of
+// exactly zero, values out of bounds or timestamps in the future. Rejected
+// readings are logged and counted. It has to run before the temperatures are
+// converted.
+type Validator struct {
+	bounds   Bounds
+	log      *zap.Logger
+	rejected metric.Int64Counter
+}
+
+// NewValidator creates a validator with the given bounds.
+func NewValidator(meter metric.Meter, logger *zap.Logger, bounds Bounds) (*Validator, error) {
+	if err := bounds.Validate(); err != nil {
+		return nil, err
+	}
+
+	rejected, err := meter.Int64Counter("sensor.readings.rejected",
+		metric.WithDescription("The number of implausible readings which were not exported"),
+		metric.WithUnit("{reading}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{bounds: bounds, log: logger, rejected: rejected}, nil
+}
+
+func (v *Validator) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	now := time.Now()
+	out := make([]*egain.SensorReading, 0, len(readings))
+	for _, r := range readings {
+		reason := v.bounds.check(r, now)
+		if reason == "" {
+			out = append(out, r)
+			continue
+		}
+
+		v.log.Warn("rejecting implausible reading",
+			zap.String("sensorId", r.SensorID),
+			zap.String("location", r.Location),
+			zap.String("reason", reason),
+			zap.Float64("temperature", r.Temperature),
+			zap.Float64("humidity", r.Humidity),
+			zap.Time("timestamp", r.Timestamp),
+		)
+		v.rejected.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("sensor.id", r.SensorID),
+			attribute.String("sensor.location", r.Location),
+			attribute.String("reason", reason),
+		))
+	}
+	return out, nil
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Windows records the average, minimum and maximum temperature and humidity
+// of each sensor over fixed windows as metrics, e.g. for backends with a long
+// retention which do not need every reading. The windows are aligned to
+// multiples of their duration and assigned by the timestamp of the readings,
+// a window is recorded once the first reading of the next window arrives.
+// Unchanged and stale readings are left out, so a reading is only counted
+// once, as are heating systems. The readings are passed on as they are.
+type Windows struct {
+	window      time.Duration
+	temperature metric.Float64Gauge
+	humidity    metric.Float64Gauge
+
+	mu      sync.Mutex
+	current map[string]*sensorWindow
+}
+
+// sensorWindow is the running window of a sensor.
+type sensorWindow struct {
+	start                 time.Time
+	sensor                egain.Sensor
+	temperature, humidity aggregate
+}
+
+// NewWindows creates the instruments of the windows on the meter. The
+// temperature unit is the unit of the readings the processor is given.
+func NewWindows(meter metric.Meter, window time.Duration, temperatureUnit string) (*Windows, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid aggregation window %s", window)
+	}
+
+	var (
+		w   = Windows{window: window, current: map[string]*sensorWindow{}}
+		err error
+	)
+	w.temperature, err = meter.Float64Gauge("sensor.window.temperature",
+		metric.WithUnit(temperatureUnit),
+		metric.WithDescription("Average, minimum and maximum temperature of a sensor over the last aggregation window"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	w.humidity, err = meter.Float64Gauge("sensor.window.humidity",
+		metric.WithUnit("%rH"),
+		metric.WithDescription("Average, minimum and maximum relative humidity of a sensor over the last aggregation window"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (w *Windows) Process(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, r := range readings {
+		if r.Unchanged || r.Stale || r.Heating != nil {
+			continue
+		}
+		start := r.Timestamp.Truncate(w.window)
+		cur := w.current[r.SensorID]
+		switch {
+		case cur == nil:
+		case start.Equal(cur.start):
+			cur.add(r)
+			continue
+		case start.Before(cur.start):
+			// late readings of a window which is already recorded
+			continue
+		default:
+			w.record(ctx, cur)
+		}
+		cur = &sensorWindow{start: start, sensor: r.Sensor}
+		cur.add(r)
+		w.current[r.SensorID] = cur
+	}
+	return readings, nil
+}
+
+func (s *sensorWindow) add(r *egain.SensorReading) {
+	s.sensor = r.Sensor
+	s.temperature.add(r.Temperature)
+	s.humidity.add(r.Humidity)
+}
+
+// record records the aggregates of the completed window.
+func (w *Windows) record(ctx context.Context, s *sensorWindow) {
+	attrs := []attribute.KeyValue{
+		attribute.String("sensor.id", s.sensor.SensorID),
+		attribute.String("sensor.location", s.sensor.Location),
+		attribute.String("window", w.window.String()),
+	}
+	for _, m := range []struct {
+		gauge metric.Float64Gauge
+		agg   *aggregate
+	}{
+		{w.temperature, &s.temperature},
+		{w.humidity, &s.humidity},
+	} {
+		for _, stat := range m.agg.stats() {
+			m.gauge.Record(ctx, stat.value, metric.WithAttributes(append(attrs, attribute.String("aggregation", stat.name))...))
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Package bacnet is the provider of sensors read from the present values of
+// the objects of a local BACnet/IP device, e.g. a building controller.
+//
+// The objects of each sensor are configured in the settings of the provider:
+//
+//	providers:
+//	  - name: controller
+//	    kind: bacnet
+//	    settings:
+//	      address: 10.0.0.6:47808
+//	      sensors:
+//	        room-2:
+//	          temperature: {object: analog-input:1}
+//	          humidity: {object: analog-value:3}
+package bacnet
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/providers"
+	"go.uber.org/zap"
+)
+
+// Kind is the kind of the provider in the config file.
+const Kind = "bacnet"
+
+const (
+	defaultPort    = "47808"
+	defaultTimeout = 5 * time.Second
+
+	// propertyPresentValue is the property of the value of an object
+	propertyPresentValue = 85
+	// serviceReadProperty is the confirmed service reading a property
+	serviceReadProperty = 0x0c
+)
+
+// objectTypes are the types of objects with a numeric present value.
+var objectTypes = map[string]uint32{
+	"analog-input":  0,
+	"analog-output": 1,
+	"analog-value":  2,
+}
+
+func init() {
+	providers.Register(Kind, New)
+}
+
+type settings struct {
+	// Address is the host and port of the device, the port defaults to
+	// 47808.
+	Address string                    `yaml:"address"`
+	Timeout time.Duration             `yaml:"timeout"`
+	Sensors map[string]sensorSettings `yaml:"sensors"`
+}
+
+type sensorSettings struct {
+	Temperature *Point `yaml:"temperature"`
+	Humidity    *Point `yaml:"humidity"`
+}
+
+// Point is the present value of an object of the device, value * scale +
+// offset.
+type Point struct {
+	// Object is the type and instance of the object, e.g. analog-input:1.
+	Object string   `yaml:"object"`
+	Scale  *float64 `yaml:"scale"`
+	Offset float64  `yaml:"offset"`
+
+	id uint32
+}
+
+// parse parses the object identifier of the point.
+func (p *Point) parse() error {
+	typ, instance, ok := strings.Cut(p.Object, ":")
+	t, known := objectTypes[typ]
+	if !ok || !known {
+		return fmt.Errorf("invalid object %q, expected analog-input, analog-output or analog-value and the instance, e.g. analog-input:1", p.Object)
+	}
+	n, err := strconv.ParseUint(instance, 10, 22)
+	if err != nil {
+		return fmt.Errorf("invalid instance of object %q", p.Object)
+	}
+	p.id = t<<22 | uint32(n)
+	return nil
+}
+
+// Provider reads the sensors from the objects of a BACnet/IP device with a
+// ReadProperty request per value.
+type Provider struct {
+	name    string
+	log     *zap.Logger
+	address string
+	timeout time.Duration
+	points  map[string]sensorSettings
+
+	mu      sync.Mutex
+	sensors []egain.Sensor
+	// fetch serializes the fetches, so the invoke IDs do not collide
+	fetch    sync.Mutex
+	invokeID uint8
+}
+
+// New creates the provider of the settings.
+func New(logger *zap.Logger, name string, s map[string]any) (providers.Provider, error) {
+	var cfg settings
+	if err := providers.DecodeSettings(s, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Address == "" {
+		return nil, errors.New("missing address")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		cfg.Address = net.JoinHostPort(cfg.Address, defaultPort)
+	}
+	for id, s := range cfg.Sensors {
+		if s.Temperature == nil {
+			return nil, fmt.Errorf("sensor %s: missing temperature object", id)
+		}
+		for _, p := range []*Point{s.Temperature, s.Humidity} {
+			if p == nil {
+				continue
+			}
+			if err := p.parse(); err != nil {
+				return nil, fmt.Errorf("sensor %s: %w", id, err)
+			}
+		}
+	}
+
+	p := &Provider{
+		name:    name,
+		log:     logger,
+		address: cfg.Address,
+		timeout: defaultTimeout,
+		points:  cfg.Sensors,
+	}
+	if cfg.Timeout > 0 {
+		p.timeout = cfg.Timeout
+	}
+	return p, nil
+}
+
+// SetSensors replaces the sensors, each needs objects in the settings.
+func (p *Provider) SetSensors(sensors []egain.Sensor) error {
+	for _, s := range sensors {
+		if _, ok := p.points[s.SensorID]; !ok {
+			return fmt.Errorf("sensor %s: no objects in the settings of provider %s", s.SensorID, p.name)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sensors = slices.Clone(sensors)
+	return nil
+}
+
+// Sensors returns a copy of the sensors.
+func (p *Provider) Sensors() []egain.Sensor {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.sensors)
+}
+
+// FetchSensors reads the objects of the sensors.
+func (p *Provider) FetchSensors(ctx context.Context, sensors []egain.Sensor) ([]*egain.SensorReading, error) {
+	p.fetch.Lock()
+	defer p.fetch.Unlock()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "udp", p.address)
+	if err != nil {
+		errs := make([]error, 0, len(sensors))
+		for _, s := range sensors {
+			errs = append(errs, &egain.SensorError{SensorID: s.SensorID, Location: s.Location, Err: err})
+		}
+		return nil, errors.Join(errs...)
+	}
+	defer conn.Close()
+
+	var (
+		readings []*egain.SensorReading
+		errs     []error
+	)
+	for _, s := range sensors {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, &egain.SensorError{SensorID: s.SensorID, Location: s.Location, Err: err})
+			continue
+		}
+		r, err := p.read(conn, s)
+		if err != nil {
+			errs = append(errs, &egain.SensorError{SensorID: s.SensorID, Location: s.Location, Err: err})
+			continue
+		}
+		p.log.Debug("read sensor", zap.String("sensorId", s.SensorID), zap.Float64("temperature", r.Temperature))
+		readings = append(readings, r)
+	}
+	return readings, errors.Join(errs...)
+}
+
+// read reads the points of the sensor.
+func (p *Provider) read(conn net.Conn, s egain.Sensor) (*egain.SensorReading, error) {
+	cfg, ok := p.points[s.SensorID]
+	if !ok {
+		return nil, fmt.Errorf("no objects in the settings of provider %s", p.name)
+	}
+
+	r := providers.NewReading(s, time.Now())
+	t, err := p.readPoint(conn, cfg.Temperature)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read temperature: %w", err)
+	}
+	r.Temperature = t
+	if cfg.Humidity != nil {
+		h, err := p.readPoint(conn, cfg.Humidity)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read humidity: %w", err)
+		}
+		r.Humidity = h
+	}
+	return r, nil
+}
+
+// readPoint reads the present value of the object of the point.
+func (p *Provider) readPoint(conn net.Conn, point *Point) (float64, error) {
+	p.invokeID++
+	id := p.invokeID
+	req := []byte{
+		// BVLC: BACnet/IP, original unicast NPDU, length
+		0x81, 0x0a, 0, 0,
+		// NPDU: version 1, expecting a reply
+		0x01, 0x04,
+		// APDU: confirmed request, up to 1476 bytes, invoke ID, service
+		0x00, 0x05, id, serviceReadProperty,
+		// context tag 0: object identifier
+		0x0c, 0, 0, 0, 0,
+		// context tag 1: property identifier
+		0x19, propertyPresentValue,
+	}
+	binary.BigEndian.PutUint16(req[2:], uint16(len(req)))
+	binary.BigEndian.PutUint32(req[11:], point.id)
+
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		apdu, err := parseNPDU(buf[:n])
+		if err != nil {
+			return 0, err
+		}
+		// responses to earlier, timed out requests are skipped
+		if len(apdu) < 3 || apdu[1] != id {
+			continue
+		}
+		v, err := parseResponse(apdu)
+		if err != nil {
+			return 0, err
+		}
+		if point.Scale != nil {
+			v *= *point.Scale
+		}
+		return v + point.Offset, nil
+	}
+}
+
+// parseNPDU returns the APDU of a BACnet/IP packet.
+func parseNPDU(b []byte) ([]byte, error) {
+	if len(b) < 6 || b[0] != 0x81 || b[4] != 0x01 {
+		return nil, errors.New("invalid BACnet/IP packet")
+	}
+	b, control := b[6:], b[5]
+	if control&0x80 != 0 {
+		return nil, errors.New("unexpected network layer message")
+	}
+	// skip the destination and source specifiers of routed packets
+	if control&0x20 != 0 {
+		if len(b) < 3 || len(b) < 3+int(b[2]) {
+			return nil, errors.New("invalid BACnet/IP packet")
+		}
+		b = b[3+int(b[2]):]
+	}
+	if control&0x08 != 0 {
+		if len(b) < 3 || len(b) < 3+int(b[2]) {
+			return nil, errors.New("invalid BACnet/IP packet")
+		}
+		b = b[3+int(b[2]):]
+	}
+	if control&0x20 != 0 {
+		// hop count
+		if len(b) < 1 {
+			return nil, errors.New("invalid BACnet/IP packet")
+		}
+		b = b[1:]
+	}
+	return b, nil
+}
+
+// parseResponse returns the present value of the response to a ReadProperty
+// request.
+func parseResponse(apdu []byte) (float64, error) {
+	switch apdu[0] >> 4 {
+	case 3: // complex ACK
+	case 5: // error
+		if len(apdu) >= 7 {
+			return 0, fmt.Errorf("bacnet error: class %d, code %d", apdu[4], apdu[6])
+		}
+		return 0, errors.New("bacnet error")
+	case 6: // reject
+		return 0, fmt.Errorf("bacnet reject: reason %d", apdu[2])
+	case 7: // abort
+		return 0, fmt.Errorf("bacnet abort: reason %d", apdu[2])
+	default:
+		return 0, fmt.Errorf("unexpected bacnet response %#x", apdu[0])
+	}
+	if apdu[0]&0x08 != 0 {
+		return 0, errors.New("segmented bacnet responses are not supported")
+	}
+
+	// the object identifier, the property identifier and the optional array
+	// index precede the opening tag 3 of the value
+	v := apdu[3:]
+	if len(v) < 5 || v[0] != 0x0c {
+		return 0, errors.New("invalid bacnet response: missing object identifier")
+	}
+	v = v[5:]
+	for _, tag := range []byte{0x18, 0x28} {
+		if len(v) > 0 && v[0]&0xf8 == tag {
+			n := int(v[0] & 0x07)
+			if len(v) < 1+n {
+				return 0, errors.New("invalid bacnet response")
+			}
+			v = v[1+n:]
+		}
+	}
+	if len(v) < 2 || v[0] != 0x3e {
+		return 0, errors.New("invalid bacnet response: missing property value")
+	}
+	v = v[1:]
+	tag, length := v[0]>>4, int(v[0]&0x07)
+	v = v[1:]
+	if length == 5 {
+		// the extended length
+		if len(v) == 0 {
+			return 0, errors.New("invalid bacnet response: short property value")
+		}
+		length, v = int(v[0]), v[1:]
+	}
+	if len(v) < length {
+		return 0, errors.New("invalid bacnet response: short property value")
+	}
+	v = v[:length]
+	switch {
+	case tag == 4 && length == 4: // real
+		return providers.Float32(binary.BigEndian.Uint32(v)), nil
+	case tag == 5 && length == 8: // double
+		return math.Float64frombits(binary.BigEndian.Uint64(v)), nil
+	case tag == 2 && length <= 4: // unsigned
+		var n uint32
+		for _, b := range v {
+			n = n<<8 | uint32(b)
+		}
+		return float64(n), nil
+	case tag == 3 && length <= 4 && length > 0: // signed
+		n := int32(int8(v[0]))
+		for _, b := range v[1:] {
+			n = n<<8 | int32(b)
+		}
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("unsupported bacnet value of tag %d", tag)
+}
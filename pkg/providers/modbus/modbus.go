@@ -0,0 +1,341 @@
+// Package modbus is the provider of sensors read from the holding or input
+// registers of a local Modbus-TCP device, e.g. a building controller.
+//
+// The registers of each sensor are configured in the settings of the
+// provider:
+//
+//	providers:
+//	  - name: plant
+//	    kind: modbus
+//	    settings:
+//	      address: 10.0.0.5:502
+//	      sensors:
+//	        room-1:
+//	          temperature: {register: 100, type: int16, scale: 0.1}
+//	          humidity: {register: 101, type: uint16, scale: 0.1}
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/providers"
+	"go.uber.org/zap"
+)
+
+// Kind is the kind of the provider in the config file.
+const Kind = "modbus"
+
+const (
+	defaultPort    = "502"
+	defaultUnitID  = 1
+	defaultTimeout = 5 * time.Second
+
+	readHolding = 0x03
+	readInput   = 0x04
+)
+
+func init() {
+	providers.Register(Kind, New)
+}
+
+type settings struct {
+	// Address is the host and port of the device, the port defaults to 502.
+	Address string `yaml:"address"`
+	// UnitID is the unit of the device, 1 by default.
+	UnitID  *uint8                    `yaml:"unit_id"`
+	Timeout time.Duration             `yaml:"timeout"`
+	Sensors map[string]sensorSettings `yaml:"sensors"`
+}
+
+type sensorSettings struct {
+	// UnitID overrides the unit of the device for the sensor, e.g. behind a
+	// gateway.
+	UnitID      *uint8 `yaml:"unit_id"`
+	Temperature *Point `yaml:"temperature"`
+	Humidity    *Point `yaml:"humidity"`
+}
+
+// Point is a value of the device, register * scale + offset.
+type Point struct {
+	Register uint16 `yaml:"register"`
+	// Input reads an input register instead of a holding register.
+	Input bool `yaml:"input"`
+	// Type is the type of the register, int16 by default. The 32 bit types
+	// span two registers, the high word first.
+	Type   string   `yaml:"type"`
+	Scale  *float64 `yaml:"scale"`
+	Offset float64  `yaml:"offset"`
+}
+
+// registers returns the number of registers of the type.
+func (p *Point) registers() (uint16, error) {
+	switch p.Type {
+	case "", "int16", "uint16":
+		return 1, nil
+	case "int32", "uint32", "float32":
+		return 2, nil
+	}
+	return 0, fmt.Errorf("unknown register type %q, expected int16, uint16, int32, uint32 or float32", p.Type)
+}
+
+// value decodes the registers of the point.
+func (p *Point) value(data []byte) float64 {
+	var v float64
+	switch p.Type {
+	case "", "int16":
+		v = float64(int16(binary.BigEndian.Uint16(data)))
+	case "uint16":
+		v = float64(binary.BigEndian.Uint16(data))
+	case "int32":
+		v = float64(int32(binary.BigEndian.Uint32(data)))
+	case "uint32":
+		v = float64(binary.BigEndian.Uint32(data))
+	case "float32":
+		v = providers.Float32(binary.BigEndian.Uint32(data))
+	}
+	if p.Scale != nil {
+		v *= *p.Scale
+	}
+	return v + p.Offset
+}
+
+// Provider reads the sensors from the registers of a Modbus-TCP device, it
+// connects for each fetch and reads the sensors one after the other.
+type Provider struct {
+	name    string
+	log     *zap.Logger
+	address string
+	unitID  uint8
+	timeout time.Duration
+	points  map[string]sensorSettings
+
+	mu      sync.Mutex
+	sensors []egain.Sensor
+	// fetch serializes the fetches, so the transactions do not interleave
+	fetch sync.Mutex
+	tid   uint16
+}
+
+// New creates the provider of the settings.
+func New(logger *zap.Logger, name string, s map[string]any) (providers.Provider, error) {
+	var cfg settings
+	if err := providers.DecodeSettings(s, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Address == "" {
+		return nil, errors.New("missing address")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		cfg.Address = net.JoinHostPort(cfg.Address, defaultPort)
+	}
+	for id, s := range cfg.Sensors {
+		if s.Temperature == nil {
+			return nil, fmt.Errorf("sensor %s: missing temperature register", id)
+		}
+		for _, p := range []*Point{s.Temperature, s.Humidity} {
+			if p == nil {
+				continue
+			}
+			if _, err := p.registers(); err != nil {
+				return nil, fmt.Errorf("sensor %s: %w", id, err)
+			}
+		}
+	}
+
+	p := &Provider{
+		name:    name,
+		log:     logger,
+		address: cfg.Address,
+		unitID:  defaultUnitID,
+		timeout: defaultTimeout,
+		points:  cfg.Sensors,
+	}
+	if cfg.UnitID != nil {
+		p.unitID = *cfg.UnitID
+	}
+	if cfg.Timeout > 0 {
+		p.timeout = cfg.Timeout
+	}
+	return p, nil
+}
+
+// SetSensors replaces the sensors, each needs registers in the settings.
+func (p *Provider) SetSensors(sensors []egain.Sensor) error {
+	for _, s := range sensors {
+		if _, ok := p.points[s.SensorID]; !ok {
+			return fmt.Errorf("sensor %s: no registers in the settings of provider %s", s.SensorID, p.name)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sensors = slices.Clone(sensors)
+	return nil
+}
+
+// Sensors returns a copy of the sensors.
+func (p *Provider) Sensors() []egain.Sensor {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.sensors)
+}
+
+// FetchSensors reads the registers of the sensors. The connection is
+// re-established after a failure, so one broken transaction only fails its
+// sensor.
+func (p *Provider) FetchSensors(ctx context.Context, sensors []egain.Sensor) ([]*egain.SensorReading, error) {
+	p.fetch.Lock()
+	defer p.fetch.Unlock()
+
+	var (
+		conn     net.Conn
+		readings []*egain.SensorReading
+		errs     []error
+	)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	dialer := net.Dialer{Timeout: p.timeout}
+	for _, s := range sensors {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, &egain.SensorError{SensorID: s.SensorID, Location: s.Location, Err: err})
+			continue
+		}
+		if conn == nil {
+			c, err := dialer.DialContext(ctx, "tcp", p.address)
+			if err != nil {
+				errs = append(errs, &egain.SensorError{SensorID: s.SensorID, Location: s.Location, Err: err})
+				continue
+			}
+			conn = c
+		}
+
+		r, err := p.read(conn, s)
+		if err != nil {
+			var exc *exceptionError
+			if !errors.As(err, &exc) {
+				conn.Close()
+				conn = nil
+			}
+			errs = append(errs, &egain.SensorError{SensorID: s.SensorID, Location: s.Location, Err: err})
+			continue
+		}
+		p.log.Debug("read sensor", zap.String("sensorId", s.SensorID), zap.Float64("temperature", r.Temperature))
+		readings = append(readings, r)
+	}
+	return readings, errors.Join(errs...)
+}
+
+// read reads the points of the sensor.
+func (p *Provider) read(conn net.Conn, s egain.Sensor) (*egain.SensorReading, error) {
+	cfg, ok := p.points[s.SensorID]
+	if !ok {
+		return nil, fmt.Errorf("no registers in the settings of provider %s", p.name)
+	}
+	unit := p.unitID
+	if cfg.UnitID != nil {
+		unit = *cfg.UnitID
+	}
+
+	r := providers.NewReading(s, time.Now())
+	t, err := p.readPoint(conn, unit, cfg.Temperature)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read temperature: %w", err)
+	}
+	r.Temperature = t
+	if cfg.Humidity != nil {
+		h, err := p.readPoint(conn, unit, cfg.Humidity)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read humidity: %w", err)
+		}
+		r.Humidity = h
+	}
+	return r, nil
+}
+
+// readPoint reads the registers of the point in a single transaction.
+func (p *Provider) readPoint(conn net.Conn, unit uint8, point *Point) (float64, error) {
+	count, err := point.registers()
+	if err != nil {
+		return 0, err
+	}
+	function := byte(readHolding)
+	if point.Input {
+		function = readInput
+	}
+
+	p.tid++
+	req := make([]byte, 12)
+	binary.BigEndian.PutUint16(req[0:], p.tid)
+	binary.BigEndian.PutUint16(req[4:], 6)
+	req[6] = unit
+	req[7] = function
+	binary.BigEndian.PutUint16(req[8:], point.Register)
+	binary.BigEndian.PutUint16(req[10:], count)
+
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	// the MBAP header tells the length of the rest of the response
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, err
+	}
+	n := binary.BigEndian.Uint16(header[4:])
+	if n < 2 || n > 254 {
+		return 0, fmt.Errorf("invalid response length %d", n)
+	}
+	pdu := make([]byte, n-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		return 0, err
+	}
+	if tid := binary.BigEndian.Uint16(header); tid != p.tid {
+		return 0, fmt.Errorf("unexpected transaction %d, expected %d", tid, p.tid)
+	}
+
+	switch {
+	case pdu[0] == function|0x80 && len(pdu) >= 2:
+		return 0, &exceptionError{code: pdu[1]}
+	case pdu[0] != function:
+		return 0, fmt.Errorf("unexpected function %#x", pdu[0])
+	case len(pdu) < 2 || int(pdu[1]) != 2*int(count) || len(pdu) < 2+2*int(count):
+		return 0, errors.New("invalid response size")
+	}
+	return point.value(pdu[2:]), nil
+}
+
+// exceptionError is an exception response of the device, the connection is
+// still usable.
+type exceptionError struct {
+	code byte
+}
+
+func (e *exceptionError) Error() string {
+	switch e.code {
+	case 0x01:
+		return "modbus exception: illegal function"
+	case 0x02:
+		return "modbus exception: illegal data address"
+	case 0x03:
+		return "modbus exception: illegal data value"
+	case 0x04:
+		return "modbus exception: server device failure"
+	case 0x0b:
+		return "modbus exception: gateway target device failed to respond"
+	}
+	return fmt.Sprintf("modbus exception %#x", e.code)
+}
@@ -0,0 +1,175 @@
+// Package providers is the registry of the backends of climate sensors. The
+// egain accounts are the default provider, further backends are implemented
+// in the packages below and register their kind on init, so their sensors can
+// be mixed with the egain sensors in one scraper.
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/pipeline"
+	"go.uber.org/zap"
+)
+
+// Default is the name of the egain accounts, the provider of the sensors
+// whose Provider is empty.
+const Default = ""
+
+// Provider fetches the readings of its sensors like the egain accounts, it
+// reports the readings in the model of the egain readings.
+type Provider interface {
+	pipeline.Fetcher
+	// SetSensors replaces the sensors of the provider, the previous ones are
+	// kept if any is invalid.
+	SetSensors(sensors []egain.Sensor) error
+	// Sensors returns a copy of the sensors of the provider.
+	Sensors() []egain.Sensor
+}
+
+// Factory creates a provider of a kind, the settings are the ones of the
+// provider in the config file.
+type Factory func(logger *zap.Logger, name string, settings map[string]any) (Provider, error)
+
+var (
+	registryMu sync.Mutex
+	factories  = map[string]Factory{}
+)
+
+// Register registers the factory of the kind of providers, it panics if the
+// kind is registered twice.
+func Register(kind string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := factories[kind]; ok {
+		panic("providers: kind " + kind + " registered twice")
+	}
+	factories[kind] = factory
+}
+
+// Kinds returns the registered kinds of providers.
+func Kinds() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return slices.Sorted(maps.Keys(factories))
+}
+
+// New creates a provider of the kind.
+func New(kind string, logger *zap.Logger, name string, settings map[string]any) (Provider, error) {
+	registryMu.Lock()
+	factory, ok := factories[kind]
+	registryMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown kind of provider %q, expected one of %v", kind, Kinds())
+	}
+	return factory(logger, name, settings)
+}
+
+// Select returns the sensors of the provider.
+func Select(sensors []egain.Sensor, provider string) []egain.Sensor {
+	var selected []egain.Sensor
+	for _, s := range sensors {
+		if s.Provider == provider {
+			selected = append(selected, s)
+		}
+	}
+	return selected
+}
+
+// Router routes the sensors to the providers of their Provider names, it is
+// the fetcher of the pipeline when sensors of several providers are mixed.
+type Router struct {
+	providers map[string]Provider
+}
+
+// NewRouter returns the router of the providers by their name, which need to
+// include the Default provider, and distributes the sensors to them.
+func NewRouter(providers map[string]Provider, sensors []egain.Sensor) (*Router, error) {
+	if _, ok := providers[Default]; !ok {
+		return nil, errors.New("missing the default provider")
+	}
+	r := &Router{providers: providers}
+	if err := r.SetSensors(sensors); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Sensors returns a copy of the sensors of all providers.
+func (r *Router) Sensors() []egain.Sensor {
+	var sensors []egain.Sensor
+	for _, p := range r.providers {
+		sensors = append(sensors, p.Sensors()...)
+	}
+	return sensors
+}
+
+// SetSensors replaces the sensors of all providers. Nothing is replaced if a
+// sensor belongs to an unknown provider or a provider rejects its sensors.
+func (r *Router) SetSensors(sensors []egain.Sensor) error {
+	for _, s := range sensors {
+		if _, ok := r.providers[s.Provider]; !ok {
+			return fmt.Errorf("sensor %s: unknown provider %q", s.SensorID, s.Provider)
+		}
+	}
+
+	previous := make(map[string][]egain.Sensor, len(r.providers))
+	for name, p := range r.providers {
+		previous[name] = p.Sensors()
+	}
+	for name, p := range r.providers {
+		if err := p.SetSensors(Select(sensors, name)); err != nil {
+			// restore the providers which were already set
+			for n, q := range r.providers {
+				q.SetSensors(previous[n])
+			}
+			if name != Default {
+				return fmt.Errorf("provider %s: %w", name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchSensors fetches the given sensors, the providers are fetched
+// concurrently. Like the egain client, it returns partial results alongside
+// the joined errors.
+func (r *Router) FetchSensors(ctx context.Context, sensors []egain.Sensor) ([]*egain.SensorReading, error) {
+	byProvider := make(map[string][]egain.Sensor, len(r.providers))
+	var errs []error
+	for _, s := range sensors {
+		if _, ok := r.providers[s.Provider]; !ok {
+			errs = append(errs, fmt.Errorf("sensor %s: unknown provider %q", s.SensorID, s.Provider))
+			continue
+		}
+		byProvider[s.Provider] = append(byProvider[s.Provider], s)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		readings []*egain.SensorReading
+	)
+	for name, sensors := range byProvider {
+		wg.Add(1)
+		go func(p Provider, sensors []egain.Sensor) {
+			defer wg.Done()
+			r, err := p.FetchSensors(ctx, sensors)
+
+			mu.Lock()
+			defer mu.Unlock()
+			readings = append(readings, r...)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(r.providers[name], sensors)
+	}
+	wg.Wait()
+	return readings, errors.Join(errs...)
+}
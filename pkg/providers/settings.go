@@ -0,0 +1,46 @@
+package providers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"gopkg.in/yaml.v3"
+)
+
+// DecodeSettings decodes the settings of a provider into v, a pointer to a
+// struct with yaml tags. Unknown settings are rejected, so typos are not
+// silently ignored.
+func DecodeSettings(settings map[string]any, v any) error {
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+	return nil
+}
+
+// NewReading returns a reading of the sensor taken at the time, for providers
+// which read the current values of the devices.
+func NewReading(s egain.Sensor, ts time.Time) *egain.SensorReading {
+	r := &egain.SensorReading{Sensor: s}
+	r.Installed = true
+	r.Timestamp = ts
+	return r
+}
+
+// Float32 returns the value of the bits of a float32 as the float64 of its
+// shortest decimal, so 21.7 is reported as 21.7 and not 21.700000762939453.
+func Float32(bits uint32) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(math.Float32frombits(bits)), 'g', -1, 32), 64)
+	return f
+}
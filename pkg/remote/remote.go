@@ -0,0 +1,186 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+)
+
+// maxSize bounds the size of a document, which is a configuration.
+const maxSize = 10 << 20
+
+// Document is a document served over HTTP, e.g. the sensors of a central
+// configuration service. It is fetched with conditional requests, so an
+// unchanged document costs a 304 Not Modified if the server sends an ETag or
+// Last-Modified.
+type Document struct {
+	url     *url.URL
+	client  *http.Client
+	header  http.Header
+	timeout time.Duration
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+type Option func(d *Document) error
+
+// New returns the document at the http or https URL. Credentials in the URL
+// are sent with basic authentication.
+func New(rawURL string, opts ...Option) (*Document, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid document url %q, expected an http or https url", u.Redacted())
+	}
+
+	d := &Document{
+		url:     u,
+		client:  &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		header:  http.Header{},
+		timeout: 30 * time.Second,
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// WithHeader adds a header to the requests, e.g. the Authorization of the
+// configuration service.
+func WithHeader(key, value string) Option {
+	return func(d *Document) error {
+		if key == "" {
+			return errors.New("empty header name")
+		}
+		d.header.Add(key, value)
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of a fetch, 30 seconds by default.
+func WithTimeout(t time.Duration) Option {
+	return func(d *Document) error {
+		if t <= 0 {
+			return errors.New("invalid document timeout")
+		}
+		d.timeout = t
+		return nil
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client.
+func WithHTTPClient(c *http.Client) Option {
+	return func(d *Document) error {
+		d.client = c
+		return nil
+	}
+}
+
+// URL returns the URL of the document without its password, e.g. to log it.
+func (d *Document) URL() string {
+	return d.url.Redacted()
+}
+
+// Fetch returns the body of the document and whether it changed since the
+// previous fetch. The body of the previous fetch is returned if the server
+// responds with 304 Not Modified.
+func (d *Document) Fetch(ctx context.Context) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header = d.header.Clone()
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9")
+
+	d.mu.Lock()
+	if d.body != nil {
+		if d.etag != "" {
+			req.Header.Set("If-None-Match", d.etag)
+		}
+		if d.lastModified != "" {
+			req.Header.Set("If-Modified-Since", d.lastModified)
+		}
+	}
+	d.mu.Unlock()
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot fetch %s: %w", d.URL(), err)
+	}
+	defer resp.Body.Close()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && d.body != nil:
+		return d.body, false, nil
+	case resp.StatusCode != http.StatusOK:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, false, fmt.Errorf("cannot fetch %s: %s: %s", d.URL(), resp.Status, bytes.TrimSpace(msg))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot fetch %s: %w", d.URL(), err)
+	}
+	if len(body) > maxSize {
+		return nil, false, fmt.Errorf("cannot fetch %s: larger than %d bytes", d.URL(), maxSize)
+	}
+	// servers without validators respond with the whole document, which
+	// changed only if its content did
+	changed := d.body == nil || !bytes.Equal(body, d.body)
+	d.body = body
+	d.etag = resp.Header.Get("ETag")
+	d.lastModified = resp.Header.Get("Last-Modified")
+	return body, changed, nil
+}
+
+// Watch fetches the document at the interval and notifies changed whenever
+// it changed, until the context is done. Failed fetches are logged and
+// retried at the next interval.
+func (d *Document) Watch(ctx context.Context, logger *zap.Logger, interval time.Duration, changed chan<- struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, ok, err := d.Fetch(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			logger.Warn("cannot fetch document", zap.String("url", d.URL()), zap.Error(err))
+		case ok:
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
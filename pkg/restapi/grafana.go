@@ -0,0 +1,161 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Grafana is the stored history of the readings with the sensors it holds,
+// e.g. the store.
+type Grafana interface {
+	History
+	Sensors(ctx context.Context) ([]string, error)
+}
+
+// measurements are the measurements which can be charted per sensor.
+var measurements = []string{"temperature", "humidity"}
+
+// RegisterGrafana registers the routes of the Grafana JSON datasource on the
+// mux, so the stored readings can be charted without another database:
+//
+//	GET  /grafana/         the test of the datasource connection
+//	POST /grafana/search   the targets, <sensor>.temperature and
+//	                       <sensor>.humidity of each stored sensor
+//	POST /grafana/query    the averaged readings of the targets as time
+//	                       series
+//
+// The Infinity datasource can chart the history route directly.
+func RegisterGrafana(mux *http.ServeMux, g Grafana) {
+	mux.HandleFunc("GET /grafana/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+
+	mux.HandleFunc("POST /grafana/search", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Target string `json:"target"`
+		}
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		sensors, err := g.Sensors(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		targets := []string{}
+		for _, s := range sensors {
+			for _, m := range measurements {
+				t := s + "." + m
+				if strings.Contains(t, req.Target) {
+					targets = append(targets, t)
+				}
+			}
+		}
+		writeJSON(w, http.StatusOK, targets)
+	})
+
+	mux.HandleFunc("POST /grafana/query", func(w http.ResponseWriter, r *http.Request) {
+		var req grafanaQuery
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		if !req.Range.From.Before(req.Range.To) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "from is not before to"})
+			return
+		}
+		step := req.step()
+
+		out := []timeSeries{}
+		for _, t := range req.Targets {
+			if t.Hide || t.Target == "" {
+				continue
+			}
+			sensor, measurement, ok := parseTarget(t.Target)
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid target %q", t.Target)})
+				return
+			}
+
+			buckets, err := g.Query(r.Context(), sensor, req.Range.From, req.Range.To, step)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+				return
+			}
+			ts := timeSeries{Target: t.Target, Datapoints: make([][2]float64, 0, len(buckets))}
+			for _, b := range buckets {
+				v := b.Temperature.Avg
+				if measurement == "humidity" {
+					v = b.Humidity.Avg
+				}
+				ts.Datapoints = append(ts.Datapoints, [2]float64{v, float64(b.Start.UnixMilli())})
+			}
+			out = append(out, ts)
+		}
+		writeJSON(w, http.StatusOK, out)
+	})
+}
+
+// grafanaQuery is the request of a query of the Grafana JSON datasource.
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int64 `json:"intervalMs"`
+	MaxDataPoints int64 `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// step returns the interval requested by Grafana, increased if necessary to
+// stay within the maximum number of data points.
+func (q grafanaQuery) step() time.Duration {
+	step := max(time.Duration(q.IntervalMs)*time.Millisecond, time.Second)
+	points := int64(maxBuckets)
+	if q.MaxDataPoints > 0 {
+		points = min(q.MaxDataPoints, points)
+	}
+	if span := q.Range.To.Sub(q.Range.From); span/step > time.Duration(points) {
+		step = (span/time.Duration(points) + time.Second - 1).Truncate(time.Second)
+	}
+	return step
+}
+
+// timeSeries is a target of the response of a query, the data points are
+// pairs of the value and the time in milliseconds.
+type timeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// parseTarget splits a target into the sensor and the measurement.
+func parseTarget(t string) (sensor, measurement string, ok bool) {
+	i := strings.LastIndexByte(t, '.')
+	if i <= 0 {
+		return "", "", false
+	}
+	sensor, measurement = t[:i], t[i+1:]
+	for _, m := range measurements {
+		if m == measurement {
+			return sensor, measurement, true
+		}
+	}
+	return "", "", false
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package restapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/store"
+)
+
+// History is the stored history of the readings, e.g. the store.
+type History interface {
+	Query(ctx context.Context, sensorID string, from, to time.Time, step time.Duration) ([]store.Bucket, error)
+}
+
+// maxBuckets is the maximum number of buckets of a history query.
+const maxBuckets = 11000
+
+// RegisterHistory registers the history route of the API on the mux:
+//
+//	GET /api/v1/history?sensor=&from=&to=&step=  the stored readings of a
+//	                                             sensor in time buckets
+//
+// The from and to times are RFC 3339 timestamps, the last day by default.
+// The step is a duration like 5m or a number of seconds, one hour by
+// default.
+func RegisterHistory(mux *http.ServeMux, h History) {
+	mux.HandleFunc("GET /api/v1/history", func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseHistoryQuery(r, time.Now())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		buckets, err := h.Query(r.Context(), q.sensor, q.from, q.to, q.step)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		if buckets == nil {
+			buckets = []store.Bucket{}
+		}
+		writeJSON(w, http.StatusOK, history{
+			SensorID: q.sensor,
+			From:     q.from,
+			To:       q.to,
+			Step:     q.step.Seconds(),
+			Points:   buckets,
+		})
+	})
+}
+
+// history is the response of a history query.
+type history struct {
+	SensorID string         `json:"sensorId"`
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Step     float64        `json:"stepSeconds"`
+	Points   []store.Bucket `json:"points"`
+}
+
+type historyQuery struct {
+	sensor   string
+	from, to time.Time
+	step     time.Duration
+}
+
+func parseHistoryQuery(r *http.Request, now time.Time) (historyQuery, error) {
+	v := r.URL.Query()
+	q := historyQuery{
+		sensor: v.Get("sensor"),
+		from:   now.Add(-24 * time.Hour),
+		to:     now,
+		step:   time.Hour,
+	}
+	if q.sensor == "" {
+		return q, errors.New("missing sensor")
+	}
+
+	var err error
+	if s := v.Get("from"); s != "" {
+		if q.from, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if s := v.Get("to"); s != "" {
+		if q.to, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if s := v.Get("step"); s != "" {
+		if q.step, err = parseStep(s); err != nil {
+			return q, err
+		}
+	}
+
+	if !q.from.Before(q.to) {
+		return q, errors.New("from is not before to")
+	}
+	if q.step < time.Second {
+		return q, errors.New("step must be at least one second")
+	}
+	if q.to.Sub(q.from)/q.step > maxBuckets {
+		return q, fmt.Errorf("more than %d buckets, increase the step", maxBuckets)
+	}
+	return q, nil
+}
+
+// parseStep parses a duration or a number of seconds.
+func parseStep(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid step %q", s)
+	}
+	return d, nil
+}
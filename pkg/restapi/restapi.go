@@ -0,0 +1,202 @@
+// Package restapi serves the latest readings of the scraper as JSON.
+package restapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/state"
+)
+
+// Register registers the routes of the API on the mux:
+//
+//	GET /api/v1/readings             the latest readings of all sensors
+//	GET /api/v1/readings/{sensorID}  the latest reading of a sensor
+//	GET /api/v1/events               a stream of the new readings as
+//	                                 server-sent events
+func Register(mux *http.ServeMux, st *state.Registry) {
+	mux.HandleFunc("GET /api/v1/events", func(w http.ResponseWriter, r *http.Request) {
+		streamEvents(w, r, st)
+	})
+
+	mux.HandleFunc("GET /api/v1/readings", func(w http.ResponseWriter, r *http.Request) {
+		snap := st.Snapshot()
+		out := make([]reading, 0, len(snap.Sensors))
+		for _, s := range snap.Sensors {
+			out = append(out, toReading(s, snap.Time))
+		}
+		writeJSON(w, http.StatusOK, out)
+	})
+
+	mux.HandleFunc("GET /api/v1/readings/{sensorID}", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := st.Status(r.PathValue("sensorID"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "unknown sensor"})
+			return
+		}
+		writeJSON(w, http.StatusOK, toReading(s, time.Now()))
+	})
+}
+
+// Fetch statuses of a sensor.
+const (
+	statusOK      = "ok"
+	statusFailing = "failing"
+)
+
+// reading is the representation of the latest reading and fetch status of a
+// sensor.
+type reading struct {
+	SensorID string            `json:"sensorId"`
+	Location string            `json:"location"`
+	Kind     string            `json:"kind,omitempty"`
+	Account  string            `json:"account,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Status   string            `json:"status"`
+	Error    string            `json:"error,omitempty"`
+	FailedAt *time.Time        `json:"failedAt,omitempty"`
+
+	// the latest reading, if the sensor was fetched successfully before
+	FetchedAt            *time.Time     `json:"fetchedAt,omitempty"`
+	Temperature          *float64       `json:"temperature,omitempty"`
+	Humidity             *float64       `json:"humidity,omitempty"`
+	Timestamp            *time.Time     `json:"timestamp,omitempty"`
+	TimeZone             string         `json:"timeZone,omitempty"`
+	LocalTimestamp       *time.Time     `json:"localTimestamp,omitempty"`
+	AgeSeconds           *float64       `json:"ageSeconds,omitempty"`
+	Unchanged            bool           `json:"unchanged"`
+	Stale                bool           `json:"stale"`
+	Battery              *float64       `json:"battery,omitempty"`
+	SignalStrength       *float64       `json:"signalStrength,omitempty"`
+	CO2                  *float64       `json:"co2,omitempty"`
+	VOC                  *float64       `json:"voc,omitempty"`
+	ExternalTemperatures []egain.Value  `json:"externalTemperatures,omitempty"`
+	Values               []egain.Value  `json:"values,omitempty"`
+	Weather              *egain.Weather `json:"weather,omitempty"`
+	Comfort              *egain.Comfort `json:"comfort,omitempty"`
+	MoldRisk             *float64       `json:"moldRisk,omitempty"`
+	Trend                *egain.Trend   `json:"trend,omitempty"`
+	Heating              *egain.Heating `json:"heating,omitempty"`
+}
+
+func toReading(s state.Status, now time.Time) reading {
+	out := reading{
+		SensorID: s.SensorID,
+		Location: s.Location,
+		Status:   statusOK,
+	}
+	if s.Err != nil {
+		out.Status = statusFailing
+		out.Error = s.Err.Error()
+		out.FailedAt = &s.FailedAt
+	}
+
+	r := s.Reading
+	if r == nil {
+		return out
+	}
+	age := now.Sub(r.Timestamp).Seconds()
+	out.Kind = r.Kind.String()
+	out.Account = r.Account
+	out.Labels = r.Labels
+	out.FetchedAt = &s.FetchedAt
+	out.Temperature = &r.Temperature
+	out.Humidity = &r.Humidity
+	out.Timestamp = &r.Timestamp
+	if r.TimeZone != nil {
+		local := r.In(r.Timestamp)
+		out.TimeZone = r.TimeZone.String()
+		out.LocalTimestamp = &local
+	}
+	out.AgeSeconds = &age
+	out.Unchanged = r.Unchanged
+	out.Stale = r.Stale
+	out.Battery = r.Battery
+	out.SignalStrength = r.SignalStrength
+	out.CO2 = r.CO2
+	out.VOC = r.VOC
+	out.ExternalTemperatures = r.ExternalTemperatures
+	out.Values = r.Values
+	out.Weather = r.Weather
+	out.Comfort = r.Comfort
+	out.MoldRisk = r.MoldRisk
+	out.Trend = r.Trend
+	out.Heating = r.Heating
+	return out
+}
+
+// keepAlive is the interval of the comments sent to keep idle event streams
+// open through proxies.
+const keepAlive = 30 * time.Second
+
+// streamEvents sends each new reading as a server-sent event until the client
+// disconnects. The readings can be restricted to sensors with one or more
+// sensor query parameters.
+func streamEvents(w http.ResponseWriter, r *http.Request, st *state.Registry) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "streaming not supported"})
+		return
+	}
+	sensors := r.URL.Query()["sensor"]
+
+	ctx := r.Context()
+	readings := st.Subscribe(ctx)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case re, ok := <-readings:
+			if !ok {
+				return
+			}
+			if len(sensors) > 0 && !slices.Contains(sensors, re.SensorID) {
+				continue
+			}
+
+			now := time.Now()
+			data, err := json.Marshal(toReading(state.Status{
+				SensorID:  re.SensorID,
+				Location:  re.Location,
+				Reading:   re,
+				FetchedAt: now,
+			}, now))
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: reading\nid: %s\ndata: %s\n\n", re.SensorID, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,198 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a cron expression of the five standard fields minute, hour, day of
+// month, month and day of week, e.g. */15 8-18 * * MON-FRI every 15 minutes
+// during business hours. The fields are lists of values, ranges and steps,
+// months and days of the week may also be given by their first three
+// letters and Sunday is 0 or 7. If both the day of month and the day of week
+// are restricted, a day matching either is due, as in crontab. The
+// descriptors @yearly, @monthly, @weekly, @daily and @hourly are supported
+// as well.
+//
+// The times of an expression are in its location. An expression may start
+// with CRON_TZ=<zone> to override the location it is parsed in, e.g.
+// CRON_TZ=Europe/Zurich 0 7 * * *. Times which do not exist on the change to
+// daylight saving time are skipped, times which exist twice on the change
+// back are due twice.
+type Cron struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+	loc                           *time.Location
+}
+
+// descriptors are the shorthands of common expressions.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	dayNames   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// ParseCron parses the cron expression with its times in the location.
+func ParseCron(expr string, loc *time.Location) (*Cron, error) {
+	c := &Cron{expr: expr, loc: loc}
+	s := strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(s, "CRON_TZ="); ok {
+		zone, fields, _ := strings.Cut(rest, " ")
+		l, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		c.loc, s = l, strings.TrimSpace(fields)
+	}
+	if d, ok := descriptors[strings.ToLower(s)]; ok {
+		s = d
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q, expected 5 fields", expr)
+	}
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute of cron expression %q: %w", expr, err)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour of cron expression %q: %w", expr, err)
+	}
+	if c.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month of cron expression %q: %w", expr, err)
+	}
+	if c.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month of cron expression %q: %w", expr, err)
+	}
+	if c.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week of cron expression %q: %w", expr, err)
+	}
+	// Sunday is 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.domRestricted = !strings.HasPrefix(fields[2], "*")
+	c.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid cron expression %q, it is never due", expr)
+	}
+	return c, nil
+}
+
+// parseField parses a comma-separated list of values, ranges a-b and steps
+// */n, a-b/n or a/n into a set of bits.
+func parseField(s string, lo, hi int, names map[string]int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		r, step, hasStep := strings.Cut(part, "/")
+		n := 1
+		if hasStep {
+			var err error
+			if n, err = strconv.Atoi(step); err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", step)
+			}
+		}
+
+		from, to := lo, hi
+		if r != "*" {
+			a, b, isRange := strings.Cut(r, "-")
+			var err error
+			if from, err = parseValue(a, lo, hi, names); err != nil {
+				return 0, err
+			}
+			to = from
+			if isRange {
+				if to, err = parseValue(b, lo, hi, names); err != nil {
+					return 0, err
+				}
+				if to < from {
+					return 0, fmt.Errorf("invalid range %q", r)
+				}
+			} else if hasStep {
+				// a/n starts at a up to the maximum
+				to = hi
+			}
+		}
+		for v := from; v <= to; v += n {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, lo, hi int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("invalid value %q, expected %d to %d", s, lo, hi)
+	}
+	return v, nil
+}
+
+// String returns the expression as it was given.
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// maxSearch bounds the search for the next time, after which an expression
+// like 0 0 30 2 * is considered never due.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Next returns the first time after t at which the expression is due, the
+// zero time if it is never due.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !has(c.month, int(m)):
+			t = forward(t, time.Date(y, m+1, 1, 0, 0, 0, 0, c.loc))
+		case !c.dayMatches(t):
+			t = forward(t, time.Date(y, m, d+1, 0, 0, 0, 0, c.loc))
+		case !has(c.hour, t.Hour()):
+			t = forward(t, time.Date(y, m, d, t.Hour()+1, 0, 0, 0, c.loc))
+		case !has(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// forward returns next, or the next minute if the change of the time zone
+// normalized next to a time before t.
+func forward(t, next time.Time) time.Time {
+	if !next.After(t) {
+		return t.Add(time.Minute)
+	}
+	return next
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
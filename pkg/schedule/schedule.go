@@ -0,0 +1,340 @@
+package schedule
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Scheduler keeps track of when each sensor is due to be polled next. Sensors
+// with an own Schedule or Interval are polled at their own cadence, all others
+// use the default cron schedule or interval of the scheduler.
+type Scheduler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	sensors  []egain.Sensor
+	next     map[string]time.Time
+
+	// cron is the default schedule replacing the interval, if set, crons
+	// holds the parsed schedules of the sensors in the location
+	cron  *Cron
+	loc   *time.Location
+	crons map[string]*Cron
+
+	// jitter delays each poll by a random duration up to jitter, offset
+	// holds the delay of the next poll of each sensor
+	jitter time.Duration
+	rand   *rand.Rand
+	offset map[string]time.Duration
+
+	// adaptive polls the sensors without an own interval at their observed
+	// update cadence, bounded by min and max
+	adaptive bool
+	min, max time.Duration
+	cadence  map[string]*cadence
+}
+
+// cadence is the observed update cadence of a sensor.
+type cadence struct {
+	last     time.Time
+	interval time.Duration
+}
+
+type Option func(s *Scheduler) error
+
+// New creates a scheduler for the given sensors. All sensors are due
+// immediately, unless there is a jitter.
+func New(interval time.Duration, sensors []egain.Sensor, opts ...Option) (*Scheduler, error) {
+	s := &Scheduler{
+		interval: interval,
+		sensors:  sensors,
+		next:     make(map[string]time.Time, len(sensors)),
+		offset:   make(map[string]time.Duration, len(sensors)),
+		cadence:  map[string]*cadence{},
+		loc:      time.Local,
+		crons:    map[string]*Cron{},
+	}
+
+	// apply the options
+	for _, o := range opts {
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// WithJitter delays every poll by a random duration up to d, so the polls of
+// several instances started at the same time are spread out. The delays do
+// not accumulate, the polls stay at their interval on average. Instances
+// should use different seeds, e.g. derived from their hostname.
+func WithJitter(d time.Duration, seed uint64) Option {
+	return func(s *Scheduler) error {
+		if d < 0 {
+			return errors.New("negative jitter")
+		}
+		s.jitter = d
+		s.rand = rand.New(rand.NewPCG(seed, seed))
+		return nil
+	}
+}
+
+// WithCron polls the sensors without an own schedule or interval at the
+// times of the cron expression instead of the interval, e.g. only during
+// business hours. Unlike the interval, the sensors are not due right away
+// but at the first time of the schedule.
+func WithCron(c *Cron) Option {
+	return func(s *Scheduler) error {
+		if c == nil {
+			return errors.New("missing cron schedule")
+		}
+		s.cron = c
+		return nil
+	}
+}
+
+// WithLocation sets the location of the times of the Schedules of the
+// sensors, the local time zone by default.
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scheduler) error {
+		if loc == nil {
+			return errors.New("missing location")
+		}
+		s.loc = loc
+		return nil
+	}
+}
+
+// WithAdaptiveInterval adapts the interval of the sensors without an own
+// interval to the cadence at which their readings change, as reported with
+// Observe. The sensors are polled at half their cadence, so no update is
+// missed, but not more often than lo nor less often than hi. Until the
+// cadence of a sensor is known, it is polled at the default interval.
+func WithAdaptiveInterval(lo, hi time.Duration) Option {
+	return func(s *Scheduler) error {
+		if lo <= 0 || hi < lo {
+			return errors.New("invalid adaptive interval bounds")
+		}
+		s.adaptive = true
+		s.min = lo
+		s.max = hi
+		return nil
+	}
+}
+
+// cadenceWeight is the weight of a new observation in the moving average of
+// the cadence.
+const cadenceWeight = 0.25
+
+// Observe records the timestamp of a reading to learn the update cadence of
+// its sensor, it is a no-op unless the interval is adaptive.
+func (s *Scheduler) Observe(r *egain.SensorReading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.adaptive || r.Timestamp.IsZero() {
+		return
+	}
+	c, ok := s.cadence[r.SensorID]
+	if !ok {
+		s.cadence[r.SensorID] = &cadence{last: r.Timestamp}
+		return
+	}
+	if !r.Timestamp.After(c.last) {
+		return
+	}
+
+	d := r.Timestamp.Sub(c.last)
+	c.last = r.Timestamp
+	if c.interval == 0 {
+		c.interval = d
+		return
+	}
+	c.interval = time.Duration(float64(c.interval)*(1-cadenceWeight) + float64(d)*cadenceWeight)
+}
+
+// delay returns a random delay of the next poll.
+func (s *Scheduler) delay() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(s.rand.Int64N(int64(s.jitter)))
+}
+
+// SetSensors replaces the sensors of the scheduler. Sensors which were
+// scheduled before keep their next poll, new sensors are due immediately,
+// unless there is a jitter.
+func (s *Scheduler) SetSensors(sensors []egain.Sensor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := make(map[string]time.Time, len(sensors))
+	offset := make(map[string]time.Duration, len(sensors))
+	for _, sensor := range sensors {
+		if n, ok := s.next[sensor.SensorID]; ok {
+			next[sensor.SensorID] = n
+			offset[sensor.SensorID] = s.offset[sensor.SensorID]
+		}
+	}
+	for id := range s.cadence {
+		if _, ok := next[id]; !ok {
+			delete(s.cadence, id)
+		}
+	}
+	s.sensors = sensors
+	s.next = next
+	s.offset = offset
+}
+
+// cronLocked returns the cron schedule of the sensor, nil if it is polled at
+// an interval. A Schedule of a sensor which cannot be parsed falls back to
+// the interval, the schedules are expected to be validated beforehand.
+func (s *Scheduler) cronLocked(sensor egain.Sensor) *Cron {
+	if sensor.Schedule == "" {
+		if sensor.Interval > 0 {
+			return nil
+		}
+		return s.cron
+	}
+	c, ok := s.crons[sensor.Schedule]
+	if !ok {
+		c, _ = ParseCron(sensor.Schedule, s.loc)
+		s.crons[sensor.Schedule] = c
+	}
+	return c
+}
+
+// Interval returns the polling interval of the given sensor, the interval of
+// the sensors polled at a cron schedule is not used.
+func (s *Scheduler) Interval(sensor egain.Sensor) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.intervalLocked(sensor)
+}
+
+func (s *Scheduler) intervalLocked(sensor egain.Sensor) time.Duration {
+	if sensor.Interval > 0 {
+		return sensor.Interval
+	}
+	if c, ok := s.cadence[sensor.SensorID]; ok && c.interval > 0 {
+		return min(max(c.interval/2, s.min), s.max)
+	}
+	return s.interval
+}
+
+// Due returns the sensors which are due at the given time and schedules their
+// next poll. Polls which were missed are not caught up on.
+func (s *Scheduler) Due(now time.Time) []egain.Sensor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []egain.Sensor
+	for _, sensor := range s.sensors {
+		c := s.cronLocked(sensor)
+		next, ok := s.next[sensor.SensorID]
+		if !ok {
+			// the first poll is only delayed by the jitter, or waits for the
+			// first time of the cron schedule
+			next = now
+			if c != nil {
+				next = c.Next(now)
+			}
+			s.next[sensor.SensorID] = next
+			s.offset[sensor.SensorID] = s.delay()
+		}
+		if now.Before(next.Add(s.offset[sensor.SensorID])) {
+			continue
+		}
+		due = append(due, sensor)
+
+		if c != nil {
+			s.next[sensor.SensorID] = c.Next(now)
+			s.offset[sensor.SensorID] = s.delay()
+			continue
+		}
+		// the jitter is applied on top of the schedule, so it does not drift
+		interval := s.intervalLocked(sensor)
+		next = next.Add(interval)
+		if !next.After(now) {
+			next = now.Add(interval)
+		}
+		s.next[sensor.SensorID] = next
+		s.offset[sensor.SensorID] = s.delay()
+	}
+	return due
+}
+
+// Next returns the time at which the next sensor is due.
+func (s *Scheduler) Next() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, sensor := range s.sensors {
+		n, ok := s.next[sensor.SensorID]
+		if !ok {
+			// not scheduled yet, i.e. due right away
+			return time.Time{}
+		}
+		n = n.Add(s.offset[sensor.SensorID])
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// NextOf returns the time at which the first of the given sensors is due
+// again, the zero time if none of them is scheduled. A cycle polling the
+// sensors should be done by then, so it does not run into their next poll.
+func (s *Scheduler) NextOf(sensors []egain.Sensor) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, sensor := range sensors {
+		n, ok := s.next[sensor.SensorID]
+		if !ok {
+			continue
+		}
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// Skip skips the polls which were due before the given time, e.g. as they
+// came due while the previous cycle was still running, and schedules the next
+// polls of their sensors after it. It returns the number of skipped polls by
+// the id of the sensor.
+func (s *Scheduler) Skip(now time.Time) map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	skipped := map[string]int{}
+	for _, sensor := range s.sensors {
+		next, ok := s.next[sensor.SensorID]
+		if !ok || now.Before(next.Add(s.offset[sensor.SensorID])) {
+			continue
+		}
+
+		if c := s.cronLocked(sensor); c != nil {
+			for ; !next.IsZero() && !next.After(now); next = c.Next(next) {
+				skipped[sensor.SensorID]++
+			}
+		} else {
+			// the polls stay at their interval, like the polls which are due
+			interval := s.intervalLocked(sensor)
+			n := int(now.Sub(next)/interval) + 1
+			skipped[sensor.SensorID] = n
+			next = next.Add(time.Duration(n) * interval)
+		}
+		s.next[sensor.SensorID] = next
+		s.offset[sensor.SensorID] = s.delay()
+	}
+	return skipped
+}
@@ -0,0 +1,175 @@
+// Package secrets resolves the references to secrets in the configuration,
+// so plaintext credentials do not have to be stored in the file. A string
+// value of the configuration is replaced by the secret it refers to if it is
+//
+//	env:NAME    the value of the environment variable NAME
+//	file:/path  the content of the file without a trailing newline, e.g. of
+//	            a mounted Kubernetes or Docker secret
+//
+// Configuration files encrypted with SOPS as a whole are decrypted with the
+// sops command, which is given the age identities of the resolver and
+// finds the other keys of the file as on the command line, e.g. in AWS KMS.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resolver resolves the references to secrets.
+type Resolver struct {
+	// ageKeys are the identity files passed to sops
+	ageKeys   []string
+	lookupEnv func(string) (string, bool)
+	readFile  func(string) ([]byte, error)
+	sops      string
+}
+
+type Option func(r *Resolver) error
+
+// New creates a resolver of the environment and the files of the host.
+func New(opts ...Option) (*Resolver, error) {
+	r := &Resolver{
+		lookupEnv: os.LookupEnv,
+		readFile:  os.ReadFile,
+		sops:      "sops",
+	}
+
+	// apply the options
+	for _, o := range opts {
+		err := o(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// WithIdentityFile adds the age identities of a key file of age-keygen, which
+// sops decrypts the SOPS files with.
+func WithIdentityFile(path string) Option {
+	return func(r *Resolver) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read age identities: %w", err)
+		}
+		if !bytes.Contains(bytes.ToUpper(data), []byte("AGE-SECRET-KEY-")) {
+			return fmt.Errorf("no age identities in %s", path)
+		}
+		r.ageKeys = append(r.ageKeys, string(data))
+		return nil
+	}
+}
+
+// WithSOPS sets the sops command decrypting the SOPS files, sops of the PATH
+// by default.
+func WithSOPS(command string) Option {
+	return func(r *Resolver) error {
+		r.sops = command
+		return nil
+	}
+}
+
+// IsReference returns whether the value refers to a secret.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, "env:") || strings.HasPrefix(value, "file:")
+}
+
+// Resolve returns the secret the value refers to, or the value itself if it
+// does not refer to a secret.
+func (r *Resolver) Resolve(value string) (string, error) {
+	if name, ok := strings.CutPrefix(value, "env:"); ok {
+		v, ok := r.lookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s of the secret is not set", name)
+		}
+		return v, nil
+	}
+	if path, ok := strings.CutPrefix(value, "file:"); ok {
+		data, err := r.readFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read secret: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return value, nil
+}
+
+// ResolveNode replaces the references of the string scalars of the YAML
+// document with their secrets and returns the number of references. The
+// errors name the line of the reference, but never the secret.
+func (r *Resolver) ResolveNode(n *yaml.Node) (int, error) {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode, yaml.MappingNode:
+		resolved := 0
+		for i, c := range n.Content {
+			// only the values of the mappings are resolved, not their keys
+			if n.Kind == yaml.MappingNode && i%2 == 0 {
+				continue
+			}
+			k, err := r.ResolveNode(c)
+			if err != nil {
+				return 0, err
+			}
+			resolved += k
+		}
+		return resolved, nil
+	case yaml.ScalarNode:
+		if n.ShortTag() != "!!str" || !IsReference(n.Value) {
+			return 0, nil
+		}
+		v, err := r.Resolve(n.Value)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		// the secret stays a string, however it looks
+		n.Value = v
+		n.Tag = "!!str"
+		n.Style = 0
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// IsSOPS returns whether the YAML document is a file encrypted with SOPS,
+// i.e. has the sops metadata.
+func IsSOPS(n *yaml.Node) bool {
+	if n.Kind == yaml.DocumentNode && len(n.Content) == 1 {
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].Value == "sops" && n.Content[i+1].Kind == yaml.MappingNode {
+			return true
+		}
+	}
+	return false
+}
+
+// DecryptSOPS decrypts the YAML file encrypted with SOPS with the sops
+// command.
+func (r *Resolver) DecryptSOPS(ctx context.Context, path string) ([]byte, error) {
+	command, err := exec.LookPath(r.sops)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt SOPS file %s, please install sops: %w", path, err)
+	}
+	cmd := exec.CommandContext(ctx, command, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", path)
+	if len(r.ageKeys) > 0 {
+		cmd.Env = append(os.Environ(), "SOPS_AGE_KEY="+strings.Join(r.ageKeys, "\n"))
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt SOPS file %s: %w: %s", path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
@@ -0,0 +1,103 @@
+// Package shard splits the sensors between the replicas of the scraper by a
+// consistent hash of their IDs, so each replica fetches a share of a large
+// fleet and the load of the egain API stays the same. On a change of the
+// number of shards only the sensors which move to the new shards, or off the
+// removed ones, change their replica.
+package shard
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Shard is the share of a replica of the sensors.
+type Shard struct {
+	// Index is the zero based index of the shard.
+	Index int
+	// Count is the number of shards, 0 or 1 if the sensors are not sharded.
+	Count int
+}
+
+// Parse parses a shard in the format index/count, e.g. 0/3. The index auto
+// takes the ordinal of the hostname, i.e. its numeric suffix, e.g. 2 of the
+// pod again-scraper-2 of a Kubernetes StatefulSet.
+func Parse(s, hostname string) (Shard, error) {
+	index, count, ok := strings.Cut(s, "/")
+	if !ok {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected index/count", s)
+	}
+	var sh Shard
+	var err error
+	if sh.Count, err = strconv.Atoi(count); err != nil || sh.Count <= 0 {
+		return Shard{}, fmt.Errorf("invalid shard count %q", count)
+	}
+	if index == "auto" {
+		if sh.Index, err = Ordinal(hostname); err != nil {
+			return Shard{}, err
+		}
+	} else if sh.Index, err = strconv.Atoi(index); err != nil {
+		return Shard{}, fmt.Errorf("invalid shard index %q", index)
+	}
+	if sh.Index < 0 || sh.Index >= sh.Count {
+		return Shard{}, fmt.Errorf("invalid shard index %d, expected 0 to %d", sh.Index, sh.Count-1)
+	}
+	return sh, nil
+}
+
+// Ordinal returns the ordinal of the hostname of a replica of a StatefulSet,
+// the number after its last dash.
+func Ordinal(hostname string) (int, error) {
+	i := strings.LastIndexByte(hostname, '-')
+	if i < 0 {
+		return 0, fmt.Errorf("no ordinal in the hostname %q", hostname)
+	}
+	n, err := strconv.Atoi(hostname[i+1:])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("no ordinal in the hostname %q", hostname)
+	}
+	return n, nil
+}
+
+// String returns the shard in the format of Parse.
+func (s Shard) String() string {
+	return strconv.Itoa(s.Index) + "/" + strconv.Itoa(s.Count)
+}
+
+// Sharded returns whether the sensors are split between several shards.
+func (s Shard) Sharded() bool {
+	return s.Count > 1
+}
+
+// Owns returns whether the key, e.g. the ID of a sensor, belongs to the
+// shard. Every key belongs to a single shard of a count.
+func (s Shard) Owns(key string) bool {
+	if !s.Sharded() {
+		return true
+	}
+	return Of(key, s.Count) == s.Index
+}
+
+// Of returns the shard of the key among count shards, by the jump consistent
+// hash of the FNV-1a hash of the key.
+func Of(key string, count int) int {
+	if count <= 1 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return jump(h.Sum64(), count)
+}
+
+// jump is the jump consistent hash of Lamping and Veach, which moves only
+// 1/n of the keys on a change from n-1 to n buckets.
+func jump(key uint64, buckets int) int {
+	b, j := int64(-1), int64(0)
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,202 @@
+// Package state keeps the latest sensor readings in memory, so they can be
+// served by the APIs, the health endpoints and the tui of the scraper from a
+// single registry.
+package state
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// subscriberBuffer is the number of readings buffered per subscriber,
+// readings are dropped for subscribers which fall further behind.
+const subscriberBuffer = 64
+
+// Registry holds the latest reading and fetch status of each sensor and
+// broadcasts new readings to its subscribers. It implements the exporter
+// interface, so it is fed like any other exporter.
+type Registry struct {
+	mu          sync.RWMutex
+	sensors     map[string]*Status
+	subscribers map[chan *egain.SensorReading]struct{}
+}
+
+// Status is the state of a sensor.
+type Status struct {
+	SensorID string
+	Location string
+	// Reading is the latest reading, nil if the sensor was never fetched.
+	Reading   *egain.SensorReading
+	FetchedAt time.Time
+	// Err is the error of the last fetch, if it failed.
+	Err      error
+	FailedAt time.Time
+}
+
+// New creates an empty registry.
+func New() *Registry {
+	return &Registry{
+		sensors:     map[string]*Status{},
+		subscribers: map[chan *egain.SensorReading]struct{}{},
+	}
+}
+
+// Export stores the readings as the latest of their sensors and sends the
+// changed ones to the subscribers.
+func (s *Registry) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, r := range readings {
+		st := s.status(r.SensorID)
+		st.Location = r.Location
+		st.Reading = r
+		st.FetchedAt = now
+		st.Err = nil
+		if r.Unchanged {
+			continue
+		}
+		for ch := range s.subscribers {
+			select {
+			case ch <- r:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+// RecordError records the failed sensors of the error of a fetch, i.e. the
+// joined *egain.SensorError of the sensors.
+func (s *Registry) RecordError(ctx context.Context, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, se := range sensorErrors(err) {
+		st := s.status(se.SensorID)
+		st.Location = se.Location
+		st.Err = se.Err
+		st.FailedAt = now
+	}
+}
+
+// sensorErrors returns the sensor errors in the tree of the error.
+func sensorErrors(err error) []*egain.SensorError {
+	var se *egain.SensorError
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case interface{ Unwrap() []error }:
+		var errs []*egain.SensorError
+		for _, err := range e.Unwrap() {
+			errs = append(errs, sensorErrors(err)...)
+		}
+		return errs
+	default:
+		if errors.As(err, &se) {
+			return []*egain.SensorError{se}
+		}
+		return nil
+	}
+}
+
+// status returns the status of the sensor, creating it if needed. The lock
+// has to be held.
+func (s *Registry) status(sensorID string) *Status {
+	st, ok := s.sensors[sensorID]
+	if !ok {
+		st = &Status{SensorID: sensorID}
+		s.sensors[sensorID] = st
+	}
+	return st
+}
+
+// Snapshot is a consistent copy of the statuses of the sensors at a time.
+type Snapshot struct {
+	Time time.Time
+	// Sensors are the statuses of the sensors which were fetched so far,
+	// ordered by the sensor id.
+	Sensors []Status
+}
+
+// Get returns the status of the sensor in the snapshot.
+func (s Snapshot) Get(sensorID string) (Status, bool) {
+	i, ok := slices.BinarySearchFunc(s.Sensors, sensorID, func(st Status, id string) int { return strings.Compare(st.SensorID, id) })
+	if !ok {
+		return Status{}, false
+	}
+	return s.Sensors[i], true
+}
+
+// Snapshot returns the statuses of all sensors at once, so they are consistent
+// with each other.
+func (s *Registry) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(s.sensors))
+	for _, st := range s.sensors {
+		statuses = append(statuses, *st)
+	}
+	slices.SortFunc(statuses, func(a, b Status) int { return strings.Compare(a.SensorID, b.SensorID) })
+	return Snapshot{Time: time.Now(), Sensors: statuses}
+}
+
+// Latest returns the latest reading of each sensor, ordered by the sensor id.
+func (s *Registry) Latest() []*egain.SensorReading {
+	var readings []*egain.SensorReading
+	for _, st := range s.Snapshot().Sensors {
+		if st.Reading != nil {
+			readings = append(readings, st.Reading)
+		}
+	}
+	return readings
+}
+
+// Get returns the latest reading of the sensor.
+func (s *Registry) Get(sensorID string) (*egain.SensorReading, bool) {
+	st, ok := s.Status(sensorID)
+	if !ok || st.Reading == nil {
+		return nil, false
+	}
+	return st.Reading, true
+}
+
+// Status returns the status of the sensor.
+func (s *Registry) Status(sensorID string) (Status, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok := s.sensors[sensorID]
+	if !ok {
+		return Status{}, false
+	}
+	return *st, true
+}
+
+// Subscribe returns a channel receiving every changed reading until the
+// context is done, then the channel is closed.
+func (s *Registry) Subscribe(ctx context.Context) <-chan *egain.SensorReading {
+	ch := make(chan *egain.SensorReading, subscriberBuffer)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+)
+
+// Aggregate is the minimum, average and maximum of a measurement.
+type Aggregate struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// Bucket holds the aggregated readings of a sensor in a time bucket.
+type Bucket struct {
+	Start       time.Time `json:"timestamp"`
+	Temperature Aggregate `json:"temperature"`
+	Humidity    Aggregate `json:"humidity"`
+	// Count is the number of raw readings in the bucket.
+	Count int64 `json:"count"`
+}
+
+// Query returns the readings of the sensor between from and to, aggregated
+// into buckets of the given step, aligned to multiples of the step since the
+// Unix epoch. Buckets without readings are left out. Downsampled readings
+// fall into the bucket of their hour.
+func (s *Store) Query(ctx context.Context, sensorID string, from, to time.Time, step time.Duration) ([]Bucket, error) {
+	if step < time.Second {
+		return nil, fmt.Errorf("invalid step %s", step)
+	}
+	start, end, width := from.Unix(), to.Unix(), int64(step/time.Second)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT t / ? * ?,
+			min(tmin), sum(tavg * n) / sum(n), max(tmax),
+			min(hmin), sum(havg * n) / sum(n), max(hmax), sum(n)
+		FROM (
+			SELECT timestamp AS t,
+				temperature AS tmin, temperature AS tavg, temperature AS tmax,
+				humidity AS hmin, humidity AS havg, humidity AS hmax, 1 AS n
+			FROM readings WHERE sensor_id = ? AND timestamp >= ? AND timestamp < ?
+			UNION ALL
+			SELECT hour, temperature_min, temperature_avg, temperature_max,
+				humidity_min, humidity_avg, humidity_max, count
+			FROM readings_hourly WHERE sensor_id = ? AND hour >= ? AND hour < ?
+		)
+		GROUP BY 1 ORDER BY 1`,
+		width, width,
+		sensorID, start, end,
+		sensorID, start, end,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []Bucket
+	for rows.Next() {
+		var (
+			b Bucket
+			t int64
+		)
+		err := rows.Scan(&t,
+			&b.Temperature.Min, &b.Temperature.Avg, &b.Temperature.Max,
+			&b.Humidity.Min, &b.Humidity.Avg, &b.Humidity.Max, &b.Count,
+		)
+		if err != nil {
+			return nil, err
+		}
+		b.Start = time.Unix(t, 0).UTC()
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// Sensors returns the ids of the sensors with stored readings, sorted.
+func (s *Store) Sensors(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT sensor_id FROM readings
+		UNION SELECT sensor_id FROM readings_hourly
+		ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Readings returns the raw readings between from and to, ordered by their
+// timestamp, e.g. to replay them. A zero to is open ended. The downsampled
+// readings are left out.
+func (s *Store) Readings(ctx context.Context, from, to time.Time) ([]*egain.SensorReading, error) {
+	end := int64(math.MaxInt64)
+	if !to.IsZero() {
+		end = to.Unix()
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT sensor_id, location, temperature, humidity, timestamp
+		FROM readings WHERE timestamp >= ? AND timestamp < ?
+		ORDER BY timestamp, sensor_id`, from.Unix(), end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []*egain.SensorReading
+	for rows.Next() {
+		var (
+			r egain.SensorReading
+			t int64
+		)
+		if err := rows.Scan(&r.SensorID, &r.Location, &r.Temperature, &r.Humidity, &t); err != nil {
+			return nil, err
+		}
+		r.Timestamp = time.Unix(t, 0).UTC()
+		readings = append(readings, &r)
+	}
+	return readings, rows.Err()
+}
@@ -0,0 +1,263 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"go.uber.org/zap"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// Store persists sensor readings in an embedded SQLite database.
+type Store struct {
+	db  *sql.DB
+	log *zap.Logger
+
+	// retention of the raw and the hourly readings, see WithRetention
+	raw, hourly time.Duration
+	stop        context.CancelFunc
+	done        chan struct{}
+}
+
+type Option func(s *Store) error
+
+// migrations are applied in order, the index of the last applied migration
+// is tracked in the user_version of the database.
+var migrations = []string{
+	`CREATE TABLE readings (
+		sensor_id   TEXT    NOT NULL,
+		location    TEXT    NOT NULL,
+		temperature REAL    NOT NULL,
+		humidity    REAL    NOT NULL,
+		timestamp   INTEGER NOT NULL,
+		PRIMARY KEY (sensor_id, timestamp)
+	)`,
+	`CREATE INDEX readings_timestamp ON readings (timestamp)`,
+	`CREATE TABLE readings_hourly (
+		sensor_id       TEXT    NOT NULL,
+		location        TEXT    NOT NULL,
+		hour            INTEGER NOT NULL,
+		temperature_min REAL    NOT NULL,
+		temperature_avg REAL    NOT NULL,
+		temperature_max REAL    NOT NULL,
+		humidity_min    REAL    NOT NULL,
+		humidity_avg    REAL    NOT NULL,
+		humidity_max    REAL    NOT NULL,
+		count           INTEGER NOT NULL,
+		PRIMARY KEY (sensor_id, hour)
+	)`,
+}
+
+// Open opens the database at the given path, creating and migrating it if
+// necessary.
+func Open(path string, opts ...Option) (*Store, error) {
+	s := &Store{log: zap.L()}
+
+	// apply the options
+	for _, o := range opts {
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(wal)")
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot migrate store %s: %w", path, err)
+	}
+
+	if s.raw > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stop = cancel
+		s.done = make(chan struct{})
+		go s.runRetention(ctx)
+	}
+	return s, nil
+}
+
+// WithRetention keeps the raw readings for the given duration, older
+// readings are downsampled to their hourly minimum, average and maximum.
+// The hourly readings are kept for the given duration, forever if 0. The
+// retention is applied in the background every hour.
+func WithRetention(raw, hourly time.Duration) Option {
+	return func(s *Store) error {
+		if raw < 0 || hourly < 0 {
+			return errors.New("negative store retention")
+		}
+		s.raw = raw
+		s.hourly = hourly
+		return nil
+	}
+}
+
+func WithLogger(l *zap.Logger) Option {
+	return func(s *Store) error {
+		s.log = l
+		return nil
+	}
+}
+
+func (s *Store) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeepsTimestamps returns true, the readings are stored at their timestamps.
+func (s *Store) KeepsTimestamps() (bool, time.Duration) {
+	return true, 0
+}
+
+// Export appends the readings to the store. Readings which are already
+// stored, i.e. with the same sensor and timestamp, are ignored.
+func (s *Store) Export(ctx context.Context, readings []*egain.SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO readings
+		(sensor_id, location, temperature, humidity, timestamp) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range readings {
+		// heating readings have no temperature and humidity to store
+		if r.Heating != nil {
+			continue
+		}
+		_, err := stmt.ExecContext(ctx, r.SensorID, r.Location, r.Temperature, r.Humidity, r.Timestamp.Unix())
+		if err != nil {
+			return fmt.Errorf("cannot store reading of sensor %s: %w", r.SensorID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// retentionInterval is the interval at which the retention is applied.
+const retentionInterval = time.Hour
+
+// runRetention applies the retention right away and then every interval
+// until the context is done.
+func (s *Store) runRetention(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.ApplyRetention(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.log.Error("cannot apply store retention", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ApplyRetention downsamples the raw readings older than the raw retention
+// to hourly readings and purges the hourly readings older than their
+// retention. Only complete hours are downsampled, readings stored later for
+// an hour which was already downsampled are merged into it.
+func (s *Store) ApplyRetention(ctx context.Context, now time.Time) error {
+	if s.raw <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-s.raw).Truncate(time.Hour).Unix()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the averages are merged weighted by their number of readings
+	res, err := tx.ExecContext(ctx, `INSERT INTO readings_hourly
+		SELECT sensor_id, max(location), timestamp / 3600 * 3600,
+			min(temperature), avg(temperature), max(temperature),
+			min(humidity), avg(humidity), max(humidity), count(*)
+		FROM readings WHERE timestamp < ?
+		GROUP BY sensor_id, timestamp / 3600
+		ON CONFLICT (sensor_id, hour) DO UPDATE SET
+			temperature_min = min(temperature_min, excluded.temperature_min),
+			temperature_avg = (temperature_avg * count + excluded.temperature_avg * excluded.count) / (count + excluded.count),
+			temperature_max = max(temperature_max, excluded.temperature_max),
+			humidity_min = min(humidity_min, excluded.humidity_min),
+			humidity_avg = (humidity_avg * count + excluded.humidity_avg * excluded.count) / (count + excluded.count),
+			humidity_max = max(humidity_max, excluded.humidity_max),
+			count = count + excluded.count`, cutoff)
+	if err != nil {
+		return fmt.Errorf("cannot downsample readings: %w", err)
+	}
+	hours, _ := res.RowsAffected()
+
+	res, err = tx.ExecContext(ctx, `DELETE FROM readings WHERE timestamp < ?`, cutoff)
+	if err != nil {
+		return fmt.Errorf("cannot purge readings: %w", err)
+	}
+	raw, _ := res.RowsAffected()
+
+	var hourly int64
+	if s.hourly > 0 {
+		res, err = tx.ExecContext(ctx, `DELETE FROM readings_hourly WHERE hour < ?`, now.Add(-s.hourly).Unix())
+		if err != nil {
+			return fmt.Errorf("cannot purge hourly readings: %w", err)
+		}
+		hourly, _ = res.RowsAffected()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if raw > 0 || hourly > 0 {
+		s.log.Info("applied store retention", zap.Int64("downsampled", raw), zap.Int64("hours", hours), zap.Int64("purgedHours", hourly))
+	}
+	return nil
+}
+
+// Close stops the retention and closes the database.
+func (s *Store) Close() error {
+	if s.stop != nil {
+		s.stop()
+		<-s.done
+	}
+	return s.db.Close()
+}
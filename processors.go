@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+	"github.com/nimdanitro/again-scraper-go/pkg/pipeline"
+	"github.com/nimdanitro/again-scraper-go/pkg/processor"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// temperatureUnit is the unit the temperatures are exported in.
+var temperatureUnit = unitFlag(processor.Celsius)
+
+// unitFlag is a flag value parsing a temperature unit.
+type unitFlag processor.TemperatureUnit
+
+func (f *unitFlag) Set(s string) error {
+	u, err := processor.ParseTemperatureUnit(s)
+	if err != nil {
+		return err
+	}
+	*f = unitFlag(u)
+	return nil
+}
+
+func (f *unitFlag) String() string { return string(*f) }
+
+func (f *unitFlag) Type() string { return "unit" }
+
+func (f *unitFlag) unit() processor.TemperatureUnit { return processor.TemperatureUnit(*f) }
+
+// newPipeline assembles the pipeline of the cycles from the configuration. The
+// stages run on the fetched readings before the configured processors, the
+// processed readings are fanned out to the exporters.
+func newPipeline(cfg *config, logger *zap.Logger, meter metric.Meter, fetcher pipeline.Fetcher, exporters exporter.Multi, stages ...processor.Processor) (*pipeline.Pipeline, error) {
+	processors, err := newProcessors(cfg, logger, meter)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create processors: %w", err)
+	}
+	return pipeline.New(fetcher,
+		pipeline.WithProcessors(stages...),
+		pipeline.WithProcessors(processors...),
+		pipeline.WithExporters(exporters...),
+	)
+}
+
+// newProcessors creates the processors configured in the file and on the
+// command line, they run on all readings before they are exported.
+func newProcessors(cfg *config, logger *zap.Logger, meter metric.Meter) (processor.Chain, error) {
+	sanitizer, err := processor.NewSanitizer(meter, logger, cfg.limits())
+	if err != nil {
+		return nil, err
+	}
+	validator, err := processor.NewValidator(meter, logger, cfg.bounds())
+	if err != nil {
+		return nil, err
+	}
+	calibrate, err := processor.Calibrate(cfg.calibrations())
+	if err != nil {
+		return nil, err
+	}
+	processors := processor.Chain{sanitizer, validator, calibrate}
+	if cfg.Comfort {
+		processors = append(processors, processor.DeriveComfort())
+	}
+	if len(cfg.Expressions) > 0 {
+		// before the conversion, so the expressions see degrees Celsius
+		exprs, err := processor.NewExpressions(logger, cfg.expressions())
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile expressions: %w", err)
+		}
+		processors = append(processors, exprs)
+	}
+	if cfg.MoldRisk > 0 {
+		// before the conversion, as the critical humidity depends on the
+		// temperature in degrees Celsius
+		mold, err := processor.NewMoldRisk(cfg.MoldRisk)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, mold)
+	}
+	processors = append(processors, processor.ConvertTemperature(temperatureUnit.unit()))
+	if cfg.Trend > 0 {
+		// after the conversion, so the rates are in the exported unit like
+		// the thresholds of the alerts
+		trend, err := processor.NewTrend(cfg.Trend)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, trend)
+	}
+
+	if len(cfg.Groups) > 0 {
+		aggregates, err := processor.NewAggregates(meter, cfg.groups(), temperatureUnit.unit().Symbol())
+		if err != nil {
+			return nil, fmt.Errorf("cannot create group aggregates: %w", err)
+		}
+		processors = append(processors, aggregates)
+	}
+	if cfg.Window > 0 {
+		windows, err := processor.NewWindows(meter, cfg.Window, temperatureUnit.unit().Symbol())
+		if err != nil {
+			return nil, fmt.Errorf("cannot create window aggregates: %w", err)
+		}
+		processors = append(processors, windows)
+	}
+	if cfg.Summary != nil {
+		opts, err := cfg.Summary.options()
+		if err != nil {
+			return nil, fmt.Errorf("summary: %w", err)
+		}
+		summaries, err := processor.NewDailySummaries(meter, logger, temperatureUnit.unit().Symbol(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create daily summaries: %w", err)
+		}
+		processors = append(processors, summaries)
+	}
+	return processors, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/providers"
+	_ "github.com/nimdanitro/again-scraper-go/pkg/providers/bacnet"
+	_ "github.com/nimdanitro/again-scraper-go/pkg/providers/modbus"
+	"go.uber.org/zap"
+)
+
+// egainProvider is the name of the egain accounts in the provider of the
+// sensors of the config, the same as leaving it empty.
+const egainProvider = "egain"
+
+// newRouter creates the providers of the configuration next to the egain
+// accounts of the client and distributes the sensors to them.
+func newRouter(logger *zap.Logger, cfg *config, client *egain.Accounts, sensors []egain.Sensor) (*providers.Router, error) {
+	all := map[string]providers.Provider{providers.Default: client}
+	for _, p := range cfg.Providers {
+		provider, err := providers.New(p.Kind, logger.With(zap.String("provider", p.Name)), p.Name, p.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", p.Name, err)
+		}
+		all[p.Name] = provider
+	}
+	return providers.NewRouter(all, sensors)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/providers"
+	"github.com/nimdanitro/again-scraper-go/pkg/schedule"
+	"go.uber.org/zap"
+)
+
+// reloadSensors re-reads the configuration and swaps the sensors of the
+// providers and the scheduler. The previous sensors are kept if the new
+// configuration is invalid. Only the sensors are reloaded, the accounts,
+// exporters and alert rules keep their configuration until restart.
+func reloadSensors(logger *zap.Logger, fetcher *providers.Router, client *egain.Accounts, sched *schedule.Scheduler) error {
+	_, sensors, err := loadSensors()
+	if err != nil {
+		return err
+	}
+	if len(sensors) == 0 {
+		return errNoSensors
+	}
+
+	previous := fetcher.Sensors()
+	if err := fetcher.SetSensors(sensors); err != nil {
+		return err
+	}
+	if err := client.ValidateInterval(interval); err != nil {
+		fetcher.SetSensors(previous)
+		return fmt.Errorf("invalid polling interval %s: %w", interval, err)
+	}
+	sched.SetSensors(sensors)
+
+	added, removed := diffSensors(previous, sensors)
+	logger.Info("reloaded sensors",
+		zap.Int("sensors", len(sensors)),
+		zap.Strings("added", added),
+		zap.Strings("removed", removed),
+	)
+	return nil
+}
+
+// diffSensors returns the ids of the sensors which were added and removed.
+func diffSensors(previous, current []egain.Sensor) (added, removed []string) {
+	known := make(map[string]bool, len(previous))
+	for _, s := range previous {
+		known[s.SensorID] = true
+	}
+	for _, s := range current {
+		if !known[s.SensorID] {
+			added = append(added, s.SensorID)
+		}
+		delete(known, s.SensorID)
+	}
+	for _, s := range previous {
+		if known[s.SensorID] {
+			removed = append(removed, s.SensorID)
+		}
+	}
+	return added, removed
+}
+
+// resetTimer schedules the timer for the next due sensor.
+func resetTimer(timer *time.Timer, sched *schedule.Scheduler) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(time.Until(sched.Next()))
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/cassette"
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter/csvfile"
+	"github.com/nimdanitro/again-scraper-go/pkg/store"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	replayFormat string
+	replaySpeed  float64
+	replayShift  bool
+	replayFrom   string
+	replayTo     string
+)
+
+// replayFormats are the formats of the recorded readings.
+var replayFormats = []string{"store", "csv", "cassette"}
+
+func newReplayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay <store.db|readings.csv|cassette>",
+		Short: "Feed recorded readings through the processors and exporters",
+		Long: `Feed recorded readings through the processors and exporters, e.g. to test
+dashboards and alert rules without live sensors.
+
+The readings are read from a store of --store-path, a CSV file of --csv-path or
+a cassette of --record-dir, the format is detected from the path unless --format
+is given. They are exported in the order of their timestamps, waiting for the
+time between them divided by --speed, or as fast as possible with a speed of
+0. The sensors of the --config file add their labels to the readings.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReplay(cmd.Context(), args[0])
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&replayFormat, "format", "", "Format of the recorded readings (store, csv, cassette), detected from the path by default")
+	flags.Float64Var(&replaySpeed, "speed", 1, "Speed of the replay relative to the original pace of the readings, e.g. 60 replays an hour in a minute, 0 replays as fast as possible")
+	flags.BoolVar(&replayShift, "shift", false, "Shift the timestamps of the readings, so the first one is taken at the start of the replay")
+	flags.StringVar(&replayFrom, "from", "", "Start of the replayed readings as RFC 3339 time or as duration before now (e.g. 2024-01-15T00:00:00Z, 24h)")
+	flags.StringVar(&replayTo, "to", "", "End of the replayed readings as RFC 3339 time or as duration before now")
+	flags.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "Maximum time to wait for the telemetry flush on shutdown")
+	registerExporterFlags(flags)
+	registerOTelFlags(flags)
+	return cmd
+}
+
+func runReplay(ctx context.Context, source string) error {
+	if replaySpeed < 0 {
+		return fmt.Errorf("invalid --speed %g", replaySpeed)
+	}
+	format := replayFormat
+	if format == "" {
+		format = detectReplayFormat(source)
+	}
+	if !slices.Contains(replayFormats, format) {
+		return fmt.Errorf("unknown format %q, expected one of %v", format, replayFormats)
+	}
+	var from, to time.Time
+	now := time.Now()
+	var err error
+	if replayFrom != "" {
+		if from, err = parseTime(replayFrom, now); err != nil {
+			return fmt.Errorf("invalid --from: %w", err)
+		}
+	}
+	if replayTo != "" {
+		if to, err = parseTime(replayTo, now); err != nil {
+			return fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+
+	cfg, sensors, err := loadSensors()
+	if err != nil {
+		return err
+	}
+	readings, err := loadRecorded(ctx, format, source, from, to)
+	if err != nil {
+		return fmt.Errorf("cannot load recorded readings: %w", err)
+	}
+	if len(readings) == 0 {
+		return fmt.Errorf("no recorded readings in %s", source)
+	}
+	labelReadings(readings, sensors)
+	views, err := cfg.views()
+	if err != nil {
+		return err
+	}
+
+	// the telemetry is flushed on return with a fresh context, as ctx is
+	// already cancelled on an interrupt
+	shutdown, err := setupOTelSDK(ctx, views...)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot flush telemetry:", err)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	logger, err := newLogger(zapcore.AddSync(os.Stderr), zapcore.InfoLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	meter := otel.Meter(
+		"gitub.com/nimdanitro/again-scraper-go",
+		metric.WithInstrumentationAttributes(semconv.OTelScopeName("gitub.com/nimdanitro/again-scraper-go")),
+	)
+	otelExporter, err := exporter.NewOTel(meter,
+		exporter.WithTemperatureUnit(temperatureUnit.unit().Symbol()),
+		exporter.WithOTelLogger(logger),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot create metric instruments: %w", err)
+	}
+	exporters, err := newExporters(cfg, logger, meter)
+	if err != nil {
+		return fmt.Errorf("cannot create exporters: %w", err)
+	}
+	exporters = append(exporter.Multi{otelExporter}, exporters...)
+	defer exporters.Close()
+	processors, err := newProcessors(cfg, logger, meter)
+	if err != nil {
+		return fmt.Errorf("cannot create processors: %w", err)
+	}
+
+	logger.Info("replaying readings",
+		zap.String("source", source),
+		zap.Int("readings", len(readings)),
+		zap.Time("from", readings[0].Timestamp),
+		zap.Time("to", readings[len(readings)-1].Timestamp),
+		zap.Float64("speed", replaySpeed),
+	)
+	start, first := time.Now(), readings[0].Timestamp
+	replayed := 0
+	for len(readings) > 0 {
+		// the readings of the same time are exported together, like the
+		// readings of a cycle
+		n := 1
+		for n < len(readings) && readings[n].Timestamp.Equal(readings[0].Timestamp) {
+			n++
+		}
+		batch := readings[:n]
+		readings = readings[n:]
+
+		if replaySpeed > 0 {
+			due := start.Add(time.Duration(float64(batch[0].Timestamp.Sub(first)) / replaySpeed))
+			select {
+			case <-ctx.Done():
+				logger.Info("replay interrupted", zap.Int("replayed", replayed))
+				return nil
+			case <-time.After(time.Until(due)):
+			}
+		}
+		if replayShift {
+			for _, r := range batch {
+				r.Timestamp = start.Add(r.Timestamp.Sub(first))
+			}
+		}
+
+		processed, err := processors.Process(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("cannot process readings: %w", err)
+		}
+		if err := exporters.Export(ctx, processed); err != nil {
+			logger.Error("Failed to export data", zap.Error(err))
+		}
+		replayed += len(batch)
+	}
+	logger.Info("replayed readings", zap.Int("readings", replayed))
+	return nil
+}
+
+// detectReplayFormat returns the format of the recorded readings at the path,
+// cassettes are directories.
+func detectReplayFormat(p string) string {
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return "cassette"
+	}
+	if strings.EqualFold(filepath.Ext(p), ".csv") {
+		return "csv"
+	}
+	return "store"
+}
+
+// loadRecorded loads the recorded readings between from and to, a zero time
+// is open ended, and sorts them by their timestamp.
+func loadRecorded(ctx context.Context, format, source string, from, to time.Time) ([]*egain.SensorReading, error) {
+	var (
+		readings []*egain.SensorReading
+		err      error
+	)
+	switch format {
+	case "store":
+		// opening a missing store would create it
+		if _, err := os.Stat(source); err != nil {
+			return nil, err
+		}
+		s, err := store.Open(source, store.WithLogger(zap.NewNop()))
+		if err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		return s.Readings(ctx, from, to)
+	case "csv":
+		readings, err = csvfile.Read(source)
+	case "cassette":
+		readings, err = cassetteReadings(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	readings = slices.DeleteFunc(readings, func(r *egain.SensorReading) bool {
+		return r.Timestamp.Before(from) || !to.IsZero() && !r.Timestamp.Before(to)
+	})
+	slices.SortStableFunc(readings, func(a, b *egain.SensorReading) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	return readings, nil
+}
+
+// cassetteReadings decodes the responses of the sensors and their history in
+// the cassette, the other responses like the batches are skipped.
+func cassetteReadings(dir string) ([]*egain.SensorReading, error) {
+	interactions, err := cassette.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	var readings []*egain.SensorReading
+	seen := map[string]bool{}
+	for _, i := range interactions {
+		if i.Method != "GET" || i.Status != 200 {
+			continue
+		}
+		p, _, _ := strings.Cut(i.URL, "?")
+		parts := strings.Split(strings.Trim(path.Clean(p), "/"), "/")
+		if len(parts) > 0 && parts[len(parts)-1] == "history" {
+			parts = parts[:len(parts)-1]
+		}
+		if len(parts) < 3 || parts[len(parts)-3] != "api" {
+			continue
+		}
+		kind, err := egain.ParseKind(parts[len(parts)-2])
+		if err != nil {
+			continue
+		}
+		id := parts[len(parts)-1]
+
+		d, err := egain.Decode(kind, []byte(i.Body))
+		if err != nil {
+			return nil, fmt.Errorf("response of %s: %w", i.URL, err)
+		}
+		for _, r := range d.Readings {
+			// unchanged readings are recorded again with every fetch
+			key := id + " " + r.Timestamp.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			r.SensorID = id
+			readings = append(readings, r)
+		}
+	}
+	return readings, nil
+}
+
+// labelReadings adds the kinds, accounts and labels of the configured sensors
+// to their readings, and their locations if the recording has none.
+func labelReadings(readings []*egain.SensorReading, sensors []egain.Sensor) {
+	byID := make(map[string]egain.Sensor, len(sensors))
+	for _, s := range sensors {
+		byID[s.SensorID] = s
+	}
+	for _, r := range readings {
+		s, ok := byID[r.SensorID]
+		if !ok {
+			continue
+		}
+		if r.Location == "" {
+			r.Location = s.Location
+		}
+		r.Account, r.Labels = s.Account, s.Labels
+		if r.Kind == "" {
+			r.Kind = s.Kind
+		}
+	}
+}
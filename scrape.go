@@ -0,0 +1,629 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+	"github.com/nimdanitro/again-scraper-go/pkg/leader"
+	"github.com/nimdanitro/again-scraper-go/pkg/pipeline"
+	"github.com/nimdanitro/again-scraper-go/pkg/processor"
+	"github.com/nimdanitro/again-scraper-go/pkg/schedule"
+	"github.com/nimdanitro/again-scraper-go/pkg/state"
+	"github.com/nimdanitro/again-scraper-go/pkg/systemd"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/contrib/bridges/otelzap"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	interval         time.Duration
+	shutdownTimeout  time.Duration
+	once             bool
+	watch            bool
+	dryRun           bool
+	jitter           time.Duration
+	adaptiveMin      time.Duration
+	adaptiveMax      time.Duration
+	metadataInterval time.Duration
+	verifySensors    bool
+	output           string
+	export           bool
+	failureBudget    int
+	cycleTimeout     time.Duration
+	cycleOverrun     string
+	cronSchedule     string
+	scheduleTimezone string
+)
+
+func newScrapeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scrape",
+		Short: "Poll the sensors and export their readings (default)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if once && !export {
+				cfg, sensors, err := loadSensors()
+				if err != nil {
+					return err
+				}
+				if code := runOnce(cmd.Context(), cfg, sensors, output); code != 0 {
+					return exitCode(code)
+				}
+				return nil
+			}
+			return runScrape(cmd.Context())
+		},
+	}
+
+	flags := cmd.Flags()
+	registerScrapeFlags(flags)
+	flags.BoolVar(&once, "once", false, "Fetch all sensors once, print the readings to stdout and exit with 0 if all sensors were fetched, 3 if some failed and 1 if all failed")
+	flags.StringVarP(&output, "output", "o", "json", "Output format of --once (json, csv, openmetrics)")
+	flags.BoolVar(&export, "export", false, "With --once, export the readings to the configured exporters and flush them instead of printing the readings, e.g. when run by cron")
+	return cmd
+}
+
+// registerScrapeFlags defines the flags of the polling loop and the exporters,
+// which are shared by the commands which scrape the sensors continuously.
+func registerScrapeFlags(flags *pflag.FlagSet) {
+	flags.DurationVarP(&interval, "interval", "i", 1*time.Minute, "Interval between two sensor readings (e.g. 30s, 5m)")
+	flags.StringVar(&cronSchedule, "schedule", "", "Cron expression of the polls of the sensors without an own interval or schedule instead of the --interval, e.g. \"*/5 7-19 * * MON-FRI\" to poll only during business hours")
+	flags.StringVar(&scheduleTimezone, "schedule-timezone", "", "Time zone of the cron expressions of the --schedule and of the sensors, e.g. Europe/Zurich, the local time zone by default")
+	flags.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "Maximum time to wait for the current fetch and the telemetry flush on shutdown")
+	flags.DurationVar(&jitter, "jitter", 0, "Delay each poll by a random duration up to the jitter, to spread out the polls of several instances")
+	flags.DurationVar(&adaptiveMax, "adaptive-max", 0, "Adapt the interval of each sensor to its update cadence, polling it at least at this interval, 0 to disable")
+	flags.DurationVar(&adaptiveMin, "adaptive-min", 0, "Minimum interval of the adaptive polling, the --interval by default")
+	flags.DurationVar(&metadataInterval, "metadata-interval", 24*time.Hour, "Interval of refreshing the metadata of the sensors like their model and firmware, 0 to disable")
+	flags.BoolVar(&dryRun, "dry-run", false, "Fetch the sensors and log the readings instead of exporting them")
+	flags.BoolVar(&watch, "watch-config", false, "Reload the sensors when the --config file changes")
+	flags.BoolVar(&verifySensors, "verify-sensors", false, "Check that the egain API knows the sensors at startup and fail if it does not")
+	flags.IntVar(&failureBudget, "failure-budget", 0, "Exit with a non-zero status once all sensors failed in this many consecutive cycles, e.g. as the credentials were revoked, so the scraper gets restarted or alerted on, 0 to disable")
+	flags.DurationVar(&cycleTimeout, "cycle-timeout", 0, "Maximum duration of fetching the sensors of a cycle, the sensors not fetched by then fail and the others are exported, 0 to fetch until the sensors are due again")
+	flags.StringVar(&cycleOverrun, "cycle-overrun", overrunQueue, "What to do with the polls which came due while a cycle was still running: queue to poll the sensors right after it, or skip to poll them at their next time")
+	registerStateFlags(flags)
+	registerLeaderFlags(flags)
+	registerShardFlags(flags)
+	registerExporterFlags(flags)
+	registerOTelFlags(flags)
+	registerAdminFlags(flags)
+}
+
+// scheduleLocation returns the location of the cron expressions.
+func scheduleLocation() (*time.Location, error) {
+	if scheduleTimezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(scheduleTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --schedule-timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// parseSchedule parses a cron expression in the --schedule-timezone.
+func parseSchedule(expr string) (*schedule.Cron, error) {
+	loc, err := scheduleLocation()
+	if err != nil {
+		return nil, err
+	}
+	return schedule.ParseCron(expr, loc)
+}
+
+// service is run alongside the polling loop, e.g. to serve an HTTP API. It
+// is started once the scraper is set up and has to return once the context is
+// done.
+type service func(ctx context.Context, logger *zap.Logger) error
+
+// registry keeps the latest reading and fetch status of each sensor, which
+// the APIs and the health endpoints read from.
+var registry = state.New()
+
+// runScrape sets up telemetry, the exporters and the client and polls the
+// sensors until the context is done, or a single time with --once --export.
+// The readings are kept in the registry next to the configured exporters.
+func runScrape(ctx context.Context, services ...service) error {
+	cfg, sensors, err := loadSensors()
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("please specify a positive polling interval with the --interval flag")
+	}
+	if len(sensors) == 0 {
+		return errNoSensors
+	}
+	if jitter < 0 || jitter >= interval {
+		return fmt.Errorf("invalid jitter %s, it has to be less than the interval %s", jitter, interval)
+	}
+	if err := validateCycle(); err != nil {
+		return err
+	}
+
+	views, err := cfg.views()
+	if err != nil {
+		return err
+	}
+
+	// Setup Otel, the telemetry is flushed on shutdown with a fresh context
+	// as ctx is already cancelled by then
+	shutdown, err := setupOTelSDK(ctx, views...)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot flush telemetry:", err)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	// Initialize logger
+	logger, err := newLogger(zapcore.AddSync(os.Stdout), zapcore.DebugLevel,
+		otelzap.NewCore("github.com/nimdanitro/again-scraper-go", otelzap.WithLoggerProvider(global.GetLoggerProvider())),
+	)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+	logger.Info("starting up", zap.String("version", version), zap.String("commit", commit), zap.String("buildDate", date))
+
+	// Initialize metrics
+	meter := otel.Meter(
+		"gitub.com/nimdanitro/again-scraper-go",
+		metric.WithInstrumentationAttributes(semconv.OTelScopeName("gitub.com/nimdanitro/again-scraper-go")),
+	)
+	otelExporter, err := exporter.NewOTel(meter,
+		exporter.WithTemperatureUnit(temperatureUnit.unit().Symbol()),
+		exporter.WithOTelLogger(logger),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot create metric instruments: %w", err)
+	}
+	if err := registerBuildInfo(meter); err != nil {
+		return fmt.Errorf("cannot create metric instruments: %w", err)
+	}
+	cycles, err := newCycleMetrics(meter)
+	if err != nil {
+		return fmt.Errorf("cannot create metric instruments: %w", err)
+	}
+
+	// all readings are fanned out to the configured exporters, a dry run only
+	// logs them and does not create the exporters at all
+	var exporters exporter.Multi
+	if dryRun {
+		names := exporterNames(cfg)
+		logger.Info("dry run, readings are not exported", zap.Strings("exporters", names))
+		exporters = exporter.Multi{exporter.NewDryRun(logger, names)}
+	} else {
+		exporters, err = newExporters(cfg, logger, meter)
+		if err != nil {
+			return fmt.Errorf("cannot create exporters: %w", err)
+		}
+		exporters = append(exporter.Multi{otelExporter}, exporters...)
+	}
+	exporters = append(exporters, registry)
+	defer exporters.Close()
+
+	// create the fetcher
+	client, err := newFetcher(logger, cfg, sensors)
+	if err != nil {
+		return fmt.Errorf("cannot create fetcher: %w", err)
+	}
+	defer closeArchive(logger)
+	fetcher, err := newRouter(logger, cfg, client, sensors)
+	if err != nil {
+		return fmt.Errorf("cannot create providers: %w", err)
+	}
+	if verifySensors {
+		logger.Info("verifying the sensors with the egain API")
+		if err := client.VerifySensors(ctx); err != nil {
+			return fmt.Errorf("cannot verify the sensors: %w", err)
+		}
+	}
+
+	// readings fetched before a restart are not exported again
+	if err := restoreState(logger, client); err != nil {
+		return err
+	}
+	saveState := stateSaver(logger, client)
+
+	// a single cycle exits once the readings are exported, the exporters and
+	// the telemetry are flushed on return. It exits with 1 if the cycle
+	// failed and 3 if only some sensors could be fetched.
+	if once {
+		resolveLocations(ctx, logger, client)
+		pipe, err := newPipeline(cfg, logger, meter, fetcher, exporters)
+		if err != nil {
+			return err
+		}
+		return exportOnce(ctx, logger, pipe, fetcher.Sensors(), saveState)
+	}
+
+	// make sure the interval can be served by the rate limit and timeout of
+	// the client
+	if err := client.ValidateInterval(interval); err != nil {
+		return fmt.Errorf("invalid polling interval %s: %w", interval, err)
+	}
+
+	// only the leader fetches the sensors, the election runs next to the
+	// services and releases the leadership on shutdown
+	elector, err := newElector(logger)
+	if err != nil {
+		return fmt.Errorf("cannot set up the leader election: %w", err)
+	}
+	if svc := adminService(logger); svc != nil {
+		services = append(services, svc)
+	}
+	if s, _ := currentShard(); s.Sharded() {
+		logger.Info("polling a shard of the sensors", zap.Stringer("shard", s), zap.Int("sensors", len(sensors)))
+	}
+	if elector != nil {
+		logger.Info("electing a leader", zap.String("election", leaderElection), zap.Duration("duration", leaderDuration))
+		services = append(services, func(ctx context.Context, logger *zap.Logger) error {
+			elector.Run(ctx)
+			return nil
+		})
+	}
+
+	// the metadata is refreshed in the background, unless it was already
+	// fetched to resolve the locations
+	first := time.Duration(0)
+	if resolveLocations(ctx, logger, client) {
+		first = metadataInterval
+	}
+	if metadataInterval > 0 {
+		services = append(services, metadataService(client, elector, first))
+	}
+
+	// start the services, they are stopped before the telemetry is flushed
+	svcCtx, stopServices := context.WithCancel(ctx)
+	errs := make(chan error, len(services))
+	for _, svc := range services {
+		go func(svc service) {
+			errs <- svc(svcCtx, logger)
+		}(svc)
+	}
+	defer func() {
+		stopServices()
+		for range services {
+			if err := <-errs; err != nil {
+				logger.Error("service failed", zap.Error(err))
+			}
+		}
+	}()
+
+	// Setup the scheduler to read each sensor at its own interval, all
+	// sensors are due for an initial read right away or after the jitter,
+	// unless they are polled at a cron schedule.
+	loc, err := scheduleLocation()
+	if err != nil {
+		return err
+	}
+	schedOpts := []schedule.Option{schedule.WithJitter(jitter, instanceSeed()), schedule.WithLocation(loc)}
+	if cronSchedule != "" {
+		c, err := schedule.ParseCron(cronSchedule, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --schedule: %w", err)
+		}
+		logger.Info("polling sensors", zap.Stringer("schedule", c), zap.Stringer("timezone", loc), zap.Duration("jitter", jitter))
+		schedOpts = append(schedOpts, schedule.WithCron(c))
+	} else {
+		logger.Info("polling sensors", zap.Duration("interval", interval), zap.Duration("jitter", jitter))
+	}
+	if adaptiveMax > 0 {
+		if adaptiveMin == 0 {
+			adaptiveMin = interval
+		}
+		logger.Info("adapting the polling interval to the sensors", zap.Duration("min", adaptiveMin), zap.Duration("max", adaptiveMax))
+		schedOpts = append(schedOpts, schedule.WithAdaptiveInterval(adaptiveMin, adaptiveMax))
+	}
+	sched, err := schedule.New(interval, sensors, schedOpts...)
+	if err != nil {
+		return fmt.Errorf("cannot create scheduler: %w", err)
+	}
+
+	// systemd is notified once the first readings are fetched, or the
+	// instance is standing by
+	ready := false
+	notifyReady := func(status string) {
+		if ready {
+			return
+		}
+		ready = true
+		if err := systemd.Notify(systemd.Ready + "\nSTATUS=" + status); err != nil {
+			logger.Warn("cannot notify systemd", zap.Error(err))
+		}
+	}
+
+	// failedCycles is the number of consecutive cycles in which all sensors
+	// failed, it is only touched by the cycles
+	failedCycles := 0
+	// the fetched readings are observed by the scheduler and logged before
+	// they are processed
+	observe := processor.Func(func(ctx context.Context, readings []*egain.SensorReading) ([]*egain.SensorReading, error) {
+		for _, data := range readings {
+			sched.Observe(data)
+			logger.Info("Fetched data",
+				zap.Float64("temperature", data.Temperature),
+				zap.Float64("humidity", data.Humidity),
+				zap.String("sensorId", data.SensorID),
+				zap.String("location", data.Location),
+				zap.Time("timestamp", data.Timestamp),
+				zap.Bool("unchanged", data.Unchanged),
+				zap.Bool("stale", data.Stale),
+			)
+		}
+		return readings, nil
+	})
+	pipe, err := newPipeline(cfg, logger, meter, fetcher, exporters, observe)
+	if err != nil {
+		return err
+	}
+
+	// the fetch of a cycle is bounded by its deadline, so a slow cycle does
+	// not pile up behind the next polls
+	readSensors := func(ctx context.Context, due []egain.Sensor, deadline time.Time) {
+		logger.Info("fetching data from egain", zap.Int("sensors", len(due)), zap.Time("deadline", deadline))
+		res, err := pipe.RunUntil(ctx, due, deadline)
+		if res.DeadlineExceeded {
+			cycles.exceeded.Add(ctx, 1)
+			logger.Warn("fetch cycle exceeded its deadline, exporting the readings fetched so far",
+				zap.Int("fetched", res.Fetched),
+				zap.Int("sensors", len(due)),
+			)
+		}
+		if res.Fetched > 0 {
+			notifyReady("scraping")
+		}
+		if res.FetchErr != nil && res.Fetched == 0 {
+			failedCycles++
+		} else {
+			failedCycles = 0
+		}
+		if res.FetchErr != nil {
+			// the readings of the other sensors are exported anyway
+			logger.Error("Failed to fetch data",
+				zap.Int("failed", len(due)-res.Fetched),
+				zap.Int("sensors", len(due)),
+				zap.Error(res.FetchErr),
+			)
+		}
+
+		// nothing was exported if the processors failed, so the state is
+		// not saved and the readings are exported again after a restart
+		var stageErr *pipeline.StageError
+		if errors.As(err, &stageErr) && stageErr.Stage == pipeline.StageProcess {
+			logger.Error("Failed to process data", zap.Error(stageErr.Err))
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to export data", zap.Error(err))
+		}
+		saveState()
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	// the cycles run with their own context, so a cycle in flight when the
+	// signal arrives can finish within the shutdown timeout
+	cycleCtx, cancelCycle := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelCycle()
+
+	// SIGHUP and changes of the config file reload the sensors, they are only
+	// handled between cycles so the cycle in flight finishes with the previous
+	// sensors
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	changed := make(chan struct{}, 1)
+	if watch && configFile != "" {
+		if err := watchConfig(ctx, logger, configFile, changed); err != nil {
+			return err
+		}
+	}
+	// changes of the discovered ConfigMaps reload the sensors like the config
+	// file
+	discovered := make(chan struct{}, 1)
+	cms, err := newConfigMaps()
+	if err != nil {
+		return err
+	}
+	if cms != nil {
+		go cms.Watch(ctx, logger, discoveredVersion, discovered)
+	}
+	// and so do the changes of the --sensors-url
+	fetched := make(chan struct{}, 1)
+	doc, err := newSensorsDocument()
+	if err != nil {
+		return err
+	}
+	if doc != nil && sensorsURLInterval > 0 {
+		go doc.Watch(ctx, logger, sensorsURLInterval, fetched)
+	}
+	// the loop pings the systemd watchdog, a cycle which takes longer than
+	// its deadline is considered hung and the pings stop, so systemd restarts
+	// the service
+	var watchdog <-chan time.Time
+	if d := systemd.WatchdogInterval(); d > 0 {
+		logger.Info("pinging the systemd watchdog", zap.Duration("interval", d))
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+	ping := func() {
+		if err := systemd.Notify(systemd.Watchdog); err != nil {
+			logger.Warn("cannot ping the systemd watchdog", zap.Error(err))
+		}
+	}
+	defer systemd.Notify(systemd.Stopping)
+
+	reload := func(reason string) {
+		logger.Info("reloading sensors", zap.String("config", configFile), zap.String("reason", reason))
+		if err := reloadSensors(logger, fetcher, client, sched); err != nil {
+			logger.Error("cannot reload sensors, keeping the previous sensors", zap.Error(err))
+			return
+		}
+		resetTimer(timer, sched)
+	}
+
+	for {
+		select {
+		case now := <-timer.C:
+			// a standby keeps the sensors due, so it fetches them right
+			// away once it takes over
+			if elector != nil && !elector.IsLeader() {
+				notifyReady("standing by")
+				timer.Reset(standbyInterval)
+				continue
+			}
+			due := sched.Due(now)
+			if len(due) == 0 {
+				// the sensors of a schedule may not be due for hours
+				next := time.Until(sched.Next())
+				if next > jitter {
+					notifyReady("waiting for the schedule")
+				}
+				timer.Reset(next)
+				continue
+			}
+
+			done := make(chan struct{})
+			fetchBy := fetchDeadline(sched, due, now)
+			go func() {
+				defer close(done)
+				readSensors(cycleCtx, due, fetchBy)
+			}()
+
+			deadline := time.Now().Add(cycleDeadline(due))
+			hung := false
+		wait:
+			for {
+				select {
+				case <-done:
+					break wait
+				case <-watchdog:
+					if time.Now().Before(deadline) {
+						ping()
+					} else if !hung {
+						hung = true
+						logger.Error("fetch cycle is hung, no longer pinging the systemd watchdog", zap.Int("sensors", len(due)))
+					}
+				case <-ctx.Done():
+					logger.Info("shutting down, waiting for the current fetch", zap.Duration("timeout", shutdownTimeout))
+					select {
+					case <-done:
+					case <-time.After(shutdownTimeout):
+						logger.Warn("current fetch did not finish in time, cancelling it")
+						cancelCycle()
+						<-done
+					}
+					logger.Info("shut down")
+					return nil
+				}
+			}
+			if failureBudget > 0 && failedCycles >= failureBudget {
+				logger.Error("all sensors failed repeatedly, exceeding the failure budget", zap.Int("cycles", failedCycles))
+				return fmt.Errorf("all sensors failed in %d consecutive cycles", failedCycles)
+			}
+			// the cycles never overlap, the polls which came due in the
+			// meantime are either queued or skipped
+			if next := sched.Next(); !next.IsZero() && !next.After(time.Now()) {
+				if cycleOverrun == overrunSkip {
+					skipped := sched.Skip(time.Now())
+					cycles.recordSkipped(ctx, skipped)
+					logger.Warn("fetch cycle ran into the next polls, skipping them", zap.Int("sensors", len(skipped)))
+				} else {
+					logger.Info("fetch cycle ran into the next polls, polling the sensors right away")
+				}
+			}
+			timer.Reset(time.Until(sched.Next()))
+		case <-watchdog:
+			ping()
+		case <-hup:
+			reload("SIGHUP")
+		case <-changed:
+			reload("config file changed")
+		case <-discovered:
+			reload("discovered configmaps changed")
+		case <-fetched:
+			reload("sensors url changed")
+		case <-ctx.Done():
+			logger.Info("shut down")
+			return nil
+		}
+	}
+}
+
+// metadataService refreshes the metadata of the sensors after the first delay
+// and then at the metadata interval. Only the leader refreshes the metadata.
+func metadataService(client *egain.Accounts, elector *leader.Elector, first time.Duration) service {
+	return func(ctx context.Context, logger *zap.Logger) error {
+		timer := time.NewTimer(first)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-timer.C:
+			}
+			if elector != nil && !elector.IsLeader() {
+				timer.Reset(standbyInterval)
+				continue
+			}
+
+			if err := client.RefreshMetadata(ctx); err != nil && ctx.Err() == nil {
+				logger.Warn("cannot refresh the metadata of the sensors", zap.Error(err))
+			}
+			timer.Reset(metadataInterval)
+		}
+	}
+}
+
+// cycleDeadline returns the time after which a fetch cycle of the sensors is
+// considered hung. Every fetch is bounded by the timeout of its sensor, the
+// deadline leaves room for the waits for the rate limiter and the throttling
+// of the API.
+func cycleDeadline(sensors []egain.Sensor) time.Duration {
+	var d time.Duration
+	for _, s := range sensors {
+		t := timeout
+		if s.Timeout > 0 {
+			t = s.Timeout
+		}
+		d += 2 * t
+	}
+	return d + time.Minute
+}
+
+// standbyInterval is the interval at which a standby checks whether it became
+// the leader.
+const standbyInterval = time.Second
+
+// instanceSeed returns the seed of the jitter, which is derived from the
+// hostname so replicas like the pods of a deployment get different delays.
+func instanceSeed() uint64 {
+	h := fnv.New64a()
+	if name, err := os.Hostname(); err == nil {
+		h.Write([]byte(name))
+	}
+	binary.Write(h, binary.LittleEndian, int64(os.Getpid()))
+	return h.Sum64()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/nimdanitro/again-scraper-go/pkg/secrets"
+	"github.com/spf13/pflag"
+)
+
+var ageIdentities []string
+
+// registerSecretsFlags defines the flags of the secrets the config file
+// refers to. They are taken from the command line and the environment only,
+// as they are needed to read the config file.
+func registerSecretsFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&ageIdentities, "age-identity", nil, "Comma-separated paths of age identity files of age-keygen decrypting the --config file encrypted with SOPS")
+	envFlags["age-identity"] = "AGE_IDENTITY_FILE"
+}
+
+// newSecretResolver creates the resolver of the references to secrets in the
+// config file, e.g. env:MQTT_PASSWORD, see the secrets package.
+func newSecretResolver() (*secrets.Resolver, error) {
+	var opts []secrets.Option
+	for _, path := range ageIdentities {
+		opts = append(opts, secrets.WithIdentityFile(path))
+	}
+	return secrets.New(opts...)
+}
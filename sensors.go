@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/providers"
+	"github.com/spf13/cobra"
+)
+
+func newSensorsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sensors",
+		Short: "Inspect the configured sensors",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the configured sensors",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, sensors, err := loadSensors()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tLOCATION\tKIND\tPROVIDER\tINTERVAL")
+			for _, s := range sensors {
+				interval := "default"
+				if s.Interval > 0 {
+					interval = s.Interval.String()
+				}
+				if s.Schedule != "" {
+					interval = s.Schedule
+				}
+				provider := s.Provider
+				if provider == providers.Default {
+					provider = egainProvider
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.SensorID, s.Location, s.Kind, provider, interval)
+			}
+			return w.Flush()
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/remote"
+	"github.com/spf13/pflag"
+)
+
+var (
+	sensorsURL         string
+	sensorsURLInterval time.Duration
+	sensorsURLHeaders  []string
+
+	// sensorsDocument is the document of --sensors-url, it is kept across
+	// reloads so its ETag saves the transfer of an unchanged document
+	sensorsDocument *remote.Document
+)
+
+// registerSensorsURLFlags defines the flags of the sensors served by a
+// central configuration service.
+func registerSensorsURLFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&sensorsURL, "sensors-url", "", "HTTP(S) URL of a JSON or YAML list of sensor objects like the sensors of the --config file, e.g. of a central configuration service, fetched at startup and polled for changes")
+	flags.DurationVar(&sensorsURLInterval, "sensors-url-interval", 5*time.Minute, "Interval of polling the --sensors-url for changes, which reload the sensors, 0 to fetch it only at startup and on SIGHUP")
+	flags.StringArrayVar(&sensorsURLHeaders, "sensors-url-header", nil, "Header of the requests to the --sensors-url, e.g. \"Authorization: Bearer <token>\", can be repeated")
+	envFlags["sensors-url"] = "SENSORS_URL"
+	envFlags["sensors-url-interval"] = "SENSORS_URL_INTERVAL"
+	envFlags["sensors-url-header"] = "SENSORS_URL_HEADER"
+}
+
+// newSensorsDocument returns the document of --sensors-url, nil if it is not
+// set.
+func newSensorsDocument() (*remote.Document, error) {
+	if sensorsURL == "" {
+		return nil, nil
+	}
+	if sensorsDocument != nil {
+		return sensorsDocument, nil
+	}
+	if sensorsURLInterval < 0 {
+		return nil, fmt.Errorf("invalid --sensors-url-interval %s", sensorsURLInterval)
+	}
+
+	opts := []remote.Option{remote.WithTimeout(timeout)}
+	for _, h := range sensorsURLHeaders {
+		k, v, ok := strings.Cut(h, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid --sensors-url-header %q, expected name: value", h)
+		}
+		opts = append(opts, remote.WithHeader(strings.TrimSpace(k), strings.TrimSpace(v)))
+	}
+	doc, err := remote.New(sensorsURL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --sensors-url: %w", err)
+	}
+	sensorsDocument = doc
+	return doc, nil
+}
+
+// fetchSensors adds the sensor objects of --sensors-url to the sensors of the
+// configuration.
+func (c *config) fetchSensors() error {
+	doc, err := newSensorsDocument()
+	if doc == nil || err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	data, _, err := doc.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot fetch sensors: %w", err)
+	}
+	sensors, err := parseSensors(doc.URL(), data)
+	if err != nil {
+		return err
+	}
+	c.Sensors = append(c.Sensors, sensors...)
+	return nil
+}
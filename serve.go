@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/api/egainv1"
+	"github.com/nimdanitro/again-scraper-go/pkg/dashboard"
+	"github.com/nimdanitro/again-scraper-go/pkg/grpcapi"
+	"github.com/nimdanitro/again-scraper-go/pkg/restapi"
+	"github.com/nimdanitro/again-scraper-go/pkg/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+var (
+	listenAddr     string
+	grpcListenAddr string
+	dashboardOn    bool
+	dashboardTitle string
+)
+
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Poll the sensors and serve an HTTP API next to exporting the readings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /healthz", healthz)
+			restapi.Register(mux, registry)
+
+			// the history is queried through a handle of its own, the
+			// readings are stored by the store exporter
+			if storePath != "" {
+				history, err := store.Open(storePath)
+				if err != nil {
+					return err
+				}
+				defer history.Close()
+				restapi.RegisterHistory(mux, history)
+				restapi.RegisterGrafana(mux, history)
+			}
+			if dashboardOn {
+				opts := []dashboard.Option{
+					dashboard.WithTitle(dashboardTitle),
+					dashboard.WithTemperatureUnit(temperatureUnit.unit().Symbol()),
+				}
+				if storePath != "" {
+					opts = append(opts, dashboard.WithHistory())
+				}
+				dashboard.Register(mux, opts...)
+			}
+			services := []service{httpService(listenAddr, mux)}
+
+			if grpcListenAddr != "" {
+				services = append(services, grpcService(grpcListenAddr, grpcapi.NewServer(registry)))
+			}
+			return runScrape(cmd.Context(), services...)
+		},
+	}
+
+	flags := cmd.Flags()
+	registerScrapeFlags(flags)
+	registerListenFlag(flags)
+	flags.StringVar(&grpcListenAddr, "grpc-listen", "", "Address the gRPC API listens on, disabled if empty (e.g. :9090)")
+	envFlags["grpc-listen"] = "GRPC_LISTEN_ADDR"
+	flags.BoolVar(&dashboardOn, "dashboard", true, "Serve a web dashboard of the readings at /, with sparklines of the last day if the readings are stored with --store-path")
+	flags.StringVar(&dashboardTitle, "dashboard-title", "Sensors", "Title of the web dashboard, e.g. the name of the building")
+	return cmd
+}
+
+// registerListenFlag defines the flag of the address of the HTTP API.
+func registerListenFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&listenAddr, "listen", ":8080", "Address the HTTP API listens on")
+	envFlags["listen"] = "LISTEN_ADDR"
+}
+
+// httpService serves the handler on the address until the context is done.
+func httpService(addr string, h http.Handler) service {
+	return func(ctx context.Context, logger *zap.Logger) error {
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           h,
+			ReadHeaderTimeout: 10 * time.Second,
+			BaseContext:       func(net.Listener) context.Context { return ctx },
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			logger.Info("serving HTTP", zap.String("addr", addr))
+			errs <- srv.ListenAndServe()
+		}()
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// grpcService serves the readings API over gRPC on the address until the
+// context is done.
+func grpcService(addr string, api egainv1.ReadingsServiceServer) service {
+	return func(ctx context.Context, logger *zap.Logger) error {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+
+		srv := grpc.NewServer()
+		egainv1.RegisterReadingsServiceServer(srv, api)
+
+		errs := make(chan error, 1)
+		go func() {
+			logger.Info("serving gRPC", zap.String("addr", addr))
+			errs <- srv.Serve(lis)
+		}()
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+		}
+
+		// streams only end with their clients, so they are cut off after the
+		// shutdown timeout
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			srv.Stop()
+		}
+		return <-errs
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// The sources of the values of the flags, in the order of their precedence.
+const (
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceFile    = "file"
+	sourceDefault = "default"
+)
+
+// flagSources are the sources of the flags which were not left at their
+// defaults.
+var flagSources = map[string]string{}
+
+// secretFlags are the flags whose values are not printed.
+var secretFlags = map[string]bool{
+	"header":         true,
+	"otel-headers":   true,
+	"influx-token":   true,
+	"mqtt-password":  true,
+	"kafka-password": true,
+	"nats-password":  true,
+	"webhook-secret": true,
+	// the headers carry the credentials, e.g. Authorization: Bearer <token>
+	"webhook-header":     true,
+	"sensors-url-header": true,
+	// the instrumentation key of Application Insights
+	"azure-connection-string": true,
+}
+
+// applySettings sets the flags of the command from the command line, the
+// environment and the flags section of the --config file, in this order of
+// precedence, the flags which are set by none of them keep their defaults.
+func applySettings(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	flags.Visit(func(f *pflag.Flag) {
+		flagSources[f.Name] = sourceFlag
+	})
+	if err := applyEnv(flags); err != nil {
+		return err
+	}
+	flags.Visit(func(f *pflag.Flag) {
+		if flagSources[f.Name] == "" {
+			flagSources[f.Name] = sourceEnv
+		}
+	})
+
+	if configFile == "" {
+		return nil
+	}
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	startupConfig.Lock()
+	startupConfig.cfg = cfg
+	startupConfig.Unlock()
+	return applyFile(cmd, cfg.Flags)
+}
+
+// startupConfig is the config file loaded for the settings, which the first
+// loadSensors takes, so the file is read and its secrets are resolved once
+// per start. A reload loads the file again.
+var startupConfig struct {
+	sync.Mutex
+	cfg *config
+}
+
+// takeStartupConfig returns the config file loaded for the settings once, nil
+// if it is taken or was not loaded.
+func takeStartupConfig() *config {
+	startupConfig.Lock()
+	defer startupConfig.Unlock()
+	cfg := startupConfig.cfg
+	startupConfig.cfg = nil
+	return cfg
+}
+
+// applyFile sets the flags which are not set yet from the settings of the
+// config file. Settings of flags of other commands are ignored, so the
+// commands can share the file, but unknown settings are rejected.
+func applyFile(cmd *cobra.Command, settings map[string]any) error {
+	flags := cmd.Flags()
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if name == "config" || !knownFlag(cmd.Root(), name) {
+			return fmt.Errorf("unknown flag %q in the flags of config %s", name, configFile)
+		}
+		f := flags.Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+
+		// lists and maps set the flag element by element, which appends
+		// to slice and map flags
+		var values []string
+		switch v := settings[name].(type) {
+		case nil:
+			continue
+		case []any:
+			for _, e := range v {
+				values = append(values, fmt.Sprint(e))
+			}
+		case map[string]any:
+			for _, k := range slices.Sorted(maps.Keys(v)) {
+				values = append(values, k+"="+fmt.Sprint(v[k]))
+			}
+		default:
+			values = append(values, fmt.Sprint(v))
+		}
+		for _, v := range values {
+			if err := flags.Set(name, v); err != nil {
+				return fmt.Errorf("invalid value %q for %s in config %s: %w", v, name, configFile, err)
+			}
+		}
+		flagSources[name] = sourceFile
+	}
+	return nil
+}
+
+// knownFlag returns whether any command defines the flag.
+func knownFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, c := range cmd.Commands() {
+		if knownFlag(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// printSettings writes the effective value and the source of each flag of
+// the command, with the secrets and the passwords of URLs redacted.
+func printSettings(w io.Writer, cmd *cobra.Command) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FLAG\tVALUE\tSOURCE")
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+		source := flagSources[f.Name]
+		if source == "" {
+			source = sourceDefault
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name, redact(f), source)
+	})
+	return tw.Flush()
+}
+
+// redact returns the value of the flag for printing.
+func redact(f *pflag.Flag) string {
+	v := f.Value.String()
+	if secretFlags[f.Name] && v != f.DefValue {
+		return "REDACTED"
+	}
+	if u, err := url.Parse(v); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return v
+}
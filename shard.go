@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/shard"
+	"github.com/spf13/pflag"
+)
+
+var shardSpec string
+
+// registerShardFlags defines the flags of the sharding of the sensors between
+// replicas.
+func registerShardFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&shardSpec, "shard", "", "Only poll the share of index/count of the sensors, split between the replicas by a consistent hash of their IDs, e.g. 0/3, the index auto takes the ordinal of the pod of a StatefulSet from the hostname, e.g. auto/3, disabled by default. The --rate-limit applies to each replica. The groups of the --config file cannot be used with --shard, as the aggregates of a group need all of its sensors")
+	envFlags["shard"] = "SHARD"
+}
+
+// currentShard returns the shard of --shard, the zero shard if the sensors
+// are not sharded.
+func currentShard() (shard.Shard, error) {
+	if shardSpec == "" {
+		return shard.Shard{}, nil
+	}
+	// the pod name is the hostname
+	hostname, _ := os.Hostname()
+	s, err := shard.Parse(shardSpec, hostname)
+	if err != nil {
+		return shard.Shard{}, fmt.Errorf("invalid --shard: %w", err)
+	}
+	return s, nil
+}
+
+// shardSensors keeps the sensors of the --shard, if any. It fails if the shard
+// has no sensors, so a reload keeps the previous sensors.
+func shardSensors(sensors []egain.Sensor) ([]egain.Sensor, error) {
+	s, err := currentShard()
+	if err != nil || !s.Sharded() {
+		return sensors, err
+	}
+	sharded := []egain.Sensor{}
+	for _, sensor := range sensors {
+		if s.Owns(sensor.SensorID) {
+			sharded = append(sharded, sensor)
+		}
+	}
+	if len(sensors) > 0 && len(sharded) == 0 {
+		return nil, fmt.Errorf("no sensors in the shard %s of %d sensors, please lower the shard count", s, len(sensors))
+	}
+	return sharded, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// stateFile is the path of the file the last readings of the sensors are
+// persisted to across restarts, disabled if empty.
+var stateFile string
+
+// registerStateFlags defines the flags of the state file.
+func registerStateFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&stateFile, "state-file", "", "Path of a file to persist the timestamps of the last readings of the sensors to, so readings fetched before a restart are not exported again, disabled if empty")
+	envFlags["state-file"] = "STATE_FILE"
+}
+
+// scraperState is the content of the state file.
+type scraperState struct {
+	// LastReadings are the timestamps of the last readings by sensor ID.
+	LastReadings map[string]time.Time `json:"lastReadings"`
+}
+
+// restoreState restores the last readings of the state file, if any, into
+// the client. A missing file is not an error, it is created once the first
+// readings were fetched.
+func restoreState(logger *zap.Logger, client *egain.Accounts) error {
+	if stateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read state file: %w", err)
+	}
+
+	var st scraperState
+	if err := json.Unmarshal(data, &st); err != nil {
+		// a broken state only costs duplicate exports, it is overwritten
+		logger.Warn("ignoring invalid state file", zap.String("path", stateFile), zap.Error(err))
+		return nil
+	}
+	client.RestoreLastReadings(st.LastReadings)
+	logger.Info("restored the last readings", zap.String("path", stateFile), zap.Int("sensors", len(st.LastReadings)))
+	return nil
+}
+
+// stateSaver returns a function which writes the last readings of the client
+// to the state file if they changed since the last write, it does nothing if
+// the state file is disabled. The file is replaced atomically, so a crash does
+// not leave a partial file behind.
+func stateSaver(logger *zap.Logger, client *egain.Accounts) func() {
+	if stateFile == "" {
+		return func() {}
+	}
+	var saved map[string]time.Time
+	return func() {
+		last := client.LastReadings()
+		if maps.EqualFunc(last, saved, time.Time.Equal) {
+			return
+		}
+		data, err := json.Marshal(scraperState{LastReadings: last})
+		if err != nil {
+			logger.Warn("cannot encode state", zap.Error(err))
+			return
+		}
+		tmp := stateFile + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			logger.Warn("cannot write state file", zap.String("path", stateFile), zap.Error(err))
+			return
+		}
+		if err := os.Rename(tmp, stateFile); err != nil {
+			os.Remove(tmp)
+			logger.Warn("cannot write state file", zap.String("path", stateFile), zap.Error(err))
+			return
+		}
+		saved = last
+	}
+}
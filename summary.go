@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/processor"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// reportFormats are the formats of the daily reports.
+var reportFormats = []string{"json", "csv"}
+
+// options returns the options of the daily summaries of the config.
+func (c *summaryConfig) options() ([]processor.SummaryOption, error) {
+	var opts []processor.SummaryOption
+	if c.TimeZone != "" {
+		loc, err := time.LoadLocation(c.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone: %w", err)
+		}
+		opts = append(opts, processor.WithSummaryLocation(loc))
+	}
+	if c.ReportDir != "" {
+		format := c.ReportFormat
+		if format == "" {
+			format = "json"
+		}
+		if !slices.Contains(reportFormats, format) {
+			return nil, fmt.Errorf("unknown report format %q, expected one of %v", format, reportFormats)
+		}
+		opts = append(opts, processor.WithSummaryReporter(fileReporter{dir: c.ReportDir, format: format}))
+	} else if c.ReportFormat != "" {
+		return nil, fmt.Errorf("report format %s configured without a report directory", c.ReportFormat)
+	}
+	if c.Webhook != "" {
+		opts = append(opts, processor.WithSummaryReporter(webhookReporter{
+			url:    c.Webhook,
+			client: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 30 * time.Second},
+		}))
+	}
+	return opts, nil
+}
+
+// fileReporter writes the daily summaries to a file per day in the directory.
+type fileReporter struct {
+	dir    string
+	format string
+}
+
+func (f fileReporter) Report(ctx context.Context, summaries []processor.DailySummary) error {
+	if len(summaries) == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	switch f.format {
+	case "csv":
+		if err := writeSummariesCSV(&b, summaries); err != nil {
+			return err
+		}
+	default:
+		enc := json.NewEncoder(&b)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(summaries); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	// the report is written to a temporary file first, so readers never see
+	// a partial report
+	path := filepath.Join(f.dir, "summary-"+summaries[0].Date+"."+f.format)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeSummariesCSV writes the summaries as CSV with a header.
+func writeSummariesCSV(w io.Writer, summaries []processor.DailySummary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"date", "timeZone", "sensorId", "location", "readings",
+		"temperatureMin", "temperatureMax", "temperatureMean",
+		"humidityMin", "humidityMax", "humidityMean",
+	})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, s := range summaries {
+		cw.Write([]string{
+			s.Date, s.TimeZone, s.SensorID, s.Location, strconv.Itoa(s.Readings),
+			f(s.Temperature.Min), f(s.Temperature.Max), f(s.Temperature.Mean),
+			f(s.Humidity.Min), f(s.Humidity.Max), f(s.Humidity.Mean),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// webhookReporter posts the daily summaries as JSON to a URL.
+type webhookReporter struct {
+	url    string
+	client *http.Client
+}
+
+func (w webhookReporter) Report(ctx context.Context, summaries []processor.DailySummary) error {
+	if len(summaries) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(struct {
+		Date    string                   `json:"date"`
+		Sensors []processor.DailySummary `json:"sensors"`
+	}{summaries[0].Date, summaries})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot post the daily summaries: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("cannot post the daily summaries: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/spf13/pflag"
+)
+
+var (
+	tlsCA                 string
+	tlsCert               string
+	tlsKey                string
+	tlsMinVersion         string
+	tlsInsecureSkipVerify bool
+)
+
+// tlsVersions maps the values of --tls-min-version to the TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// registerTLSFlags defines the flags of the TLS connections to the egain API.
+func registerTLSFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&tlsCA, "tls-ca", "", "Path of a PEM bundle of the CAs trusted for the egain API instead of the system CAs, e.g. of a TLS-intercepting gateway")
+	flags.StringVar(&tlsCert, "tls-cert", "", "Path of the PEM client certificate presented to the egain API (mTLS)")
+	flags.StringVar(&tlsKey, "tls-key", "", "Path of the PEM private key of the --tls-cert")
+	flags.StringVar(&tlsMinVersion, "tls-min-version", "1.2", "Minimum TLS version of the connections to the egain API (1.0, 1.1, 1.2, 1.3)")
+	flags.BoolVar(&tlsInsecureSkipVerify, "tls-insecure-skip-verify", false, "Do not verify the certificate of the egain API, INSECURE and for debugging only")
+	envFlags["tls-ca"] = "EGAIN_TLS_CA"
+	envFlags["tls-cert"] = "EGAIN_TLS_CERT"
+	envFlags["tls-key"] = "EGAIN_TLS_KEY"
+	envFlags["tls-min-version"] = "EGAIN_TLS_MIN_VERSION"
+	envFlags["tls-insecure-skip-verify"] = "EGAIN_TLS_INSECURE_SKIP_VERIFY"
+}
+
+// tlsOption returns the option of the egain client setting the TLS
+// configuration of the flags, nil if the defaults are kept.
+func tlsOption() egain.Option {
+	if tlsCA == "" && tlsCert == "" && tlsKey == "" && tlsMinVersion == "1.2" && !tlsInsecureSkipVerify {
+		return nil
+	}
+	return func(c *egain.Client) error {
+		version, ok := tlsVersions[tlsMinVersion]
+		if !ok {
+			return fmt.Errorf("invalid TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", tlsMinVersion)
+		}
+		cfg := &tls.Config{
+			MinVersion:         version,
+			InsecureSkipVerify: tlsInsecureSkipVerify,
+		}
+		if tlsCA != "" {
+			pool, err := loadCertPool(tlsCA)
+			if err != nil {
+				return err
+			}
+			cfg.RootCAs = pool
+		}
+		if tlsCert != "" || tlsKey != "" {
+			if tlsCert == "" || tlsKey == "" {
+				return fmt.Errorf("please specify both --tls-cert and --tls-key")
+			}
+			cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+			if err != nil {
+				return fmt.Errorf("cannot load client certificate: %w", err)
+			}
+			cfg.Certificates = []tls.Certificate{cert}
+		}
+		return egain.WithTLSConfig(cfg)(c)
+	}
+}
+
+// loadCertPool reads the PEM certificates of the file into a pool.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+var (
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	tcpKeepAlive        time.Duration
+	disableHTTP2        bool
+	dnsCache            bool
+	dnsMinTTL           time.Duration
+	dnsMaxTTL           time.Duration
+	dnsNegativeTTL      time.Duration
+
+	// resolver is the DNS cache shared by the clients of all accounts
+	resolver *egain.CachingResolver
+)
+
+// registerTransportFlags defines the flags of the connections to the egain
+// API.
+func registerTransportFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&maxIdleConnsPerHost, "max-idle-conns-per-host", 0, "Number of idle connections kept to the egain API, e.g. the number of sensors fetched concurrently, 0 for the Go default of 2")
+	flags.DurationVar(&idleConnTimeout, "idle-conn-timeout", 0, "Time after which idle connections to the egain API are closed, 0 for the Go default of 90s")
+	flags.DurationVar(&tcpKeepAlive, "tcp-keepalive", 0, "Interval of the TCP keep-alive probes of the connections to the egain API, negative to disable them, 0 for the Go default of 30s")
+	flags.BoolVar(&disableHTTP2, "disable-http2", false, "Only use HTTP/1.1 for the egain API, e.g. for proxies with a broken HTTP/2 support")
+	envFlags["max-idle-conns-per-host"] = "EGAIN_MAX_IDLE_CONNS_PER_HOST"
+	envFlags["idle-conn-timeout"] = "EGAIN_IDLE_CONN_TIMEOUT"
+	envFlags["tcp-keepalive"] = "EGAIN_TCP_KEEPALIVE"
+	flags.BoolVar(&dnsCache, "dns-cache", false, "Cache the addresses of the egain API for the TTL of their DNS records, e.g. on a slow or flaky DNS")
+	flags.DurationVar(&dnsMinTTL, "dns-cache-min-ttl", egain.DefaultDNSMinTTL, "Minimum time the addresses of the egain API are cached with --dns-cache, whatever the TTL of their records")
+	flags.DurationVar(&dnsMaxTTL, "dns-cache-max-ttl", egain.DefaultDNSMaxTTL, "Maximum time the addresses of the egain API are cached with --dns-cache, also the time of the addresses of the hosts file")
+	flags.DurationVar(&dnsNegativeTTL, "dns-negative-ttl", 0, "Time a host which does not exist is cached with --dns-cache, 0 to resolve it again on every connection")
+	envFlags["disable-http2"] = "EGAIN_DISABLE_HTTP2"
+	envFlags["dns-cache"] = "EGAIN_DNS_CACHE"
+	envFlags["dns-cache-min-ttl"] = "EGAIN_DNS_CACHE_MIN_TTL"
+	envFlags["dns-cache-max-ttl"] = "EGAIN_DNS_CACHE_MAX_TTL"
+	envFlags["dns-negative-ttl"] = "EGAIN_DNS_NEGATIVE_TTL"
+}
+
+// transportOption returns the option of the egain client tuning its
+// connections, nil if the defaults are kept.
+func transportOption(logger *zap.Logger) egain.Option {
+	pool := egain.ConnectionPool{
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+		KeepAlive:           tcpKeepAlive,
+		DisableHTTP2:        disableHTTP2,
+	}
+	if dnsCache {
+		if resolver == nil {
+			r, err := egain.NewCachingResolver(
+				egain.WithTTLBounds(dnsMinTTL, dnsMaxTTL),
+				egain.WithNegativeTTL(dnsNegativeTTL),
+				egain.WithResolverLogger(logger),
+			)
+			if err != nil {
+				return func(c *egain.Client) error { return err }
+			}
+			resolver = r
+		}
+		pool.Resolver = resolver
+	}
+	if pool == (egain.ConnectionPool{}) {
+		return nil
+	}
+	return egain.WithConnectionPool(pool)
+}
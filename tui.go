@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nimdanitro/again-scraper-go/pkg/egain"
+	"github.com/nimdanitro/again-scraper-go/pkg/exporter"
+	"github.com/nimdanitro/again-scraper-go/pkg/state"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var tuiLogFile string
+
+func newTUICmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tui",
+		Short: "Show a live table of the sensors in the terminal",
+		Long: `Show a live table of the sensors in the terminal, e.g. when commissioning
+sensors on-site. The sensors are fetched every --interval and the table shows
+their location, temperature, humidity, the age of their last reading and the
+status of their last fetch. The readings are not exported.
+
+The logs would garble the table, they are written to the --log-file if given
+and discarded otherwise.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTUI(cmd.Context(), os.Stdout)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.DurationVarP(&interval, "interval", "i", 1*time.Minute, "Interval between two fetches of the sensors (e.g. 30s, 5m)")
+	flags.StringVar(&tuiLogFile, "log-file", "", "File the logs are appended to, the logs are discarded by default")
+	return cmd
+}
+
+func runTUI(ctx context.Context, w io.Writer) error {
+	cfg, sensors, err := loadSensors()
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("please specify a positive polling interval with the --interval flag")
+	}
+	if len(sensors) == 0 {
+		return errNoSensors
+	}
+
+	var sink zapcore.WriteSyncer = zapcore.AddSync(io.Discard)
+	if tuiLogFile != "" {
+		f, err := os.OpenFile(tuiLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("cannot open log file: %w", err)
+		}
+		defer f.Close()
+		sink = f
+	}
+	logger, err := newLogger(sink, zapcore.InfoLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	client, err := newFetcher(logger, cfg, sensors)
+	if err != nil {
+		return fmt.Errorf("cannot create fetcher: %w", err)
+	}
+	fetcher, err := newRouter(logger, cfg, client, sensors)
+	if err != nil {
+		return fmt.Errorf("cannot create providers: %w", err)
+	}
+	if err := client.ValidateInterval(interval); err != nil {
+		return fmt.Errorf("invalid polling interval %s: %w", interval, err)
+	}
+	table := newSensorTable(fetcher.Sensors(), registry, temperatureUnit.unit().Symbol())
+	pipe, err := newPipeline(cfg, logger, noop.Meter{}, fetcher, exporter.Multi{registry})
+	if err != nil {
+		return err
+	}
+
+	resolveLocations(ctx, logger, client)
+
+	// the table is redrawn every second, so the age of the readings is
+	// current between the cycles
+	cycle := time.NewTimer(0)
+	defer cycle.Stop()
+	redraw := time.NewTicker(time.Second)
+	defer redraw.Stop()
+	// the cursor is hidden while drawing and shown again on exit
+	fmt.Fprint(w, "\x1b[?25l")
+	defer fmt.Fprint(w, "\x1b[?25h\n")
+
+	// done is closed once the cycle in flight finished, the next cycle is
+	// started at the interval after the start of the previous one
+	var (
+		done chan struct{}
+		next time.Time
+	)
+	for {
+		table.draw(w, time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-redraw.C:
+		case <-cycle.C:
+			next = time.Now().Add(interval)
+			table.startCycle(next)
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				if _, err := pipe.Run(ctx, fetcher.Sensors()); err != nil {
+					logger.Error("cycle failed", zap.Error(err))
+				}
+				table.endCycle()
+			}()
+		case <-done:
+			done = nil
+			cycle.Reset(time.Until(next))
+		}
+	}
+}
+
+// sensorTable draws the latest reading and fetch status of each sensor in
+// the registry as a table.
+type sensorTable struct {
+	unit     string
+	sensors  []egain.Sensor
+	registry *state.Registry
+
+	mu       sync.Mutex
+	fetching bool
+	next     time.Time
+}
+
+func newSensorTable(sensors []egain.Sensor, registry *state.Registry, unit string) *sensorTable {
+	return &sensorTable{unit: unit, sensors: sensors, registry: registry}
+}
+
+func (d *sensorTable) startCycle(next time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fetching = true
+	d.next = next
+}
+
+func (d *sensorTable) endCycle() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fetching = false
+}
+
+// draw clears the terminal and draws the table of the sensors.
+func (d *sensorTable) draw(w io.Writer, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	status := "next fetch in " + now.Sub(d.next).Abs().Truncate(time.Second).String()
+	if d.fetching {
+		status = "fetching"
+	}
+	fmt.Fprintf(&b, "again-scraper-go %s  %d sensors  %s  (ctrl-c to quit)\n\n", now.Format(time.TimeOnly), len(d.sensors), status)
+
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLOCATION\tTEMPERATURE\tHUMIDITY\tAGE\tSTATUS")
+	snap := d.registry.Snapshot()
+	for _, sensor := range d.sensors {
+		st, _ := snap.Get(sensor.SensorID)
+		location := sensor.Location
+		temperature, humidity, age := "-", "-", "-"
+		if r := st.Reading; r != nil {
+			if r.Location != "" {
+				location = r.Location
+			}
+			if r.Heating != nil {
+				temperature = fmt.Sprintf("%.1f %s flow", r.Heating.FlowTemperature, d.unit)
+			} else {
+				temperature = fmt.Sprintf("%.1f %s", r.Temperature, d.unit)
+				humidity = fmt.Sprintf("%.0f %%", r.Humidity)
+			}
+			age = formatAge(now.Sub(r.Timestamp))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", sensor.SensorID, location, temperature, humidity, age, tableStatus(st))
+	}
+	tw.Flush()
+	io.WriteString(w, b.String())
+}
+
+// tableStatus returns the status of the last fetch of the sensor.
+func tableStatus(st state.Status) string {
+	switch r := st.Reading; {
+	case st.Err != nil:
+		// the table stays on one line per sensor
+		msg := strings.ReplaceAll(st.Err.Error(), "\n", " ")
+		if len(msg) > 60 {
+			msg = msg[:57] + "..."
+		}
+		return "error: " + msg
+	case r == nil:
+		return "pending"
+	case r.Stale:
+		return "stale"
+	case r.Unchanged:
+		return "unchanged"
+	}
+	return "ok"
+}
+
+// formatAge formats the age of a reading, e.g. 42s, 5m12s or 3h4m.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "0s"
+	case d < time.Hour:
+		return d.Truncate(time.Second).String()
+	}
+	return strings.TrimSuffix(d.Truncate(time.Minute).String(), "0s")
+}
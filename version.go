@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println(versionString())
+		},
+	}
+}
+
+// versionString describes the build of the scraper.
+func versionString() string {
+	return fmt.Sprintf("again-scraper %s (commit %s, built %s, %s %s/%s)", version, commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// registerBuildInfo exports the build of the scraper as the attributes of the
+// again_scraper.build_info metric, e.g. to track the versions of a fleet.
+func registerBuildInfo(meter metric.Meter) error {
+	attrs := metric.WithAttributes(
+		attribute.String("version", version),
+		attribute.String("commit", commit),
+		attribute.String("build_date", date),
+		attribute.String("go_version", runtime.Version()),
+		attribute.String("platform", runtime.GOOS+"/"+runtime.GOARCH),
+	)
+	_, err := meter.Int64ObservableGauge("again_scraper.build_info",
+		metric.WithDescription("The build of the scraper as attributes, the value is always 1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(1, attrs)
+			return nil
+		}),
+	)
+	return err
+}
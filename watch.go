@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// watchConfig notifies changed whenever the config file changes until the
+// context is done. The directory of the file is watched instead of the file
+// itself, so replacing the file and the symlink swap of Kubernetes ConfigMaps,
+// where the ..data link in the directory is replaced, are detected as well.
+func watchConfig(ctx context.Context, logger *zap.Logger, path string, changed chan<- struct{}) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot watch config file: %w", err)
+	}
+
+	path = filepath.Clean(path)
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		w.Close()
+		return fmt.Errorf("cannot watch config file %s: %w", path, err)
+	}
+
+	go func() {
+		defer w.Close()
+
+		resolved, _ := filepath.EvalSymlinks(path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("cannot watch config file", zap.String("config", path), zap.Error(err))
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+
+				// the file changed if it was written or replaced, or if the
+				// symlink now points to a different file
+				current, _ := filepath.EvalSymlinks(path)
+				modified := filepath.Clean(ev.Name) == path && ev.Op&(fsnotify.Write|fsnotify.Create) != 0
+				if !modified && current == resolved {
+					continue
+				}
+				resolved = current
+				if current == "" {
+					// removed, wait for it to be created again
+					continue
+				}
+
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return nil
+}